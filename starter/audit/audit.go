@@ -0,0 +1,51 @@
+// Package audit appends entries to the audit log inside the transaction of
+// the write they describe, whether it came through the API or a background job
+package audit
+
+import (
+	"cms-backend/models"
+	"encoding/json"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// Actor is who made a write and from where. The zero Actor stands for the
+// system itself, such as the trash retention job.
+type Actor struct {
+	ID   *uint
+	Name string
+	IP   string
+}
+
+// Snapshot captures v as JSON for the before or after side of an entry.
+// Fields tagged json:"-", such as password hashes and webhook secrets, are
+// left out. Take it before the write for the before side, since the model is
+// changed in place.
+func Snapshot(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to snapshot %T for the audit log: %v", v, err)
+		return nil
+	}
+	return data
+}
+
+// Record appends an entry for a write made by actor. Call it inside the
+// write's transaction so the change and its entry commit together. A
+// resourceID of 0 records an action on no particular resource.
+func Record(tx *gorm.DB, actor Actor, resourceType string, resourceID uint, action string, before, after json.RawMessage) error {
+	entry := models.AuditEntry{
+		ActorID:      actor.ID,
+		ActorName:    actor.Name,
+		IP:           actor.IP,
+		ResourceType: resourceType,
+		Action:       action,
+		Before:       before,
+		After:        after,
+	}
+	if resourceID != 0 {
+		entry.ResourceID = &resourceID
+	}
+	return tx.Create(&entry).Error
+}
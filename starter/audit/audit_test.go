@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	user := models.User{ID: 3, Username: "ana", PasswordHash: "$2a$10$secret"}
+	snapshot := string(Snapshot(&user))
+	assert.Contains(t, snapshot, `"username":"ana"`)
+	assert.NotContains(t, snapshot, "secret", "password hashes never reach the log")
+
+	assert.Nil(t, Snapshot(func() {}), "values that cannot be encoded give no snapshot")
+}
+
+func TestRecord(t *testing.T) {
+	t.Run("Actor", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		actorID := uint(1)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "audit_entries"`).
+			WithArgs(actorID, "admin", "10.0.0.1", "tag", 4, models.AuditActionDelete, `{"id":4}`, nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		actor := Actor{ID: &actorID, Name: "admin", IP: "10.0.0.1"}
+		require.NoError(t, Record(db, actor, "tag", 4, models.AuditActionDelete, []byte(`{"id":4}`), nil))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("System", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "audit_entries"`).
+			WithArgs(nil, "", "", models.AuditResourceCache, nil, models.AuditActionClear, nil, nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		require.NoError(t, Record(db, Actor{}, models.AuditResourceCache, 0, models.AuditActionClear, nil, nil))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
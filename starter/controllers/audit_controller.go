@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"cms-backend/audit"
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// auditCSVBatchSize is how many entries a CSV export reads at a time
+const auditCSVBatchSize = 500
+
+// auditSnapshot captures v as JSON for the before or after side of an audit
+// entry; see audit.Snapshot
+func auditSnapshot(v interface{}) json.RawMessage {
+	return audit.Snapshot(v)
+}
+
+// recordAudit appends an audit entry for a write made by the caller. Call it
+// inside the write's transaction so the change and its entry commit together.
+// A resourceID of 0 records an action on no particular resource.
+func recordAudit(c *gin.Context, tx *gorm.DB, resourceType string, resourceID uint, action string, before, after json.RawMessage) error {
+	actor := audit.Actor{IP: c.ClientIP()}
+	if claims, ok := middleware.CurrentUser(c); ok {
+		actor.ID = &claims.UserID
+		actor.Name = claims.Username
+	}
+	return audit.Record(tx, actor, resourceType, resourceID, action, before, after)
+}
+
+// auditQuery applies the GetAuditLog filters, writing 400 for malformed ones
+func auditQuery(c *gin.Context, db *gorm.DB) (*gorm.DB, bool) {
+	query := db.Model(&models.AuditEntry{})
+	for _, filter := range []struct{ param, column string }{
+		{"resource_type", "resource_type"},
+		{"action", "action"},
+		{"actor_name", "actor_name"},
+	} {
+		if value := c.Query(filter.param); value != "" {
+			query = query.Where(filter.column+" = ?", value)
+		}
+	}
+	for _, filter := range []struct{ param, column string }{
+		{"resource_id", "resource_id"},
+		{"actor_id", "actor_id"},
+	} {
+		if value := c.Query(filter.param); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid " + filter.param})
+				return nil, false
+			}
+			query = query.Where(filter.column+" = ?", id)
+		}
+	}
+	for _, filter := range []struct{ param, condition string }{
+		{"since", "created_at >= ?"},
+		{"until", "created_at < ?"},
+	} {
+		if value := c.Query(filter.param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: filter.param + " must be an RFC3339 timestamp"})
+				return nil, false
+			}
+			query = query.Where(filter.condition, t)
+		}
+	}
+	return query, true
+}
+
+// GetAuditLog lists audit entries, newest first, filtered by resource_type,
+// resource_id, action, actor_id, actor_name, since and until. With
+// ?format=csv every matching entry is exported as a CSV attachment instead.
+func GetAuditLog(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	query, ok := auditQuery(c, db)
+	if !ok {
+		return
+	}
+	if c.Query("format") == "csv" {
+		exportAuditCSV(c, query)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 50
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	entries := []models.AuditEntry{}
+	if err := query.Order("id desc").Limit(pageSize).Offset((page - 1) * pageSize).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       entries,
+		"page":       page,
+		"page_size":  pageSize,
+		"total":      total,
+		"total_page": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+var auditCSVHeader = []string{"id", "created_at", "actor_id", "actor_name", "ip", "resource_type", "resource_id", "action", "before", "after"}
+
+// exportAuditCSV streams the matching entries oldest first, reading them in
+// batches so large exports are not held in memory
+func exportAuditCSV(c *gin.Context, query *gorm.DB) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename=audit-"+time.Now().UTC().Format("20060102T150405Z")+".csv")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(auditCSVHeader)
+	var batch []models.AuditEntry
+	err := query.FindInBatches(&batch, auditCSVBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			w.Write(auditCSVRecord(&entry))
+		}
+		w.Flush()
+		return w.Error()
+	}).Error
+	w.Flush()
+	if err != nil {
+		// The status line is already sent, so a failure can only cut the file short
+		log.Printf("Audit CSV export failed: %v", err)
+	}
+}
+
+func auditCSVRecord(entry *models.AuditEntry) []string {
+	optionalID := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*id), 10)
+	}
+	return []string{
+		strconv.FormatUint(uint64(entry.ID), 10),
+		entry.CreatedAt.UTC().Format(time.RFC3339),
+		optionalID(entry.ActorID),
+		csvSafe(entry.ActorName),
+		entry.IP,
+		entry.ResourceType,
+		optionalID(entry.ResourceID),
+		entry.Action,
+		string(entry.Before),
+		string(entry.After),
+	}
+}
+
+// csvSafe keeps spreadsheet applications from evaluating a user-supplied cell
+// as a formula
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectAudit expects one audit entry to be appended
+func expectAudit(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`INSERT INTO "audit_entries"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+// snapshotWithout matches an audit snapshot containing none of the strings,
+// to check that secrets are left out
+type snapshotWithout []string
+
+func (s snapshotWithout) Match(v driver.Value) bool {
+	var snapshot string
+	switch value := v.(type) {
+	case []byte:
+		snapshot = string(value)
+	case string:
+		snapshot = value
+	}
+	for _, secret := range s {
+		if strings.Contains(snapshot, secret) {
+			return false
+		}
+	}
+	return true
+}
+
+var auditColumns = []string{"id", "actor_id", "actor_name", "ip", "resource_type", "resource_id", "action", "before", "after", "created_at"}
+
+func TestRecordAudit(t *testing.T) {
+	router, db, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 7, "editor")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "audit_entries" \("actor_id","actor_name","ip","resource_type","resource_id","action","before","after","created_at"\)`).
+		WithArgs(7, "tester", "203.0.113.9", models.RevisionResourcePost, 3, models.AuditActionUpdate, `{"title":"Old"}`, `{"title":"New"}`, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	router.PUT("/posts/:id", func(c *gin.Context) {
+		err := recordAudit(c, db, models.RevisionResourcePost, 3, models.AuditActionUpdate,
+			auditSnapshot(gin.H{"title": "Old"}), auditSnapshot(gin.H{"title": "New"}))
+		require.NoError(t, err)
+		c.Status(http.StatusNoContent)
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/posts/3", nil)
+	req.RemoteAddr = "203.0.113.9:41000"
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeletePost_RecordsAudit(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "version", "created_at", "updated_at"}).
+			AddRow(1, "Title", "Content", 2, now, now))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_entries"`).
+		WithArgs(1, "tester", sqlmock.AnyArg(), models.RevisionResourcePost, 1, models.AuditActionDelete, sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	router.DELETE("/posts/:id", DeletePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/posts/1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code, "a write is not kept without its audit entry")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAuditLog(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "audit_entries" WHERE resource_type = \$1 AND resource_id = \$2 AND created_at >= \$3`).
+		WithArgs("post", 3, since).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "audit_entries" WHERE resource_type = \$1 AND resource_id = \$2 AND created_at >= \$3 ORDER BY id desc LIMIT \$4`).
+		WithArgs("post", 3, since, 50).
+		WillReturnRows(sqlmock.NewRows(auditColumns).
+			AddRow(9, 1, "admin", "203.0.113.9", "post", 3, "publish", `{"status":"draft"}`, `{"status":"published"}`, since.Add(time.Hour)))
+
+	router.GET("/audit", GetAuditLog)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/audit?resource_type=post&resource_id=3&since=2024-05-01T00:00:00Z", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data  []map[string]interface{} `json:"data"`
+		Total int64                    `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, "publish", response.Data[0]["action"])
+	assert.Equal(t, map[string]interface{}{"status": "published"}, response.Data[0]["after"], "snapshots are returned as JSON")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAuditLog_BadFilters(t *testing.T) {
+	for _, query := range []string{"resource_id=abc", "actor_id=-1", "since=yesterday", "until=2024-05-01"} {
+		t.Run(query, func(t *testing.T) {
+			router, _, mock := utils.SetupRouterAndMockDB(t)
+			defer mock.ExpectClose()
+
+			router.GET("/audit", GetAuditLog)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/audit?"+query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetAuditLog_CSV(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT \* FROM "audit_entries" WHERE action = \$1 ORDER BY "audit_entries"\."id" LIMIT \$2`).
+		WithArgs("clear", auditCSVBatchSize).
+		WillReturnRows(sqlmock.NewRows(auditColumns).
+			AddRow(4, 2, "=HYPERLINK(\"x\")", "203.0.113.9", "cache", nil, "clear", nil, `null`, at))
+
+	router.GET("/audit", GetAuditLog)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/audit?action=clear&format=csv", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment; filename=audit-")
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, auditCSVHeader, records[0])
+	assert.Equal(t, []string{"4", "2024-05-01T12:00:00Z", "2", `'=HYPERLINK("x")`, "203.0.113.9", "cache", "", "clear", "", "null"}, records[1])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/utils"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var authValidator = validator.New()
+
+type LoginInput struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type AuthResponse struct {
+	utils.TokenPair
+	User models.User `json:"user"`
+}
+
+// revokeToken records the token as revoked. revoked is false when it already
+// was, which makes the insert itself the check for concurrent refreshes.
+func revokeToken(db *gorm.DB, claims *utils.Claims) (revoked bool, err error) {
+	token := models.RevokedToken{JTI: claims.ID, ExpiresAt: claims.ExpiresAt.Time}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&token)
+	return result.RowsAffected > 0, result.Error
+}
+
+// dummyPasswordHash is compared against when no user matches a login, so an
+// unknown username takes as long to reject as a wrong password
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not-a-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Login exchanges a username (or email) and password for a token pair
+func Login(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input LoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := authValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := db.Where("username = ? OR email = ?", input.Username, input.Username).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(input.Password))
+			c.JSON(http.StatusUnauthorized, utils.HTTPError{Code: 401, Message: "Invalid credentials"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	if !user.CheckPassword(input.Password) {
+		c.JSON(http.StatusUnauthorized, utils.HTTPError{Code: 401, Message: "Invalid credentials"})
+		return
+	}
+
+	tokens, err := utils.GenerateTokenPair(user.ID, user.Username, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: "Failed to issue tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, AuthResponse{TokenPair: *tokens, User: user})
+}
+
+// Refresh rotates a refresh token: the presented token is revoked and a new pair is issued
+func Refresh(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := authValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return
+	}
+
+	claims, err := utils.ParseToken(input.RefreshToken, utils.RefreshTokenType)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, utils.HTTPError{Code: 401, Message: "Invalid or expired refresh token"})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, claims.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, utils.HTTPError{Code: 401, Message: "User no longer exists"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	if !user.AcceptsToken(claims.IssuedAt.Time) {
+		c.JSON(http.StatusUnauthorized, utils.HTTPError{Code: 401, Message: "Refresh token was issued before a password change"})
+		return
+	}
+
+	// Of two refreshes racing with the same token, only the one whose insert
+	// lands gets a new pair
+	revoked, err := revokeToken(db, claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusUnauthorized, utils.HTTPError{Code: 401, Message: "Refresh token has been revoked"})
+		return
+	}
+
+	tokens, err := utils.GenerateTokenPair(user.ID, user.Username, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: "Failed to issue tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, AuthResponse{TokenPair: *tokens, User: user})
+}
+
+// Logout revokes the caller's access token and, if supplied, their refresh token
+func Logout(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	claims, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, utils.HTTPError{Code: 401, Message: "Authorization token required"})
+		return
+	}
+
+	var input LogoutInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+			return
+		}
+	}
+
+	revoke := []*utils.Claims{claims}
+	if input.RefreshToken != "" {
+		refreshClaims, err := utils.ParseToken(input.RefreshToken, utils.RefreshTokenType)
+		if err != nil || refreshClaims.UserID != claims.UserID {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid refresh token"})
+			return
+		}
+		revoke = append(revoke, refreshClaims)
+	}
+
+	tx := db.Begin()
+	for _, rc := range revoke {
+		if _, err := revokeToken(tx, rc); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Logged out"})
+}
+
+// Me returns the authenticated user
+func Me(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	claims, ok := middleware.CurrentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, utils.HTTPError{Code: 401, Message: "Authorization token required"})
+		return
+	}
+	var user models.User
+	if err := db.First(&user, claims.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
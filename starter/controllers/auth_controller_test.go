@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func userRows(t *testing.T, password string) *sqlmock.Rows {
+	user := models.User{}
+	if err := user.SetPassword(password); err != nil {
+		t.Fatal(err)
+	}
+	return sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "role", "created_at", "updated_at"}).
+		AddRow(1, "alice", "alice@example.com", user.PasswordHash, "author", time.Now(), time.Now())
+}
+
+func TestLogin(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE username = \$1 OR email = \$2 ORDER BY "users"\."id" LIMIT \$3`).
+		WithArgs("alice", "alice", 1).
+		WillReturnRows(userRows(t, "s3cret"))
+
+	router.POST("/auth/login", Login)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username":"alice","password":"s3cret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response AuthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.AccessToken == "" || response.RefreshToken == "" {
+		t.Fatalf("Expected tokens in response, got %+v", response)
+	}
+	if response.User.Username != "alice" {
+		t.Fatalf("Expected user alice, got %s", response.User.Username)
+	}
+	if strings.Contains(w.Body.String(), "password_hash") {
+		t.Fatalf("Password hash must not be returned")
+	}
+}
+
+func TestLogin_WrongPassword(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(userRows(t, "s3cret"))
+
+	router.POST("/auth/login", Login)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username":"alice","password":"nope"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestLogin_UnknownUser(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	router.POST("/auth/login", Login)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username":"mallory","password":"nope"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestLogin_MissingFields(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	router.POST("/auth/login", Login)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username":"alice"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	pair, err := utils.GenerateTokenPair(1, "alice", "author")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1`).
+		WithArgs(1, 1).
+		WillReturnRows(userRows(t, "s3cret"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "revoked_tokens"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	router.POST("/auth/refresh", Refresh)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token":"`+pair.RefreshToken+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response AuthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.RefreshToken == "" || response.RefreshToken == pair.RefreshToken {
+		t.Fatalf("Expected a rotated refresh token")
+	}
+}
+
+func TestRefresh_AlreadyRevoked(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	pair, err := utils.GenerateTokenPair(1, "alice", "author")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A concurrent refresh or a logout recorded the token first
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1`).
+		WillReturnRows(userRows(t, "s3cret"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "revoked_tokens" .* ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	router.POST("/auth/refresh", Refresh)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token":"`+pair.RefreshToken+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefresh_RejectsAccessToken(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	pair, err := utils.GenerateTokenPair(1, "alice", "author")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router.POST("/auth/refresh", Refresh)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token":"`+pair.AccessToken+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	pair, err := utils.GenerateTokenPair(1, "alice", "author")
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := utils.ParseToken(pair.AccessToken, utils.AccessTokenType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "revoked_tokens"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "revoked_tokens"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	router.POST("/auth/logout", func(c *gin.Context) {
+		middleware.SetCurrentUser(c, access)
+	}, Logout)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/auth/logout", strings.NewReader(`{"refresh_token":"`+pair.RefreshToken+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
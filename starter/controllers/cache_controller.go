@@ -2,10 +2,13 @@ package controllers
 
 import (
 	"cms-backend/middleware"
+	"cms-backend/models"
+	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type CacheStatsResponse struct {
@@ -22,6 +25,15 @@ type CacheConfig struct {
 	Enabled   bool   `json:"enabled"`
 }
 
+// auditCacheAction records a cache admin action with its parameters. The cache
+// has already changed by then, so a failure to record it is only logged.
+func auditCacheAction(c *gin.Context, action string, params gin.H) {
+	db := c.MustGet("db").(*gorm.DB)
+	if err := recordAudit(c, db, models.AuditResourceCache, 0, action, nil, auditSnapshot(params)); err != nil {
+		log.Printf("Failed to record cache %s in the audit log: %v", action, err)
+	}
+}
+
 func GetCacheStats(c *gin.Context) {
 	stats := middleware.GetCacheStats()
 
@@ -48,6 +60,8 @@ func ClearCache(c *gin.Context) {
 		return
 	}
 
+	auditCacheAction(c, models.AuditActionClear, nil)
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
 		"message": "Cache cleared successfully",
@@ -73,6 +87,8 @@ func InvalidateCache(c *gin.Context) {
 		return
 	}
 
+	auditCacheAction(c, models.AuditActionInvalidate, gin.H{"pattern": pattern})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
 		"message": "Cache invalidated successfully",
@@ -91,10 +107,16 @@ func InvalidateResourceCache(c *gin.Context) {
 		err = middleware.InvalidatePostCache()
 	case "pages":
 		err = middleware.InvalidatePageCache()
+	case "categories":
+		err = middleware.InvalidateCategoryCache()
+	case "tags":
+		err = middleware.InvalidateTagCache()
+	case "search":
+		err = middleware.InvalidateSearchCache()
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  "error",
-			"message": "Invalid resource type. Use: media, posts, or pages",
+			"message": "Invalid resource type. Use: media, posts, pages, categories, tags, or search",
 		})
 		return
 	}
@@ -108,6 +130,8 @@ func InvalidateResourceCache(c *gin.Context) {
 		return
 	}
 
+	auditCacheAction(c, models.AuditActionInvalidate, gin.H{"resource": resource})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":   "success",
 		"message":  "Resource cache invalidated successfully",
@@ -138,6 +162,8 @@ func WarmupCache(c *gin.Context) {
 		warmedUp = append(warmedUp, "pages")
 	}
 
+	auditCacheAction(c, models.AuditActionWarmup, gin.H{"resources": warmedUp, "limit": limit})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "success",
 		"message":   "Cache warmup completed",
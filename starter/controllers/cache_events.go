@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// CacheInvalidation is the outbox handler dropping the cached responses that
+// an event makes stale
+var CacheInvalidation = outbox.Handler{Name: "cache", Handle: invalidateForEvent}
+
+func invalidateForEvent(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+	if !middleware.CacheEnabled() {
+		return nil
+	}
+	switch event.ResourceType {
+	case models.RevisionResourcePost, models.RevisionResourcePage, models.AuditResourceMedia, tagResource:
+		// Search hits and suggestions come from all of these
+		if err := middleware.InvalidateSearchCache(); err != nil {
+			return err
+		}
+	}
+	switch event.ResourceType {
+	case categoryResource, tagResource:
+		return invalidateTaxonomyCache()
+	case models.RevisionResourcePost:
+		return middleware.InvalidatePost(event.ResourceID)
+	case models.RevisionResourcePage:
+		return middleware.InvalidatePage(event.ResourceID)
+	case models.AuditResourceMedia:
+		// Usages of trashed media are found too; purged media only have their ID
+		var media models.Media
+		if err := tx.Unscoped().Take(&media, event.ResourceID).Error; err != nil {
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+			media.ID = event.ResourceID
+		}
+		return invalidateMediaAndUsages(tx, &media)
+	}
+	return nil
+}
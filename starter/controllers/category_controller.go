@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+var categoryValidator = validator.New()
+
+// CategoryInput is shared by create and update. A parent_id of 0 moves the
+// category to the top level; leaving it out keeps the current parent.
+type CategoryInput struct {
+	Name        string  `json:"name" validate:"required,max=100"`
+	Slug        string  `json:"slug"`
+	Description *string `json:"description"`
+	ParentID    *uint   `json:"parent_id"`
+}
+
+const categoryResource = "category"
+
+// buildCategoryTree nests a flat category list under its top-level entries.
+// Categories whose parent is not in the list are treated as roots.
+func buildCategoryTree(categories []models.Category) []models.Category {
+	present := make(map[uint]bool, len(categories))
+	for _, category := range categories {
+		present[category.ID] = true
+	}
+	children := make(map[uint][]models.Category)
+	roots := make([]models.Category, 0)
+	for _, category := range categories {
+		if category.ParentID != nil && present[*category.ParentID] {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		} else {
+			roots = append(roots, category)
+		}
+	}
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			if kids, ok := children[nodes[i].ID]; ok {
+				nodes[i].Children = attach(kids)
+			}
+		}
+		return nodes
+	}
+	return attach(roots)
+}
+
+// categoryTreeLockKey identifies the Postgres advisory lock held while a
+// transaction changes where categories sit in the tree
+const categoryTreeLockKey int64 = 0x636d735f636174
+
+// lockCategoryTree serialises moves within tx, so that a parent check and the
+// write it guards see no concurrent move. The lock is released when tx ends.
+func lockCategoryTree(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", categoryTreeLockKey).Error
+}
+
+// checkCategoryParent makes sure parentID exists and is neither the category
+// itself nor one of its descendants, writing a 400 or 500 response otherwise.
+// It takes the tree lock, so call it inside the transaction making the move.
+func checkCategoryParent(c *gin.Context, tx *gorm.DB, id, parentID uint) bool {
+	if err := lockCategoryTree(tx); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return false
+	}
+	seen := make(map[uint]bool)
+	for next := parentID; ; {
+		if next == id {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "A category cannot be nested under itself or its descendants"})
+			return false
+		}
+		var ancestor models.Category
+		if err := tx.Select("id", "parent_id").First(&ancestor, next).Error; err != nil {
+			if err != gorm.ErrRecordNotFound {
+				c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+				return false
+			}
+			if next == parentID {
+				c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Parent category not found"})
+				return false
+			}
+			return true
+		}
+		seen[next] = true
+		if ancestor.ParentID == nil || seen[*ancestor.ParentID] {
+			return true
+		}
+		next = *ancestor.ParentID
+	}
+}
+
+func invalidateTaxonomyCache() error {
+	if err := middleware.InvalidateCategoryCache(); err != nil {
+		return err
+	}
+	if err := middleware.InvalidateTagCache(); err != nil {
+		return err
+	}
+	return middleware.InvalidatePostCache()
+}
+
+// GetCategories lists all categories by name, nested when tree=true
+func GetCategories(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var categories []models.Category
+	if err := db.Order("name asc").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if c.Query("tree") == "true" {
+		categories = buildCategoryTree(categories)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": categories})
+}
+
+// GetCategory retrieves a category with its direct children
+func GetCategory(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid category ID"})
+		return
+	}
+	var category models.Category
+	if err := db.Preload("Children", func(db *gorm.DB) *gorm.DB {
+		return db.Order("name asc")
+	}).First(&category, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Category not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// CreateCategory creates a category, optionally under a parent
+func CreateCategory(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input CategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := categoryValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return
+	}
+	category := models.Category{Name: input.Name}
+	if input.Description != nil {
+		category.Description = *input.Description
+	}
+	tx := db.Begin()
+	if input.ParentID != nil && *input.ParentID != 0 {
+		if !checkCategoryParent(c, tx, 0, *input.ParentID) {
+			tx.Rollback()
+			return
+		}
+		category.ParentID = input.ParentID
+	}
+	slug, err := resolveSlug(tx, &models.Category{}, categoryResource, input.Slug, input.Name, 0)
+	if err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	category.Slug = slug
+	if err := tx.Create(&category).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, categoryResource, category.ID, models.EventCreated, &category); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, categoryResource, category.ID, models.AuditActionCreate, nil, auditSnapshot(&category)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory renames or moves a category
+func UpdateCategory(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid category ID"})
+		return
+	}
+	var category models.Category
+	if err := db.First(&category, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Category not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	before := auditSnapshot(&category)
+	var input CategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := categoryValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return
+	}
+	category.Name = input.Name
+	if input.Description != nil {
+		category.Description = *input.Description
+	}
+	tx := db.Begin()
+	if input.ParentID != nil {
+		if *input.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			if !checkCategoryParent(c, tx, category.ID, *input.ParentID) {
+				tx.Rollback()
+				return
+			}
+			category.ParentID = input.ParentID
+		}
+	}
+	if input.Slug != "" && utils.Slugify(input.Slug) != category.Slug {
+		slug, err := resolveSlug(tx, &models.Category{}, categoryResource, input.Slug, input.Name, category.ID)
+		if err != nil {
+			tx.Rollback()
+			respondWriteError(c, err)
+			return
+		}
+		category.Slug = slug
+	}
+	if err := tx.Save(&category).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, categoryResource, category.ID, models.EventUpdated, &category); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, categoryResource, category.ID, models.AuditActionUpdate, before, auditSnapshot(&category)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory removes a category. Its children move up to its parent and
+// posts simply lose the category.
+func DeleteCategory(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid category ID"})
+		return
+	}
+	var category models.Category
+	if err := db.First(&category, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Category not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	tx := db.Begin()
+	if err := lockCategoryTree(tx); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).Update("parent_id", category.ParentID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := tx.Where("category_id = ?", category.ID).Delete(&models.PostCategory{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := tx.Delete(&category).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, categoryResource, category.ID, models.EventDeleted, &category); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, categoryResource, category.ID, models.AuditActionDelete, auditSnapshot(&category), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Category deleted"})
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetCategories_Tree(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "name", "slug", "parent_id", "created_at", "updated_at"}).
+		AddRow(4, "Football", "football", 3, now, now).
+		AddRow(2, "Local", "local", 1, now, now).
+		AddRow(1, "News", "news", nil, now, now).
+		AddRow(3, "Sports", "sports", nil, now, now).
+		AddRow(5, "Weather", "weather", 2, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "categories" ORDER BY name asc`).WillReturnRows(rows)
+
+	router.GET("/categories", GetCategories)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/categories?tree=true", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data []models.Category `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if len(response.Data) != 2 || response.Data[0].Slug != "news" || response.Data[1].Slug != "sports" {
+		t.Fatalf("Unexpected roots: %+v", response.Data)
+	}
+	news := response.Data[0]
+	if len(news.Children) != 1 || news.Children[0].Slug != "local" || len(news.Children[0].Children) != 1 {
+		t.Fatalf("Expected news > local > weather, got %+v", news.Children)
+	}
+}
+
+func expectCategoryTreeLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1\)`).
+		WithArgs(categoryTreeLockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestCreateCategory(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectBegin()
+	expectCategoryTreeLock(mock)
+	mock.ExpectQuery(`SELECT "id","parent_id" FROM "categories" WHERE "categories"\."id" = \$1`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(1, nil))
+	expectSlugLookup(mock, "categories", "local-news")
+	mock.ExpectQuery(`INSERT INTO "categories"`).
+		WithArgs("Local News", "local-news", "", 1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	expectOutboxEvent(mock)
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	router.POST("/categories", CreateCategory)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/categories", strings.NewReader(`{"name":"Local News","parent_id":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var response models.Category
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.ID != 2 || response.Slug != "local-news" || response.ParentID == nil || *response.ParentID != 1 {
+		t.Fatalf("Unexpected category: %+v", response)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCreateCategory_ParentNotFound(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectBegin()
+	expectCategoryTreeLock(mock)
+	mock.ExpectQuery(`SELECT "id","parent_id" FROM "categories"`).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}))
+	mock.ExpectRollback()
+
+	router.POST("/categories", CreateCategory)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/categories", strings.NewReader(`{"name":"Orphan","parent_id":9}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateCategory_RejectsCycle(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "categories" WHERE "categories"\."id" = \$1`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "parent_id", "created_at", "updated_at"}).
+			AddRow(1, "News", "news", nil, now, now))
+	mock.ExpectBegin()
+	expectCategoryTreeLock(mock)
+	// 3 is a grandchild of 1
+	mock.ExpectQuery(`SELECT "id","parent_id" FROM "categories"`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(3, 2))
+	mock.ExpectQuery(`SELECT "id","parent_id" FROM "categories"`).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, 1))
+	mock.ExpectRollback()
+
+	router.PUT("/categories/:id", UpdateCategory)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/categories/1", strings.NewReader(`{"name":"News","parent_id":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteCategory_ReparentsChildren(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "categories" WHERE "categories"\."id" = \$1`).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "parent_id", "created_at", "updated_at"}).
+			AddRow(2, "Local", "local", 1, now, now))
+	mock.ExpectBegin()
+	expectCategoryTreeLock(mock)
+	mock.ExpectExec(`UPDATE "categories" SET "parent_id"=\$1,"updated_at"=\$2 WHERE parent_id = \$3`).
+		WithArgs(1, sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "post_categories" WHERE category_id = \$1`).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM "categories" WHERE "categories"\."id" = \$1`).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectOutboxEvent(mock)
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	router.DELETE("/categories/:id", DeleteCategory)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/categories/2", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
@@ -3,10 +3,16 @@ package controllers
 import (
 	"cms-backend/middleware"
 	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/storage"
 	"cms-backend/utils"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -15,6 +21,24 @@ import (
 
 var mediaValidator = validator.New()
 
+// mediaETag is the strong validator clients send back in If-Match
+func mediaETag(media *models.Media) string {
+	return utils.ResourceETag("media", media.ID, media.Version)
+}
+
+// mediaRangeFilters are the GetMedia query parameters bounding dimensions and duration
+var mediaRangeFilters = []struct {
+	param     string
+	condition string
+}{
+	{"min_width", "width >= ?"},
+	{"max_width", "width <= ?"},
+	{"min_height", "height >= ?"},
+	{"max_height", "height <= ?"},
+	{"min_duration", "duration >= ?"},
+	{"max_duration", "duration <= ?"},
+}
+
 func GetMedia(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	var media []models.Media
@@ -36,7 +60,7 @@ func GetMedia(c *gin.Context) {
 	}
 	sortField := sortBy
 	switch sortBy {
-	case "url", "type", "created_at", "updated_at":
+	case "url", "type", "width", "height", "duration", "created_at", "updated_at":
 	default:
 		sortField = "created_at"
 	}
@@ -50,7 +74,7 @@ func GetMedia(c *gin.Context) {
 
 	if search != "" {
 		searchPattern := "%" + search + "%"
-		conditions = append(conditions, "url ILIKE ? OR type ILIKE ?")
+		conditions = append(conditions, "(url ILIKE ? OR type ILIKE ?)")
 		args = append(args, searchPattern, searchPattern)
 	}
 
@@ -59,6 +83,39 @@ func GetMedia(c *gin.Context) {
 		args = append(args, mediaType)
 	}
 
+	for _, filter := range mediaRangeFilters {
+		value := c.Query(filter.param)
+		if value == "" {
+			continue
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number < 0 {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: filter.param + " must be a non-negative number"})
+			return
+		}
+		conditions = append(conditions, filter.condition)
+		args = append(args, number)
+	}
+
+	switch c.Query("orientation") {
+	case "":
+	case "landscape":
+		conditions = append(conditions, "width > height")
+	case "portrait":
+		conditions = append(conditions, "width < height")
+	case "square":
+		conditions = append(conditions, "width = height AND width > 0")
+	default:
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "orientation must be landscape, portrait or square"})
+		return
+	}
+
+	if camera := c.Query("camera"); camera != "" {
+		cameraPattern := "%" + camera + "%"
+		conditions = append(conditions, "(metadata->>'camera_make' ILIKE ? OR metadata->>'camera_model' ILIKE ?)")
+		args = append(args, cameraPattern, cameraPattern)
+	}
+
 	if len(conditions) > 0 {
 		whereClause := strings.Join(conditions, " AND ")
 		query = query.Where(whereClause, args...)
@@ -78,7 +135,8 @@ func GetMedia(c *gin.Context) {
 		return
 	}
 
-	if err := query.Order(sortField + " " + sortOrder).
+	if err := query.Preload("Renditions").
+		Order(sortField + " " + sortOrder).
 		Limit(pageSize).
 		Offset(offset).
 		Find(&media).Error; err != nil {
@@ -89,6 +147,12 @@ func GetMedia(c *gin.Context) {
 		return
 	}
 
+	tags := []string{middleware.ListTag("media")}
+	for _, item := range media {
+		tags = append(tags, middleware.EntityTag(models.AuditResourceMedia, item.ID))
+	}
+	middleware.TagCache(c, tags...)
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":       media,
 		"page":       page,
@@ -107,7 +171,7 @@ func GetMediaByID(c *gin.Context) {
 		return
 	}
 	var media models.Media
-	if err := db.First(&media, id).Error; err != nil {
+	if err := db.Preload("Renditions").First(&media, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Media not found"})
 		} else {
@@ -115,6 +179,8 @@ func GetMediaByID(c *gin.Context) {
 		}
 		return
 	}
+	middleware.TagCache(c, middleware.EntityTag(models.AuditResourceMedia, media.ID))
+	c.Header("ETag", mediaETag(&media))
 	c.JSON(http.StatusOK, media)
 }
 
@@ -129,19 +195,124 @@ func CreateMedia(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
 		return
 	}
+	// File details are only ever recorded by UploadMedia
+	media := models.Media{URL: input.URL, Type: input.Type, Title: input.Title, AltText: input.AltText, Caption: input.Caption, Credit: input.Credit, License: input.License, Version: 1}
 	tx := db.Begin()
-	if err := tx.Create(&input).Error; err != nil {
+	if err := tx.Create(&media).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.AuditResourceMedia, media.ID, models.AuditActionCreate, nil, auditSnapshot(&media)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.AuditResourceMedia, media.ID, models.EventCreated, &media); err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
 		return
 	}
 	tx.Commit()
 
-	middleware.InvalidateMediaCache()
+	outbox.Notify()
+
+	c.JSON(http.StatusCreated, media)
+}
+
+// MediaUpdateInput is the body of UpdateMedia; fields left out keep their value
+type MediaUpdateInput struct {
+	URL     *string `json:"url" validate:"omitempty,url,max=255"`
+	Type    *string `json:"type" validate:"omitempty,min=1,max=50"`
+	Title   *string `json:"title" validate:"omitempty,max=255"`
+	AltText *string `json:"alt_text" validate:"omitempty,max=500"`
+	Caption *string `json:"caption"`
+	Credit  *string `json:"credit" validate:"omitempty,max=255"`
+	License *string `json:"license" validate:"omitempty,max=100"`
+}
+
+// UpdateMedia edits the editorial text, credit, license and type of a media item, and
+// the URL of one registered by URL. The ID stays the same, so posts keep it.
+func UpdateMedia(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid media ID"})
+		return
+	}
+	var media models.Media
+	if err := db.Preload("Renditions").First(&media, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Media not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	if !middleware.CheckIfMatch(c, mediaETag(&media)) {
+		return
+	}
+	var input MediaUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := mediaValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return
+	}
+	if input.URL != nil && media.StorageKey != "" {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "The URL of an uploaded file cannot be changed; replace the file instead"})
+		return
+	}
+
+	before := auditSnapshot(&media)
+	updates := make(map[string]interface{})
+	for column, field := range map[string]struct {
+		value  *string
+		target *string
+	}{
+		"url":      {input.URL, &media.URL},
+		"type":     {input.Type, &media.Type},
+		"title":    {input.Title, &media.Title},
+		"alt_text": {input.AltText, &media.AltText},
+		"caption":  {input.Caption, &media.Caption},
+		"credit":   {input.Credit, &media.Credit},
+		"license":  {input.License, &media.License},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+			updates[column] = *field.value
+		}
+	}
+	if len(updates) > 0 {
+		tx := db.Begin()
+		if err := updateVersioned(tx, &media, media.Version, updates); err != nil {
+			tx.Rollback()
+			respondWriteError(c, err)
+			return
+		}
+		if err := recordAudit(c, tx, models.AuditResourceMedia, media.ID, models.AuditActionUpdate, before, auditSnapshot(&media)); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+		if err := outbox.Publish(tx, models.AuditResourceMedia, media.ID, models.EventUpdated, &media); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+		tx.Commit()
+		outbox.Notify()
+	}
 
-	c.JSON(http.StatusCreated, input)
+	c.Header("ETag", mediaETag(&media))
+	c.JSON(http.StatusOK, media)
 }
 
+// DeleteMedia moves media to the trash; its file stays stored until the media
+// is purged. It refuses with 409 while posts or pages use the media, listing
+// them, unless ?force=true is passed.
 func DeleteMedia(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	idStr := c.Param("id")
@@ -151,7 +322,7 @@ func DeleteMedia(c *gin.Context) {
 		return
 	}
 	var media models.Media
-	if err := db.First(&media, id).Error; err != nil {
+	if err := db.Preload("Renditions").First(&media, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Media not found"})
 		} else {
@@ -159,15 +330,286 @@ func DeleteMedia(c *gin.Context) {
 		}
 		return
 	}
+	if !middleware.CheckIfMatch(c, mediaETag(&media)) {
+		return
+	}
+	usages, err := findMediaUsages(db, &media)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	force, _ := strconv.ParseBool(c.Query("force"))
+	if len(usages) > 0 && !force {
+		c.JSON(http.StatusConflict, mediaInUseError{
+			HTTPError: utils.HTTPError{Code: 409, Message: fmt.Sprintf("Media is used by %d posts or pages; pass force=true to delete it anyway", len(usages))},
+			Usages:    usages,
+		})
+		return
+	}
+	tx := db.Begin()
+	if err := deleteVersioned(tx, &media, media.Version); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := recordAudit(c, tx, models.AuditResourceMedia, media.ID, models.AuditActionDelete, auditSnapshot(&media), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.AuditResourceMedia, media.ID, models.EventDeleted, &media); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Media moved to trash"})
+}
+
+// UploadMedia accepts a multipart/form-data body with the file in the "file"
+// field, an optional "type" field overriding the detected media type and an
+// optional "strip_gps" field overriding MEDIA_STRIP_GPS
+func UploadMedia(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	driver := storage.Default()
+	if driver == nil {
+		c.JSON(http.StatusServiceUnavailable, utils.HTTPError{Code: 503, Message: "Media storage is not configured"})
+		return
+	}
+	form, ok := readUploadForm(c)
+	if !ok {
+		return
+	}
+	defer form.Close()
+	upload := form.file
+	mediaType := form.mediaType
+	if mediaType == "" {
+		mediaType = mediaTypeFor(upload.ContentType)
+	}
+	info, ok := inspectUpload(c, form)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	key, ok := storeUpload(c, driver, upload)
+	if !ok {
+		return
+	}
+
+	media := models.Media{
+		URL:              key,
+		Type:             mediaType,
+		OriginalFilename: form.filename,
+		MimeType:         upload.ContentType,
+		Size:             upload.Size,
+		Checksum:         upload.Checksum,
+		StorageDriver:    driver.Name(),
+		StorageKey:       key,
+		Version:          1,
+	}
+	applyMetadata(&media, info)
 	tx := db.Begin()
-	if err := tx.Delete(&media).Error; err != nil {
+	err := tx.Create(&media).Error
+	if err == nil {
+		media.URL = mediaFileURL(media.ID)
+		err = tx.Model(&media).UpdateColumn("url", media.URL).Error
+	}
+	if err == nil {
+		err = recordAudit(c, tx, models.AuditResourceMedia, media.ID, models.AuditActionCreate, nil, auditSnapshot(&media))
+	}
+	if err == nil {
+		err = outbox.Publish(tx, models.AuditResourceMedia, media.ID, models.EventCreated, &media)
+	}
+	if err != nil {
 		tx.Rollback()
+		removeOrphanedUpload(ctx, driver, key)
 		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
 		return
 	}
 	tx.Commit()
+	outbox.Notify()
+
+	// Renditions are stored after the commit, possibly after the outbox event
+	// has already been handled
+	renderPresets(ctx, db, driver, &media, upload)
+	middleware.InvalidateMedia(media.ID)
+
+	c.Header("ETag", mediaETag(&media))
+	c.JSON(http.StatusCreated, media)
+}
+
+// ReplaceMediaFile swaps the file behind a media item for a new upload, sent
+// like UploadMedia's. The ID stays the same, so posts keep showing it; the old
+// file and its renditions are removed and the presets rendered again. Without
+// a "type" field, a type derived from the old file follows the new one.
+func ReplaceMediaFile(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid media ID"})
+		return
+	}
+	var media models.Media
+	if err := db.Preload("Renditions").First(&media, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Media not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	if !middleware.CheckIfMatch(c, mediaETag(&media)) {
+		return
+	}
+	driver := storage.Default()
+	if driver == nil {
+		c.JSON(http.StatusServiceUnavailable, utils.HTTPError{Code: 503, Message: "Media storage is not configured"})
+		return
+	}
+	form, ok := readUploadForm(c)
+	if !ok {
+		return
+	}
+	defer form.Close()
+	upload := form.file
+	info, ok := inspectUpload(c, form)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	key, ok := storeUpload(c, driver, upload)
+	if !ok {
+		return
+	}
+
+	previous := media
+	if form.mediaType != "" {
+		media.Type = form.mediaType
+	} else if media.Type == mediaTypeFor(media.MimeType) {
+		media.Type = mediaTypeFor(upload.ContentType)
+	}
+	media.URL = mediaFileURL(media.ID)
+	media.OriginalFilename = form.filename
+	media.MimeType = upload.ContentType
+	media.Size = upload.Size
+	media.Checksum = upload.Checksum
+	media.StorageDriver = driver.Name()
+	media.StorageKey = key
+	media.Renditions = nil
+	applyMetadata(&media, info)
+	media.Version++
+
+	tx := db.Begin()
+	err = tx.Where("media_id = ?", media.ID).Delete(&models.MediaRendition{}).Error
+	if err == nil {
+		err = saveVersioned(tx, &media, previous.Version)
+	}
+	if err == nil {
+		err = recordAudit(c, tx, models.AuditResourceMedia, media.ID, models.AuditActionReplaceFile, auditSnapshot(&previous), auditSnapshot(&media))
+	}
+	if err == nil {
+		err = outbox.Publish(tx, models.AuditResourceMedia, media.ID, models.EventUpdated, &media)
+	}
+	if err != nil {
+		tx.Rollback()
+		removeOrphanedUpload(ctx, driver, key)
+		respondWriteError(c, err)
+		return
+	}
+	tx.Commit()
+	outbox.Notify()
 
-	middleware.InvalidateMediaCache()
+	storage.Remove(ctx, previous.StorageDriver, previous.StorageKeys()...)
+	renderPresets(ctx, db, driver, &media, upload)
+	invalidateMediaAndUsages(db, &media)
 
-	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Media deleted"})
+	c.Header("ETag", mediaETag(&media))
+	c.JSON(http.StatusOK, media)
+}
+
+// ServeMediaFile streams an uploaded file, or redirects to it when the storage
+// driver exposes a direct URL. Media created from an external URL redirects there.
+func ServeMediaFile(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid media ID"})
+		return
+	}
+	var media models.Media
+	if err := db.First(&media, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Media not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	if media.StorageKey == "" {
+		c.Redirect(http.StatusFound, media.URL)
+		return
+	}
+	driver, ok := mediaDriver(c, &media)
+	if !ok {
+		return
+	}
+	serveStoredFile(c, driver, storedFile{
+		key:         media.StorageKey,
+		contentType: media.MimeType,
+		checksum:    media.Checksum,
+		filename:    media.OriginalFilename,
+		modTime:     media.UpdatedAt,
+	})
+}
+
+// storedFile describes an uploaded file or rendition for serveStoredFile
+type storedFile struct {
+	key         string
+	contentType string
+	checksum    string
+	filename    string
+	modTime     time.Time
+}
+
+// serveStoredFile streams a stored file with Range and conditional request
+// support, or redirects when the driver exposes a direct URL
+func serveStoredFile(c *gin.Context, driver storage.Driver, file storedFile) {
+	ctx := c.Request.Context()
+	location, err := driver.URL(ctx, file.key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if location != "" {
+		c.Redirect(http.StatusFound, location)
+		return
+	}
+	object, err := driver.Open(ctx, file.key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Media file not found"})
+		} else {
+			c.JSON(http.StatusBadGateway, utils.HTTPError{Code: 502, Message: err.Error()})
+		}
+		return
+	}
+	defer object.Close()
+
+	header := c.Writer.Header()
+	if file.contentType != "" {
+		header.Set("Content-Type", file.contentType)
+	}
+	if file.checksum != "" {
+		header.Set("ETag", `"`+file.checksum+`"`)
+	}
+	if file.filename != "" {
+		header.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": file.filename}))
+	}
+	// Uploaded content must never run as a page on the API's origin
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	http.ServeContent(c.Writer, c.Request, "", file.modTime, object)
 }
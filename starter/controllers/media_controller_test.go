@@ -3,6 +3,7 @@ package controllers
 import (
 	"cms-backend/models"
 	"cms-backend/utils"
+	"database/sql/driver"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -30,9 +31,10 @@ func TestGetMedia(t *testing.T) {
 		AddRow(1, "http://example.com/image1.jpg", "image", time.Now(), time.Now()).
 		AddRow(2, "http://example.com/image2.jpg", "image", time.Now(), time.Now())
 
-	mock.ExpectQuery(`SELECT \* FROM "media" ORDER BY created_at desc LIMIT \$1`).
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$1`).
 		WithArgs(10).
 		WillReturnRows(rows)
+	expectNoRenditions(mock)
 
 	router.GET("/media", GetMedia)
 	w := httptest.NewRecorder()
@@ -65,7 +67,8 @@ func TestGetMediaByID(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "url", "type", "created_at", "updated_at"}).
 		AddRow(1, "http://example.com/image1.jpg", "image", now, now)
 
-	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 ORDER BY "media"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 AND "media"\."deleted_at" IS NULL ORDER BY "media"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	expectNoRenditions(mock)
 
 	router.GET("/media/:id", GetMediaByID)
 	w := httptest.NewRecorder()
@@ -91,8 +94,10 @@ func TestCreateMedia(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO "media"`).
-		WithArgs("http://example.com/image3.jpg", "image", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("http://example.com/image3.jpg", "image", "", "", "", "", "", "", "", 0, "", 0, 0, 0.0, 0, nil, "", "", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
 	mock.ExpectCommit()
 
 	media := models.Media{URL: "http://example.com/image3.jpg", Type: "image"}
@@ -124,11 +129,15 @@ func TestDeleteMedia(t *testing.T) {
 	now := time.Now()
 	rows := sqlmock.NewRows([]string{"id", "url", "type", "created_at", "updated_at"}).
 		AddRow(1, "http://example.com/image1.jpg", "image", now, now)
-	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 ORDER BY "media"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 AND "media"\."deleted_at" IS NULL ORDER BY "media"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	expectNoRenditions(mock)
+	expectNoUsages(mock)
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "media" WHERE "media"\."id" = \$1`).WithArgs(1).
+	mock.ExpectExec(`UPDATE "media" SET "deleted_at"=\$1 WHERE version = \$2 AND "media"\."id" = \$3 AND "media"\."deleted_at" IS NULL`).WithArgs(sqlmock.AnyArg(), 0, 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
 	mock.ExpectCommit()
 
 	router.DELETE("/media/:id", DeleteMedia)
@@ -144,7 +153,7 @@ func TestDeleteMedia(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
 		t.Fatalf("Error unmarshaling response: %v", err)
 	}
-	if response.Message != "Media deleted" {
+	if response.Message != "Media moved to trash" {
 		t.Fatalf("Expected deletion message, got %s", response.Message)
 	}
 }
@@ -176,7 +185,7 @@ func TestGetMediaByID_NotFound(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 ORDER BY "media"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 AND "media"\."deleted_at" IS NULL ORDER BY "media"\."id" LIMIT \$2`).
 		WithArgs(999, 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
@@ -202,7 +211,7 @@ func TestGetMediaByID_DatabaseError(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 ORDER BY "media"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 AND "media"\."deleted_at" IS NULL ORDER BY "media"\."id" LIMIT \$2`).
 		WithArgs(1, 1).
 		WillReturnError(gorm.ErrInvalidDB)
 
@@ -282,7 +291,7 @@ func TestCreateMedia_DatabaseError(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO "media"`).
-		WithArgs("http://example.com/image.jpg", "image", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("http://example.com/image.jpg", "image", "", "", "", "", "", "", "", 0, "", 0, 0, 0.0, 0, nil, "", "", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnError(gorm.ErrInvalidDB)
 	mock.ExpectRollback()
 
@@ -334,7 +343,7 @@ func TestDeleteMedia_NotFound(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 ORDER BY "media"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 AND "media"\."deleted_at" IS NULL ORDER BY "media"\."id" LIMIT \$2`).
 		WithArgs(999, 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
@@ -363,13 +372,15 @@ func TestDeleteMedia_DatabaseError(t *testing.T) {
 	now := time.Now()
 	rows := sqlmock.NewRows([]string{"id", "url", "type", "created_at", "updated_at"}).
 		AddRow(1, "http://example.com/image.jpg", "image", now, now)
-	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 ORDER BY "media"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 AND "media"\."deleted_at" IS NULL ORDER BY "media"\."id" LIMIT \$2`).
 		WithArgs(1, 1).
 		WillReturnRows(rows)
+	expectNoRenditions(mock)
+	expectNoUsages(mock)
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "media" WHERE "media"\."id" = \$1`).
-		WithArgs(1).
+	mock.ExpectExec(`UPDATE "media" SET "deleted_at"=\$1 WHERE version = \$2 AND "media"\."id" = \$3 AND "media"\."deleted_at" IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 0, 1).
 		WillReturnError(gorm.ErrInvalidDB)
 	mock.ExpectRollback()
 
@@ -415,7 +426,7 @@ func TestGetMedia_WithPaginationBoundaries(t *testing.T) {
 			mock.ExpectQuery(`SELECT count\(\*\) FROM "media"`).WillReturnRows(countRows)
 
 			rows := sqlmock.NewRows([]string{"id", "url", "type", "created_at", "updated_at"})
-			mock.ExpectQuery(`SELECT \* FROM "media" ORDER BY created_at desc LIMIT \$1`).
+			mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$1`).
 				WithArgs(tc.expectedPageSize).
 				WillReturnRows(rows)
 
@@ -466,12 +477,12 @@ func TestGetMedia_WithSortingAndFiltering(t *testing.T) {
 			countRows := sqlmock.NewRows([]string{"count"}).AddRow(0)
 
 			if tc.name == "SearchFilter" {
-				mock.ExpectQuery(`SELECT count\(\*\) FROM "media" WHERE url ILIKE \$1 OR type ILIKE \$2`).
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "media" WHERE \(\(url ILIKE \$1 OR type ILIKE \$2\)\) AND "media"\."deleted_at" IS NULL`).
 					WithArgs("%test%", "%test%").
 					WillReturnRows(countRows)
 
 				rows := sqlmock.NewRows([]string{"id", "url", "type", "created_at", "updated_at"})
-				mock.ExpectQuery(`SELECT \* FROM "media" WHERE url ILIKE \$1 OR type ILIKE \$2 ORDER BY created_at desc LIMIT \$3`).
+				mock.ExpectQuery(`SELECT \* FROM "media" WHERE \(\(url ILIKE \$1 OR type ILIKE \$2\)\) AND "media"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$3`).
 					WithArgs("%test%", "%test%", 10).
 					WillReturnRows(rows)
 			} else if tc.name == "TypeFilter" {
@@ -480,7 +491,7 @@ func TestGetMedia_WithSortingAndFiltering(t *testing.T) {
 					WillReturnRows(countRows)
 
 				rows := sqlmock.NewRows([]string{"id", "url", "type", "created_at", "updated_at"})
-				mock.ExpectQuery(`SELECT \* FROM "media" WHERE type = \$1 ORDER BY created_at desc LIMIT \$2`).
+				mock.ExpectQuery(`SELECT \* FROM "media" WHERE type = \$1 AND "media"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$2`).
 					WithArgs("image", 10).
 					WillReturnRows(rows)
 			} else {
@@ -488,11 +499,11 @@ func TestGetMedia_WithSortingAndFiltering(t *testing.T) {
 
 				rows := sqlmock.NewRows([]string{"id", "url", "type", "created_at", "updated_at"})
 				if tc.expectQuery != "" {
-					mock.ExpectQuery(`SELECT \* FROM "media" ORDER BY ` + tc.expectQuery + ` LIMIT \$1`).
+					mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."deleted_at" IS NULL ORDER BY ` + tc.expectQuery + ` LIMIT \$1`).
 						WithArgs(10).
 						WillReturnRows(rows)
 				} else {
-					mock.ExpectQuery(`SELECT \* FROM "media" ORDER BY created_at desc LIMIT \$1`).
+					mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$1`).
 						WithArgs(10).
 						WillReturnRows(rows)
 				}
@@ -509,3 +520,60 @@ func TestGetMedia_WithSortingAndFiltering(t *testing.T) {
 		})
 	}
 }
+
+func TestGetMedia_MetadataFilters(t *testing.T) {
+	testCases := []struct {
+		name        string
+		queryParams string
+		where       string
+		args        []driver.Value
+	}{
+		{"MinWidth", "?min_width=800", `width >= \$1`, []driver.Value{800.0}},
+		{"DurationRange", "?min_duration=30&max_duration=90.5", `duration >= \$1 AND duration <= \$2`, []driver.Value{30.0, 90.5}},
+		{"Portrait", "?orientation=portrait&min_height=1000", `height >= \$1 AND width < height`, []driver.Value{1000.0}},
+		{"Camera", "?camera=pixel&type=image", `type = \$1 AND \(metadata->>'camera_make' ILIKE \$2 OR metadata->>'camera_model' ILIKE \$3\)`, []driver.Value{"image", "%pixel%", "%pixel%"}},
+		{"SearchAndSquare", "?search=logo&orientation=square", `\(url ILIKE \$1 OR type ILIKE \$2\) AND width = height AND width > 0`, []driver.Value{"%logo%", "%logo%"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, _, mock := utils.SetupRouterAndMockDB(t)
+			defer mock.ExpectClose()
+
+			mock.ExpectQuery(`SELECT count\(\*\) FROM "media" WHERE \(?` + tc.where + `\)? AND "media"\."deleted_at" IS NULL$`).
+				WithArgs(tc.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+			mock.ExpectQuery(`SELECT \* FROM "media" WHERE \(?` + tc.where + `\)? AND "media"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT`).
+				WithArgs(append(tc.args, 10)...).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "url", "type", "created_at", "updated_at"}))
+
+			router.GET("/media", GetMedia)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/media"+tc.queryParams, nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200 for %s, got %d: %s", tc.name, w.Code, w.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("Unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetMedia_InvalidMetadataFilters(t *testing.T) {
+	for _, query := range []string{"?min_width=wide", "?max_duration=-1", "?orientation=diagonal"} {
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+
+		router.GET("/media", GetMedia)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/media"+query, nil)
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+		mock.ExpectClose()
+	}
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// missingAltTextError is the 422 body of a post refused by the alt text policy
+type missingAltTextError struct {
+	utils.HTTPError
+	MediaIDs []uint `json:"media_ids"`
+}
+
+// altTextRequired reads MEDIA_REQUIRE_ALT_TEXT; when set, posts cannot attach
+// images without alt text
+func altTextRequired() bool {
+	required, _ := strconv.ParseBool(os.Getenv("MEDIA_REQUIRE_ALT_TEXT"))
+	return required
+}
+
+// isImage reports whether media is shown as an image, by its type or sniffed MIME type
+func isImage(media *models.Media) bool {
+	return media.Type == "image" || strings.HasPrefix(media.MimeType, "image/")
+}
+
+// checkAltText enforces the alt text policy on the media a post is about to
+// attach. Media in attached, the post's current attachments, is let through so
+// posts written before the policy can still be edited. It writes 422 listing
+// the offending media and returns false when the policy is not met.
+func checkAltText(c *gin.Context, media, attached []models.Media) bool {
+	if !altTextRequired() {
+		return true
+	}
+	existing := make(map[uint]bool, len(attached))
+	for _, m := range attached {
+		existing[m.ID] = true
+	}
+	missing := []uint{}
+	for i := range media {
+		if isImage(&media[i]) && strings.TrimSpace(media[i].AltText) == "" && !existing[media[i].ID] {
+			missing = append(missing, media[i].ID)
+		}
+	}
+	if len(missing) == 0 {
+		return true
+	}
+	c.JSON(http.StatusUnprocessableEntity, missingAltTextError{
+		HTTPError: utils.HTTPError{Code: 422, Message: "Images attached to a post need alt text; set it with PUT /media/:id"},
+		MediaIDs:  missing,
+	})
+	return false
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var policyMediaColumns = []string{"id", "url", "type", "mime_type", "alt_text"}
+
+func TestCreatePost_RequiresAltText(t *testing.T) {
+	t.Setenv("MEDIA_REQUIRE_ALT_TEXT", "true")
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" IN \(\$1,\$2,\$3,\$4\)`).
+		WithArgs(4, 5, 6, 7).
+		WillReturnRows(sqlmock.NewRows(policyMediaColumns).
+			AddRow(4, "/api/v1/media/4/file", "image", "image/png", "").
+			AddRow(5, "/api/v1/media/5/file", "image", "image/png", "A red bicycle").
+			AddRow(6, "/api/v1/media/6/file", "document", "application/pdf", "").
+			AddRow(7, "/api/v1/media/7/file", "photo", "image/jpeg", "  "))
+
+	router.POST("/posts", CreatePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"title":"Ride","content":"Content","media_ids":[4,5,6,7]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	var response missingAltTextError
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []uint{4, 7}, response.MediaIDs, "documents need no alt text, blank alt text does not count")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePost_AltTextOnlyForNewAttachments(t *testing.T) {
+	t.Setenv("MEDIA_REQUIRE_ALT_TEXT", "true")
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "status", "version", "created_at", "updated_at"}).
+			AddRow(1, "Ride", "Content", models.StatusDraft, 1, now, now))
+	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}).AddRow(1, 4))
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(policyMediaColumns).AddRow(4, "/api/v1/media/4/file", "image", "image/png", ""))
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" IN \(\$1,\$2\)`).
+		WithArgs(4, 8).
+		WillReturnRows(sqlmock.NewRows(policyMediaColumns).
+			AddRow(4, "/api/v1/media/4/file", "image", "image/png", "").
+			AddRow(8, "/api/v1/media/8/file", "image", "image/png", ""))
+
+	router.PUT("/posts/:id", UpdatePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/posts/1", strings.NewReader(`{"title":"Ride","content":"Content","media_ids":[4,8]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	var response missingAltTextError
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []uint{8}, response.MediaIDs, "media attached before the policy is kept")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPost_MediaAccessibilityFields(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "status", "created_at", "updated_at"}).
+			AddRow(1, "Ride", "Content", models.StatusPublished, now, now))
+	expectNoPostCategories(mock)
+	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}).AddRow(1, 5))
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "type", "alt_text", "caption", "credit", "license"}).
+			AddRow(5, "/api/v1/media/5/file", "image", "A red bicycle", "At the harbour", "Jo Doe", "CC BY 4.0"))
+	expectNoPostTags(mock)
+
+	router.GET("/posts/:id", GetPost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/1", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Media []map[string]interface{} `json:"media"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Media, 1)
+	assert.Equal(t, "A red bicycle", response.Media[0]["alt_text"])
+	assert.Equal(t, "At the harbour", response.Media[0]["caption"])
+	assert.Equal(t, "Jo Doe", response.Media[0]["credit"])
+	assert.Equal(t, "CC BY 4.0", response.Media[0]["license"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
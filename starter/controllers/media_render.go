@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"bytes"
+	"cms-backend/imaging"
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/storage"
+	"cms-backend/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxRenderWidth        = 4096
+	defaultMaxImagePixels = 40_000_000
+)
+
+// renditionPreset is a named variant generated as soon as an image is uploaded.
+// An empty format keeps the source's format.
+type renditionPreset struct {
+	name   string
+	width  int
+	format string
+}
+
+var renditionPresets = []renditionPreset{
+	{"thumbnail", 150, ""},
+	{"medium", 300, ""},
+	{"large", 1024, ""},
+	{"webp", 1024, imaging.FormatWebP},
+}
+
+// presetWidth reports whether width is that of a rendition preset
+func presetWidth(width int) bool {
+	for _, preset := range renditionPresets {
+		if preset.width == width {
+			return true
+		}
+	}
+	return false
+}
+
+// presetWidths lists the preset widths for error messages, e.g. "150, 300, 1024"
+func presetWidths() string {
+	var widths []string
+	for _, preset := range renditionPresets {
+		if width := strconv.Itoa(preset.width); !slices.Contains(widths, width) {
+			widths = append(widths, width)
+		}
+	}
+	return strings.Join(widths, ", ")
+}
+
+// maxImagePixels reads MEDIA_MAX_IMAGE_PIXELS, the largest image that will be decoded
+func maxImagePixels() int {
+	if pixels, err := strconv.Atoi(os.Getenv("MEDIA_MAX_IMAGE_PIXELS")); err == nil && pixels > 0 {
+		return pixels
+	}
+	return defaultMaxImagePixels
+}
+
+func renditionURL(mediaID uint, width int, format string) string {
+	return fmt.Sprintf("/api/v1/media/%d/render?w=%d&fmt=%s", mediaID, width, format)
+}
+
+// renditionKey stores a rendition next to its original, e.g. 2024/05/abc.png
+// becomes 2024/05/abc-w300.webp
+func renditionKey(key string, width int, format string) string {
+	return fmt.Sprintf("%s-w%d.%s", strings.TrimSuffix(key, path.Ext(key)), width, imaging.Extension(format))
+}
+
+// renditionFilename names a rendition after the uploaded file
+func renditionFilename(original string, width int, format string) string {
+	if original == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-w%d.%s", strings.TrimSuffix(original, path.Ext(original)), width, imaging.Extension(format))
+}
+
+// storeRendition resizes src, encodes it and stores the result. The returned
+// rendition still has to be saved.
+func storeRendition(ctx context.Context, driver storage.Driver, media *models.Media, src image.Image, name string, width int, format string) (*models.MediaRendition, error) {
+	img := imaging.Resize(src, width)
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, format); err != nil {
+		return nil, err
+	}
+	size := img.Bounds().Size()
+	key := renditionKey(media.StorageKey, size.X, format)
+	contentType := imaging.ContentType(format)
+	if err := driver.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), contentType); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return &models.MediaRendition{
+		MediaID:    media.ID,
+		Name:       name,
+		Width:      size.X,
+		Height:     size.Y,
+		Format:     format,
+		MimeType:   contentType,
+		Size:       int64(buf.Len()),
+		Checksum:   hex.EncodeToString(sum[:]),
+		StorageKey: key,
+		URL:        renditionURL(media.ID, size.X, format),
+	}, nil
+}
+
+// saveRendition records a rendition unless a concurrent request already did
+func saveRendition(db *gorm.DB, rendition *models.MediaRendition) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "media_id"}, {Name: "width"}, {Name: "format"}},
+		DoNothing: true,
+	}).Create(rendition).Error
+}
+
+// generatePresets creates the preset renditions for a freshly uploaded image.
+// Sizes at or above the original width are skipped, except for the WebP copy.
+// Failures are logged; the upload itself has already succeeded.
+func generatePresets(ctx context.Context, db *gorm.DB, driver storage.Driver, media *models.Media, src image.Image) {
+	done := make(map[string]bool)
+	for _, preset := range renditionPresets {
+		format := preset.format
+		if format == "" {
+			if media.Width <= preset.width {
+				continue
+			}
+			format = imaging.DefaultFormat(media.MimeType)
+		}
+		width := min(preset.width, media.Width)
+		variant := fmt.Sprintf("%d.%s", width, format)
+		if done[variant] {
+			continue
+		}
+		done[variant] = true
+
+		rendition, err := storeRendition(ctx, driver, media, src, preset.name, width, format)
+		if err == nil {
+			err = saveRendition(db, rendition)
+		}
+		if err != nil {
+			log.Printf("Failed to generate %s rendition of media %d: %v", preset.name, media.ID, err)
+			continue
+		}
+		media.Renditions = append(media.Renditions, *rendition)
+	}
+}
+
+// mediaDriver returns the storage driver holding an uploaded file, answering
+// 503 when it is not the configured one
+func mediaDriver(c *gin.Context, media *models.Media) (storage.Driver, bool) {
+	driver := storage.Default()
+	if driver == nil || driver.Name() != media.StorageDriver {
+		c.JSON(http.StatusServiceUnavailable, utils.HTTPError{Code: 503, Message: "Storage for this media is not available"})
+		return nil, false
+	}
+	return driver, true
+}
+
+// decodeStoredImage opens and decodes the original of an uploaded image and
+// turns it upright, writing the error response on failure
+func decodeStoredImage(c *gin.Context, driver storage.Driver, media *models.Media) (image.Image, bool) {
+	object, err := driver.Open(c.Request.Context(), media.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Media file not found"})
+		} else {
+			c.JSON(http.StatusBadGateway, utils.HTTPError{Code: 502, Message: err.Error()})
+		}
+		return nil, false
+	}
+	defer object.Close()
+	img, err := imaging.Decode(object, maxImagePixels())
+	if err != nil {
+		message := "Failed to decode image: " + err.Error()
+		if errors.Is(err, imaging.ErrTooLarge) {
+			message = "Image is too large to render"
+		}
+		c.JSON(http.StatusUnprocessableEntity, utils.HTTPError{Code: 422, Message: message})
+		return nil, false
+	}
+	return imaging.Orient(img, mediaOrientation(media)), true
+}
+
+// RenderMedia serves an uploaded image scaled to ?w= pixels wide and encoded as
+// ?fmt= (jpeg, png or webp). Each variant is generated once, stored next to the
+// original and listed in the media's renditions. Images are never enlarged.
+// Anonymous callers are limited to the preset widths, since every new size
+// costs a decode of the original and a stored file.
+func RenderMedia(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid media ID"})
+		return
+	}
+	width := 0
+	if value := c.Query("w"); value != "" {
+		width, err = strconv.Atoi(value)
+		if err != nil || width < 1 || width > maxRenderWidth {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: fmt.Sprintf("w must be between 1 and %d", maxRenderWidth)})
+			return
+		}
+		if _, authenticated := middleware.CurrentUser(c); !authenticated && !presetWidth(width) {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "w must be one of " + presetWidths() + " unless authenticated"})
+			return
+		}
+	}
+	var media models.Media
+	if err := db.First(&media, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Media not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	if media.StorageKey == "" || !imaging.Decodable(media.MimeType) {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Only uploaded JPEG, PNG, GIF and WebP images can be rendered"})
+		return
+	}
+	format := imaging.DefaultFormat(media.MimeType)
+	if value := c.Query("fmt"); value != "" {
+		var ok bool
+		if format, ok = imaging.ParseFormat(value); !ok {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "fmt must be jpeg, png or webp"})
+			return
+		}
+	}
+	driver, ok := mediaDriver(c, &media)
+	if !ok {
+		return
+	}
+	var src image.Image
+	if media.Width == 0 {
+		// Uploaded before dimensions were recorded: decode once and keep them so
+		// later requests find the stored rendition without decoding
+		if src, ok = decodeStoredImage(c, driver, &media); !ok {
+			return
+		}
+		size := src.Bounds().Size()
+		if err := db.Model(&media).UpdateColumns(map[string]interface{}{"width": size.X, "height": size.Y}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+		media.Width, media.Height = size.X, size.Y
+	}
+	if width == 0 || width > media.Width {
+		width = media.Width
+	}
+
+	var rendition models.MediaRendition
+	err = db.Where("media_id = ? AND width = ? AND format = ?", media.ID, width, format).First(&rendition).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err == gorm.ErrRecordNotFound {
+		if src == nil {
+			if src, ok = decodeStoredImage(c, driver, &media); !ok {
+				return
+			}
+		}
+		created, err := storeRendition(c.Request.Context(), driver, &media, src, "", width, format)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, utils.HTTPError{Code: 502, Message: "Failed to store rendition: " + err.Error()})
+			return
+		}
+		if err := saveRendition(db, created); err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+		middleware.InvalidateMedia(media.ID)
+		rendition = *created
+	}
+
+	serveStoredFile(c, driver, storedFile{
+		key:         rendition.StorageKey,
+		contentType: rendition.MimeType,
+		checksum:    rendition.Checksum,
+		filename:    renditionFilename(media.OriginalFilename, rendition.Width, rendition.Format),
+		modTime:     rendition.CreatedAt,
+	})
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"bytes"
+	"cms-backend/middleware"
+	"cms-backend/utils"
+	"database/sql/driver"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectNoRenditions expects the renditions preload for loaded media
+func expectNoRenditions(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "media_renditions" WHERE "media_renditions"\."media_id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "media_id"}))
+}
+
+// expectRenditionInsert expects a rendition to be recorded
+func expectRenditionInsert(mock sqlmock.Sqlmock, mediaID uint, name string, width, height int, format string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "media_renditions" .* ON CONFLICT \("media_id","width","format"\) DO NOTHING RETURNING "id"`).
+		WithArgs(mediaID, name, width, height, format, "image/"+format, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+// storedImageRow is the media row for testPNG stored at 2024/05/abc.png
+func storedImageRow(width int) []driver.Value {
+	now := time.Now()
+	return []driver.Value{5, "/api/v1/media/5/file", "image", "logo.png", "image/png", len(testPNG), "abc123", width, 100, "local", "2024/05/abc.png", 1, now, now}
+}
+
+var storedImageColumns = []string{"id", "url", "type", "original_filename", "mime_type", "size", "checksum", "width", "height", "storage_driver", "storage_key", "version", "created_at", "updated_at"}
+
+func TestRenderMedia(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	root := useLocalStorage(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "2024", "05"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "2024", "05", "abc.png"), testPNG, 0o644))
+	router.GET("/media/:id/render", RenderMedia)
+	// Widths outside the presets need a signed-in caller
+	router.GET("/editor/media/:id/render", func(c *gin.Context) {
+		middleware.SetCurrentUser(c, &utils.Claims{UserID: 1, Username: "tester", Role: "editor"})
+	}, RenderMedia)
+
+	t.Run("Generates", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows(storedImageColumns).AddRow(storedImageRow(200)...))
+		mock.ExpectQuery(`SELECT \* FROM "media_renditions" WHERE media_id = \$1 AND width = \$2 AND format = \$3`).
+			WithArgs(5, 80, "webp", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		expectRenditionInsert(mock, 5, "", 80, 40, "webp")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/editor/media/5/render?w=80&fmt=webp", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "image/webp", w.Header().Get("Content-Type"))
+		assert.Equal(t, `inline; filename=logo-w80.webp`, w.Header().Get("Content-Disposition"))
+		assert.NotEmpty(t, w.Header().Get("ETag"))
+		config, format, err := image.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, "webp", format)
+		assert.Equal(t, 80, config.Width)
+		assert.FileExists(t, filepath.Join(root, "2024", "05", "abc-w80.webp"))
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows(storedImageColumns).AddRow(storedImageRow(200)...))
+		// Wider than the original, so the full-size rendition is served
+		mock.ExpectQuery(`SELECT \* FROM "media_renditions" WHERE media_id = \$1 AND width = \$2 AND format = \$3`).
+			WithArgs(5, 200, "png", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "media_id", "width", "height", "format", "mime_type", "checksum", "storage_key"}).
+				AddRow(2, 5, 200, 100, "png", "image/png", "def456", "2024/05/abc.png"))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/media/5/render?w=1024", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, `"def456"`, w.Header().Get("ETag"))
+		assert.Equal(t, testPNG, w.Body.Bytes())
+	})
+
+	t.Run("UnknownDimensions", func(t *testing.T) {
+		// The dimensions are recorded so the next request finds the rendition
+		// without decoding the original again
+		mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows(storedImageColumns).AddRow(storedImageRow(0)...))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "media" SET "height"=\$1,"width"=\$2 WHERE`).
+			WithArgs(100, 200, 5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery(`SELECT \* FROM "media_renditions" WHERE media_id = \$1 AND width = \$2 AND format = \$3`).
+			WithArgs(5, 200, "png", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		expectRenditionInsert(mock, 5, "", 200, 100, "png")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/media/5/render", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.FileExists(t, filepath.Join(root, "2024", "05", "abc-w200.png"))
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRenderMedia_BadRequests(t *testing.T) {
+	cases := map[string]string{
+		"ZeroWidth":     "/media/5/render?w=0",
+		"HugeWidth":     "/media/5/render?w=10000",
+		"CustomWidth":   "/media/5/render?w=80",
+		"NotANumber":    "/media/5/render?w=abc",
+		"UnknownFormat": "/media/5/render?w=100&fmt=tiff",
+	}
+	for name, path := range cases {
+		t.Run(name, func(t *testing.T) {
+			router, _, mock := utils.SetupRouterAndMockDB(t)
+			defer mock.ExpectClose()
+			mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+				WillReturnRows(sqlmock.NewRows(storedImageColumns).AddRow(storedImageRow(200)...))
+
+			router.GET("/media/:id/render", RenderMedia)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestRenderMedia_NotAnImage(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "type"}).AddRow(2, "https://example.com/a.jpg", "image"))
+
+	router.GET("/media/:id/render", RenderMedia)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/media/2/render?w=100", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDeleteMedia_KeepsFilesInTrash(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	root := useLocalStorage(t)
+	for _, name := range []string{"abc.png", "abc-w150.png"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "2024", "05"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "2024", "05", name), testPNG, 0o644))
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(storedImageColumns).AddRow(storedImageRow(200)...))
+	mock.ExpectQuery(`SELECT \* FROM "media_renditions" WHERE "media_renditions"\."media_id" = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "media_id", "storage_key"}).AddRow(1, 5, "2024/05/abc-w150.png"))
+	expectNoUsages(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "media" SET "deleted_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.DELETE("/media/:id", DeleteMedia)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/media/5", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.FileExists(t, filepath.Join(root, "2024", "05", "abc.png"), "files are kept until the media is purged")
+	assert.FileExists(t, filepath.Join(root, "2024", "05", "abc-w150.png"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"bytes"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateMedia(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	expectStoredMedia(mock, true)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "media" SET "alt_text"=\$1,"caption"=\$2,"title"=\$3,"version"=\$4,"updated_at"=\$5 WHERE version = \$6 AND "media"\."deleted_at" IS NULL AND "id" = \$7`).
+		WithArgs("Company logo", "", "Logo", 2, sqlmock.AnyArg(), 1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.PUT("/media/:id", UpdateMedia)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/media/5", bytes.NewBufferString(`{"title":"Logo","alt_text":"Company logo","caption":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"media-5-v1"`)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Logo", response["title"])
+	assert.Equal(t, "Company logo", response["alt_text"])
+	assert.Equal(t, "image", response["type"], "fields left out are kept")
+	assert.Equal(t, `"media-5-v2"`, w.Header().Get("ETag"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMedia_BadRequests(t *testing.T) {
+	cases := map[string]struct {
+		body    string
+		message string
+	}{
+		"UploadedURL": {`{"url":"http://example.com/other.png"}`, "replace the file instead"},
+		"EmptyType":   {`{"type":""}`, "Validation failed"},
+		"LongAltText": {`{"alt_text":"` + string(bytes.Repeat([]byte("a"), 501)) + `"}`, "Validation failed"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			router, _, mock := utils.SetupRouterAndMockDB(t)
+			defer mock.ExpectClose()
+			expectStoredMedia(mock, true)
+
+			router.PUT("/media/:id", UpdateMedia)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/media/5", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.message)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateMedia_StaleVersion(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	expectStoredMedia(mock, true)
+
+	router.PUT("/media/:id", UpdateMedia)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/media/5", bytes.NewBufferString(`{"title":"Logo"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"media-5-v0"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceMediaFile(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	root := useLocalStorage(t)
+	dir := filepath.Join(root, "2024", "05")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	for _, name := range []string{"abc.png", "abc-w150.png"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), testPNG, 0o644))
+	}
+	replacement := encodeTestPNG(180, 90)
+
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(storedImageColumns).AddRow(storedImageRow(200)...))
+	mock.ExpectQuery(`SELECT \* FROM "media_renditions" WHERE "media_renditions"\."media_id" = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "media_id", "storage_key"}).AddRow(1, 5, "2024/05/abc-w150.png"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "media_renditions" WHERE media_id = \$1`).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "media" SET .* WHERE version = \$\d+ AND "media"\."deleted_at" IS NULL AND "id" = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+	expectRenditionInsert(mock, 5, "thumbnail", 150, 75, "png")
+	expectRenditionInsert(mock, 5, "webp", 180, 90, "webp")
+
+	router.PUT("/media/:id/file", ReplaceMediaFile)
+	w := httptest.NewRecorder()
+	req := multipartUpload(t, "banner.png", replacement, nil)
+	req.Method, req.URL.Path = http.MethodPut, "/media/5/file"
+	req.Header.Set("If-Match", `"media-5-v1"`)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.EqualValues(t, 5, response["id"], "the ID is kept")
+	assert.Equal(t, "/api/v1/media/5/file", response["url"])
+	assert.Equal(t, "banner.png", response["original_filename"])
+	assert.EqualValues(t, 180, response["width"])
+	assert.Len(t, response["renditions"], 2)
+	assert.Equal(t, `"media-5-v2"`, w.Header().Get("ETag"))
+
+	assert.NoFileExists(t, filepath.Join(dir, "abc.png"), "the old file is removed")
+	assert.NoFileExists(t, filepath.Join(dir, "abc-w150.png"), "so are its renditions")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplaceMediaFile_WriteFailureKeepsOldFile(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	root := useLocalStorage(t)
+	dir := filepath.Join(root, "2024", "05")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "abc.png"), testPNG, 0o644))
+
+	expectStoredMedia(mock, true)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "media_renditions"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "media"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	router.PUT("/media/:id/file", ReplaceMediaFile)
+	w := httptest.NewRecorder()
+	req := multipartUpload(t, "banner.png", encodeTestPNG(180, 90), nil)
+	req.Method, req.URL.Path = http.MethodPut, "/media/5/file"
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code, "a concurrent write wins")
+	assert.FileExists(t, filepath.Join(dir, "abc.png"))
+	var stored []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			stored = append(stored, path)
+		}
+		return nil
+	})
+	assert.Len(t, stored, 1, "the new upload is removed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"cms-backend/imaging"
+	"cms-backend/metadata"
+	"cms-backend/models"
+	"cms-backend/storage"
+	"cms-backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxUploadSize = 50 << 20
+	defaultAllowedTypes  = "image/jpeg,image/png,image/gif,image/webp,video/*,audio/*,application/pdf"
+)
+
+// maxUploadSize reads MEDIA_MAX_UPLOAD_SIZE in bytes
+func maxUploadSize() int64 {
+	if size, err := strconv.ParseInt(os.Getenv("MEDIA_MAX_UPLOAD_SIZE"), 10, 64); err == nil && size > 0 {
+		return size
+	}
+	return defaultMaxUploadSize
+}
+
+// allowedUploadTypes reads MEDIA_ALLOWED_TYPES, a comma separated list such as "image/*,application/pdf"
+func allowedUploadTypes() []string {
+	value := os.Getenv("MEDIA_ALLOWED_TYPES")
+	if value == "" {
+		value = defaultAllowedTypes
+	}
+	return strings.Split(value, ",")
+}
+
+// stripGPSByDefault reads MEDIA_STRIP_GPS; uploads can override it with the strip_gps field
+func stripGPSByDefault() bool {
+	strip, _ := strconv.ParseBool(os.Getenv("MEDIA_STRIP_GPS"))
+	return strip
+}
+
+// applyMetadata copies what was read from an uploaded file onto its media row
+func applyMetadata(media *models.Media, info metadata.Info) {
+	media.Width, media.Height = info.Width, info.Height
+	media.Duration, media.Bitrate = info.Duration, info.Bitrate
+	media.Metadata = nil
+	if info.EXIF == nil {
+		return
+	}
+	exif := info.EXIF
+	media.Metadata = &models.MediaMetadata{
+		CameraMake:   exif.Make,
+		CameraModel:  exif.Model,
+		LensModel:    exif.LensModel,
+		TakenAt:      exif.TakenAt,
+		Orientation:  exif.Orientation,
+		ExposureTime: exif.ExposureTime,
+		FNumber:      exif.FNumber,
+		ISO:          exif.ISO,
+		FocalLength:  exif.FocalLength,
+	}
+	if exif.GPS != nil {
+		media.Metadata.GPS = &models.GPSPoint{Latitude: exif.GPS.Latitude, Longitude: exif.GPS.Longitude, Altitude: exif.GPS.Altitude}
+	}
+}
+
+// mediaOrientation is the EXIF orientation renditions are turned upright by
+func mediaOrientation(media *models.Media) int {
+	if media.Metadata == nil {
+		return 0
+	}
+	return media.Metadata.Orientation
+}
+
+// mediaTypeFor derives the coarse models.Media Type from a MIME type
+func mediaTypeFor(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return "image"
+	case strings.HasPrefix(contentType, "video/"):
+		return "video"
+	case strings.HasPrefix(contentType, "audio/"):
+		return "audio"
+	case contentType == "application/pdf":
+		return "document"
+	}
+	return "file"
+}
+
+// mediaFileURL is where an uploaded file is served from
+func mediaFileURL(id uint) string {
+	return fmt.Sprintf("/api/v1/media/%d/file", id)
+}
+
+func respondUploadError(c *gin.Context, err error) {
+	var maxBytes *http.MaxBytesError
+	if errors.Is(err, storage.ErrTooLarge) || errors.As(err, &maxBytes) {
+		c.JSON(http.StatusRequestEntityTooLarge, utils.HTTPError{Code: 413, Message: fmt.Sprintf("File exceeds the %d byte upload limit", maxUploadSize())})
+		return
+	}
+	c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Failed to read upload: " + err.Error()})
+}
+
+// uploadForm is a multipart upload: the spooled file and the fields sent with it
+type uploadForm struct {
+	file      *storage.Spooled
+	filename  string
+	mediaType string
+	stripGPS  bool
+}
+
+func (f *uploadForm) Close() {
+	f.file.Close()
+}
+
+// readUploadForm spools the "file" part of a multipart/form-data body and reads
+// the "type" and "strip_gps" fields, writing the error response on failure.
+// The file's sniffed type must be allowed. The caller must Close the result.
+func readUploadForm(c *gin.Context) (*uploadForm, bool) {
+	limit := maxUploadSize()
+	// Leave room for the multipart framing and small form fields
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+1<<20)
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Expected a multipart/form-data body"})
+		return nil, false
+	}
+
+	form := &uploadForm{stripGPS: stripGPSByDefault()}
+	fail := func() (*uploadForm, bool) {
+		if form.file != nil {
+			form.file.Close()
+		}
+		return nil, false
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			respondUploadError(c, err)
+			return fail()
+		}
+		switch part.FormName() {
+		case "file":
+			if form.file != nil {
+				part.Close()
+				c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Only one file can be uploaded at a time"})
+				return fail()
+			}
+			form.filename = filepath.Base(part.FileName())
+			form.file, err = storage.Spool(part, limit)
+			if err != nil {
+				part.Close()
+				respondUploadError(c, err)
+				return fail()
+			}
+		case "type":
+			value, err := io.ReadAll(io.LimitReader(part, 51))
+			if err != nil {
+				part.Close()
+				respondUploadError(c, err)
+				return fail()
+			}
+			form.mediaType = strings.TrimSpace(string(value))
+		case "strip_gps":
+			value, err := io.ReadAll(io.LimitReader(part, 10))
+			if err != nil {
+				part.Close()
+				respondUploadError(c, err)
+				return fail()
+			}
+			if form.stripGPS, err = strconv.ParseBool(strings.TrimSpace(string(value))); err != nil {
+				part.Close()
+				c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "strip_gps must be true or false"})
+				return fail()
+			}
+		}
+		part.Close()
+	}
+	if form.file == nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Missing file field"})
+		return fail()
+	}
+	if len(form.mediaType) > 50 {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Type must be at most 50 characters"})
+		return fail()
+	}
+	if !storage.TypeAllowed(form.file.ContentType, allowedUploadTypes()) {
+		c.JSON(http.StatusUnsupportedMediaType, utils.HTTPError{Code: 415, Message: "File type " + form.file.ContentType + " is not allowed"})
+		return fail()
+	}
+	return form, true
+}
+
+// inspectUpload reads the metadata of an upload, blanking its GPS coordinates
+// first when the form asks for it. A file whose metadata cannot be read is
+// still stored, it just gets no dimensions, duration or renditions.
+func inspectUpload(c *gin.Context, form *uploadForm) (metadata.Info, bool) {
+	upload := form.file
+	info, _ := metadata.Extract(upload.File, upload.Size, upload.ContentType)
+	if form.stripGPS && info.EXIF != nil && info.EXIF.GPS != nil {
+		stripped, err := metadata.StripGPS(upload.File, upload.Size)
+		if err == nil && stripped {
+			err = upload.Rehash()
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: "Failed to strip GPS data: " + err.Error()})
+			return info, false
+		}
+		info.EXIF.GPS = nil
+	}
+	return info, true
+}
+
+// storeUpload puts an upload under a new key
+func storeUpload(c *gin.Context, driver storage.Driver, upload *storage.Spooled) (string, bool) {
+	key, err := storage.NewKey(time.Now(), upload.ContentType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return "", false
+	}
+	if err := driver.Put(c.Request.Context(), key, upload.File, upload.Size, upload.ContentType); err != nil {
+		c.JSON(http.StatusBadGateway, utils.HTTPError{Code: 502, Message: "Failed to store file: " + err.Error()})
+		return "", false
+	}
+	return key, true
+}
+
+// removeOrphanedUpload deletes a stored upload whose media row was never saved
+func removeOrphanedUpload(ctx context.Context, driver storage.Driver, key string) {
+	if err := driver.Delete(ctx, key); err != nil {
+		log.Printf("Failed to remove orphaned upload %s: %v", key, err)
+	}
+}
+
+// renderPresets decodes a freshly stored image from its upload and generates
+// its preset renditions
+func renderPresets(ctx context.Context, db *gorm.DB, driver storage.Driver, media *models.Media, upload *storage.Spooled) {
+	if !imaging.Decodable(media.MimeType) || media.Width == 0 || media.Width*media.Height > maxImagePixels() {
+		return
+	}
+	if _, err := upload.File.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	src, err := imaging.Decode(upload.File, maxImagePixels())
+	if err != nil {
+		log.Printf("Failed to decode media %d for renditions: %v", media.ID, err)
+		return
+	}
+	generatePresets(ctx, db, driver, media, imaging.Orient(src, mediaOrientation(media)))
+}
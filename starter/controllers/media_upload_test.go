@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"bytes"
+	"cms-backend/models"
+	"cms-backend/storage"
+	"cms-backend/utils"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPNG is a 200x100 image, wide enough for the thumbnail preset only
+var testPNG = encodeTestPNG(200, 100)
+
+func encodeTestPNG(width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x), uint8(y), 128, 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// useLocalStorage installs a local driver rooted in a temp directory for the test
+func useLocalStorage(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	driver, err := storage.NewLocal(root, "")
+	require.NoError(t, err)
+	storage.SetDefault(driver)
+	t.Cleanup(func() { storage.SetDefault(nil) })
+	return root
+}
+
+func multipartUpload(t *testing.T, filename string, content []byte, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	part.Write(content)
+	require.NoError(t, writer.Close())
+	req, _ := http.NewRequest(http.MethodPost, "/media/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadMedia(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	root := useLocalStorage(t)
+
+	sum := sha256.Sum256(testPNG)
+	checksum := hex.EncodeToString(sum[:])
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "media"`).
+		WithArgs(sqlmock.AnyArg(), "image", "", "", "", "", "", "logo.png", "image/png", len(testPNG), checksum, 200, 100, 0.0, 0, nil, "local", sqlmock.AnyArg(), 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec(`UPDATE "media" SET "url"=\$1 WHERE "media"\."deleted_at" IS NULL AND "id" = \$2`).
+		WithArgs("/api/v1/media/5/file", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+	expectRenditionInsert(mock, 5, "thumbnail", 150, 75, "png")
+	expectRenditionInsert(mock, 5, "webp", 200, 100, "webp")
+
+	router.POST("/media/upload", UploadMedia)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, multipartUpload(t, "../logo.png", testPNG, nil))
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "/api/v1/media/5/file", response["url"])
+	assert.Equal(t, "image/png", response["mime_type"])
+	assert.Equal(t, checksum, response["checksum"])
+	assert.NotContains(t, response, "storage_key")
+	assert.Equal(t, `"media-5-v1"`, w.Header().Get("ETag"))
+	assert.EqualValues(t, 200, response["width"])
+	renditions, _ := response["renditions"].([]interface{})
+	require.Len(t, renditions, 2)
+	assert.Equal(t, "/api/v1/media/5/render?w=150&fmt=png", renditions[0].(map[string]interface{})["url"])
+
+	var stored []string
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			stored = append(stored, path)
+		}
+		return nil
+	})
+	// Walk order is lexical: abc-w150.png, abc-w200.webp, abc.png
+	require.Len(t, stored, 3)
+	assert.Regexp(t, `-w150\.png$`, stored[0])
+	assert.Regexp(t, `-w200\.webp$`, stored[1])
+	data, _ := os.ReadFile(stored[2])
+	assert.Equal(t, testPNG, data)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// exifJPEG is a width x height JPEG whose EXIF says it was taken by a Pixel 8
+// held upright (orientation 6) at 48.8584 N, 2.2945 E
+func exifJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	le := binary.LittleEndian
+	entry := func(buf []byte, tag, typ uint16, count, value uint32) []byte {
+		buf = le.AppendUint16(le.AppendUint16(buf, tag), typ)
+		return le.AppendUint32(le.AppendUint32(buf, count), value)
+	}
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	// IFD0 at 8 with its model string at 50, GPS IFD at 58 with its values at 112 and 136
+	tiff = le.AppendUint16(tiff, 3)
+	tiff = entry(tiff, 0x0110, 2, 8, 50)
+	tiff = entry(tiff, 0x0112, 3, 1, 6)
+	tiff = entry(tiff, 0x8825, 4, 1, 58)
+	tiff = append(le.AppendUint32(tiff, 0), "Pixel 8\x00"...)
+	tiff = le.AppendUint16(tiff, 4)
+	tiff = entry(tiff, 1, 2, 2, 'N')
+	tiff = entry(tiff, 2, 5, 3, 112)
+	tiff = entry(tiff, 3, 2, 2, 'E')
+	tiff = entry(tiff, 4, 5, 3, 136)
+	tiff = le.AppendUint32(tiff, 0)
+	for _, value := range []uint32{48, 1, 51, 1, 3024, 100, 2, 1, 17, 1, 4020, 100} {
+		tiff = le.AppendUint32(tiff, value)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil))
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	file := binary.BigEndian.AppendUint16([]byte{0xFF, 0xD8, 0xFF, 0xE1}, uint16(len(segment)+2))
+	return append(append(file, segment...), buf.Bytes()[2:]...)
+}
+
+func TestUploadMedia_EXIF(t *testing.T) {
+	for _, strip := range []bool{false, true} {
+		t.Run(fmt.Sprintf("StripGPS=%v", strip), func(t *testing.T) {
+			router, _, mock := utils.SetupRouterAndMockDB(t)
+			defer mock.ExpectClose()
+			root := useLocalStorage(t)
+			photo := exifJPEG(t, 40, 20)
+
+			// Rotated to portrait, so the dimensions are swapped
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO "media"`).
+				WithArgs(sqlmock.AnyArg(), "image", "", "", "", "", "", "photo.jpg", "image/jpeg", len(photo), sqlmock.AnyArg(), 20, 40, 0.0, 0, sqlmock.AnyArg(), "local", sqlmock.AnyArg(), 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+			mock.ExpectExec(`UPDATE "media" SET "url"`).WillReturnResult(sqlmock.NewResult(0, 1))
+			expectAudit(mock)
+			expectOutboxEvent(mock)
+			mock.ExpectCommit()
+			expectRenditionInsert(mock, 6, "webp", 20, 40, "webp")
+
+			router.POST("/media/upload", UploadMedia)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, multipartUpload(t, "photo.jpg", photo, map[string]string{"strip_gps": strconv.FormatBool(strip)}))
+
+			require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+			var response struct {
+				Checksum string               `json:"checksum"`
+				Metadata models.MediaMetadata `json:"metadata"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "Pixel 8", response.Metadata.CameraModel)
+			assert.Equal(t, 6, response.Metadata.Orientation)
+
+			stored, err := filepath.Glob(filepath.Join(root, "*", "*", "*.jpg"))
+			require.NoError(t, err)
+			require.Len(t, stored, 1)
+			data, err := os.ReadFile(stored[0])
+			require.NoError(t, err)
+			sum := sha256.Sum256(data)
+			assert.Equal(t, hex.EncodeToString(sum[:]), response.Checksum, "the checksum matches the stored file")
+			coordinates := string(binary.LittleEndian.AppendUint32(nil, 3024))
+			if strip {
+				assert.Nil(t, response.Metadata.GPS)
+				assert.NotContains(t, string(data), coordinates)
+				assert.Len(t, data, len(photo))
+			} else {
+				require.NotNil(t, response.Metadata.GPS)
+				assert.InDelta(t, 48.8584, response.Metadata.GPS.Latitude, 1e-4)
+				assert.InDelta(t, 2.2945, response.Metadata.GPS.Longitude, 1e-4)
+				assert.Equal(t, photo, data)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("InvalidStripGPS", func(t *testing.T) {
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+		defer mock.ExpectClose()
+		useLocalStorage(t)
+
+		router.POST("/media/upload", UploadMedia)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, multipartUpload(t, "photo.jpg", testPNG, map[string]string{"strip_gps": "maybe"}))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestUploadMedia_DisallowedType(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	useLocalStorage(t)
+
+	router.POST("/media/upload", UploadMedia)
+	w := httptest.NewRecorder()
+	// The claimed extension does not matter, the content is sniffed
+	router.ServeHTTP(w, multipartUpload(t, "photo.png", []byte("<html><script>alert(1)</script></html>"), nil))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadMedia_TooLarge(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	useLocalStorage(t)
+	t.Setenv("MEDIA_MAX_UPLOAD_SIZE", "16")
+
+	router.POST("/media/upload", UploadMedia)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, multipartUpload(t, "logo.png", append(testPNG, make([]byte, 64)...), nil))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestUploadMedia_MissingFile(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	useLocalStorage(t)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("type", "image")
+	writer.Close()
+	req, _ := http.NewRequest(http.MethodPost, "/media/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	router.POST("/media/upload", UploadMedia)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUploadMedia_NoStorage(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	storage.SetDefault(nil)
+
+	router.POST("/media/upload", UploadMedia)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, multipartUpload(t, "logo.png", testPNG, nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestServeMediaFile(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	root := useLocalStorage(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "2024", "05"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "2024", "05", "abc.png"), testPNG, 0o644))
+
+	now := time.Now()
+	columns := []string{"id", "url", "type", "original_filename", "mime_type", "size", "checksum", "storage_driver", "storage_key", "version", "created_at", "updated_at"}
+	row := []driver.Value{5, "/api/v1/media/5/file", "image", "logo.png", "image/png", len(testPNG), "abc123", "local", "2024/05/abc.png", 1, now, now}
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows(columns).AddRow(row...))
+	}
+	router.GET("/media/:id/file", ServeMediaFile)
+
+	t.Run("Streams", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/media/5/file", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, testPNG, w.Body.Bytes())
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.Equal(t, `"abc123"`, w.Header().Get("ETag"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, `inline; filename=logo.png`, w.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/media/5/file", nil)
+		req.Header.Set("Range", "bytes=1-3")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPartialContent, w.Code)
+		assert.Equal(t, "PNG", w.Body.String())
+	})
+
+	t.Run("NotModified", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/media/5/file", nil)
+		req.Header.Set("If-None-Match", `"abc123"`)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServeMediaFile_ExternalURL(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "type"}).AddRow(2, "https://example.com/a.jpg", "image"))
+
+	router.GET("/media/:id/file", ServeMediaFile)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/media/2/file", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/a.jpg", w.Header().Get("Location"))
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// How a post or page uses a media item
+const (
+	referenceAttachment = "attachment"
+	referenceContent    = "content"
+)
+
+// MediaUsage is a post or page that attaches a media item or embeds it in its content
+type MediaUsage struct {
+	ResourceType string   `json:"resource_type"`
+	ResourceID   uint     `json:"resource_id"`
+	Title        string   `json:"title"`
+	Slug         string   `json:"slug"`
+	Status       string   `json:"status"`
+	References   []string `json:"references"`
+}
+
+// mediaInUseError is the 409 body of a refused delete
+type mediaInUseError struct {
+	utils.HTTPError
+	Usages []MediaUsage `json:"usages"`
+}
+
+type usageRow struct {
+	ID     uint
+	Title  string
+	Slug   string
+	Status string
+}
+
+// likeEscaper makes a string match itself literally in a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// mediaContentPatterns are LIKE patterns for content embedding the media: its
+// file and render URLs, or the external URL it was registered with
+func mediaContentPatterns(media *models.Media) []string {
+	patterns := []string{"%" + likeEscaper.Replace(fmt.Sprintf("/api/v1/media/%d/", media.ID)) + "%"}
+	if media.StorageKey == "" && media.URL != "" {
+		patterns = append(patterns, "%"+likeEscaper.Replace(media.URL)+"%")
+	}
+	return patterns
+}
+
+// findMediaUsages lists the posts attaching the media and the posts and pages
+// whose content references it, one entry per resource
+func findMediaUsages(db *gorm.DB, media *models.Media) ([]MediaUsage, error) {
+	usages := []MediaUsage{}
+	seen := make(map[string]int)
+	add := func(resourceType string, rows []usageRow, reference string) {
+		for _, row := range rows {
+			key := fmt.Sprintf("%s:%d", resourceType, row.ID)
+			if i, ok := seen[key]; ok {
+				usages[i].References = append(usages[i].References, reference)
+				continue
+			}
+			seen[key] = len(usages)
+			usages = append(usages, MediaUsage{
+				ResourceType: resourceType,
+				ResourceID:   row.ID,
+				Title:        row.Title,
+				Slug:         row.Slug,
+				Status:       row.Status,
+				References:   []string{reference},
+			})
+		}
+	}
+
+	var attached []usageRow
+	if err := db.Model(&models.Post{}).
+		Select("posts.id, posts.title, posts.slug, posts.status").
+		Joins("JOIN post_media ON post_media.post_id = posts.id").
+		Where("post_media.media_id = ?", media.ID).
+		Order("posts.id").
+		Scan(&attached).Error; err != nil {
+		return nil, err
+	}
+	add("post", attached, referenceAttachment)
+
+	patterns := mediaContentPatterns(media)
+	condition := strings.TrimSuffix(strings.Repeat("content LIKE ? OR ", len(patterns)), " OR ")
+	args := make([]interface{}, len(patterns))
+	for i, pattern := range patterns {
+		args[i] = pattern
+	}
+	for _, resource := range []struct {
+		resourceType string
+		model        interface{}
+	}{{"post", &models.Post{}}, {"page", &models.Page{}}} {
+		var embedded []usageRow
+		if err := db.Model(resource.model).
+			Select("id, title, slug, status").
+			Where(condition, args...).
+			Order("id").
+			Scan(&embedded).Error; err != nil {
+			return nil, err
+		}
+		add(resource.resourceType, embedded, referenceContent)
+	}
+	return usages, nil
+}
+
+// invalidateUsageCaches drops the cached responses showing the posts and
+// pages that use the media, including the lists they appear in
+func invalidateUsageCaches(usages []MediaUsage) error {
+	if len(usages) == 0 {
+		return nil
+	}
+	tags := make([]string, len(usages))
+	for i, usage := range usages {
+		tags[i] = middleware.EntityTag(usage.ResourceType, usage.ResourceID)
+	}
+	return middleware.InvalidateCacheTags(tags...)
+}
+
+// invalidateMediaAndUsages drops the cached responses showing the media and,
+// since post responses embed their media, those of everything using it
+func invalidateMediaAndUsages(db *gorm.DB, media *models.Media) error {
+	if err := middleware.InvalidateMedia(media.ID); err != nil {
+		return err
+	}
+	usages, err := findMediaUsages(db, media)
+	if err != nil {
+		log.Printf("Failed to look up usages of media %d: %v", media.ID, err)
+		if err := middleware.InvalidatePostCache(); err != nil {
+			return err
+		}
+		return middleware.InvalidatePageCache()
+	}
+	return invalidateUsageCaches(usages)
+}
+
+// GetMediaUsages lists every post and page that uses a media item
+func GetMediaUsages(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid media ID"})
+		return
+	}
+	var media models.Media
+	if err := db.First(&media, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Media not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	usages, err := findMediaUsages(db, &media)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": usages, "total": len(usages)})
+}
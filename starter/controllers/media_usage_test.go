@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var usageColumns = []string{"id", "title", "slug", "status"}
+
+// expectNoUsages expects the usage lookups of findMediaUsages to find nothing
+func expectNoUsages(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT posts.id, posts.title, posts.slug, posts.status FROM "posts" JOIN post_media`).
+		WillReturnRows(sqlmock.NewRows(usageColumns))
+	mock.ExpectQuery(`SELECT id, title, slug, status FROM "posts" WHERE \(?content LIKE`).
+		WillReturnRows(sqlmock.NewRows(usageColumns))
+	mock.ExpectQuery(`SELECT id, title, slug, status FROM "pages" WHERE \(?content LIKE`).
+		WillReturnRows(sqlmock.NewRows(usageColumns))
+}
+
+// expectUsages has post 3 attach and embed media 5, and page 8 embed it
+func expectUsages(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT posts.id, posts.title, posts.slug, posts.status FROM "posts" JOIN post_media ON post_media.post_id = posts.id WHERE post_media.media_id = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY posts.id`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(3, "Launch", "launch", "published"))
+	mock.ExpectQuery(`SELECT id, title, slug, status FROM "posts" WHERE content LIKE \$1 AND "posts"\."deleted_at" IS NULL ORDER BY id`).
+		WithArgs(`%/api/v1/media/5/%`).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(3, "Launch", "launch", "published"))
+	mock.ExpectQuery(`SELECT id, title, slug, status FROM "pages" WHERE content LIKE \$1 AND "pages"\."deleted_at" IS NULL ORDER BY id`).
+		WithArgs(`%/api/v1/media/5/%`).
+		WillReturnRows(sqlmock.NewRows(usageColumns).AddRow(8, "About", "about", "draft"))
+}
+
+func expectStoredMedia(mock sqlmock.Sqlmock, preloadRenditions bool) {
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(storedImageColumns).AddRow(storedImageRow(200)...))
+	if preloadRenditions {
+		expectNoRenditions(mock)
+	}
+}
+
+func TestGetMediaUsages(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	expectStoredMedia(mock, false)
+	expectUsages(mock)
+
+	router.GET("/media/:id/usages", GetMediaUsages)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/media/5/usages", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data  []MediaUsage `json:"data"`
+		Total int          `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Total)
+	assert.Equal(t, []MediaUsage{
+		{ResourceType: "post", ResourceID: 3, Title: "Launch", Slug: "launch", Status: "published", References: []string{"attachment", "content"}},
+		{ResourceType: "page", ResourceID: 8, Title: "About", Slug: "about", Status: "draft", References: []string{"content"}},
+	}, response.Data)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMediaUsages_ExternalURL(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "type", "created_at", "updated_at"}).
+			AddRow(2, "https://cdn.example.com/100%_real.jpg", "image", now, now))
+	mock.ExpectQuery(`FROM "posts" JOIN post_media`).WillReturnRows(sqlmock.NewRows(usageColumns))
+	mock.ExpectQuery(`SELECT id, title, slug, status FROM "posts" WHERE \(content LIKE \$1 OR content LIKE \$2\) AND "posts"\."deleted_at" IS NULL ORDER BY id`).
+		WithArgs(`%/api/v1/media/2/%`, `%https://cdn.example.com/100\%\_real.jpg%`).
+		WillReturnRows(sqlmock.NewRows(usageColumns))
+	mock.ExpectQuery(`FROM "pages" WHERE \(content LIKE \$1 OR content LIKE \$2\)`).WillReturnRows(sqlmock.NewRows(usageColumns))
+
+	router.GET("/media/:id/usages", GetMediaUsages)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/media/2/usages", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"data":[],"total":0}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteMedia_InUse(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	expectStoredMedia(mock, true)
+	expectUsages(mock)
+
+	router.DELETE("/media/:id", DeleteMedia)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/media/5", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	var response mediaInUseError
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 409, response.Code)
+	assert.Contains(t, response.Message, "force=true")
+	assert.Len(t, response.Usages, 2)
+	assert.NoError(t, mock.ExpectationsWereMet(), "nothing is deleted")
+}
+
+func TestDeleteMedia_Force(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	useLocalStorage(t)
+	expectStoredMedia(mock, true)
+	expectUsages(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "media" SET "deleted_at"=\$1 WHERE version = \$2 AND "media"\."id" = \$3 AND "media"\."deleted_at" IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.DELETE("/media/:id", DeleteMedia)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/media/5?force=true", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
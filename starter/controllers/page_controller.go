@@ -3,6 +3,7 @@ package controllers
 import (
 	"cms-backend/middleware"
 	"cms-backend/models"
+	"cms-backend/outbox"
 	"cms-backend/utils"
 	"net/http"
 	"strconv"
@@ -15,6 +16,11 @@ import (
 
 var pageValidator = validator.New()
 
+// pageETag is the strong validator clients send back in If-Match
+func pageETag(page *models.Page) string {
+	return utils.ResourceETag("page", page.ID, page.Version)
+}
+
 func GetPages(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	var pages []models.Page
@@ -42,6 +48,13 @@ func GetPages(c *gin.Context) {
 
 	search := c.Query("search")
 	query := db.Model(&models.Page{})
+	if canViewUnpublishedPages(c) {
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+	} else {
+		query = query.Where("status = ?", models.StatusPublished)
+	}
 	if search != "" {
 		searchPattern := "%" + search + "%"
 		query = query.Where(
@@ -69,6 +82,12 @@ func GetPages(c *gin.Context) {
 		return
 	}
 
+	tags := []string{middleware.ListTag("pages")}
+	for _, item := range pages {
+		tags = append(tags, middleware.EntityTag(models.RevisionResourcePage, item.ID))
+	}
+	middleware.TagCache(c, tags...)
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":       pages,
 		"page":       page,
@@ -100,6 +119,44 @@ func GetPage(c *gin.Context) {
 		}
 		return
 	}
+	if page.Status != models.StatusPublished && !canViewUnpublishedPages(c) {
+		c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Page not found"})
+		return
+	}
+	middleware.TagCache(c, middleware.EntityTag(models.RevisionResourcePage, page.ID))
+	c.Header("ETag", pageETag(&page))
+	c.JSON(http.StatusOK, page)
+}
+
+// GetPageBySlug retrieves a page by its permalink slug. A slug the page had
+// before a rename answers 301 with the current one.
+func GetPageBySlug(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	slug := c.Param("slug")
+	var page models.Page
+	err := db.Where("slug = ?", slug).First(&page).Error
+	if err == gorm.ErrRecordNotFound {
+		id, ok := findSlugRedirect(c, db, models.RevisionResourcePage, slug, "Page not found")
+		if !ok {
+			return
+		}
+		if err := db.First(&page, id).Error; err != nil || (page.Status != models.StatusPublished && !canViewUnpublishedPages(c)) {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Page not found"})
+			return
+		}
+		redirectToSlug(c, page.Slug)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if page.Status != models.StatusPublished && !canViewUnpublishedPages(c) {
+		c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Page not found"})
+		return
+	}
+	middleware.TagCache(c, middleware.EntityTag(models.RevisionResourcePage, page.ID))
+	c.Header("ETag", pageETag(&page))
 	c.JSON(http.StatusOK, page)
 }
 
@@ -119,15 +176,41 @@ func CreatePage(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
 		return
 	}
+	page.Status = models.StatusDraft
+	page.PublishedAt = nil
+	page.Version = 1
+	requestedSlug := page.Slug
+	page.Slug = ""
 	tx := db.Begin()
+	if err := applySlug(tx, &models.Page{}, models.RevisionResourcePage, &page.Slug, requestedSlug, page.Title, 0); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
 	if err := tx.Create(&page).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
 		return
 	}
+	if err := recordPageRevision(c, tx, &page, nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePage, page.ID, models.AuditActionCreate, nil, auditSnapshot(&page)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePage, page.ID, models.EventCreated, &page); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
 	tx.Commit()
-	middleware.InvalidatePageCache()
+	outbox.Notify()
 
+	c.Header("ETag", pageETag(&page))
 	c.JSON(http.StatusCreated, page)
 }
 
@@ -153,6 +236,10 @@ func UpdatePage(c *gin.Context) {
 		}
 		return
 	}
+	if !middleware.CheckIfMatch(c, pageETag(&page)) {
+		return
+	}
+	before := auditSnapshot(&page)
 	var input models.Page
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
@@ -164,19 +251,42 @@ func UpdatePage(c *gin.Context) {
 	}
 	page.Title = input.Title
 	page.Content = input.Content
+	previousVersion := page.Version
+	page.Version++
 	tx := db.Begin()
-	if err := tx.Save(&page).Error; err != nil {
+	if err := applySlug(tx, &models.Page{}, models.RevisionResourcePage, &page.Slug, input.Slug, page.Title, page.ID); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := saveVersioned(tx, &page, previousVersion); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := recordPageRevision(c, tx, &page, nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePage, page.ID, models.AuditActionUpdate, before, auditSnapshot(&page)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePage, page.ID, models.EventUpdated, &page); err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
 		return
 	}
 	tx.Commit()
-	middleware.InvalidatePageCache()
+	outbox.Notify()
 
+	c.Header("ETag", pageETag(&page))
 	c.JSON(http.StatusOK, page)
 }
 
-// DeletePage deletes a page by ID
+// DeletePage moves a page to the trash
 func DeletePage(c *gin.Context) {
 	db, ok := c.MustGet("db").(*gorm.DB)
 	if !ok {
@@ -198,15 +308,28 @@ func DeletePage(c *gin.Context) {
 		}
 		return
 	}
+	if !middleware.CheckIfMatch(c, pageETag(&page)) {
+		return
+	}
 	tx := db.Begin()
-	if err := tx.Delete(&page).Error; err != nil {
+	if err := deleteVersioned(tx, &page, page.Version); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePage, page.ID, models.AuditActionDelete, auditSnapshot(&page), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePage, page.ID, models.EventDeleted, &page); err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
 		return
 	}
 	tx.Commit()
 
-	middleware.InvalidatePageCache()
+	outbox.Notify()
 
-	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Page deleted"})
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Page moved to trash"})
 }
@@ -56,10 +56,10 @@ func TestGetPage(t *testing.T) {
 	defer mock.ExpectClose()
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "updated_at"}).
-		AddRow(1, "First Page", "Content 1", now, now)
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "status", "created_at", "updated_at"}).
+		AddRow(1, "First Page", "Content 1", models.StatusPublished, now, now)
 
-	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 ORDER BY "pages"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
 
 	router.GET("/pages/:id", GetPage)
 	w := httptest.NewRecorder()
@@ -84,9 +84,13 @@ func TestCreatePage(t *testing.T) {
 	defer mock.ExpectClose()
 
 	mock.ExpectBegin()
+	expectSlugLookup(mock, "pages", "new-page")
 	mock.ExpectQuery(`INSERT INTO "pages"`).
-		WithArgs("New Page", "New Content", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("New Page", "new-page", "New Content", models.StatusDraft, nil, nil, nil, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	expectRevision(mock, models.RevisionResourcePage, 3, 0)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
 	mock.ExpectCommit()
 
 	page := models.Page{Title: "New Page", Content: "New Content"}
@@ -116,14 +120,17 @@ func TestUpdatePage(t *testing.T) {
 	defer mock.ExpectClose()
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "updated_at"}).
-		AddRow(1, "Old Title", "Old Content", now, now)
-	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 ORDER BY "pages"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	rows := sqlmock.NewRows([]string{"id", "title", "slug", "content", "status", "version", "created_at", "updated_at"}).
+		AddRow(1, "Old Title", "old-title", "Old Content", models.StatusPublished, 2, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE "pages" SET "title"=\$1,"content"=\$2,"created_at"=\$3,"updated_at"=\$4 WHERE "id" = \$5`).
-		WithArgs("Updated Title", "Updated Content", sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+	mock.ExpectExec(`UPDATE "pages" SET "title"=\$1,"slug"=\$2,"content"=\$3,"status"=\$4,"published_at"=\$5,"publish_at"=\$6,"unpublish_at"=\$7,"version"=\$8,"created_at"=\$9,"updated_at"=\$10 WHERE version = \$11 AND "pages"\."deleted_at" IS NULL AND "id" = \$12`).
+		WithArgs("Updated Title", "old-title", "Updated Content", models.StatusPublished, nil, nil, nil, 3, sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, models.RevisionResourcePage, 1, 1)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
 	mock.ExpectCommit()
 
 	update := models.Page{Title: "Updated Title", Content: "Updated Content"}
@@ -155,11 +162,13 @@ func TestDeletePage(t *testing.T) {
 	now := time.Now()
 	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "updated_at"}).
 		AddRow(1, "Title", "Content", now, now)
-	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 ORDER BY "pages"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "pages" WHERE "pages"\."id" = \$1`).WithArgs(1).
+	mock.ExpectExec(`UPDATE "pages" SET "deleted_at"=\$1 WHERE version = \$2 AND "pages"\."id" = \$3 AND "pages"\."deleted_at" IS NULL`).WithArgs(sqlmock.AnyArg(), 0, 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
 	mock.ExpectCommit()
 
 	router.DELETE("/pages/:id", DeletePage)
@@ -175,7 +184,7 @@ func TestDeletePage(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
 		t.Fatalf("Error unmarshaling response: %v", err)
 	}
-	if response.Message != "Page deleted" {
+	if response.Message != "Page moved to trash" {
 		t.Fatalf("Expected deletion message, got %s", response.Message)
 	}
 }
@@ -206,7 +215,7 @@ func TestGetPage_NotFound(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 ORDER BY "pages"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).
 		WithArgs(999, 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
@@ -232,7 +241,7 @@ func TestGetPage_DatabaseError(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 ORDER BY "pages"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).
 		WithArgs(1, 1).
 		WillReturnError(gorm.ErrInvalidDB)
 
@@ -311,8 +320,9 @@ func TestCreatePage_DatabaseError(t *testing.T) {
 	defer mock.ExpectClose()
 
 	mock.ExpectBegin()
+	expectSlugLookup(mock, "pages", "test-page")
 	mock.ExpectQuery(`INSERT INTO "pages"`).
-		WithArgs("Test Page", "Test Content", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("Test Page", "test-page", "Test Content", models.StatusDraft, nil, nil, nil, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnError(gorm.ErrInvalidDB)
 	mock.ExpectRollback()
 
@@ -368,7 +378,7 @@ func TestUpdatePage_NotFound(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 ORDER BY "pages"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).
 		WithArgs(999, 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
@@ -420,7 +430,7 @@ func TestDeletePage_NotFound(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 ORDER BY "pages"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).
 		WithArgs(999, 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
@@ -449,13 +459,13 @@ func TestDeletePage_DatabaseError(t *testing.T) {
 	now := time.Now()
 	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "updated_at"}).
 		AddRow(1, "Test Page", "Test Content", now, now)
-	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 ORDER BY "pages"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).
 		WithArgs(1, 1).
 		WillReturnRows(rows)
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "pages" WHERE "pages"\."id" = \$1`).
-		WithArgs(1).
+	mock.ExpectExec(`UPDATE "pages" SET "deleted_at"=\$1 WHERE version = \$2 AND "pages"\."id" = \$3 AND "pages"\."deleted_at" IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 0, 1).
 		WillReturnError(gorm.ErrInvalidDB)
 	mock.ExpectRollback()
 
@@ -500,13 +510,13 @@ func TestGetPages_WithPaginationAndSorting(t *testing.T) {
 			countRows := sqlmock.NewRows([]string{"count"}).AddRow(0)
 
 			if tc.name == "SearchFilter" {
-				mock.ExpectQuery(`SELECT count\(\*\) FROM "pages" WHERE title ILIKE \$1 OR content ILIKE \$2`).
-					WithArgs("%test%", "%test%").
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "pages" WHERE status = \$1 AND \(title ILIKE \$2 OR content ILIKE \$3\)`).
+					WithArgs(models.StatusPublished, "%test%", "%test%").
 					WillReturnRows(countRows)
 
 				rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "updated_at"})
-				mock.ExpectQuery(`SELECT \* FROM "pages" WHERE title ILIKE \$1 OR content ILIKE \$2 ORDER BY created_at desc LIMIT \$3`).
-					WithArgs("%test%", "%test%", 10).
+				mock.ExpectQuery(`SELECT \* FROM "pages" WHERE status = \$1 AND \(title ILIKE \$2 OR content ILIKE \$3\) AND "pages"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$4`).
+					WithArgs(models.StatusPublished, "%test%", "%test%", 10).
 					WillReturnRows(rows)
 			} else {
 				mock.ExpectQuery(`SELECT count\(\*\) FROM "pages"`).WillReturnRows(countRows)
@@ -3,6 +3,7 @@ package controllers
 import (
 	"cms-backend/middleware"
 	"cms-backend/models"
+	"cms-backend/outbox"
 	"cms-backend/utils"
 	"net/http"
 	"strconv"
@@ -15,13 +16,31 @@ import (
 
 var postValidator = validator.New()
 
+// postETag is the strong validator clients send back in If-Match
+func postETag(post *models.Post) string {
+	return utils.ResourceETag("post", post.ID, post.Version)
+}
+
 type PostInput struct {
-	Title    string `json:"title" validate:"required"`
-	Content  string `json:"content" validate:"required"`
-	Author   string `json:"author"`
-	MediaIDs []uint `json:"media_ids"`
+	Title       string `json:"title" validate:"required"`
+	Slug        string `json:"slug"`
+	Content     string `json:"content" validate:"required"`
+	Author      string `json:"author"`
+	MediaIDs    []uint `json:"media_ids"`
+	CategoryIDs []uint `json:"category_ids"`
+	TagIDs      []uint `json:"tag_ids"`
 }
 
+// Posts in a category or any of its descendants
+const postCategoryFilter = `id IN (SELECT pc.post_id FROM post_categories pc WHERE pc.category_id IN (
+	WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE slug = ?
+		UNION ALL
+		SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+	) SELECT id FROM tree))`
+
+const postTagFilter = `id IN (SELECT pt.post_id FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE t.slug = ?)`
+
 func GetPosts(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	var posts []models.Post
@@ -51,10 +70,22 @@ func GetPosts(c *gin.Context) {
 	search := c.Query("search")
 	title := c.Query("title")
 	author := c.Query("author")
+	category := c.Query("category")
+	tag := c.Query("tag")
 
 	var conditions []string
 	var args []interface{}
 
+	if middleware.HasPermission(c, middleware.PermPostsPublish, nil) {
+		if status := c.Query("status"); status != "" {
+			conditions = append(conditions, "status = ?")
+			args = append(args, status)
+		}
+	} else {
+		conditions = append(conditions, "status = ?")
+		args = append(args, models.StatusPublished)
+	}
+
 	if search != "" {
 		searchPattern := "%" + search + "%"
 		conditions = append(conditions, "(title ILIKE ? OR content ILIKE ? OR author ILIKE ?)")
@@ -71,6 +102,16 @@ func GetPosts(c *gin.Context) {
 		args = append(args, author)
 	}
 
+	if category != "" {
+		conditions = append(conditions, postCategoryFilter)
+		args = append(args, category)
+	}
+
+	if tag != "" {
+		conditions = append(conditions, postTagFilter)
+		args = append(args, tag)
+	}
+
 	var total int64
 	countQuery := db.Model(&models.Post{})
 	if len(conditions) > 0 {
@@ -85,7 +126,7 @@ func GetPosts(c *gin.Context) {
 		return
 	}
 
-	query := db.Preload("Media", func(db *gorm.DB) *gorm.DB {
+	query := preloadPostTerms(db).Preload("Media", func(db *gorm.DB) *gorm.DB {
 		return db.Select("id, url, type")
 	})
 
@@ -105,6 +146,12 @@ func GetPosts(c *gin.Context) {
 		return
 	}
 
+	tags := []string{middleware.ListTag("posts")}
+	for _, post := range posts {
+		tags = append(tags, middleware.EntityTag(models.RevisionResourcePost, post.ID))
+	}
+	middleware.TagCache(c, tags...)
+
 	c.JSON(http.StatusOK, gin.H{
 		"data":       posts,
 		"page":       page,
@@ -124,7 +171,7 @@ func GetPost(c *gin.Context) {
 		return
 	}
 	var post models.Post
-	if err := db.Preload("Media").First(&post, id).Error; err != nil {
+	if err := preloadPostTerms(db).Preload("Media").First(&post, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Post not found"})
 		} else {
@@ -132,6 +179,44 @@ func GetPost(c *gin.Context) {
 		}
 		return
 	}
+	if !canViewPost(c, &post) {
+		c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Post not found"})
+		return
+	}
+	middleware.TagCache(c, middleware.EntityTag(models.RevisionResourcePost, post.ID))
+	c.Header("ETag", postETag(&post))
+	c.JSON(http.StatusOK, post)
+}
+
+// GetPostBySlug retrieves a post by its permalink slug. A slug the post had
+// before a rename answers 301 with the current one.
+func GetPostBySlug(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	slug := c.Param("slug")
+	var post models.Post
+	err := preloadPostTerms(db).Preload("Media").Where("slug = ?", slug).First(&post).Error
+	if err == gorm.ErrRecordNotFound {
+		id, ok := findSlugRedirect(c, db, models.RevisionResourcePost, slug, "Post not found")
+		if !ok {
+			return
+		}
+		if err := db.First(&post, id).Error; err != nil || !canViewPost(c, &post) {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Post not found"})
+			return
+		}
+		redirectToSlug(c, post.Slug)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if !canViewPost(c, &post) {
+		c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Post not found"})
+		return
+	}
+	middleware.TagCache(c, middleware.EntityTag(models.RevisionResourcePost, post.ID))
+	c.Header("ETag", postETag(&post))
 	c.JSON(http.StatusOK, post)
 }
 
@@ -154,23 +239,65 @@ func CreatePost(c *gin.Context) {
 			return
 		}
 	}
+	if !checkAltText(c, media, nil) {
+		return
+	}
+	if !checkPostTerms(c, db, &input) {
+		return
+	}
 	post := models.Post{
 		Title:   input.Title,
 		Content: input.Content,
 		Author:  input.Author,
+		Status:  models.StatusDraft,
+		Version: 1,
 		Media:   media,
 	}
+	if claims, ok := middleware.CurrentUser(c); ok {
+		post.OwnerID = &claims.UserID
+		if post.Author == "" {
+			post.Author = claims.Username
+		}
+	}
 	tx := db.Begin()
+	if err := applySlug(tx, &models.Post{}, models.RevisionResourcePost, &post.Slug, input.Slug, post.Title, 0); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
 	if err := tx.Create(&post).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
 		return
 	}
+	if len(input.CategoryIDs) > 0 || len(input.TagIDs) > 0 {
+		if err := setPostTerms(tx, post.ID, input.CategoryIDs, input.TagIDs); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+	}
+	if err := recordPostRevision(c, tx, &post, nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePost, post.ID, models.AuditActionCreate, nil, auditSnapshot(&post)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePost, post.ID, models.EventCreated, &post); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
 	tx.Commit()
 
-	middleware.InvalidatePostCache()
+	outbox.Notify()
 
-	if err := db.Preload("Media").First(&post, "id = ?", post.ID).Error; err == nil {
+	c.Header("ETag", postETag(&post))
+	if err := preloadPostTerms(db).Preload("Media").First(&post, "id = ?", post.ID).Error; err == nil {
 		c.JSON(http.StatusCreated, post)
 	} else {
 		c.JSON(http.StatusCreated, post)
@@ -195,6 +322,14 @@ func UpdatePost(c *gin.Context) {
 		}
 		return
 	}
+	if !middleware.HasPermission(c, middleware.PermPostsUpdate, post.OwnerID) {
+		middleware.Forbidden(c)
+		return
+	}
+	if !middleware.CheckIfMatch(c, postETag(&post)) {
+		return
+	}
+	before := auditSnapshot(&post)
 	var input PostInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
@@ -213,34 +348,76 @@ func UpdatePost(c *gin.Context) {
 	if input.Author != "" {
 		post.Author = input.Author
 	}
+	var media []models.Media
 	if input.MediaIDs != nil {
-		var media []models.Media
 		if len(input.MediaIDs) > 0 {
 			if err := db.Find(&media, input.MediaIDs).Error; err != nil {
 				c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid media IDs"})
 				return
 			}
 		}
-		post.Media = media
+		if !checkAltText(c, media, post.Media) {
+			return
+		}
+	}
+	if !checkPostTerms(c, db, &input) {
+		return
 	}
+	previousVersion := post.Version
+	post.Version++
 	tx := db.Begin()
-	if err := tx.Save(&post).Error; err != nil {
+	if err := applySlug(tx, &models.Post{}, models.RevisionResourcePost, &post.Slug, input.Slug, post.Title, post.ID); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := saveVersioned(tx, &post, previousVersion); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if input.MediaIDs != nil {
+		// Replace also unlinks the media left out of media_ids
+		if err := tx.Model(&post).Association("Media").Replace(media); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+		post.Media = media
+	}
+	if err := setPostTerms(tx, post.ID, input.CategoryIDs, input.TagIDs); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordPostRevision(c, tx, &post, nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePost, post.ID, models.AuditActionUpdate, before, auditSnapshot(&post)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePost, post.ID, models.EventUpdated, &post); err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
 		return
 	}
 	tx.Commit()
 
-	middleware.InvalidatePostCache()
+	outbox.Notify()
 
-	if err := db.Preload("Media").First(&post, "id = ?", post.ID).Error; err == nil {
+	c.Header("ETag", postETag(&post))
+	if err := preloadPostTerms(db).Preload("Media").First(&post, "id = ?", post.ID).Error; err == nil {
 		c.JSON(http.StatusOK, post)
 	} else {
 		c.JSON(http.StatusOK, post)
 	}
 }
 
-// DeletePost deletes a post
+// DeletePost moves a post to the trash
 func DeletePost(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	idStr := c.Param("id")
@@ -258,15 +435,32 @@ func DeletePost(c *gin.Context) {
 		}
 		return
 	}
+	if !middleware.HasPermission(c, middleware.PermPostsDelete, post.OwnerID) {
+		middleware.Forbidden(c)
+		return
+	}
+	if !middleware.CheckIfMatch(c, postETag(&post)) {
+		return
+	}
 	tx := db.Begin()
-	if err := tx.Delete(&post).Error; err != nil {
+	if err := deleteVersioned(tx, &post, post.Version); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePost, post.ID, models.AuditActionDelete, auditSnapshot(&post), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePost, post.ID, models.EventDeleted, &post); err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
 		return
 	}
 	tx.Commit()
 
-	middleware.InvalidatePostCache()
+	outbox.Notify()
 
-	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Post deleted"})
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Post moved to trash"})
 }
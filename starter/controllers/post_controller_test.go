@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"cms-backend/middleware"
 	"cms-backend/models"
 	"cms-backend/utils"
 	"encoding/json"
@@ -19,6 +20,25 @@ func init() {
 	gin.SetMode(gin.ReleaseMode)
 }
 
+// authenticateAs makes every request on the router run as the given user
+func authenticateAs(router *gin.Engine, userID uint, role string) {
+	router.Use(func(c *gin.Context) {
+		middleware.SetCurrentUser(c, &utils.Claims{UserID: userID, Username: "tester", Role: role})
+		c.Next()
+	})
+}
+
+// expectNoPostCategories and expectNoPostTags answer the category and tag
+// preloads with no links. GORM runs preloads in name order, so they bracket
+// the Media preload.
+func expectNoPostCategories(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "post_categories"`).WillReturnRows(sqlmock.NewRows([]string{"post_id", "category_id"}))
+}
+
+func expectNoPostTags(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "post_tags"`).WillReturnRows(sqlmock.NewRows([]string{"post_id", "tag_id"}))
+}
+
 func TestGetPosts(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
@@ -30,14 +50,16 @@ func TestGetPosts(t *testing.T) {
 		AddRow(1, "First Post", "Content 1", "Author1", time.Now(), time.Now()).
 		AddRow(2, "Second Post", "Content 2", "Author2", time.Now(), time.Now())
 
-	mock.ExpectQuery(`SELECT \* FROM "posts" ORDER BY created_at desc LIMIT \$1`).
-		WithArgs(10).
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE status = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$2`).
+		WithArgs(models.StatusPublished, 10).
 		WillReturnRows(rows)
 
 	postMediaRows := sqlmock.NewRows([]string{"post_id", "media_id"})
+	expectNoPostCategories(mock)
 	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" IN \(\$1,\$2\)`).
 		WithArgs(1, 2).
 		WillReturnRows(postMediaRows)
+	expectNoPostTags(mock)
 
 	router.GET("/posts", GetPosts)
 	w := httptest.NewRecorder()
@@ -67,21 +89,23 @@ func TestGetPostsWithFilters(t *testing.T) {
 	defer mock.ExpectClose()
 
 	countRows := sqlmock.NewRows([]string{"count"}).AddRow(1)
-	mock.ExpectQuery(`SELECT count\(\*\) FROM "posts" WHERE title ILIKE \$1 AND author = \$2`).
-		WithArgs("%Filtered%", "AuthorX").
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "posts" WHERE \(status = \$1 AND title ILIKE \$2 AND author = \$3`).
+		WithArgs(models.StatusPublished, "%Filtered%", "AuthorX").
 		WillReturnRows(countRows)
 
 	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "created_at", "updated_at"}).
 		AddRow(1, "Filtered Post", "Content", "AuthorX", time.Now(), time.Now())
 
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE title ILIKE \$1 AND author = \$2 ORDER BY created_at desc LIMIT \$3`).
-		WithArgs("%Filtered%", "AuthorX", 10).
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE \(status = \$1 AND title ILIKE \$2 AND author = \$3\) AND "posts"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$4`).
+		WithArgs(models.StatusPublished, "%Filtered%", "AuthorX", 10).
 		WillReturnRows(rows)
 
 	postMediaRows := sqlmock.NewRows([]string{"post_id", "media_id"})
+	expectNoPostCategories(mock)
 	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
 		WithArgs(1).
 		WillReturnRows(postMediaRows)
+	expectNoPostTags(mock)
 
 	router.GET("/posts", GetPosts)
 	w := httptest.NewRecorder()
@@ -116,15 +140,17 @@ func TestGetPost(t *testing.T) {
 	defer mock.ExpectClose()
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "created_at", "updated_at"}).
-		AddRow(1, "First Post", "Content 1", "Author1", now, now)
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "created_at", "updated_at"}).
+		AddRow(1, "First Post", "Content 1", "Author1", models.StatusPublished, now, now)
 
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
 
 	postMediaRows := sqlmock.NewRows([]string{"post_id", "media_id"})
+	expectNoPostCategories(mock)
 	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
 		WithArgs(1).
 		WillReturnRows(postMediaRows)
+	expectNoPostTags(mock)
 
 	router.GET("/posts/:id", GetPost)
 	w := httptest.NewRecorder()
@@ -149,14 +175,18 @@ func TestCreatePost(t *testing.T) {
 	defer mock.ExpectClose()
 
 	mock.ExpectBegin()
+	expectSlugLookup(mock, "posts", "new-post")
 	mock.ExpectQuery(`INSERT INTO "posts"`).
-		WithArgs("New Post", "New Content", "Author", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("New Post", "new-post", "New Content", "Author", nil, models.StatusDraft, nil, nil, nil, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	expectRevision(mock, models.RevisionResourcePost, 3, 0)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
 	mock.ExpectCommit()
 
 	postRows := sqlmock.NewRows([]string{"id", "title", "content", "author", "created_at", "updated_at"}).
 		AddRow(3, "New Post", "New Content", "Author", time.Now(), time.Now())
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
 		WithArgs(3, 1).
 		WillReturnRows(postRows)
 
@@ -195,11 +225,12 @@ func TestCreatePost(t *testing.T) {
 func TestUpdatePost(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "created_at", "updated_at"}).
-		AddRow(1, "Old Title", "Old Content", "Author", now, now)
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	rows := sqlmock.NewRows([]string{"id", "title", "slug", "content", "author", "status", "version", "created_at", "updated_at"}).
+		AddRow(1, "Old Title", "old-title", "Old Content", "Author", models.StatusPublished, 2, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
 
 	postMediaRows := sqlmock.NewRows([]string{"post_id", "media_id"})
 	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
@@ -207,9 +238,12 @@ func TestUpdatePost(t *testing.T) {
 		WillReturnRows(postMediaRows)
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE "posts" SET "title"=\$1,"content"=\$2,"author"=\$3,"created_at"=\$4,"updated_at"=\$5 WHERE "id" = \$6`).
-		WithArgs("Updated Title", "Updated Content", "Author", sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+	mock.ExpectExec(`UPDATE "posts" SET "title"=\$1,"slug"=\$2,"content"=\$3,"author"=\$4,"owner_id"=\$5,"status"=\$6,"published_at"=\$7,"publish_at"=\$8,"unpublish_at"=\$9,"version"=\$10,"created_at"=\$11,"updated_at"=\$12 WHERE version = \$13 AND "posts"\."deleted_at" IS NULL AND "id" = \$14`).
+		WithArgs("Updated Title", "old-title", "Updated Content", "Author", nil, models.StatusPublished, nil, nil, nil, 3, sqlmock.AnyArg(), sqlmock.AnyArg(), 2, 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, models.RevisionResourcePost, 1, 1)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
 	mock.ExpectCommit()
 
 	update := map[string]interface{}{
@@ -238,18 +272,76 @@ func TestUpdatePost(t *testing.T) {
 	}
 }
 
+func TestUpdatePost_DetachesRemovedMedia(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug", "content", "status", "version", "created_at", "updated_at"}).
+			AddRow(1, "Ride", "ride", "Content", models.StatusDraft, 1, now, now))
+	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}).AddRow(1, 4).AddRow(1, 5))
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" IN \(\$1,\$2\)`).
+		WithArgs(4, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "type"}).
+			AddRow(4, "/api/v1/media/4/file", "image").
+			AddRow(5, "/api/v1/media/5/file", "image"))
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "type"}).AddRow(5, "/api/v1/media/5/file", "image"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts" SET`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Replace links media 5 again and unlinks media 4
+	mock.ExpectExec(`UPDATE "posts" SET "updated_at"=\$1 WHERE "posts"\."deleted_at" IS NULL AND "id" = \$2`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "media" .* ON CONFLICT DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`INSERT INTO "post_media" \("post_id","media_id"\) VALUES \(\$1,\$2\) ON CONFLICT DO NOTHING`).
+		WithArgs(1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "post_media" WHERE "post_media"\."post_id" = \$1 AND "post_media"\."media_id" <> \$2`).
+		WithArgs(1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock, models.RevisionResourcePost, 1, 1)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.PUT("/posts/:id", UpdatePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/posts/1", strings.NewReader(`{"title":"Ride","content":"Content","media_ids":[5]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
+
 func TestDeletePost(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
 
 	now := time.Now()
 	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "created_at", "updated_at"}).
 		AddRow(1, "Title", "Content", "Author", now, now)
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "posts" WHERE "posts"\."id" = \$1`).WithArgs(1).
+	mock.ExpectExec(`UPDATE "posts" SET "deleted_at"=\$1 WHERE version = \$2 AND "posts"\."id" = \$3 AND "posts"\."deleted_at" IS NULL`).WithArgs(sqlmock.AnyArg(), 0, 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
 	mock.ExpectCommit()
 
 	router.DELETE("/posts/:id", DeletePost)
@@ -265,7 +357,7 @@ func TestDeletePost(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
 		t.Fatalf("Error unmarshaling response: %v", err)
 	}
-	if response.Message != "Post deleted" {
+	if response.Message != "Post moved to trash" {
 		t.Fatalf("Expected deletion message, got %s", response.Message)
 	}
 }
@@ -296,7 +388,7 @@ func TestGetPost_NotFound(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
 		WithArgs(999, 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
@@ -322,7 +414,7 @@ func TestGetPost_DatabaseError(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
 		WithArgs(1, 1).
 		WillReturnError(gorm.ErrInvalidDB)
 
@@ -402,8 +494,9 @@ func TestCreatePost_DatabaseError(t *testing.T) {
 	defer mock.ExpectClose()
 
 	mock.ExpectBegin()
+	expectSlugLookup(mock, "posts", "test-post")
 	mock.ExpectQuery(`INSERT INTO "posts"`).
-		WithArgs("Test Post", "Test Content", "Author", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("Test Post", "test-post", "Test Content", "Author", nil, models.StatusDraft, nil, nil, nil, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
 		WillReturnError(gorm.ErrInvalidDB)
 	mock.ExpectRollback()
 
@@ -467,7 +560,7 @@ func TestUpdatePost_NotFound(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
 		WithArgs(999, 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
@@ -522,7 +615,7 @@ func TestDeletePost_NotFound(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
 
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
 		WithArgs(999, 1).
 		WillReturnError(gorm.ErrRecordNotFound)
 
@@ -547,17 +640,18 @@ func TestDeletePost_NotFound(t *testing.T) {
 func TestDeletePost_DatabaseError(t *testing.T) {
 	router, _, mock := utils.SetupRouterAndMockDB(t)
 	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
 
 	now := time.Now()
 	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "created_at", "updated_at"}).
 		AddRow(1, "Test Post", "Test Content", "Author", now, now)
-	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
 		WithArgs(1, 1).
 		WillReturnRows(rows)
 
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "posts" WHERE "posts"\."id" = \$1`).
-		WithArgs(1).
+	mock.ExpectExec(`UPDATE "posts" SET "deleted_at"=\$1 WHERE version = \$2 AND "posts"\."id" = \$3 AND "posts"\."deleted_at" IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 0, 1).
 		WillReturnError(gorm.ErrInvalidDB)
 	mock.ExpectRollback()
 
@@ -660,13 +754,13 @@ func TestGetPosts_WithComplexFilters(t *testing.T) {
 			countRows := sqlmock.NewRows([]string{"count"}).AddRow(0)
 
 			if tc.name == "CombinedFilters" {
-				mock.ExpectQuery(`SELECT count\(\*\) FROM "posts" WHERE \(title ILIKE \$1 OR content ILIKE \$2 OR author ILIKE \$3\) AND author = \$4`).
-					WithArgs("%test%", "%test%", "%test%", "TestAuthor").
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "posts" WHERE \(status = \$1 AND \(title ILIKE \$2 OR content ILIKE \$3 OR author ILIKE \$4\) AND author = \$5`).
+					WithArgs(models.StatusPublished, "%test%", "%test%", "%test%", "TestAuthor").
 					WillReturnRows(countRows)
 
 				rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "created_at", "updated_at"})
-				mock.ExpectQuery(`SELECT \* FROM "posts" WHERE \(title ILIKE \$1 OR content ILIKE \$2 OR author ILIKE \$3\) AND author = \$4 ORDER BY created_at desc LIMIT \$5`).
-					WithArgs("%test%", "%test%", "%test%", "TestAuthor", 5).
+				mock.ExpectQuery(`SELECT \* FROM "posts" WHERE \(status = \$1 AND \(title ILIKE \$2 OR content ILIKE \$3 OR author ILIKE \$4\) AND author = \$5\) AND "posts"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$6`).
+					WithArgs(models.StatusPublished, "%test%", "%test%", "%test%", "TestAuthor", 5).
 					WillReturnRows(rows)
 
 				postMediaRows := sqlmock.NewRows([]string{"post_id", "media_id"})
@@ -675,8 +769,8 @@ func TestGetPosts_WithComplexFilters(t *testing.T) {
 				mock.ExpectQuery(`SELECT count\(\*\) FROM "posts"`).WillReturnRows(countRows)
 
 				rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "created_at", "updated_at"})
-				mock.ExpectQuery(`SELECT \* FROM "posts" ORDER BY created_at desc LIMIT \$1 OFFSET \$2`).
-					WithArgs(10, 9990).
+				mock.ExpectQuery(`SELECT \* FROM "posts" WHERE status = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$2 OFFSET \$3`).
+					WithArgs(models.StatusPublished, 10, 9990).
 					WillReturnRows(rows)
 
 				postMediaRows := sqlmock.NewRows([]string{"post_id", "media_id"})
@@ -685,8 +779,8 @@ func TestGetPosts_WithComplexFilters(t *testing.T) {
 				mock.ExpectQuery(`SELECT count\(\*\) FROM "posts"`).WillReturnRows(countRows)
 
 				rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "created_at", "updated_at"})
-				mock.ExpectQuery(`SELECT \* FROM "posts" ORDER BY created_at desc LIMIT \$1`).
-					WithArgs(10).
+				mock.ExpectQuery(`SELECT \* FROM "posts" WHERE status = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$2`).
+					WithArgs(models.StatusPublished, 10).
 					WillReturnRows(rows)
 
 				postMediaRows := sqlmock.NewRows([]string{"post_id", "media_id"})
@@ -704,3 +798,63 @@ func TestGetPosts_WithComplexFilters(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdatePost_AuthorNotOwner(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 2, "author")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "owner_id", "created_at", "updated_at"}).
+		AddRow(1, "Title", "Content", "Someone", 1, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+
+	body := `{"title":"Hijacked","content":"Content"}`
+	router.PUT("/posts/:id", UpdatePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/posts/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
+	}
+
+	var response utils.HTTPError
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.Code != 403 {
+		t.Fatalf("Expected error code 403, got %d", response.Code)
+	}
+}
+
+func TestDeletePost_AuthorOwner(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 2, "author")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "owner_id", "created_at", "updated_at"}).
+		AddRow(1, "Title", "Content", "Me", 2, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts" SET "deleted_at"=\$1 WHERE version = \$2 AND "posts"\."id" = \$3 AND "posts"\."deleted_at" IS NULL`).WithArgs(sqlmock.AnyArg(), 0, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.DELETE("/posts/:id", DeletePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/posts/1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+}
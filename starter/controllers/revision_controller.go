@@ -0,0 +1,382 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RevisionDiff is the line-level difference between two revisions
+type RevisionDiff struct {
+	From         int              `json:"from"`
+	To           int              `json:"to"`
+	Title        []utils.DiffLine `json:"title"`
+	Content      []utils.DiffLine `json:"content"`
+	Author       []utils.DiffLine `json:"author,omitempty"`
+	MediaAdded   []uint           `json:"media_added,omitempty"`
+	MediaRemoved []uint           `json:"media_removed,omitempty"`
+}
+
+// createRevision stores rev as the next version of its resource, attributed to the caller
+func createRevision(c *gin.Context, tx *gorm.DB, rev *models.Revision) error {
+	var latest int
+	if err := tx.Model(&models.Revision{}).
+		Select("COALESCE(MAX(version), 0)").
+		Where("resource_type = ? AND resource_id = ?", rev.ResourceType, rev.ResourceID).
+		Scan(&latest).Error; err != nil {
+		return err
+	}
+	rev.Version = latest + 1
+	if claims, ok := middleware.CurrentUser(c); ok {
+		rev.EditorID = &claims.UserID
+		rev.EditorName = claims.Username
+	}
+	return tx.Create(rev).Error
+}
+
+func recordPostRevision(c *gin.Context, tx *gorm.DB, post *models.Post, restoredFrom *int) error {
+	mediaIDs := make([]uint, 0, len(post.Media))
+	for _, media := range post.Media {
+		mediaIDs = append(mediaIDs, media.ID)
+	}
+	return createRevision(c, tx, &models.Revision{
+		ResourceType: models.RevisionResourcePost,
+		ResourceID:   post.ID,
+		Title:        post.Title,
+		Content:      post.Content,
+		Author:       post.Author,
+		MediaIDs:     mediaIDs,
+		RestoredFrom: restoredFrom,
+	})
+}
+
+func recordPageRevision(c *gin.Context, tx *gorm.DB, page *models.Page, restoredFrom *int) error {
+	return createRevision(c, tx, &models.Revision{
+		ResourceType: models.RevisionResourcePage,
+		ResourceID:   page.ID,
+		Title:        page.Title,
+		Content:      page.Content,
+		RestoredFrom: restoredFrom,
+	})
+}
+
+// loadPostForRevisions loads the post named in the URL and checks the caller may edit it
+func loadPostForRevisions(c *gin.Context, db *gorm.DB) (*models.Post, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid post ID"})
+		return nil, false
+	}
+	var post models.Post
+	if err := db.First(&post, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Post not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return nil, false
+	}
+	if !middleware.HasPermission(c, middleware.PermPostsUpdate, post.OwnerID) {
+		middleware.Forbidden(c)
+		return nil, false
+	}
+	return &post, true
+}
+
+// loadPageForRevisions loads the page named in the URL
+func loadPageForRevisions(c *gin.Context, db *gorm.DB) (*models.Page, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid page ID"})
+		return nil, false
+	}
+	var page models.Page
+	if err := db.First(&page, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Page not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return nil, false
+	}
+	return &page, true
+}
+
+func listRevisions(c *gin.Context, db *gorm.DB, resourceType string, resourceID uint) {
+	var revisions []models.Revision
+	if err := db.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).
+		Order("version desc").
+		Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": revisions})
+}
+
+// findRevision loads one version of a resource, writing a 400/404/500 response on failure
+func findRevision(c *gin.Context, db *gorm.DB, resourceType string, resourceID uint, versionParam string) (*models.Revision, bool) {
+	version, err := strconv.Atoi(versionParam)
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid revision version"})
+		return nil, false
+	}
+	var revision models.Revision
+	if err := db.Where("resource_type = ? AND resource_id = ? AND version = ?", resourceType, resourceID, version).
+		First(&revision).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Revision not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return nil, false
+	}
+	return &revision, true
+}
+
+// diffRevisions compares ?from= with ?to=. "to" defaults to the latest version
+// and "from" to the one before it.
+func diffRevisions(c *gin.Context, db *gorm.DB, resourceType string, resourceID uint) {
+	toParam := c.Query("to")
+	if toParam == "" {
+		var latest int
+		if err := db.Model(&models.Revision{}).
+			Select("COALESCE(MAX(version), 0)").
+			Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).
+			Scan(&latest).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+		if latest == 0 {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Revision not found"})
+			return
+		}
+		toParam = strconv.Itoa(latest)
+	}
+	to, ok := findRevision(c, db, resourceType, resourceID, toParam)
+	if !ok {
+		return
+	}
+	fromParam := c.Query("from")
+	if fromParam == "" {
+		fromParam = strconv.Itoa(to.Version - 1)
+	}
+	from, ok := findRevision(c, db, resourceType, resourceID, fromParam)
+	if !ok {
+		return
+	}
+
+	diff := RevisionDiff{
+		From:    from.Version,
+		To:      to.Version,
+		Title:   utils.DiffLines(from.Title, to.Title),
+		Content: utils.DiffLines(from.Content, to.Content),
+	}
+	if resourceType == models.RevisionResourcePost {
+		diff.Author = utils.DiffLines(from.Author, to.Author)
+		diff.MediaAdded, diff.MediaRemoved = mediaChanges(from.MediaIDs, to.MediaIDs)
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+func mediaChanges(before, after []uint) (added, removed []uint) {
+	had := make(map[uint]bool, len(before))
+	for _, id := range before {
+		had[id] = true
+	}
+	has := make(map[uint]bool, len(after))
+	for _, id := range after {
+		has[id] = true
+		if !had[id] {
+			added = append(added, id)
+		}
+	}
+	for _, id := range before {
+		if !has[id] {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+// GetPostRevisions lists every revision of a post, newest first
+func GetPostRevisions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	post, ok := loadPostForRevisions(c, db)
+	if !ok {
+		return
+	}
+	listRevisions(c, db, models.RevisionResourcePost, post.ID)
+}
+
+// GetPostRevision retrieves one revision of a post
+func GetPostRevision(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	post, ok := loadPostForRevisions(c, db)
+	if !ok {
+		return
+	}
+	if revision, ok := findRevision(c, db, models.RevisionResourcePost, post.ID, c.Param("version")); ok {
+		c.JSON(http.StatusOK, revision)
+	}
+}
+
+// DiffPostRevisions shows a line-level diff between two revisions of a post
+func DiffPostRevisions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	post, ok := loadPostForRevisions(c, db)
+	if !ok {
+		return
+	}
+	diffRevisions(c, db, models.RevisionResourcePost, post.ID)
+}
+
+// RestorePostRevision copies an old revision back onto the post as a new revision
+func RestorePostRevision(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	post, ok := loadPostForRevisions(c, db)
+	if !ok {
+		return
+	}
+	if !middleware.CheckIfMatch(c, postETag(post)) {
+		return
+	}
+	before := auditSnapshot(post)
+	revision, ok := findRevision(c, db, models.RevisionResourcePost, post.ID, c.Param("version"))
+	if !ok {
+		return
+	}
+	// Media deleted since the revision was taken is silently left out
+	var media []models.Media
+	if len(revision.MediaIDs) > 0 {
+		if err := db.Find(&media, revision.MediaIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+	}
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Author = revision.Author
+	previousVersion := post.Version
+	post.Version++
+
+	tx := db.Begin()
+	if err := saveVersioned(tx, post, previousVersion); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := tx.Model(post).Association("Media").Replace(media); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	post.Media = media
+	if err := recordPostRevision(c, tx, post, &revision.Version); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePost, post.ID, models.AuditActionRestoreRevision, before, auditSnapshot(post)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePost, post.ID, models.EventUpdated, post); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.Header("ETag", postETag(post))
+	c.JSON(http.StatusOK, post)
+}
+
+// GetPageRevisions lists every revision of a page, newest first
+func GetPageRevisions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	page, ok := loadPageForRevisions(c, db)
+	if !ok {
+		return
+	}
+	listRevisions(c, db, models.RevisionResourcePage, page.ID)
+}
+
+// GetPageRevision retrieves one revision of a page
+func GetPageRevision(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	page, ok := loadPageForRevisions(c, db)
+	if !ok {
+		return
+	}
+	if revision, ok := findRevision(c, db, models.RevisionResourcePage, page.ID, c.Param("version")); ok {
+		c.JSON(http.StatusOK, revision)
+	}
+}
+
+// DiffPageRevisions shows a line-level diff between two revisions of a page
+func DiffPageRevisions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	page, ok := loadPageForRevisions(c, db)
+	if !ok {
+		return
+	}
+	diffRevisions(c, db, models.RevisionResourcePage, page.ID)
+}
+
+// RestorePageRevision copies an old revision back onto the page as a new revision
+func RestorePageRevision(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	page, ok := loadPageForRevisions(c, db)
+	if !ok {
+		return
+	}
+	if !middleware.CheckIfMatch(c, pageETag(page)) {
+		return
+	}
+	before := auditSnapshot(page)
+	revision, ok := findRevision(c, db, models.RevisionResourcePage, page.ID, c.Param("version"))
+	if !ok {
+		return
+	}
+	page.Title = revision.Title
+	page.Content = revision.Content
+	previousVersion := page.Version
+	page.Version++
+
+	tx := db.Begin()
+	if err := saveVersioned(tx, page, previousVersion); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := recordPageRevision(c, tx, page, &revision.Version); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePage, page.ID, models.AuditActionRestoreRevision, before, auditSnapshot(page)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePage, page.ID, models.EventUpdated, page); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.Header("ETag", pageETag(page))
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectRevision expects the version lookup and insert that record a revision
+func expectRevision(mock sqlmock.Sqlmock, resourceType string, resourceID uint, latest int) {
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2`).
+		WithArgs(resourceType, resourceID).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(latest))
+	mock.ExpectQuery(`INSERT INTO "revisions"`).
+		WithArgs(resourceType, resourceID, latest+1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(latest + 1))
+}
+
+func revisionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "resource_type", "resource_id", "version", "title", "content", "author", "media_ids", "editor_name", "created_at"})
+}
+
+func expectOwnedPost(mock sqlmock.Sqlmock, ownerID uint) {
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "owner_id", "status", "created_at", "updated_at"}).
+		AddRow(1, "Current", "line one\nline three", "Me", ownerID, models.StatusPublished, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+}
+
+func TestGetPostRevisions(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 2, "author")
+
+	expectOwnedPost(mock, 2)
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 ORDER BY version desc`).
+		WithArgs(models.RevisionResourcePost, 1).
+		WillReturnRows(revisionRows().
+			AddRow(2, "post", 1, 2, "Current", "line one\nline three", "Me", "[5]", "tester", now).
+			AddRow(1, "post", 1, 1, "First", "line one\nline two", "Me", "[4]", "tester", now))
+
+	router.GET("/posts/:id/revisions", GetPostRevisions)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/1/revisions", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data []models.Revision `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if len(response.Data) != 2 || response.Data[0].Version != 2 {
+		t.Fatalf("Expected two revisions newest first, got %+v", response.Data)
+	}
+	if len(response.Data[1].MediaIDs) != 1 || response.Data[1].MediaIDs[0] != 4 {
+		t.Fatalf("Expected media IDs to be decoded, got %v", response.Data[1].MediaIDs)
+	}
+}
+
+func TestGetPostRevisions_NotOwner(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 3, "author")
+
+	expectOwnedPost(mock, 2)
+
+	router.GET("/posts/:id/revisions", GetPostRevisions)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/1/revisions", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestGetPostRevision_NotFound(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	expectOwnedPost(mock, 2)
+	mock.ExpectQuery(`SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 AND version = \$3`).
+		WithArgs(models.RevisionResourcePost, 1, 9, 1).
+		WillReturnRows(revisionRows())
+
+	router.GET("/posts/:id/revisions/:version", GetPostRevision)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/1/revisions/9", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestDiffPostRevisions(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	expectOwnedPost(mock, 2)
+	now := time.Now()
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2`).
+		WithArgs(models.RevisionResourcePost, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(2))
+	mock.ExpectQuery(`SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 AND version = \$3`).
+		WithArgs(models.RevisionResourcePost, 1, 2, 1).
+		WillReturnRows(revisionRows().AddRow(2, "post", 1, 2, "Current", "line one\nline three", "Me", "[5]", "tester", now))
+	mock.ExpectQuery(`SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 AND version = \$3`).
+		WithArgs(models.RevisionResourcePost, 1, 1, 1).
+		WillReturnRows(revisionRows().AddRow(1, "post", 1, 1, "First", "line one\nline two", "Me", "[4]", "tester", now))
+
+	router.GET("/posts/:id/revisions/diff", DiffPostRevisions)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/1/revisions/diff", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response RevisionDiff
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.From != 1 || response.To != 2 {
+		t.Fatalf("Expected diff from 1 to 2, got %d to %d", response.From, response.To)
+	}
+	expected := []utils.DiffLine{
+		{Op: utils.DiffEqual, Text: "line one"},
+		{Op: utils.DiffDelete, Text: "line two"},
+		{Op: utils.DiffInsert, Text: "line three"},
+	}
+	if len(response.Content) != len(expected) {
+		t.Fatalf("Unexpected content diff: %+v", response.Content)
+	}
+	for i := range expected {
+		if response.Content[i] != expected[i] {
+			t.Fatalf("Unexpected content diff line %d: %+v", i, response.Content[i])
+		}
+	}
+	if len(response.MediaAdded) != 1 || response.MediaAdded[0] != 5 || len(response.MediaRemoved) != 1 || response.MediaRemoved[0] != 4 {
+		t.Fatalf("Unexpected media changes: +%v -%v", response.MediaAdded, response.MediaRemoved)
+	}
+}
+
+func TestRestorePageRevision(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug", "content", "status", "created_at", "updated_at"}).
+			AddRow(1, "Current", "about", "New body", models.StatusPublished, now, now))
+	mock.ExpectQuery(`SELECT \* FROM "revisions" WHERE resource_type = \$1 AND resource_id = \$2 AND version = \$3`).
+		WithArgs(models.RevisionResourcePage, 1, 1, 1).
+		WillReturnRows(revisionRows().AddRow(1, "page", 1, 1, "Original", "Old body", "", nil, "tester", now))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "pages" SET "title"=\$1,"slug"=\$2,"content"=\$3`).
+		WithArgs("Original", "about", "Old body", models.StatusPublished, nil, nil, nil, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), 0, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, models.RevisionResourcePage, 1, 2)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.POST("/pages/:id/revisions/:version/restore", RestorePageRevision)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/pages/1/revisions/1/restore", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response models.Page
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.Title != "Original" || response.Content != "Old body" {
+		t.Fatalf("Expected restored content, got %+v", response)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ScheduleInput sets or clears the publish and unpublish times of a post or page
+type ScheduleInput struct {
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+}
+
+// ScheduleEntry is one upcoming scheduled status change
+type ScheduleEntry struct {
+	ResourceType string    `json:"resource_type"`
+	ResourceID   uint      `json:"resource_id"`
+	Title        string    `json:"title"`
+	Status       string    `json:"status"`
+	Action       string    `json:"action"`
+	At           time.Time `json:"at"`
+}
+
+func bindScheduleInput(c *gin.Context) (*ScheduleInput, bool) {
+	var input ScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return nil, false
+	}
+	if input.PublishAt != nil && input.UnpublishAt != nil && !input.UnpublishAt.After(*input.PublishAt) {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "unpublish_at must be after publish_at"})
+		return nil, false
+	}
+	return &input, true
+}
+
+func scheduleUpdates(input *ScheduleInput) map[string]interface{} {
+	return map[string]interface{}{
+		"publish_at":   input.PublishAt,
+		"unpublish_at": input.UnpublishAt,
+	}
+}
+
+// SchedulePost sets when a post is automatically published and unpublished
+func SchedulePost(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid post ID"})
+		return
+	}
+	var post models.Post
+	if err := db.First(&post, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Post not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	if !middleware.CheckIfMatch(c, postETag(&post)) {
+		return
+	}
+	input, ok := bindScheduleInput(c)
+	if !ok {
+		return
+	}
+	before := auditSnapshot(&post)
+	tx := db.Begin()
+	if err := updateVersioned(tx, &post, post.Version, scheduleUpdates(input)); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePost, post.ID, models.AuditActionSchedule, before, auditSnapshot(&post)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePost, post.ID, models.EventScheduled, &post); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.Header("ETag", postETag(&post))
+	c.JSON(http.StatusOK, post)
+}
+
+// SchedulePage sets when a page is automatically published and unpublished
+func SchedulePage(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid page ID"})
+		return
+	}
+	var page models.Page
+	if err := db.First(&page, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Page not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	if !middleware.CheckIfMatch(c, pageETag(&page)) {
+		return
+	}
+	input, ok := bindScheduleInput(c)
+	if !ok {
+		return
+	}
+	before := auditSnapshot(&page)
+	tx := db.Begin()
+	if err := updateVersioned(tx, &page, page.Version, scheduleUpdates(input)); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePage, page.ID, models.AuditActionSchedule, before, auditSnapshot(&page)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePage, page.ID, models.EventScheduled, &page); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.Header("ETag", pageETag(&page))
+	c.JSON(http.StatusOK, page)
+}
+
+// scheduledRow holds the columns GetSchedule reads from posts and pages
+type scheduledRow struct {
+	ID          uint
+	Title       string
+	Status      string
+	PublishAt   *time.Time
+	UnpublishAt *time.Time
+}
+
+func appendScheduleEntries(entries []ScheduleEntry, resourceType string, rows []scheduledRow, until *time.Time) []ScheduleEntry {
+	include := func(at *time.Time) bool {
+		return at != nil && (until == nil || !at.After(*until))
+	}
+	for _, row := range rows {
+		if include(row.PublishAt) {
+			entries = append(entries, ScheduleEntry{ResourceType: resourceType, ResourceID: row.ID, Title: row.Title, Status: row.Status, Action: models.ActionPublish, At: *row.PublishAt})
+		}
+		if include(row.UnpublishAt) {
+			entries = append(entries, ScheduleEntry{ResourceType: resourceType, ResourceID: row.ID, Title: row.Title, Status: row.Status, Action: models.ActionUnpublish, At: *row.UnpublishAt})
+		}
+	}
+	return entries
+}
+
+// GetSchedule lists upcoming scheduled publish and unpublish events the caller may manage.
+// Use ?resource=posts|pages to narrow the list and ?until=<RFC3339> to limit how far ahead it looks.
+func GetSchedule(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var until *time.Time
+	if value := c.Query("until"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "until must be an RFC3339 timestamp"})
+			return
+		}
+		until = &parsed
+	}
+	resource := c.Query("resource")
+	if resource != "" && resource != "posts" && resource != "pages" {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "resource must be posts or pages"})
+		return
+	}
+
+	includePosts := resource != "pages" && middleware.HasPermission(c, middleware.PermPostsPublish, nil)
+	includePages := resource != "posts" && middleware.HasPermission(c, middleware.PermPagesPublish, nil)
+	if !includePosts && !includePages {
+		middleware.Forbidden(c)
+		return
+	}
+
+	entries := []ScheduleEntry{}
+	if includePosts {
+		var rows []scheduledRow
+		if err := db.Model(&models.Post{}).
+			Select("id, title, status, publish_at, unpublish_at").
+			Where("publish_at IS NOT NULL OR unpublish_at IS NOT NULL").
+			Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+		entries = appendScheduleEntries(entries, "post", rows, until)
+	}
+	if includePages {
+		var rows []scheduledRow
+		if err := db.Model(&models.Page{}).
+			Select("id, title, status, publish_at, unpublish_at").
+			Where("publish_at IS NOT NULL OR unpublish_at IS NOT NULL").
+			Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+		entries = appendScheduleEntries(entries, "page", rows, until)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+
+	c.JSON(http.StatusOK, gin.H{"data": entries})
+}
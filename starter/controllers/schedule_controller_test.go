@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSchedulePost(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "created_at", "updated_at"}).
+		AddRow(1, "Title", "Content", "Someone", models.StatusDraft, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts" SET "publish_at"=\$1,"unpublish_at"=\$2,"version"=\$3,"updated_at"=\$4 WHERE version = \$5 AND "posts"\."deleted_at" IS NULL AND "id" = \$6`).
+		WithArgs(sqlmock.AnyArg(), nil, 1, sqlmock.AnyArg(), 0, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	body := `{"publish_at":"2030-01-01T09:00:00Z","unpublish_at":null}`
+	router.PUT("/posts/:id/schedule", SchedulePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/posts/1/schedule", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response models.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.PublishAt == nil || response.PublishAt.Year() != 2030 {
+		t.Fatalf("Expected publish_at in 2030, got %v", response.PublishAt)
+	}
+}
+
+func TestSchedulePost_UnpublishBeforePublish(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "created_at", "updated_at"}).
+		AddRow(1, "Title", "Content", "Someone", models.StatusDraft, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+
+	body := `{"publish_at":"2030-01-02T09:00:00Z","unpublish_at":"2030-01-01T09:00:00Z"}`
+	router.PUT("/posts/:id/schedule", SchedulePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/posts/1/schedule", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestGetSchedule(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	soon := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	later := soon.Add(48 * time.Hour)
+	mock.ExpectQuery(`SELECT id, title, status, publish_at, unpublish_at FROM "posts" WHERE \(publish_at IS NOT NULL OR unpublish_at IS NOT NULL\) AND "posts"\."deleted_at" IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "publish_at", "unpublish_at"}).
+			AddRow(1, "Launch", models.StatusDraft, soon, later))
+	mock.ExpectQuery(`SELECT id, title, status, publish_at, unpublish_at FROM "pages" WHERE \(publish_at IS NOT NULL OR unpublish_at IS NOT NULL\) AND "pages"\."deleted_at" IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "publish_at", "unpublish_at"}).
+			AddRow(2, "Sale", models.StatusPublished, nil, soon.Add(time.Hour)))
+
+	router.GET("/schedule", GetSchedule)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/schedule?until=2030-01-02T00:00:00Z", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data []ScheduleEntry `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if len(response.Data) != 2 {
+		t.Fatalf("Expected 2 entries before the cutoff, got %d", len(response.Data))
+	}
+	if response.Data[0].ResourceType != "post" || response.Data[0].Action != models.ActionPublish {
+		t.Fatalf("Expected the post publish first, got %+v", response.Data[0])
+	}
+	if response.Data[1].ResourceType != "page" || response.Data[1].Action != models.ActionUnpublish {
+		t.Fatalf("Expected the page unpublish second, got %+v", response.Data[1])
+	}
+}
+
+func TestGetSchedule_ViewerForbidden(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 3, "viewer")
+
+	router.GET("/schedule", GetSchedule)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/schedule", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/search"
+	"cms-backend/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// bindSearchTypes reads the comma-separated type filter, answering 400 for a
+// type outside known
+func bindSearchTypes(c *gin.Context, known []string) ([]string, bool) {
+	value := c.Query("type")
+	if value == "" {
+		return nil, true
+	}
+	var types []string
+	for _, resourceType := range strings.Split(value, ",") {
+		resourceType = strings.TrimSpace(resourceType)
+		valid := false
+		for _, name := range known {
+			valid = valid || resourceType == name
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Unknown type " + resourceType + "; use " + strings.Join(known, ", ")})
+			return nil, false
+		}
+		types = append(types, resourceType)
+	}
+	return types, true
+}
+
+// Search runs a full-text query over posts, pages and media. q takes web
+// search syntax; type limits the hits to a comma-separated list of types and
+// lang picks the text search configuration. Facets count the matches of every
+// type, whatever the type filter.
+func Search(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Query parameter q is required"})
+		return
+	}
+	types, ok := bindSearchTypes(c, search.Types)
+	if !ok {
+		return
+	}
+	language := c.DefaultQuery("lang", search.DefaultLanguage)
+	if !search.ValidLanguage(language) {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Unknown search language " + language})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	// An external index, when configured, takes over from the database
+	indexer := search.Default()
+	if indexer == nil {
+		indexer = search.NewPostgres(db)
+	}
+	results, err := indexer.Search(c.Request.Context(), search.Query{
+		Text:             text,
+		Types:            types,
+		Language:         language,
+		UnpublishedPosts: middleware.HasPermission(c, middleware.PermPostsPublish, nil),
+		UnpublishedPages: canViewUnpublishedPages(c),
+		Limit:            pageSize,
+		Offset:           (page - 1) * pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       results.Hits,
+		"facets":     results.Facets,
+		"language":   language,
+		"page":       page,
+		"page_size":  pageSize,
+		"total":      results.Total,
+		"total_page": (results.Total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// SuggestSearch offers titles, media filenames and tag names close to what
+// has been typed so far, tolerating typos. type limits the suggestions to a
+// comma-separated list of post, page, media and tag; limit defaults to 10.
+func SuggestSearch(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Query parameter q is required"})
+		return
+	}
+	types, ok := bindSearchTypes(c, search.SuggestTypes)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 25 {
+		limit = 10
+	}
+
+	suggestions, err := search.Suggest(db, search.SuggestQuery{
+		Text:             text,
+		Types:            types,
+		UnpublishedPosts: middleware.HasPermission(c, middleware.PermPostsPublish, nil),
+		UnpublishedPages: canViewUnpublishedPages(c),
+		Limit:            limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": suggestions})
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	t.Run("Anonymous", func(t *testing.T) {
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+		defer mock.ExpectClose()
+
+		now := time.Now()
+		mock.ExpectQuery(`FROM posts, q WHERE deleted_at IS NULL AND .* AND status = 'published' UNION ALL .* FROM pages, q WHERE deleted_at IS NULL AND .* AND status = 'published' UNION ALL .* GROUP BY type`).
+			WithArgs("launch").
+			WillReturnRows(sqlmock.NewRows([]string{"type", "count"}).AddRow("post", 1).AddRow("page", 1))
+		mock.ExpectQuery(`ts_headline\('english'::regconfig`).
+			WithArgs("launch", 20, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "id", "title", "slug", "url", "status", "created_at", "rank", "snippet"}).
+				AddRow("page", 2, "Launch", "launch", "", "published", now, 0.9, "<mark>Launch</mark> day").
+				AddRow("post", 7, "News", "news", "", "published", now, 0.1, "before the <mark>launch</mark>"))
+
+		router.GET("/search", Search)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/search?q=launch", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Data     []map[string]interface{} `json:"data"`
+			Facets   map[string]int64         `json:"facets"`
+			Language string                   `json:"language"`
+			Total    int64                    `json:"total"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Data, 2)
+		assert.Equal(t, "page", response.Data[0]["type"])
+		assert.Equal(t, "<mark>Launch</mark> day", response.Data[0]["snippet"])
+		assert.Equal(t, map[string]int64{"post": 1, "page": 1, "media": 0}, response.Facets)
+		assert.Equal(t, "english", response.Language)
+		assert.EqualValues(t, 2, response.Total)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("LanguageAndTypes", func(t *testing.T) {
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+		defer mock.ExpectClose()
+
+		mock.ExpectQuery(`websearch_to_tsquery\('french'::regconfig, \$1\)`).
+			WithArgs("lancement").
+			WillReturnRows(sqlmock.NewRows([]string{"type", "count"}))
+
+		router.GET("/search", Search)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/search?q=lancement&lang=french&type=post,page", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotContains(t, w.Body.String(), `"data":null`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	for name, query := range map[string]string{
+		"MissingQuery":    "/search?q=%20",
+		"UnknownType":     "/search?q=launch&type=post,comment",
+		"UnknownLanguage": "/search?q=launch&lang=klingon",
+	} {
+		t.Run(name, func(t *testing.T) {
+			router, _, mock := utils.SetupRouterAndMockDB(t)
+			defer mock.ExpectClose()
+
+			router.GET("/search", Search)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSuggestSearch(t *testing.T) {
+	t.Run("EditorSeesDrafts", func(t *testing.T) {
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+		defer mock.ExpectClose()
+		authenticateAs(router, 1, "editor")
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`set_config`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`FROM posts WHERE deleted_at IS NULL AND \$3 <% title \) AS suggestions`).
+			WithArgs("draf", "draf%", "draf", 3).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "id", "label", "slug", "status", "score"}).
+				AddRow("post", 4, "Draft notes", "draft-notes", "draft", 1.25))
+		mock.ExpectCommit()
+
+		router.GET("/search/suggest", SuggestSearch)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/search/suggest?q=draf&type=post&limit=3", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"data":[{"type":"post","id":4,"label":"Draft notes","slug":"draft-notes","status":"draft","score":1.25}]}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("UnknownType", func(t *testing.T) {
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+		defer mock.ExpectClose()
+
+		router.GET("/search/suggest", SuggestSearch)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/search/suggest?q=go&type=category", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "post, page, media, tag")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errSlugTaken   = errors.New("slug is already in use")
+	errSlugInvalid = errors.New("slug must contain at least one letter or digit")
+)
+
+// resolveSlug picks a free slug for the row with the given id (0 when creating).
+// An explicitly requested slug must be free; one generated from the title gets a
+// numeric suffix (-2, -3, ...) until it is. Trashed rows keep their slug, so
+// they count as taken.
+func resolveSlug(tx *gorm.DB, model interface{}, resourceType, requested, title string, id uint) (string, error) {
+	if requested != "" {
+		slug := utils.Slugify(requested)
+		if slug == "" {
+			return "", errSlugInvalid
+		}
+		var count int64
+		if err := tx.Unscoped().Model(model).Where("slug = ? AND id <> ?", slug, id).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count > 0 {
+			return "", errSlugTaken
+		}
+		return slug, nil
+	}
+
+	base := utils.Slugify(title)
+	if base == "" {
+		base = resourceType
+	}
+	var taken []string
+	if err := tx.Unscoped().Model(model).
+		Where("(slug = ? OR slug LIKE ?) AND id <> ?", base, base+"-%", id).
+		Pluck("slug", &taken).Error; err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(taken))
+	for _, slug := range taken {
+		used[slug] = true
+	}
+	slug := base
+	for n := 2; used[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	return slug, nil
+}
+
+// applySlug fills in *slug on create and handles explicit renames on update.
+// Renaming leaves a redirect behind so the old permalink keeps working.
+func applySlug(tx *gorm.DB, model interface{}, resourceType string, slug *string, requested, title string, id uint) error {
+	if requested == "" && *slug != "" {
+		return nil
+	}
+	if requested != "" && utils.Slugify(requested) == *slug {
+		return nil
+	}
+	next, err := resolveSlug(tx, model, resourceType, requested, title, id)
+	if err != nil {
+		return err
+	}
+	if *slug != "" {
+		redirect := models.SlugRedirect{ResourceType: resourceType, Slug: *slug, ResourceID: id}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "resource_type"}, {Name: "slug"}},
+			DoUpdates: clause.AssignmentColumns([]string{"resource_id"}),
+		}).Create(&redirect).Error; err != nil {
+			return err
+		}
+		// The new slug is live again, so any redirect still claiming it is stale
+		if err := tx.Where("resource_type = ? AND slug = ?", resourceType, next).
+			Delete(&models.SlugRedirect{}).Error; err != nil {
+			return err
+		}
+	}
+	*slug = next
+	return nil
+}
+
+// findSlugRedirect looks up the resource that used to own slug, writing a 404
+// or 500 response when there is none
+func findSlugRedirect(c *gin.Context, db *gorm.DB, resourceType, slug, notFound string) (uint, bool) {
+	var redirect models.SlugRedirect
+	if err := db.Where("resource_type = ? AND slug = ?", resourceType, slug).First(&redirect).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: notFound})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return 0, false
+	}
+	return redirect.ResourceID, true
+}
+
+// redirectToSlug answers 301 with the same URL, the last segment swapped for slug
+func redirectToSlug(c *gin.Context, slug string) {
+	location := path.Join(path.Dir(c.Request.URL.Path), url.PathEscape(slug))
+	if c.Request.URL.RawQuery != "" {
+		location += "?" + c.Request.URL.RawQuery
+	}
+	c.Redirect(http.StatusMovedPermanently, location)
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectSlugLookup expects the query resolveSlug runs for a generated slug,
+// answering with the slugs already taken
+func expectSlugLookup(mock sqlmock.Sqlmock, table, base string, taken ...string) {
+	rows := sqlmock.NewRows([]string{"slug"})
+	for _, slug := range taken {
+		rows.AddRow(slug)
+	}
+	mock.ExpectQuery(`SELECT "slug" FROM "`+table+`" WHERE \(slug = \$1 OR slug LIKE \$2\) AND id <> \$3`).
+		WithArgs(base, base+"-%", sqlmock.AnyArg()).
+		WillReturnRows(rows)
+}
+
+func TestCreatePost_SlugCollision(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectBegin()
+	expectSlugLookup(mock, "posts", "my-post", "my-post", "my-post-2", "my-post-extra")
+	mock.ExpectQuery(`INSERT INTO "posts"`).
+		WithArgs("My Post", "my-post-3", "Content", "", nil, models.StatusDraft, nil, nil, nil, 1, sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	expectRevision(mock, models.RevisionResourcePost, 7, 0)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug"}).AddRow(7, "My Post", "my-post-3"))
+	expectNoPostCategories(mock)
+	mock.ExpectQuery(`SELECT \* FROM "post_media"`).WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	expectNoPostTags(mock)
+
+	router.POST("/posts", CreatePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"title":"My Post","content":"Content"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var response models.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.Slug != "my-post-3" {
+		t.Fatalf("Expected slug my-post-3, got %s", response.Slug)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCreatePage_SlugTaken(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "pages" WHERE slug = \$1 AND id <> \$2`).
+		WithArgs("about-us", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	router.POST("/pages", CreatePage)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/pages", strings.NewReader(`{"title":"About","content":"Content","slug":"About Us"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdatePost_RenameSlug(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug", "content", "author", "status", "version", "created_at", "updated_at"}).
+			AddRow(1, "Title", "old-slug", "Content", "Author", models.StatusPublished, 1, now, now))
+	mock.ExpectQuery(`SELECT \* FROM "post_media"`).WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "posts" WHERE slug = \$1 AND id <> \$2`).
+		WithArgs("new-slug", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO "slug_redirects" \("resource_type","slug","resource_id","created_at"\) VALUES \(\$1,\$2,\$3,\$4\) ON CONFLICT \("resource_type","slug"\) DO UPDATE SET "resource_id"="excluded"\."resource_id"`).
+		WithArgs(models.RevisionResourcePost, "old-slug", 1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`DELETE FROM "slug_redirects" WHERE resource_type = \$1 AND slug = \$2`).
+		WithArgs(models.RevisionResourcePost, "new-slug").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "posts" SET "title"=\$1,"slug"=\$2`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectRevision(mock, models.RevisionResourcePost, 1, 1)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug"}).AddRow(1, "Title", "new-slug"))
+	expectNoPostCategories(mock)
+	mock.ExpectQuery(`SELECT \* FROM "post_media"`).WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	expectNoPostTags(mock)
+
+	router.PUT("/posts/:id", UpdatePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/posts/1", strings.NewReader(`{"title":"Title","content":"Content","slug":"New Slug"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
+
+func TestGetPostBySlug(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE slug = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
+		WithArgs("my-first-post", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug", "content", "status", "version", "created_at", "updated_at"}).
+			AddRow(3, "My First Post", "my-first-post", "Content", models.StatusPublished, 1, now, now))
+	expectNoPostCategories(mock)
+	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	expectNoPostTags(mock)
+
+	router.GET("/posts/slug/:slug", GetPostBySlug)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/slug/my-first-post", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response models.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.ID != 3 {
+		t.Fatalf("Expected post 3, got %d", response.ID)
+	}
+}
+
+func TestGetPostBySlug_Redirect(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE slug = \$1`).
+		WithArgs("old-slug", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "slug_redirects" WHERE resource_type = \$1 AND slug = \$2`).
+		WithArgs(models.RevisionResourcePost, "old-slug", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "resource_type", "slug", "resource_id"}).
+			AddRow(1, models.RevisionResourcePost, "old-slug", 3))
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug", "status", "created_at", "updated_at"}).
+			AddRow(3, "Renamed", "new-slug", models.StatusPublished, now, now))
+
+	router.GET("/api/v1/posts/slug/:slug", GetPostBySlug)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/posts/slug/old-slug?preview=1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("Expected status 301, got %d: %s", w.Code, w.Body.String())
+	}
+	if location := w.Header().Get("Location"); location != "/api/v1/posts/slug/new-slug?preview=1" {
+		t.Fatalf("Unexpected Location %s", location)
+	}
+}
+
+func TestGetPageBySlug_NotFound(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE slug = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "slug_redirects" WHERE resource_type = \$1 AND slug = \$2`).
+		WithArgs(models.RevisionResourcePage, "missing", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	router.GET("/pages/slug/:slug", GetPageBySlug)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/pages/slug/missing", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+}
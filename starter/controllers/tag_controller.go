@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+var tagValidator = validator.New()
+
+type TagInput struct {
+	Name string `json:"name" validate:"required,max=100"`
+	Slug string `json:"slug"`
+}
+
+const tagResource = "tag"
+
+// GetTags lists tags by name, optionally filtered with search
+func GetTags(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	query := db.Order("name asc")
+	if search := c.Query("search"); search != "" {
+		query = query.Where("name ILIKE ?", "%"+search+"%")
+	}
+	var tags []models.Tag
+	if err := query.Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+// GetTagCounts returns the most used tags with their number of published
+// posts, for tag clouds
+func GetTagCounts(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	var counts []models.TagCount
+	if err := db.Model(&models.Tag{}).
+		Select("tags.id, tags.name, tags.slug, COUNT(posts.id) AS count").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.status = ? AND posts.deleted_at IS NULL", models.StatusPublished).
+		Group("tags.id").
+		Order("count desc, tags.name asc").
+		Limit(limit).
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if counts == nil {
+		counts = []models.TagCount{}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": counts})
+}
+
+// GetTag retrieves a tag by ID
+func GetTag(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid tag ID"})
+		return
+	}
+	var tag models.Tag
+	if err := db.First(&tag, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Tag not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, tag)
+}
+
+// CreateTag creates a tag
+func CreateTag(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := tagValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return
+	}
+	tag := models.Tag{Name: input.Name}
+	tx := db.Begin()
+	slug, err := resolveSlug(tx, &models.Tag{}, tagResource, input.Slug, input.Name, 0)
+	if err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	tag.Slug = slug
+	if err := tx.Create(&tag).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, tagResource, tag.ID, models.EventCreated, &tag); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, tagResource, tag.ID, models.AuditActionCreate, nil, auditSnapshot(&tag)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag renames a tag
+func UpdateTag(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid tag ID"})
+		return
+	}
+	var tag models.Tag
+	if err := db.First(&tag, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Tag not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	before := auditSnapshot(&tag)
+	var input TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := tagValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return
+	}
+	tag.Name = input.Name
+	tx := db.Begin()
+	if input.Slug != "" && utils.Slugify(input.Slug) != tag.Slug {
+		slug, err := resolveSlug(tx, &models.Tag{}, tagResource, input.Slug, input.Name, tag.ID)
+		if err != nil {
+			tx.Rollback()
+			respondWriteError(c, err)
+			return
+		}
+		tag.Slug = slug
+	}
+	if err := tx.Save(&tag).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, tagResource, tag.ID, models.EventUpdated, &tag); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, tagResource, tag.ID, models.AuditActionUpdate, before, auditSnapshot(&tag)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag removes a tag from all posts and deletes it
+func DeleteTag(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid tag ID"})
+		return
+	}
+	var tag models.Tag
+	if err := db.First(&tag, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Tag not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	tx := db.Begin()
+	if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.PostTag{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := tx.Delete(&tag).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, tagResource, tag.ID, models.EventDeleted, &tag); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, tagResource, tag.ID, models.AuditActionDelete, auditSnapshot(&tag), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Tag deleted"})
+}
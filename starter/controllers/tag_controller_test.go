@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetTagCounts(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	rows := sqlmock.NewRows([]string{"id", "name", "slug", "count"}).
+		AddRow(2, "Go", "go", 12).
+		AddRow(5, "Databases", "databases", 4)
+	mock.ExpectQuery(`SELECT tags\.id, tags\.name, tags\.slug, COUNT\(posts\.id\) AS count FROM "tags" `+
+		`JOIN post_tags ON post_tags\.tag_id = tags\.id `+
+		`JOIN posts ON posts\.id = post_tags\.post_id AND posts\.status = \$1 AND posts\.deleted_at IS NULL `+
+		`GROUP BY "tags"\."id" ORDER BY count desc, tags\.name asc LIMIT \$2`).
+		WithArgs(models.StatusPublished, 10).
+		WillReturnRows(rows)
+
+	router.GET("/tags/counts", GetTagCounts)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/tags/counts?limit=10", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data []models.TagCount `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if len(response.Data) != 2 || response.Data[0].Slug != "go" || response.Data[0].Count != 12 {
+		t.Fatalf("Unexpected counts: %+v", response.Data)
+	}
+}
+
+func TestCreateTag_SlugTaken(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "tags" WHERE slug = \$1 AND id <> \$2`).
+		WithArgs("golang", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	router.POST("/tags", CreateTag)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/tags", strings.NewReader(`{"name":"Go","slug":"golang"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "tags" WHERE "tags"\."id" = \$1`).
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "created_at", "updated_at"}).
+			AddRow(5, "Go", "go", now, now))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "post_tags" WHERE tag_id = \$1`).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM "tags" WHERE "tags"\."id" = \$1`).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectOutboxEvent(mock)
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	router.DELETE("/tags/:id", DeleteTag)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/tags/5", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
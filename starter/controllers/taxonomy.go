@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// uniqueIDs drops duplicates while keeping the original order
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// checkTermIDs verifies every id exists in model's table, writing a 400 or
+// 500 response when not
+func checkTermIDs(c *gin.Context, db *gorm.DB, model interface{}, ids []uint, invalid string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	var count int64
+	if err := db.Model(model).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return false
+	}
+	if count != int64(len(ids)) {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: invalid})
+		return false
+	}
+	return true
+}
+
+// checkPostTerms normalises and validates the category and tag IDs of a post
+// write. Nil slices are left alone so updates can tell "unchanged" from "none".
+func checkPostTerms(c *gin.Context, db *gorm.DB, input *PostInput) bool {
+	if input.CategoryIDs != nil {
+		input.CategoryIDs = uniqueIDs(input.CategoryIDs)
+		if !checkTermIDs(c, db, &models.Category{}, input.CategoryIDs, "Invalid category IDs") {
+			return false
+		}
+	}
+	if input.TagIDs != nil {
+		input.TagIDs = uniqueIDs(input.TagIDs)
+		if !checkTermIDs(c, db, &models.Tag{}, input.TagIDs, "Invalid tag IDs") {
+			return false
+		}
+	}
+	return true
+}
+
+// setPostTerms replaces the post's category and tag links with the given IDs;
+// a nil slice keeps the existing links of that kind
+func setPostTerms(tx *gorm.DB, postID uint, categoryIDs, tagIDs []uint) error {
+	if categoryIDs != nil {
+		if err := tx.Where("post_id = ?", postID).Delete(&models.PostCategory{}).Error; err != nil {
+			return err
+		}
+		if len(categoryIDs) > 0 {
+			links := make([]models.PostCategory, 0, len(categoryIDs))
+			for _, id := range categoryIDs {
+				links = append(links, models.PostCategory{PostID: postID, CategoryID: id})
+			}
+			if err := tx.Create(&links).Error; err != nil {
+				return err
+			}
+		}
+	}
+	if tagIDs != nil {
+		if err := tx.Where("post_id = ?", postID).Delete(&models.PostTag{}).Error; err != nil {
+			return err
+		}
+		if len(tagIDs) > 0 {
+			links := make([]models.PostTag, 0, len(tagIDs))
+			for _, id := range tagIDs {
+				links = append(links, models.PostTag{PostID: postID, TagID: id})
+			}
+			if err := tx.Create(&links).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// preloadPostTerms loads the categories and tags shown alongside a post
+func preloadPostTerms(db *gorm.DB) *gorm.DB {
+	return db.Preload("Categories").Preload("Tags")
+}
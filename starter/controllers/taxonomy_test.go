@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreatePost_WithCategoriesAndTags(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "categories" WHERE id IN \(\$1,\$2\)`).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "tags" WHERE id IN \(\$1\)`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectBegin()
+	expectSlugLookup(mock, "posts", "tagged")
+	mock.ExpectQuery(`INSERT INTO "posts"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`DELETE FROM "post_categories" WHERE post_id = \$1`).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "post_categories" \("post_id","category_id"\) VALUES \(\$1,\$2\),\(\$3,\$4\)`).
+		WithArgs(7, 1, 7, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM "post_tags" WHERE post_id = \$1`).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "post_tags" \("post_id","tag_id"\) VALUES \(\$1,\$2\)`).
+		WithArgs(7, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock, models.RevisionResourcePost, 7, 0)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug"}).AddRow(7, "Tagged", "tagged"))
+	mock.ExpectQuery(`SELECT \* FROM "post_categories" WHERE "post_categories"\."post_id" = \$1`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "category_id"}).AddRow(7, 1).AddRow(7, 2))
+	mock.ExpectQuery(`SELECT \* FROM "categories" WHERE "categories"\."id" IN \(\$1,\$2\)`).
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "created_at", "updated_at"}).
+			AddRow(1, "News", "news", now, now).
+			AddRow(2, "Local", "local", now, now))
+	mock.ExpectQuery(`SELECT \* FROM "post_media"`).WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	mock.ExpectQuery(`SELECT \* FROM "post_tags" WHERE "post_tags"\."post_id" = \$1`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "tag_id"}).AddRow(7, 3))
+	mock.ExpectQuery(`SELECT \* FROM "tags" WHERE "tags"\."id" = \$1`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "slug", "created_at", "updated_at"}).AddRow(3, "Go", "go", now, now))
+
+	router.POST("/posts", CreatePost)
+	w := httptest.NewRecorder()
+	body := `{"title":"Tagged","content":"Content","category_ids":[1,2,1],"tag_ids":[3]}`
+	req, _ := http.NewRequest(http.MethodPost, "/posts", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var response models.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if len(response.Categories) != 2 || len(response.Tags) != 1 || response.Tags[0].Slug != "go" {
+		t.Fatalf("Unexpected terms: %+v %+v", response.Categories, response.Tags)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
+
+func TestCreatePost_InvalidTagIDs(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "tags" WHERE id IN \(\$1,\$2\)`).
+		WithArgs(3, 99).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	router.POST("/posts", CreatePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts", strings.NewReader(`{"title":"Tagged","content":"Content","tag_ids":[3,99]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdatePost_ClearsTags(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug", "content", "author", "status", "version", "created_at", "updated_at"}).
+			AddRow(1, "Title", "title", "Content", "Author", models.StatusPublished, 1, now, now))
+	mock.ExpectQuery(`SELECT \* FROM "post_media"`).WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts" SET`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM "post_tags" WHERE post_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	expectRevision(mock, models.RevisionResourcePost, 1, 1)
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.PUT("/posts/:id", UpdatePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/posts/1", strings.NewReader(`{"title":"Title","content":"Content","tag_ids":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
+
+func TestGetPosts_FilterByCategoryAndTag(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	categoryFilter := `id IN \(SELECT pc\.post_id FROM post_categories pc WHERE pc\.category_id IN \(\s*WITH RECURSIVE tree AS`
+	tagFilter := `id IN \(SELECT pt\.post_id FROM post_tags pt JOIN tags t ON t\.id = pt\.tag_id WHERE t\.slug = \$3\)`
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "posts" WHERE \(status = \$1 AND `+categoryFilter+`[\s\S]*`+tagFilter).
+		WithArgs(models.StatusPublished, "news", "go").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE \(status = \$1 AND `+categoryFilter).
+		WithArgs(models.StatusPublished, "news", "go", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "created_at", "updated_at"}).
+			AddRow(4, "Local elections", models.StatusPublished, now, now))
+	expectNoPostCategories(mock)
+	mock.ExpectQuery(`SELECT \* FROM "post_media"`).WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	expectNoPostTags(mock)
+
+	router.GET("/posts", GetPosts)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts?category=news&tag=go", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/storage"
+	"cms-backend/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// listTrash writes a page of soft-deleted rows, most recently trashed first.
+// query selects the model and any extra conditions; dest is a pointer to a slice.
+func listTrash(c *gin.Context, query *gorm.DB, dest interface{}) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+	offset := (page - 1) * pageSize
+
+	query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := query.Order("deleted_at desc").Limit(pageSize).Offset(offset).Find(dest).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       dest,
+		"page":       page,
+		"page_size":  pageSize,
+		"total":      total,
+		"total_page": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// findTrashed loads a soft-deleted row by the :id parameter, writing 400 or
+// 404 when there is none. Rows that are not in the trash count as not found.
+func findTrashed(c *gin.Context, query *gorm.DB, dest interface{}, name string) bool {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid " + name + " ID"})
+		return false
+	}
+	if err := query.Unscoped().Where("deleted_at IS NOT NULL").First(dest, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "No " + name + " with this ID in the trash"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return false
+	}
+	return true
+}
+
+// restoreTrashed clears deleted_at on a trashed row and records it in the
+// audit log, writing 500 on failure
+func restoreTrashed(c *gin.Context, db *gorm.DB, model interface{}, resourceType string, id uint) bool {
+	before := auditSnapshot(model)
+	tx := db.Begin()
+	if err := tx.Unscoped().Model(model).Update("deleted_at", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return false
+	}
+	if err := recordAudit(c, tx, resourceType, id, models.AuditActionRestore, before, auditSnapshot(model)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return false
+	}
+	if err := outbox.Publish(tx, resourceType, id, models.EventRestored, model); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return false
+	}
+	tx.Commit()
+	return true
+}
+
+// purgeTrashed removes a trashed row for good along with the revisions and
+// slug redirects of a post or page, records it in the audit log and publishes
+// a purged event, writing 500 on failure. Join rows and renditions go with it
+// through ON DELETE CASCADE.
+func purgeTrashed(c *gin.Context, db *gorm.DB, model interface{}, resourceType string, id uint) bool {
+	tx := db.Begin()
+	if err := tx.Unscoped().Delete(model).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return false
+	}
+	if resourceType != models.AuditResourceMedia {
+		if err := models.DeleteHistory(tx, resourceType, id); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return false
+		}
+	}
+	if err := recordAudit(c, tx, resourceType, id, models.AuditActionPurge, auditSnapshot(model), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return false
+	}
+	if err := outbox.Publish(tx, resourceType, id, models.EventPurged, model); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return false
+	}
+	tx.Commit()
+	return true
+}
+
+// trashedPostsQuery limits authors who may only delete their own posts to
+// their own trash
+func trashedPostsQuery(c *gin.Context, db *gorm.DB) *gorm.DB {
+	query := db.Model(&models.Post{})
+	if !middleware.HasPermission(c, middleware.PermPostsDelete, nil) {
+		claims, _ := middleware.CurrentUser(c)
+		query = query.Where("owner_id = ?", claims.UserID)
+	}
+	return query
+}
+
+// findTrashedPost loads a trashed post the caller may delete
+func findTrashedPost(c *gin.Context) (*models.Post, bool) {
+	db := c.MustGet("db").(*gorm.DB)
+	var post models.Post
+	if !findTrashed(c, db, &post, "post") {
+		return nil, false
+	}
+	if !middleware.HasPermission(c, middleware.PermPostsDelete, post.OwnerID) {
+		middleware.Forbidden(c)
+		return nil, false
+	}
+	return &post, true
+}
+
+// GetTrashedPosts lists the posts in the trash
+func GetTrashedPosts(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var posts []models.Post
+	listTrash(c, trashedPostsQuery(c, db), &posts)
+}
+
+// RestorePost moves a post out of the trash
+func RestorePost(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	post, ok := findTrashedPost(c)
+	if !ok || !restoreTrashed(c, db, post, models.RevisionResourcePost, post.ID) {
+		return
+	}
+	outbox.Notify()
+
+	c.Header("ETag", postETag(post))
+	c.JSON(http.StatusOK, post)
+}
+
+// PurgePost deletes a trashed post permanently
+func PurgePost(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	post, ok := findTrashedPost(c)
+	if !ok || !purgeTrashed(c, db, post, models.RevisionResourcePost, post.ID) {
+		return
+	}
+	outbox.Notify()
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Post permanently deleted"})
+}
+
+// GetTrashedPages lists the pages in the trash
+func GetTrashedPages(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var pages []models.Page
+	listTrash(c, db.Model(&models.Page{}), &pages)
+}
+
+// RestorePage moves a page out of the trash
+func RestorePage(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var page models.Page
+	if !findTrashed(c, db, &page, "page") || !restoreTrashed(c, db, &page, models.RevisionResourcePage, page.ID) {
+		return
+	}
+	outbox.Notify()
+
+	c.Header("ETag", pageETag(&page))
+	c.JSON(http.StatusOK, page)
+}
+
+// PurgePage deletes a trashed page permanently
+func PurgePage(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var page models.Page
+	if !findTrashed(c, db, &page, "page") || !purgeTrashed(c, db, &page, models.RevisionResourcePage, page.ID) {
+		return
+	}
+	outbox.Notify()
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Page permanently deleted"})
+}
+
+// GetTrashedMedia lists the media in the trash
+func GetTrashedMedia(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var media []models.Media
+	listTrash(c, db.Model(&models.Media{}).Preload("Renditions"), &media)
+}
+
+// RestoreMedia moves media out of the trash. Attachments were kept while it
+// was trashed, so the posts using it show it again.
+func RestoreMedia(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var media models.Media
+	if !findTrashed(c, db.Preload("Renditions"), &media, "media") || !restoreTrashed(c, db, &media, models.AuditResourceMedia, media.ID) {
+		return
+	}
+	outbox.Notify()
+
+	c.Header("ETag", mediaETag(&media))
+	c.JSON(http.StatusOK, media)
+}
+
+// PurgeMedia deletes trashed media permanently along with its stored file and
+// renditions
+func PurgeMedia(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var media models.Media
+	if !findTrashed(c, db.Preload("Renditions"), &media, "media") || !purgeTrashed(c, db, &media, models.AuditResourceMedia, media.ID) {
+		return
+	}
+	outbox.Notify()
+	storage.Remove(c.Request.Context(), media.StorageDriver, media.StorageKeys()...)
+
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Media permanently deleted"})
+}
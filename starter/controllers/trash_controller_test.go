@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var trashedPostColumns = []string{"id", "title", "content", "status", "owner_id", "version", "created_at", "updated_at", "deleted_at"}
+
+func expectTrashedPost(mock sqlmock.Sqlmock, ownerID uint) {
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE deleted_at IS NOT NULL AND "posts"\."id" = \$1 ORDER BY "posts"\."id" LIMIT \$2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(trashedPostColumns).
+			AddRow(1, "Title", "Content", models.StatusDraft, ownerID, 2, now, now, now))
+}
+
+func TestGetTrashedPosts_AuthorSeesOwnTrash(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 3, "author")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "posts" WHERE owner_id = \$1 AND deleted_at IS NOT NULL`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE owner_id = \$1 AND deleted_at IS NOT NULL ORDER BY deleted_at desc LIMIT \$2`).
+		WithArgs(3, 10).
+		WillReturnRows(sqlmock.NewRows(trashedPostColumns).
+			AddRow(1, "Title", "Content", models.StatusDraft, 3, 2, now, now, now))
+
+	router.GET("/posts/trash", GetTrashedPosts)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/trash", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data  []map[string]interface{} `json:"data"`
+		Total int64                    `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.EqualValues(t, 1, response.Total)
+	require.Len(t, response.Data, 1)
+	assert.NotEmpty(t, response.Data[0]["deleted_at"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestorePost(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	expectTrashedPost(mock, 3)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts" SET "deleted_at"=\$1,"updated_at"=\$2 WHERE "id" = \$3`).
+		WithArgs(nil, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.POST("/posts/:id/restore", RestorePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts/1/restore", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Nil(t, response["deleted_at"])
+	assert.Equal(t, `"post-1-v2"`, w.Header().Get("ETag"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestorePost_NotInTrash(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE deleted_at IS NOT NULL AND "posts"\."id" = \$1`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(trashedPostColumns))
+
+	router.POST("/posts/:id/restore", RestorePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts/1/restore", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgePost(t *testing.T) {
+	t.Run("Owner", func(t *testing.T) {
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+		defer mock.ExpectClose()
+		authenticateAs(router, 3, "author")
+
+		expectTrashedPost(mock, 3)
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "posts" WHERE "posts"\."id" = \$1`).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`DELETE FROM "revisions" WHERE resource_type = \$1 AND resource_id IN \(\$2\)`).
+			WithArgs("post", 1).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`DELETE FROM "slug_redirects" WHERE resource_type = \$1 AND resource_id IN \(\$2\)`).
+			WithArgs("post", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit(mock)
+		mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+			WithArgs(sqlmock.AnyArg(), "post.purged", "post", 1, sqlmock.AnyArg(), 0, sqlmock.AnyArg(), "", nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		router.DELETE("/posts/:id/purge", PurgePost)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/posts/1/purge", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("OtherAuthor", func(t *testing.T) {
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+		defer mock.ExpectClose()
+		authenticateAs(router, 4, "author")
+
+		expectTrashedPost(mock, 3)
+
+		router.DELETE("/posts/:id/purge", PurgePost)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodDelete, "/posts/1/purge", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetTrashedPages(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "pages" WHERE deleted_at IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE deleted_at IS NOT NULL ORDER BY deleted_at desc LIMIT \$1 OFFSET \$2`).
+		WithArgs(5, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	router.GET("/pages/trash", GetTrashedPages)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/pages/trash?page=2&page_size=5", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeMedia_RemovesFiles(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	root := useLocalStorage(t)
+	dir := filepath.Join(root, "2024", "05")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	for _, name := range []string{"abc.png", "abc-w150.png"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), testPNG, 0o644))
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE deleted_at IS NOT NULL AND "media"\."id" = \$1`).
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows(storedImageColumns).AddRow(storedImageRow(200)...))
+	mock.ExpectQuery(`SELECT \* FROM "media_renditions" WHERE "media_renditions"\."media_id" = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "media_id", "storage_key"}).AddRow(1, 5, "2024/05/abc-w150.png"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "media" WHERE "media"\."id" = \$1`).
+		WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.DELETE("/media/:id/purge", PurgeMedia)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/media/5/purge", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoFileExists(t, filepath.Join(dir, "abc.png"))
+	assert.NoFileExists(t, filepath.Join(dir, "abc-w150.png"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
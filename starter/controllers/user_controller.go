@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+var userValidator = validator.New()
+
+type CreateUserInput struct {
+	Username string `json:"username" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8"`
+	Role     string `json:"role" validate:"required"`
+}
+
+type UpdateUserInput struct {
+	Email    string `json:"email" validate:"omitempty,email"`
+	Password string `json:"password" validate:"omitempty,min=8"`
+	Role     string `json:"role"`
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate
+// value for a unique index
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// respondUserSaveError answers a failed insert or update of a user, mapping a
+// taken username or email to 409
+func respondUserSaveError(c *gin.Context, err error) {
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, utils.HTTPError{Code: 409, Message: "Username or email is already taken"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+}
+
+// GetUsers lists all user accounts
+func GetUsers(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var users []models.User
+	if err := db.Order("id asc").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": users})
+}
+
+// CreateUser creates an account with the given role
+func CreateUser(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	var input CreateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := userValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return
+	}
+	if !models.IsValidRole(input.Role) {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid role"})
+		return
+	}
+
+	user := models.User{Username: input.Username, Email: input.Email, Role: input.Role}
+	if err := user.SetPassword(input.Password); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: "Failed to hash password"})
+		return
+	}
+	tx := db.Begin()
+	if err := tx.Create(&user).Error; err != nil {
+		tx.Rollback()
+		respondUserSaveError(c, err)
+		return
+	}
+	if err := recordAudit(c, tx, models.AuditResourceUser, user.ID, models.AuditActionCreate, nil, auditSnapshot(&user)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// UpdateUser changes a user's email, password or role. A new role applies to
+// the user's next request; a new password invalidates their tokens.
+func UpdateUser(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid user ID"})
+		return
+	}
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	before := auditSnapshot(&user)
+	var input UpdateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if err := userValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return
+	}
+	if input.Role != "" {
+		if !models.IsValidRole(input.Role) {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid role"})
+			return
+		}
+		if claims, ok := middleware.CurrentUser(c); ok && claims.UserID == user.ID && input.Role != user.Role {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "You cannot change your own role"})
+			return
+		}
+		user.Role = input.Role
+	}
+	if input.Email != "" {
+		user.Email = input.Email
+	}
+	if input.Password != "" {
+		if err := user.SetPassword(input.Password); err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: "Failed to hash password"})
+			return
+		}
+		// Sessions opened with the old password end here
+		now := time.Now()
+		user.PasswordChangedAt = &now
+	}
+	tx := db.Begin()
+	if err := tx.Save(&user).Error; err != nil {
+		tx.Rollback()
+		respondUserSaveError(c, err)
+		return
+	}
+	if err := recordAudit(c, tx, models.AuditResourceUser, user.ID, models.AuditActionUpdate, before, auditSnapshot(&user)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser removes a user account; posts they own are kept without an owner
+func DeleteUser(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid user ID"})
+		return
+	}
+	if claims, ok := middleware.CurrentUser(c); ok && uint64(claims.UserID) == id {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "You cannot delete your own account"})
+		return
+	}
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	tx := db.Begin()
+	if err := tx.Model(&models.Post{}).Where("owner_id = ?", user.ID).Update("owner_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := tx.Delete(&user).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.AuditResourceUser, user.ID, models.AuditActionDelete, auditSnapshot(&user), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "User deleted"})
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestGetUsers(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	rows := sqlmock.NewRows([]string{"id", "username", "email", "password_hash", "role", "created_at", "updated_at"}).
+		AddRow(1, "admin", "admin@example.com", "hash", "admin", time.Now(), time.Now()).
+		AddRow(2, "writer", "writer@example.com", "hash", "author", time.Now(), time.Now())
+	mock.ExpectQuery(`SELECT \* FROM "users" ORDER BY id asc`).WillReturnRows(rows)
+
+	router.GET("/users", GetUsers)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/users", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "hash") {
+		t.Fatalf("Password hashes must not be returned")
+	}
+
+	var response struct {
+		Data []models.User `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if len(response.Data) != 2 || response.Data[1].Role != "author" {
+		t.Fatalf("Unexpected users: %+v", response.Data)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "users"`).
+		WithArgs("writer", "writer@example.com", sqlmock.AnyArg(), "author", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	body := `{"username":"writer","email":"writer@example.com","password":"password123","role":"author"}`
+	router.POST("/users", CreateUser)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response models.User
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.ID != 2 || response.Role != "author" {
+		t.Fatalf("Unexpected user: %+v", response)
+	}
+}
+
+func TestCreateUser_InvalidRole(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	body := `{"username":"writer","email":"writer@example.com","password":"password123","role":"superuser"}`
+	router.POST("/users", CreateUser)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestDeleteUser_Self(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "admin")
+
+	router.DELETE("/users/:id", DeleteUser)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/users/1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestCreateUser_Duplicate(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "users"`).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "idx_users_username"})
+	mock.ExpectRollback()
+
+	body := `{"username":"writer","email":"writer@example.com","password":"password123","role":"author"}`
+	router.POST("/users", CreateUser)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdateUser_PasswordInvalidatesTokens(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "admin")
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1`).
+		WillReturnRows(userRows(t, "s3cret"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET .*"password_changed_at"=\$5`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Neither hash makes it into the audit log
+	mock.ExpectQuery(`INSERT INTO "audit_entries"`).
+		WithArgs(1, "tester", sqlmock.AnyArg(), models.AuditResourceUser, 1, models.AuditActionUpdate, snapshotWithout{"$2a$"}, snapshotWithout{"$2a$"}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	router.PUT("/users/:id", UpdateUser)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/users/1", strings.NewReader(`{"password":"n3w-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
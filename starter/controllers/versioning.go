@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errVersionConflict means the row changed between being read and written
+var errVersionConflict = errors.New("resource was modified concurrently")
+
+// saveVersioned writes every column of model but deleted_at as long as the stored
+// row is still at previousVersion; the caller has already bumped model's version.
+// Associations are left alone, so callers replace them explicitly.
+func saveVersioned(tx *gorm.DB, model interface{}, previousVersion int) error {
+	result := tx.Model(model).Where("version = ?", previousVersion).Select("*").Omit("deleted_at", clause.Associations).Updates(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
+
+// updateVersioned applies column updates to model as long as the stored row is
+// still at version, and bumps the version
+func updateVersioned(tx *gorm.DB, model interface{}, version int, updates map[string]interface{}) error {
+	updates["version"] = version + 1
+	result := tx.Model(model).Where("version = ?", version).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
+
+// deleteVersioned deletes model as long as the stored row is still at version
+func deleteVersioned(tx *gorm.DB, model interface{}, version int) error {
+	result := tx.Where("version = ?", version).Delete(model)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
+
+// respondWriteError reports a failed write. A lost race is a 412 when the client
+// sent If-Match and a 409 otherwise; slug problems are the client's to fix.
+func respondWriteError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errSlugTaken):
+		c.JSON(http.StatusConflict, utils.HTTPError{Code: 409, Message: err.Error()})
+		return
+	case errors.Is(err, errSlugInvalid):
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return
+	}
+	if errors.Is(err, errVersionConflict) {
+		if c.GetHeader("If-Match") != "" {
+			middleware.PreconditionFailed(c)
+			return
+		}
+		c.JSON(http.StatusConflict, utils.HTTPError{Code: 409, Message: "Resource was modified concurrently; fetch it again and retry"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+}
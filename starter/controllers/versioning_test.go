@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetPost_ETag(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "version", "created_at", "updated_at"}).
+			AddRow(1, "Title", "Content", "Author", models.StatusPublished, 4, now, now))
+	expectNoPostCategories(mock)
+	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	expectNoPostTags(mock)
+
+	router.GET("/posts/:id", GetPost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"post-1-v4"` {
+		t.Fatalf("Expected ETag \"post-1-v4\", got %s", etag)
+	}
+}
+
+func TestUpdatePost_StaleIfMatch(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "version", "created_at", "updated_at"}).
+			AddRow(1, "Title", "Content", "Author", models.StatusPublished, 3, now, now))
+	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+
+	router.PUT("/posts/:id", UpdatePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/posts/1", strings.NewReader(`{"title":"New","content":"New","author":"Author"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"post-1-v2"`)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expected status 412, got %d", w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("Unmet expectations: %v", err)
+	}
+}
+
+func TestDeletePage_IfMatchRequired(t *testing.T) {
+	t.Setenv("REQUIRE_IF_MATCH", "true")
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "version", "created_at", "updated_at"}).
+			AddRow(1, "Title", "Content", 1, now, now))
+
+	router.DELETE("/pages/:id", DeletePage)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/pages/1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPreconditionRequired {
+		t.Fatalf("Expected status 428, got %d", w.Code)
+	}
+}
+
+func TestUpdatePage_ConcurrentWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		ifMatch  string
+		expected int
+	}{
+		{"WithoutIfMatch", "", http.StatusConflict},
+		{"WithIfMatch", `"page-1-v2"`, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _, mock := utils.SetupRouterAndMockDB(t)
+			defer mock.ExpectClose()
+
+			now := time.Now()
+			mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).
+				WithArgs(1, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug", "content", "status", "version", "created_at", "updated_at"}).
+					AddRow(1, "Title", "title", "Content", models.StatusPublished, 2, now, now))
+			// Another writer got there first, so the version check matches no rows
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "pages" SET .* WHERE version = \$11 AND "pages"\."deleted_at" IS NULL AND "id" = \$12`).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectRollback()
+
+			router.PUT("/pages/:id", UpdatePage)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/pages/1", strings.NewReader(`{"title":"New","content":"New"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			var response utils.HTTPError
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Code != tt.expected {
+				t.Fatalf("Unexpected error body: %s", w.Body.String())
+			}
+		})
+	}
+}
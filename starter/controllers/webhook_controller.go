@@ -0,0 +1,311 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"cms-backend/webhooks"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+var webhookValidator = validator.New()
+
+// redeliveryHold keeps the dispatcher away from a manual redelivery while it
+// is being attempted
+const redeliveryHold = 5 * time.Minute
+
+// WebhookInput creates or updates a subscription. On update, fields left out
+// are kept; a new secret may be supplied to rotate it.
+type WebhookInput struct {
+	URL         string   `json:"url" validate:"omitempty,url,max=500"`
+	Events      []string `json:"events"`
+	Description *string  `json:"description" validate:"omitempty,max=255"`
+	Active      *bool    `json:"active"`
+	Secret      string   `json:"secret" validate:"omitempty,min=16,max=128"`
+}
+
+// webhookWithSecret is returned once, when a subscription is created, so the
+// receiver can be configured to verify signatures
+type webhookWithSecret struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+// bindWebhookInput reads and validates the body, writing 400 on failure
+func bindWebhookInput(c *gin.Context) (*WebhookInput, bool) {
+	var input WebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: err.Error()})
+		return nil, false
+	}
+	if err := webhookValidator.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: " + err.Error()})
+		return nil, false
+	}
+	for _, event := range input.Events {
+		if !webhooks.ValidFilter(event) {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Unknown webhook event " + strconv.Quote(event)})
+			return nil, false
+		}
+	}
+	return &input, true
+}
+
+// findWebhook loads the subscription named by the :id param, writing 400 or 404
+func findWebhook(c *gin.Context, db *gorm.DB) (*models.Webhook, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid webhook ID"})
+		return nil, false
+	}
+	var webhook models.Webhook
+	if err := db.First(&webhook, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Webhook not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return nil, false
+	}
+	return &webhook, true
+}
+
+// GetWebhooks lists webhook subscriptions
+func GetWebhooks(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	hooks := []models.Webhook{}
+	if err := db.Order("id asc").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": hooks})
+}
+
+// GetWebhook retrieves a webhook subscription by ID
+func GetWebhook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	webhook, ok := findWebhook(c, db)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+// CreateWebhook subscribes a URL to events. A signing secret is generated
+// unless one is supplied, and is only ever returned in this response.
+func CreateWebhook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	input, ok := bindWebhookInput(c)
+	if !ok {
+		return
+	}
+	if input.URL == "" || len(input.Events) == 0 {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: url and events are required"})
+		return
+	}
+	webhook := models.Webhook{URL: input.URL, Events: input.Events, Secret: input.Secret, Active: true}
+	if input.Description != nil {
+		webhook.Description = *input.Description
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+	if webhook.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+		webhook.Secret = secret
+	}
+	tx := db.Begin()
+	if err := tx.Create(&webhook).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.AuditResourceWebhook, webhook.ID, models.AuditActionCreate, nil, auditSnapshot(&webhook)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, webhookWithSecret{Webhook: webhook, Secret: webhook.Secret})
+}
+
+// UpdateWebhook changes a subscription's URL, events, description, active
+// flag or secret
+func UpdateWebhook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	webhook, ok := findWebhook(c, db)
+	if !ok {
+		return
+	}
+	before := auditSnapshot(webhook)
+	input, ok := bindWebhookInput(c)
+	if !ok {
+		return
+	}
+	if input.URL != "" {
+		webhook.URL = input.URL
+	}
+	if input.Events != nil {
+		if len(input.Events) == 0 {
+			c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Validation failed: events must not be empty"})
+			return
+		}
+		webhook.Events = input.Events
+	}
+	if input.Description != nil {
+		webhook.Description = *input.Description
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+	// The secret never appears in snapshots, so its rotation is recorded as
+	// its own action
+	action := models.AuditActionUpdate
+	if input.Secret != "" && input.Secret != webhook.Secret {
+		webhook.Secret = input.Secret
+		action = models.AuditActionRotateSecret
+	}
+	tx := db.Begin()
+	if err := tx.Save(webhook).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.AuditResourceWebhook, webhook.ID, action, before, auditSnapshot(webhook)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook removes a subscription along with its delivery log
+func DeleteWebhook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	webhook, ok := findWebhook(c, db)
+	if !ok {
+		return
+	}
+	tx := db.Begin()
+	if err := tx.Delete(webhook).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := recordAudit(c, tx, models.AuditResourceWebhook, webhook.ID, models.AuditActionDelete, auditSnapshot(webhook), nil); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, utils.MessageResponse{Message: "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries lists a subscription's deliveries, newest first,
+// optionally filtered by status and event
+func GetWebhookDeliveries(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	webhook, ok := findWebhook(c, db)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	deliveries := []models.WebhookDelivery{}
+	if err := query.Order("id desc").Limit(pageSize).Offset((page - 1) * pageSize).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       deliveries,
+		"page":       page,
+		"page_size":  pageSize,
+		"total":      total,
+		"total_page": (total + int64(pageSize) - 1) / int64(pageSize),
+	})
+}
+
+// RedeliverWebhookDelivery queues a copy of a past delivery and attempts it
+// right away. The original stays in the log untouched; if the new attempt
+// fails it is retried like any other delivery.
+func RedeliverWebhookDelivery(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	webhook, ok := findWebhook(c, db)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseUint(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid delivery ID"})
+		return
+	}
+	var original models.WebhookDelivery
+	if err := db.Where("webhook_id = ?", webhook.ID).First(&original, deliveryID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Delivery not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+
+	sender := webhooks.Default()
+	next := time.Now()
+	if sender != nil {
+		next = next.Add(redeliveryHold)
+	}
+	delivery := models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		Event:         original.Event,
+		EventKey:      original.EventKey,
+		Payload:       original.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &next,
+		RedeliveryOf:  &original.ID,
+	}
+	if err := db.Create(&delivery).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if sender != nil {
+		delivery.Webhook = webhook
+		if err := sender.Attempt(c.Request.Context(), db, &delivery); err != nil {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusCreated, delivery)
+}
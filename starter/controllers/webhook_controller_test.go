@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"cms-backend/webhooks"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectOutboxEvent expects one domain event to be recorded in the outbox
+func expectOutboxEvent(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`INSERT INTO "outbox_events"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+var webhookColumns = []string{"id", "url", "secret", "events", "description", "active", "created_at", "updated_at"}
+
+func TestUnpublishPost_RecordsOutboxEvent(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "posts"`).WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "status", "created_at", "updated_at"}).
+			AddRow(1, "Title", models.StatusPublished, now, now))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock)
+	mock.ExpectQuery(`INSERT INTO "outbox_events" \("idempotency_key","type","resource_type","resource_id","payload","attempts","next_attempt_at","last_error","processed_at","created_at"\)`).
+		WithArgs(sqlmock.AnyArg(), "post.unpublished", models.RevisionResourcePost, 1, sqlmock.AnyArg(), 0, sqlmock.AnyArg(), "", nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	router.POST("/posts/:id/unpublish", UnpublishPost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts/1/unpublish", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWebhook(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "webhooks" \("url","secret","events","description","active","created_at","updated_at"\)`).
+		WithArgs("https://builder.example.com/hook", sqlmock.AnyArg(), `["post.published","page.*"]`, "", false, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	expectAudit(mock)
+	mock.ExpectCommit()
+
+	router.POST("/webhooks", CreateWebhook)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/webhooks",
+		strings.NewReader(`{"url":"https://builder.example.com/hook","events":["post.published","page.*"],"active":false}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.EqualValues(t, 4, response["id"])
+	assert.Len(t, response["secret"], 64, "a generated secret is returned once")
+	assert.Equal(t, false, response["active"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateWebhook_Invalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"UnknownEvent": `{"url":"https://example.com/hook","events":["post.exploded"]}`,
+		"NoEvents":     `{"url":"https://example.com/hook","events":[]}`,
+		"BadURL":       `{"url":"not a url","events":["*"]}`,
+		"ShortSecret":  `{"url":"https://example.com/hook","events":["*"],"secret":"abc"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			router, _, mock := utils.SetupRouterAndMockDB(t)
+			defer mock.ExpectClose()
+
+			router.POST("/webhooks", CreateWebhook)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateWebhook_AuditsSecretRotation(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "webhooks" WHERE "webhooks"\."id" = \$1`).WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows(webhookColumns).
+			AddRow(4, "https://builder.example.com/hook", "topsecretvalue123", `["*"]`, "", true, now, now))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "webhooks"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_entries"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), models.AuditResourceWebhook, 4, models.AuditActionRotateSecret, snapshotWithout{"topsecretvalue123", "rotatedsecretvalue456"}, snapshotWithout{"topsecretvalue123", "rotatedsecretvalue456"}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	router.PUT("/webhooks/:id", UpdateWebhook)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, "/webhooks/4", strings.NewReader(`{"secret":"rotatedsecretvalue456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWebhook_HidesSecret(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "webhooks" WHERE "webhooks"\."id" = \$1`).WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows(webhookColumns).
+			AddRow(4, "https://builder.example.com/hook", "topsecretvalue123", `["*"]`, "", true, now, now))
+
+	router.GET("/webhooks/:id", GetWebhook)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/webhooks/4", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "topsecretvalue123")
+	assert.Contains(t, w.Body.String(), `"events":["*"]`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetWebhookDeliveries(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "webhooks"`).WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows(webhookColumns).AddRow(4, "https://example.com/hook", "s", `["*"]`, "", true, now, now))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "webhook_deliveries" WHERE webhook_id = \$1 AND status = \$2`).
+		WithArgs(4, models.DeliveryFailed).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "webhook_deliveries" WHERE webhook_id = \$1 AND status = \$2 ORDER BY id desc LIMIT \$3`).
+		WithArgs(4, models.DeliveryFailed, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event", "payload", "status", "attempts", "response_status", "error"}).
+			AddRow(9, 4, "post.published", `{"id":1}`, models.DeliveryFailed, 10, 503, "receiver responded with 503"))
+
+	router.GET("/webhooks/:id/deliveries", GetWebhookDeliveries)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/webhooks/4/deliveries?status=failed", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Data  []map[string]interface{} `json:"data"`
+		Total int64                    `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+	assert.EqualValues(t, 503, response.Data[0]["response_status"])
+	assert.Equal(t, map[string]interface{}{"id": float64(1)}, response.Data[0]["payload"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedeliverWebhookDelivery(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	webhooks.SetDefault(webhooks.NewSender(time.Second, webhooks.DefaultRetryPolicy))
+	t.Cleanup(func() { webhooks.SetDefault(nil) })
+
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "webhooks"`).WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows(webhookColumns).AddRow(4, server.URL, secret, `["post.*"]`, "", true, now, now))
+	mock.ExpectQuery(`SELECT \* FROM "webhook_deliveries" WHERE webhook_id = \$1 AND "webhook_deliveries"\."id" = \$2`).
+		WithArgs(4, 9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event", "event_key", "payload", "status", "attempts"}).
+			AddRow(9, 4, "post.deleted", "5f1d2c8e9a7b4c3d", `{"id":1,"title":"Gone"}`, models.DeliveryFailed, 10))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "webhook_deliveries"`).
+		WithArgs(4, "post.deleted", "5f1d2c8e9a7b4c3d", `{"id":1,"title":"Gone"}`, models.DeliveryPending, 0, sqlmock.AnyArg(), nil, 0, "", "", nil, 9, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET "status"=\$1,"attempts"=\$2,"next_attempt_at"=\$3,"last_attempt_at"=\$4,"response_status"=\$5,"response_body"=\$6,"error"=\$7,"delivered_at"=\$8,"updated_at"=\$9 WHERE "id" = \$10`).
+		WithArgs(models.DeliveryDelivered, 1, nil, sqlmock.AnyArg(), http.StatusAccepted, "", "", sqlmock.AnyArg(), sqlmock.AnyArg(), 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	router.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", RedeliverWebhookDelivery)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/webhooks/4/deliveries/9/redeliver", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response models.WebhookDelivery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.DeliveryDelivered, response.Status)
+	require.NotNil(t, response.RedeliveryOf)
+	assert.EqualValues(t, 9, *response.RedeliveryOf)
+
+	require.NotNil(t, received, "the receiver was called")
+	assert.Equal(t, "post.deleted", received.Header.Get(webhooks.HeaderEvent))
+	assert.Equal(t, "12", received.Header.Get(webhooks.HeaderDelivery))
+	timestamp, err := strconv.ParseInt(received.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, webhooks.Sign(secret, timestamp, body), received.Header.Get(webhooks.HeaderSignature))
+	assert.Contains(t, string(body), `"data":{"id":1,"title":"Gone"}`)
+	assert.Contains(t, string(body), `"idempotency_key":"5f1d2c8e9a7b4c3d"`, "a redelivery keeps the event's key")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
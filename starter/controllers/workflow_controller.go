@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"cms-backend/middleware"
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// canViewPost reports whether the caller may read a post in its current status.
+// Published posts are public; editors see everything and authors see their own drafts.
+func canViewPost(c *gin.Context, post *models.Post) bool {
+	if post.Status == models.StatusPublished {
+		return true
+	}
+	return middleware.HasPermission(c, middleware.PermPostsPublish, nil) ||
+		middleware.HasPermission(c, middleware.PermPostsUpdate, post.OwnerID)
+}
+
+// canViewUnpublishedPages reports whether the caller may read pages that are not published
+func canViewUnpublishedPages(c *gin.Context) bool {
+	return middleware.HasPermission(c, middleware.PermPagesPublish, nil)
+}
+
+// workflowUpdates returns the columns to write when content moves to status
+func workflowUpdates(status string) map[string]interface{} {
+	updates := map[string]interface{}{"status": status}
+	if status == models.StatusPublished {
+		updates["published_at"] = time.Now()
+	}
+	return updates
+}
+
+func transitionPost(c *gin.Context, action string) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid post ID"})
+		return
+	}
+	var post models.Post
+	if err := db.First(&post, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Post not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	permission := middleware.PermPostsPublish
+	if action == models.ActionSubmit {
+		permission = middleware.PermPostsSubmit
+	}
+	if !middleware.HasPermission(c, permission, post.OwnerID) {
+		middleware.Forbidden(c)
+		return
+	}
+	if !middleware.CheckIfMatch(c, postETag(&post)) {
+		return
+	}
+	next, err := models.NextStatus(post.Status, action)
+	if err != nil {
+		c.JSON(http.StatusConflict, utils.HTTPError{Code: 409, Message: err.Error()})
+		return
+	}
+	before := auditSnapshot(&post)
+	event := models.StatusChangeEvent(post.Status, next)
+	tx := db.Begin()
+	if err := updateVersioned(tx, &post, post.Version, workflowUpdates(next)); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePost, post.ID, action, before, auditSnapshot(&post)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePost, post.ID, event, &post); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.Header("ETag", postETag(&post))
+	c.JSON(http.StatusOK, post)
+}
+
+func transitionPage(c *gin.Context, action string) {
+	db := c.MustGet("db").(*gorm.DB)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.HTTPError{Code: 400, Message: "Invalid page ID"})
+		return
+	}
+	var page models.Page
+	if err := db.First(&page, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, utils.HTTPError{Code: 404, Message: "Page not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		}
+		return
+	}
+	if !middleware.CheckIfMatch(c, pageETag(&page)) {
+		return
+	}
+	next, err := models.NextStatus(page.Status, action)
+	if err != nil {
+		c.JSON(http.StatusConflict, utils.HTTPError{Code: 409, Message: err.Error()})
+		return
+	}
+	before := auditSnapshot(&page)
+	event := models.StatusChangeEvent(page.Status, next)
+	tx := db.Begin()
+	if err := updateVersioned(tx, &page, page.Version, workflowUpdates(next)); err != nil {
+		tx.Rollback()
+		respondWriteError(c, err)
+		return
+	}
+	if err := recordAudit(c, tx, models.RevisionResourcePage, page.ID, action, before, auditSnapshot(&page)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	if err := outbox.Publish(tx, models.RevisionResourcePage, page.ID, event, &page); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, utils.HTTPError{Code: 500, Message: err.Error()})
+		return
+	}
+	tx.Commit()
+
+	outbox.Notify()
+
+	c.Header("ETag", pageETag(&page))
+	c.JSON(http.StatusOK, page)
+}
+
+// SubmitPost sends a draft post for editorial review
+func SubmitPost(c *gin.Context) { transitionPost(c, models.ActionSubmit) }
+
+// ApprovePost publishes a post that is in review
+func ApprovePost(c *gin.Context) { transitionPost(c, models.ActionApprove) }
+
+// RejectPost returns a post in review to draft
+func RejectPost(c *gin.Context) { transitionPost(c, models.ActionReject) }
+
+// PublishPost publishes a post directly, skipping review
+func PublishPost(c *gin.Context) { transitionPost(c, models.ActionPublish) }
+
+// UnpublishPost takes a published post back to draft
+func UnpublishPost(c *gin.Context) { transitionPost(c, models.ActionUnpublish) }
+
+// ArchivePost retires a post from public view
+func ArchivePost(c *gin.Context) { transitionPost(c, models.ActionArchive) }
+
+// SubmitPage sends a draft page for review
+func SubmitPage(c *gin.Context) { transitionPage(c, models.ActionSubmit) }
+
+// ApprovePage publishes a page that is in review
+func ApprovePage(c *gin.Context) { transitionPage(c, models.ActionApprove) }
+
+// RejectPage returns a page in review to draft
+func RejectPage(c *gin.Context) { transitionPage(c, models.ActionReject) }
+
+// PublishPage publishes a page directly, skipping review
+func PublishPage(c *gin.Context) { transitionPage(c, models.ActionPublish) }
+
+// UnpublishPage takes a published page back to draft
+func UnpublishPage(c *gin.Context) { transitionPage(c, models.ActionUnpublish) }
+
+// ArchivePage retires a page from public view
+func ArchivePage(c *gin.Context) { transitionPage(c, models.ActionArchive) }
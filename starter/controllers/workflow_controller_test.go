@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSubmitPost_AuthorOwner(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 2, "author")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "owner_id", "status", "created_at", "updated_at"}).
+		AddRow(1, "Title", "Content", "Me", 2, models.StatusDraft, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts" SET "status"=\$1,"version"=\$2,"updated_at"=\$3 WHERE version = \$4 AND "posts"\."deleted_at" IS NULL AND "id" = \$5`).
+		WithArgs(models.StatusInReview, 1, sqlmock.AnyArg(), 0, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.POST("/posts/:id/submit", SubmitPost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts/1/submit", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response models.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.Status != models.StatusInReview {
+		t.Fatalf("Expected status %q, got %q", models.StatusInReview, response.Status)
+	}
+}
+
+func TestApprovePost_AuthorForbidden(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 2, "author")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "owner_id", "status", "created_at", "updated_at"}).
+		AddRow(1, "Title", "Content", "Me", 2, models.StatusInReview, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+
+	router.POST("/posts/:id/approve", ApprovePost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts/1/approve", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestPublishPost_Editor(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "created_at", "updated_at"}).
+		AddRow(1, "Title", "Content", "Someone", models.StatusInReview, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "posts" SET "published_at"=\$1,"status"=\$2,"version"=\$3,"updated_at"=\$4 WHERE version = \$5 AND "posts"\."deleted_at" IS NULL AND "id" = \$6`).
+		WithArgs(sqlmock.AnyArg(), models.StatusPublished, 1, sqlmock.AnyArg(), 0, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.POST("/posts/:id/publish", PublishPost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts/1/publish", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response models.Post
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.Status != models.StatusPublished || response.PublishedAt == nil {
+		t.Fatalf("Expected published post with published_at, got %+v", response)
+	}
+}
+
+func TestUnpublishPost_IllegalTransition(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "created_at", "updated_at"}).
+		AddRow(1, "Title", "Content", "Someone", models.StatusDraft, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+
+	router.POST("/posts/:id/unpublish", UnpublishPost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/posts/1/unpublish", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d", w.Code)
+	}
+
+	var response utils.HTTPError
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Error unmarshaling response: %v", err)
+	}
+	if response.Code != 409 {
+		t.Fatalf("Expected error code 409, got %d", response.Code)
+	}
+}
+
+func TestGetPost_DraftHiddenFromAnonymous(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "created_at", "updated_at"}).
+		AddRow(1, "Draft", "Content", "Someone", models.StatusDraft, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	expectNoPostCategories(mock)
+	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	expectNoPostTags(mock)
+
+	router.GET("/posts/:id", GetPost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestGetPost_DraftVisibleToOwner(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 2, "author")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "owner_id", "status", "created_at", "updated_at"}).
+		AddRow(1, "Draft", "Content", "Me", 2, models.StatusDraft, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+	expectNoPostCategories(mock)
+	mock.ExpectQuery(`SELECT \* FROM "post_media" WHERE "post_media"\."post_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	expectNoPostTags(mock)
+
+	router.GET("/posts/:id", GetPost)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts/1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestGetPosts_EditorFiltersByStatus(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "posts" WHERE status = \$1`).
+		WithArgs(models.StatusInReview).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "created_at", "updated_at"}).
+		AddRow(1, "Pending", "Content", "Someone", models.StatusInReview, time.Now(), time.Now())
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE status = \$1 AND "posts"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$2`).
+		WithArgs(models.StatusInReview, 10).
+		WillReturnRows(rows)
+	expectNoPostCategories(mock)
+	mock.ExpectQuery(`SELECT \* FROM "post_media"`).WillReturnRows(sqlmock.NewRows([]string{"post_id", "media_id"}))
+	expectNoPostTags(mock)
+
+	router.GET("/posts", GetPosts)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts?status=in_review", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestGetPosts_EditorSeesAllStatuses(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "posts" WHERE "posts"\."deleted_at" IS NULL$`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."deleted_at" IS NULL ORDER BY created_at desc LIMIT \$1`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "content", "author", "status", "created_at", "updated_at"}))
+
+	router.GET("/posts", GetPosts)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestPublishPage_Editor(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+	authenticateAs(router, 1, "editor")
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "status", "created_at", "updated_at"}).
+		AddRow(1, "About", "Content", models.StatusDraft, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "pages" SET "published_at"=\$1,"status"=\$2,"version"=\$3,"updated_at"=\$4 WHERE version = \$5 AND "pages"\."deleted_at" IS NULL AND "id" = \$6`).
+		WithArgs(sqlmock.AnyArg(), models.StatusPublished, 1, sqlmock.AnyArg(), 0, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAudit(mock)
+	expectOutboxEvent(mock)
+	mock.ExpectCommit()
+
+	router.POST("/pages/:id/publish", PublishPage)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/pages/1/publish", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetPage_DraftHiddenFromAnonymous(t *testing.T) {
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	defer mock.ExpectClose()
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "status", "created_at", "updated_at"}).
+		AddRow(1, "About", "Content", models.StatusDraft, now, now)
+	mock.ExpectQuery(`SELECT \* FROM "pages" WHERE "pages"\."id" = \$1 AND "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$2`).WithArgs(1, 1).WillReturnRows(rows)
+
+	router.GET("/pages/:id", GetPage)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/pages/1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+}
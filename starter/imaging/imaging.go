@@ -0,0 +1,193 @@
+// Package imaging decodes, resizes and re-encodes images for media renditions
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	scale "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Formats renditions can be encoded to
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// JPEGQuality is used for every JPEG rendition
+const JPEGQuality = 82
+
+var (
+	ErrUnsupported = errors.New("imaging: unsupported image format")
+	ErrTooLarge    = errors.New("imaging: image has too many pixels")
+)
+
+// ParseFormat normalizes a requested output format, accepting "jpg" for JPEG
+func ParseFormat(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "jpg", "jpeg":
+		return FormatJPEG, true
+	case "png":
+		return FormatPNG, true
+	case "webp":
+		return FormatWebP, true
+	}
+	return "", false
+}
+
+// DefaultFormat is the rendition format for a source MIME type: JPEG, PNG and
+// WebP keep their format, anything else (GIF) becomes PNG
+func DefaultFormat(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return FormatJPEG
+	case "image/webp":
+		return FormatWebP
+	}
+	return FormatPNG
+}
+
+// Decodable reports whether images of the MIME type can be decoded
+func Decodable(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// ContentType is the MIME type of an encoded format
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// Extension is the file extension of an encoded format, without the dot
+func Extension(format string) string {
+	if format == FormatJPEG {
+		return "jpg"
+	}
+	return format
+}
+
+// DecodeConfig reads the dimensions of an image without decoding it
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	config, _, err := image.DecodeConfig(r)
+	if errors.Is(err, image.ErrFormat) {
+		return config, ErrUnsupported
+	}
+	return config, err
+}
+
+// Decode decodes an image after checking its header against maxPixels, so a
+// small file that declares huge dimensions is rejected before allocation. Only
+// the first frame of an animated GIF is kept.
+func Decode(r io.ReadSeeker, maxPixels int) (image.Image, error) {
+	config, err := DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if maxPixels > 0 && config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrUnsupported
+	}
+	return img, err
+}
+
+// Resize scales img to width, keeping its aspect ratio. Images are never
+// enlarged; one that is already narrow enough is returned as is.
+func Resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if width <= 0 || width >= bounds.Dx() {
+		return img
+	}
+	height := (bounds.Dy()*width + bounds.Dx()/2) / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	scale.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// Orient turns img upright according to its EXIF orientation, 1 to 8. Decoders
+// ignore the tag, so without this a portrait phone photo renders sideways.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
+
+// Encode writes img in format. JPEG has no alpha channel, so transparent
+// images are flattened onto white first.
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case FormatJPEG:
+		if !opaque(img) {
+			flat := image.NewRGBA(img.Bounds())
+			draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+			draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+			img = flat
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: JPEGQuality})
+	case FormatPNG:
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		return encoder.Encode(w, img)
+	case FormatWebP:
+		return EncodeWebP(w, img)
+	}
+	return ErrUnsupported
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+)
+
+// gradient draws a smooth image with a hard-edged block and, when alpha is
+// set, a transparent corner
+func gradient(width, height int, alpha bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{uint8(x * 255 / width), uint8(y * 255 / height), uint8((x + y) % 256), 0xff}
+			if x > width/2 && y < height/3 {
+				c = color.NRGBA{200, 30, 60, 0xff}
+			}
+			if alpha && x < width/4 && y < height/4 {
+				c.A = uint8(x * 4)
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func noise(width, height int) *image.NRGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	rng.Read(img.Pix)
+	return img
+}
+
+func assertSamePixels(t *testing.T, want image.Image, got image.Image) {
+	t.Helper()
+	require.Equal(t, want.Bounds().Size(), got.Bounds().Size())
+	for y := 0; y < want.Bounds().Dy(); y++ {
+		for x := 0; x < want.Bounds().Dx(); x++ {
+			w := color.NRGBAModel.Convert(want.At(want.Bounds().Min.X+x, want.Bounds().Min.Y+y))
+			g := color.NRGBAModel.Convert(got.At(got.Bounds().Min.X+x, got.Bounds().Min.Y+y))
+			if w.(color.NRGBA).A == 0 && g.(color.NRGBA).A == 0 {
+				continue
+			}
+			require.Equal(t, w, g, "pixel %d,%d", x, y)
+		}
+	}
+}
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	cases := map[string]image.Image{
+		"Gradient":    gradient(67, 41, false),
+		"Alpha":       gradient(40, 40, true),
+		"Noise":       noise(33, 17),
+		"SinglePixel": gradient(1, 1, false),
+		"Column":      gradient(1, 30, false),
+		"Flat":        image.NewUniform(color.NRGBA{10, 20, 30, 0xff}),
+	}
+	for name, img := range cases {
+		t.Run(name, func(t *testing.T) {
+			if u, ok := img.(*image.Uniform); ok {
+				flat := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+				for i := 0; i < len(flat.Pix); i += 4 {
+					c := u.C.(color.NRGBA)
+					flat.Pix[i], flat.Pix[i+1], flat.Pix[i+2], flat.Pix[i+3] = c.R, c.G, c.B, c.A
+				}
+				img = flat
+			}
+			var buf bytes.Buffer
+			require.NoError(t, EncodeWebP(&buf, img))
+			assert.Equal(t, "RIFF", buf.String()[:4])
+			assert.Equal(t, "WEBPVP8L", buf.String()[8:16])
+			assert.Zero(t, buf.Len()%2, "RIFF chunks are padded to an even size")
+
+			decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			assertSamePixels(t, img, decoded)
+		})
+	}
+}
+
+func TestEncodeWebPCompresses(t *testing.T) {
+	img := gradient(256, 256, false)
+	var buf bytes.Buffer
+	require.NoError(t, EncodeWebP(&buf, img))
+	assert.Less(t, buf.Len(), 256*256*4/4, "a smooth image should compress well below raw size")
+}
+
+func TestEncodeWebPSubImage(t *testing.T) {
+	img := gradient(50, 50, false).SubImage(image.Rect(10, 5, 30, 45))
+	var buf bytes.Buffer
+	require.NoError(t, EncodeWebP(&buf, img))
+	decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assertSamePixels(t, img, decoded)
+}
+
+func TestHuffmanLengthsAreLimited(t *testing.T) {
+	// Fibonacci weights produce the deepest possible tree
+	histogram := make([]uint32, 30)
+	a, b := uint32(1), uint32(1)
+	for i := range histogram {
+		histogram[i] = a
+		a, b = b, a+b
+	}
+	lengths := huffmanLengths(histogram, 15)
+	kraft := 0.0
+	for _, length := range lengths {
+		require.NotZero(t, length)
+		assert.LessOrEqual(t, int(length), 15)
+		kraft += 1 / float64(uint(1)<<length)
+	}
+	assert.InDelta(t, 1.0, kraft, 1e-9, "the code must be complete")
+}
+
+func TestResize(t *testing.T) {
+	img := gradient(400, 300, false)
+
+	resized := Resize(img, 100)
+	assert.Equal(t, image.Pt(100, 75), resized.Bounds().Size())
+
+	assert.Same(t, img, Resize(img, 400), "images are never enlarged")
+	assert.Same(t, img, Resize(img, 1000))
+}
+
+func TestOrient(t *testing.T) {
+	// A 3x2 image with a marked top-left corner
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	marker := color.NRGBA{255, 0, 0, 255}
+	img.Set(0, 0, marker)
+
+	// Where the marker ends up, and the resulting size
+	cases := map[int]struct {
+		at   image.Point
+		size image.Point
+	}{
+		1: {image.Pt(0, 0), image.Pt(3, 2)},
+		2: {image.Pt(2, 0), image.Pt(3, 2)},
+		3: {image.Pt(2, 1), image.Pt(3, 2)},
+		4: {image.Pt(0, 1), image.Pt(3, 2)},
+		5: {image.Pt(0, 0), image.Pt(2, 3)},
+		6: {image.Pt(1, 0), image.Pt(2, 3)},
+		7: {image.Pt(1, 2), image.Pt(2, 3)},
+		8: {image.Pt(0, 2), image.Pt(2, 3)},
+	}
+	for orientation, want := range cases {
+		oriented := Orient(img, orientation)
+		assert.Equal(t, want.size, oriented.Bounds().Size(), "orientation %d", orientation)
+		assert.Equal(t, marker, color.NRGBAModel.Convert(oriented.At(want.at.X, want.at.Y)), "orientation %d", orientation)
+	}
+	assert.Same(t, img, Orient(img, 0))
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, gradient(20, 10, false)))
+
+	img, err := Decode(bytes.NewReader(buf.Bytes()), 0)
+	require.NoError(t, err)
+	assert.Equal(t, image.Pt(20, 10), img.Bounds().Size())
+
+	_, err = Decode(bytes.NewReader(buf.Bytes()), 199)
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	_, err = Decode(bytes.NewReader([]byte("%PDF-1.4")), 0)
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestEncode(t *testing.T) {
+	img := gradient(30, 20, true)
+	for _, format := range []string{FormatJPEG, FormatPNG, FormatWebP} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Encode(&buf, img, format))
+			_, name, err := image.Decode(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, format, name)
+		})
+	}
+	assert.ErrorIs(t, Encode(&bytes.Buffer{}, img, "bmp"), ErrUnsupported)
+}
+
+func TestParseFormat(t *testing.T) {
+	format, ok := ParseFormat("JPG")
+	assert.True(t, ok)
+	assert.Equal(t, FormatJPEG, format)
+	_, ok = ParseFormat("tiff")
+	assert.False(t, ok)
+	assert.Equal(t, FormatPNG, DefaultFormat("image/gif"))
+	assert.Equal(t, "jpg", Extension(FormatJPEG))
+}
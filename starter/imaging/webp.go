@@ -0,0 +1,660 @@
+package imaging
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"math/bits"
+)
+
+// EncodeWebP writes img as a lossless WebP (VP8L) image. The encoder applies
+// the subtract-green and predictor transforms and LZ77 backward references,
+// which keeps flat graphics and screenshots small; photographs stay larger
+// than their JPEG renditions.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > maxWebPSize || height > maxWebPSize {
+		return errors.New("imaging: WebP dimensions must be between 1 and 16384")
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	pix := nrgba.Pix
+	alpha := !nrgba.Opaque()
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3)
+
+	// Transforms are undone in reverse order, so subtract green is written first
+	subtractGreen(pix)
+	bw.write(1, 1)
+	bw.write(transformSubtractGreen, 2)
+
+	bw.write(1, 1)
+	bw.write(transformPredictor, 2)
+	bw.write(predictorBits-2, 3)
+	modes, residuals := predict(pix, width, height)
+	writeImage(bw, modes, tiles(width), false)
+	bw.write(0, 1)
+
+	writeImage(bw, residuals, width, true)
+	data := bw.bytes()
+
+	padded := len(data) + len(data)&1
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+padded))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if len(data) != padded {
+		data = append(data, 0)
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+const (
+	maxWebPSize = 1 << 14
+
+	transformPredictor     = 0
+	transformSubtractGreen = 2
+
+	// predictorBits sizes the predictor tiles at 16x16 pixels
+	predictorBits = 4
+
+	nLiteralCodes  = 256
+	nLengthCodes   = 24
+	nDistanceCodes = 40
+
+	// distanceOffset skips the short distance codes that map to 2D offsets
+	distanceOffset = 120
+	maxDistance    = 1<<20 - distanceOffset
+	minMatch       = 3
+	maxMatch       = 4096
+	hashBits       = 16
+	maxChain       = 16
+)
+
+// codeLengthCodeOrder is the order code length code lengths are written in
+var codeLengthCodeOrder = [19]uint8{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	nAcc uint
+}
+
+// write appends the low n bits of v, least significant bit first
+func (b *bitWriter) write(v uint32, n uint) {
+	b.acc |= uint64(v) << b.nAcc
+	b.nAcc += n
+	for b.nAcc >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.nAcc -= 8
+	}
+}
+
+func (b *bitWriter) bytes() []byte {
+	if b.nAcc > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.nAcc = 0, 0
+	}
+	return b.buf
+}
+
+func subtractGreen(pix []byte) {
+	for p := 0; p < len(pix); p += 4 {
+		pix[p+0] -= pix[p+1]
+		pix[p+2] -= pix[p+1]
+	}
+}
+
+func tiles(size int) int {
+	return (size + 1<<predictorBits - 1) >> predictorBits
+}
+
+// predict picks the predictor mode with the smallest residuals for every tile
+// and returns the mode sub-image together with the residual image. Prediction
+// mirrors the decoder: the first row uses L, the first column T, and the top
+// left pixel opaque black.
+func predict(pix []byte, width, height int) ([]byte, []byte) {
+	tilesW, tilesH := tiles(width), tiles(height)
+	modes := make([]byte, 4*tilesW*tilesH)
+	for i := 0; i < len(modes); i += 4 {
+		modes[i+1], modes[i+3] = 1, 0xff
+	}
+	for ty := 0; ty < tilesH; ty++ {
+		for tx := 0; tx < tilesW; tx++ {
+			best, bestCost := 1, -1
+			for mode := 0; mode < 14; mode++ {
+				cost := 0
+				forTile(tx, ty, width, height, func(p, top int) {
+					pred := predictPixel(mode, pix, p, top)
+					for i := 0; i < 4; i++ {
+						r := int(int8(pix[p+i] - pred[i]))
+						if r < 0 {
+							r = -r
+						}
+						cost += r
+					}
+				})
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[4*(ty*tilesW+tx)+1] = byte(best)
+		}
+	}
+
+	residuals := make([]byte, len(pix))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := 4 * (y*width + x)
+			var pred [4]byte
+			switch {
+			case x == 0 && y == 0:
+				pred = [4]byte{0, 0, 0, 0xff}
+			case y == 0:
+				pred = predictPixel(1, pix, p, 0)
+			case x == 0:
+				pred = predictPixel(2, pix, p, p-4*width)
+			default:
+				mode := int(modes[4*((y>>predictorBits)*tilesW+x>>predictorBits)+1])
+				pred = predictPixel(mode, pix, p, p-4*width)
+			}
+			for i := 0; i < 4; i++ {
+				residuals[p+i] = pix[p+i] - pred[i]
+			}
+		}
+	}
+	return modes, residuals
+}
+
+// forTile calls fn for the tile's pixels that use the tile's predictor mode
+func forTile(tx, ty, width, height int, fn func(p, top int)) {
+	x0, y0 := tx<<predictorBits, ty<<predictorBits
+	for y := y0; y < y0+1<<predictorBits && y < height; y++ {
+		if y == 0 {
+			continue
+		}
+		for x := x0; x < x0+1<<predictorBits && x < width; x++ {
+			if x == 0 {
+				continue
+			}
+			p := 4 * (y*width + x)
+			fn(p, p-4*width)
+		}
+	}
+}
+
+// predictPixel predicts the pixel at p from its neighbours; top is the index
+// of the pixel above
+func predictPixel(mode int, pix []byte, p, top int) [4]byte {
+	var pred [4]byte
+	if mode == 11 {
+		var distL, distT int
+		for i := 0; i < 4; i++ {
+			distL += absInt(int(pix[top-4+i]) - int(pix[top+i]))
+			distT += absInt(int(pix[top-4+i]) - int(pix[p-4+i]))
+		}
+		if distL < distT {
+			copy(pred[:], pix[p-4:p])
+		} else {
+			copy(pred[:], pix[top:top+4])
+		}
+		return pred
+	}
+	for i := 0; i < 4; i++ {
+		switch mode {
+		case 0:
+			if i == 3 {
+				pred[i] = 0xff
+			}
+		case 1:
+			pred[i] = pix[p-4+i]
+		case 2:
+			pred[i] = pix[top+i]
+		case 3:
+			pred[i] = pix[top+4+i]
+		case 4:
+			pred[i] = pix[top-4+i]
+		case 5:
+			pred[i] = avg2(avg2(pix[p-4+i], pix[top+4+i]), pix[top+i])
+		case 6:
+			pred[i] = avg2(pix[p-4+i], pix[top-4+i])
+		case 7:
+			pred[i] = avg2(pix[p-4+i], pix[top+i])
+		case 8:
+			pred[i] = avg2(pix[top-4+i], pix[top+i])
+		case 9:
+			pred[i] = avg2(pix[top+i], pix[top+4+i])
+		case 10:
+			pred[i] = avg2(avg2(pix[p-4+i], pix[top-4+i]), avg2(pix[top+i], pix[top+4+i]))
+		case 12:
+			pred[i] = clamp(int(pix[p-4+i]) + int(pix[top+i]) - int(pix[top-4+i]))
+		case 13:
+			a := avg2(pix[p-4+i], pix[top+i])
+			pred[i] = clamp(int(a) + (int(a)-int(pix[top-4+i]))/2)
+		}
+	}
+	return pred
+}
+
+func avg2(a, b byte) byte {
+	return byte((int(a) + int(b)) / 2)
+}
+
+func clamp(v int) byte {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return byte(v)
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// token is a literal pixel, or a backward reference when length is non-zero
+type token struct {
+	pixel            uint32
+	length, distance uint32
+}
+
+// writeImage entropy codes pix, an RGBA buffer, with a single group of prefix
+// codes and no color cache
+func writeImage(bw *bitWriter, pix []byte, width int, topLevel bool) {
+	bw.write(0, 1)
+	if topLevel {
+		bw.write(0, 1)
+	}
+
+	argb := make([]uint32, len(pix)/4)
+	for i := range argb {
+		argb[i] = uint32(pix[4*i])<<16 | uint32(pix[4*i+1])<<8 | uint32(pix[4*i+2]) | uint32(pix[4*i+3])<<24
+	}
+	tokens := backwardReferences(argb, width)
+
+	green := make([]uint32, nLiteralCodes+nLengthCodes)
+	red := make([]uint32, nLiteralCodes)
+	blue := make([]uint32, nLiteralCodes)
+	alpha := make([]uint32, nLiteralCodes)
+	distance := make([]uint32, nDistanceCodes)
+	for _, t := range tokens {
+		if t.length == 0 {
+			green[t.pixel>>8&0xff]++
+			red[t.pixel>>16&0xff]++
+			blue[t.pixel&0xff]++
+			alpha[t.pixel>>24]++
+			continue
+		}
+		code, _, _ := prefixEncode(t.length)
+		green[nLiteralCodes+code]++
+		code, _, _ = prefixEncode(t.distance + distanceOffset)
+		distance[code]++
+	}
+
+	greenCode := writePrefixCode(bw, green)
+	redCode := writePrefixCode(bw, red)
+	blueCode := writePrefixCode(bw, blue)
+	alphaCode := writePrefixCode(bw, alpha)
+	distanceCode := writePrefixCode(bw, distance)
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			greenCode.write(bw, int(t.pixel>>8&0xff))
+			redCode.write(bw, int(t.pixel>>16&0xff))
+			blueCode.write(bw, int(t.pixel&0xff))
+			alphaCode.write(bw, int(t.pixel>>24))
+			continue
+		}
+		code, n, extra := prefixEncode(t.length)
+		greenCode.write(bw, nLiteralCodes+int(code))
+		bw.write(extra, uint(n))
+		code, n, extra = prefixEncode(t.distance + distanceOffset)
+		distanceCode.write(bw, int(code))
+		bw.write(extra, uint(n))
+	}
+}
+
+// backwardReferences greedily replaces repeated runs of pixels with LZ77
+// references, trying the previous pixel and the one above before a hash chain
+func backwardReferences(argb []uint32, width int) []token {
+	n := len(argb)
+	head := make([]int32, 1<<hashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	chain := make([]int32, n)
+	hash := func(i int) uint32 {
+		return (argb[i]*0x1e35a7bd + argb[i+1]*0x9e3779b1) >> (32 - hashBits)
+	}
+	insert := func(i int) {
+		if i+1 < n {
+			h := hash(i)
+			chain[i] = head[h]
+			head[h] = int32(i)
+		}
+	}
+	matchLength := func(i, j int) int {
+		limit := n - i
+		if limit > maxMatch {
+			limit = maxMatch
+		}
+		l := 0
+		for l < limit && argb[i+l] == argb[j+l] {
+			l++
+		}
+		return l
+	}
+
+	tokens := make([]token, 0, n/2)
+	for i := 0; i < n; {
+		bestLength, bestDistance := 0, 0
+		try := func(j int) {
+			if j < 0 || j >= i || i-j > maxDistance {
+				return
+			}
+			if l := matchLength(i, j); l > bestLength {
+				bestLength, bestDistance = l, i-j
+			}
+		}
+		if i+minMatch <= n {
+			try(i - 1)
+			try(i - width)
+			for j, steps := int(head[hash(i)]), 0; j >= 0 && steps < maxChain && bestLength < maxMatch; j, steps = int(chain[j]), steps+1 {
+				try(j)
+			}
+		}
+		if bestLength < minMatch {
+			tokens = append(tokens, token{pixel: argb[i]})
+			insert(i)
+			i++
+			continue
+		}
+		tokens = append(tokens, token{length: uint32(bestLength), distance: uint32(bestDistance)})
+		for end := i + bestLength; i < end; i++ {
+			insert(i)
+		}
+	}
+	return tokens
+}
+
+// prefixEncode splits an LZ77 length or distance code into its prefix symbol
+// and extra bits
+func prefixEncode(v uint32) (code, extraBits, extra uint32) {
+	d := v - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	highest := uint32(bits.Len32(d)) - 1
+	second := d >> (highest - 1) & 1
+	extraBits = highest - 1
+	return 2*highest + second, extraBits, d & (1<<extraBits - 1)
+}
+
+// prefixCode holds bit-reversed canonical codes ready to be written LSB first
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint16
+}
+
+func (c prefixCode) write(bw *bitWriter, symbol int) {
+	bw.write(uint32(c.codes[symbol]), uint(c.lengths[symbol]))
+}
+
+// writePrefixCode writes a prefix code for the histogram, using the simple
+// form for one or two small symbols. A code with a single symbol takes no bits
+// per symbol.
+func writePrefixCode(bw *bitWriter, histogram []uint32) prefixCode {
+	code := prefixCode{lengths: make([]uint8, len(histogram)), codes: make([]uint16, len(histogram))}
+	var used []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		bw.write(1, 1)
+		bw.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(used[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			bw.write(uint32(used[1]), 8)
+			code.lengths[used[0]], code.lengths[used[1]] = 1, 1
+			code.codes[used[1]] = 1
+		}
+		return code
+	}
+
+	lengths := huffmanLengths(histogram, 15)
+	bw.write(0, 1)
+	writeCodeLengths(bw, lengths)
+	if len(used) == 1 {
+		return code
+	}
+	code.lengths, code.codes = lengths, canonicalCodes(lengths)
+	return code
+}
+
+type codeLengthToken struct {
+	symbol, extra uint8
+}
+
+// writeCodeLengths run-length codes the lengths with symbols 16 (repeat the
+// previous length), 17 and 18 (runs of zeros) and writes them with their own
+// prefix code
+func writeCodeLengths(bw *bitWriter, lengths []uint8) {
+	var tokens []codeLengthToken
+	previous := uint8(8)
+	for i := 0; i < len(lengths); {
+		length := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == length {
+			run++
+		}
+		i += run
+		if length == 0 {
+			for run >= 11 {
+				r := min(run, 138)
+				tokens = append(tokens, codeLengthToken{18, uint8(r - 11)})
+				run -= r
+			}
+			if run >= 3 {
+				tokens = append(tokens, codeLengthToken{17, uint8(run - 3)})
+				run = 0
+			}
+		} else {
+			if length != previous {
+				tokens = append(tokens, codeLengthToken{length, 0})
+				previous = length
+				run--
+			}
+			for run >= 3 {
+				r := min(run, 6)
+				tokens = append(tokens, codeLengthToken{16, uint8(r - 3)})
+				run -= r
+			}
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, codeLengthToken{length, 0})
+		}
+	}
+
+	histogram := make([]uint32, len(codeLengthCodeOrder))
+	for _, t := range tokens {
+		histogram[t.symbol]++
+	}
+	codeLengths := huffmanLengths(histogram, 7)
+	codes := canonicalCodes(codeLengths)
+	n := len(codeLengthCodeOrder)
+	for n > 4 && codeLengths[codeLengthCodeOrder[n-1]] == 0 {
+		n--
+	}
+	bw.write(uint32(n-4), 4)
+	for _, symbol := range codeLengthCodeOrder[:n] {
+		bw.write(uint32(codeLengths[symbol]), 3)
+	}
+	// Lengths are given for the whole alphabet
+	bw.write(0, 1)
+
+	single := 0
+	for _, length := range codeLengths {
+		if length > 0 {
+			single++
+		}
+	}
+	for _, t := range tokens {
+		if single > 1 {
+			bw.write(uint32(codes[t.symbol]), uint(codeLengths[t.symbol]))
+		}
+		switch t.symbol {
+		case 16:
+			bw.write(uint32(t.extra), 2)
+		case 17:
+			bw.write(uint32(t.extra), 3)
+		case 18:
+			bw.write(uint32(t.extra), 7)
+		}
+	}
+}
+
+// canonicalCodes assigns canonical prefix codes to the lengths and reverses
+// their bits, since the decoder reads codes most significant bit first
+func canonicalCodes(lengths []uint8) []uint16 {
+	var count, next [16]uint16
+	for _, length := range lengths {
+		if length > 0 {
+			count[length]++
+		}
+	}
+	code := uint16(0)
+	for length := 1; length < 16; length++ {
+		code = (code + count[length-1]) << 1
+		next[length] = code
+	}
+	codes := make([]uint16, len(lengths))
+	for symbol, length := range lengths {
+		if length > 0 {
+			codes[symbol] = bits.Reverse16(next[length]) >> (16 - length)
+			next[length]++
+		}
+	}
+	return codes
+}
+
+// huffmanLengths computes Huffman code lengths no longer than maxLength by
+// flattening the histogram until the tree is shallow enough
+func huffmanLengths(histogram []uint32, maxLength int) []uint8 {
+	weights := append([]uint32(nil), histogram...)
+	for {
+		lengths, depth := buildHuffman(weights)
+		if depth <= maxLength {
+			return lengths
+		}
+		for i, w := range weights {
+			if w > 1 {
+				weights[i] = w >> 1
+			}
+		}
+	}
+}
+
+type huffmanNode struct {
+	weight      uint64
+	left, right int
+}
+
+type huffmanHeap struct {
+	nodes []huffmanNode
+	items []int
+}
+
+func (h *huffmanHeap) Len() int { return len(h.items) }
+func (h *huffmanHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.nodes[a].weight != h.nodes[b].weight {
+		return h.nodes[a].weight < h.nodes[b].weight
+	}
+	return a < b
+}
+func (h *huffmanHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *huffmanHeap) Push(x any)    { h.items = append(h.items, x.(int)) }
+func (h *huffmanHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+func buildHuffman(weights []uint32) ([]uint8, int) {
+	lengths := make([]uint8, len(weights))
+	h := &huffmanHeap{}
+	for symbol, w := range weights {
+		if w > 0 {
+			h.nodes = append(h.nodes, huffmanNode{weight: uint64(w), left: -1, right: symbol})
+			h.items = append(h.items, len(h.nodes)-1)
+		}
+	}
+	switch len(h.items) {
+	case 0:
+		return lengths, 0
+	case 1:
+		lengths[h.nodes[0].right] = 1
+		return lengths, 1
+	}
+	heap.Init(h)
+	for h.Len() > 1 {
+		a := heap.Pop(h).(int)
+		b := heap.Pop(h).(int)
+		h.nodes = append(h.nodes, huffmanNode{weight: h.nodes[a].weight + h.nodes[b].weight, left: a, right: b})
+		heap.Push(h, len(h.nodes)-1)
+	}
+
+	depth := 0
+	var walk func(node, level int)
+	walk = func(node, level int) {
+		n := h.nodes[node]
+		if n.left < 0 {
+			lengths[n.right] = uint8(level)
+			if level > depth {
+				depth = level
+			}
+			return
+		}
+		walk(n.left, level+1)
+		walk(n.right, level+1)
+	}
+	walk(h.items[0], 0)
+	return lengths, depth
+}
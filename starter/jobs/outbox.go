@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/webhooks"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboxBatchSize is how many events one relay run handles
+const outboxBatchSize = 100
+
+// outboxRetry spaces out the attempts of a failing event; events are retried
+// until they succeed, so MaxAttempts is not used
+var outboxRetry = webhooks.RetryPolicy{BaseDelay: 5 * time.Second, MaxDelay: 10 * time.Minute}
+
+// OutboxRelay hands unprocessed outbox events to their handlers, retrying
+// failed events with backoff until every handler has succeeded
+type OutboxRelay struct {
+	db        *gorm.DB
+	handlers  []outbox.Handler
+	interval  time.Duration
+	retention time.Duration
+	now       func() time.Time
+}
+
+// RelayResult counts the events handled by one relay run
+type RelayResult struct {
+	Processed int
+	Failed    int
+}
+
+// NewOutboxRelay creates a relay that polls for events every interval, and
+// right away when outbox.Notify is called. Processed events are deleted once
+// they are older than retention.
+func NewOutboxRelay(db *gorm.DB, interval, retention time.Duration, handlers ...outbox.Handler) *OutboxRelay {
+	return &OutboxRelay{db: db, handlers: handlers, interval: interval, retention: retention, now: time.Now}
+}
+
+// Start runs the relay in a background goroutine until ctx is cancelled
+func (r *OutboxRelay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			for {
+				result, err := r.RunOnce(ctx)
+				if err != nil {
+					log.Printf("Outbox relay run failed: %v", err)
+				}
+				// A full batch means there is probably more waiting
+				if err != nil || result.Processed+result.Failed < outboxBatchSize {
+					break
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-outbox.Notifications():
+			}
+		}
+	}()
+	log.Printf("Outbox relay started (interval %s)", r.interval)
+}
+
+// RunOnce handles a batch of due events in id order. Each event is handled
+// under a savepoint: if a handler fails, its writes are undone and the event
+// is rescheduled, while the rest of the batch goes ahead. Events locked by
+// another replica's relay are skipped.
+func (r *OutboxRelay) RunOnce(ctx context.Context) (RelayResult, error) {
+	var result RelayResult
+	now := r.now()
+
+	tx := r.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return result, tx.Error
+	}
+	var events []models.OutboxEvent
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("processed_at IS NULL AND next_attempt_at <= ?", now).
+		Order("id").Limit(outboxBatchSize).
+		Find(&events).Error; err != nil {
+		tx.Rollback()
+		return result, err
+	}
+
+	for i := range events {
+		event := &events[i]
+		if err := tx.SavePoint("outbox_event").Error; err != nil {
+			tx.Rollback()
+			return RelayResult{}, err
+		}
+		updates := map[string]interface{}{"attempts": event.Attempts + 1}
+		if err := r.handle(ctx, tx, event); err != nil {
+			if err := tx.RollbackTo("outbox_event").Error; err != nil {
+				tx.Rollback()
+				return RelayResult{}, err
+			}
+			log.Printf("Outbox event %d (%s) failed: %v", event.ID, event.Type, err)
+			updates["last_error"] = err.Error()
+			updates["next_attempt_at"] = now.Add(outboxRetry.Backoff(event.Attempts + 1))
+			result.Failed++
+		} else {
+			updates["last_error"] = ""
+			updates["processed_at"] = now
+			result.Processed++
+		}
+		if err := tx.Model(event).UpdateColumns(updates).Error; err != nil {
+			tx.Rollback()
+			return RelayResult{}, err
+		}
+	}
+
+	if r.retention > 0 {
+		if err := tx.Where("processed_at < ?", now.Add(-r.retention)).Delete(&models.OutboxEvent{}).Error; err != nil {
+			tx.Rollback()
+			return RelayResult{}, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return RelayResult{}, err
+	}
+	return result, nil
+}
+
+// handle runs every handler on the event, stopping at the first failure
+func (r *OutboxRelay) handle(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+	for _, handler := range r.handlers {
+		if err := handler.Handle(ctx, tx, event); err != nil {
+			return fmt.Errorf("%s: %w", handler.Name, err)
+		}
+	}
+	return nil
+}
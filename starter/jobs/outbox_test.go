@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/utils"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var outboxColumns = []string{"id", "idempotency_key", "type", "resource_type", "resource_id", "payload", "attempts", "next_attempt_at"}
+
+func TestOutboxRelayRunOnce(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("MarksHandledEventsProcessed", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		var handled []string
+		relay := NewOutboxRelay(db, time.Second, 24*time.Hour, outbox.Handler{
+			Name: "test",
+			Handle: func(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+				handled = append(handled, event.Type)
+				return nil
+			},
+		})
+		relay.now = func() time.Time { return now }
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "outbox_events" WHERE processed_at IS NULL AND next_attempt_at <= \$1 ORDER BY id LIMIT \$2 FOR UPDATE SKIP LOCKED`).
+			WithArgs(now, outboxBatchSize).
+			WillReturnRows(sqlmock.NewRows(outboxColumns).
+				AddRow(1, "a1", "post.published", "post", 3, `{"id":3}`, 0, now).
+				AddRow(2, "b2", "tag.created", "tag", 4, `{"id":4}`, 2, now))
+		for _, id := range []int{1, 2} {
+			mock.ExpectExec(`SAVEPOINT outbox_event`).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(`UPDATE "outbox_events" SET "attempts"=\$1,"last_error"=\$2,"processed_at"=\$3 WHERE "id" = \$4`).
+				WithArgs(sqlmock.AnyArg(), "", now, id).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectExec(`DELETE FROM "outbox_events" WHERE processed_at < \$1`).
+			WithArgs(now.Add(-24 * time.Hour)).
+			WillReturnResult(sqlmock.NewResult(0, 5))
+		mock.ExpectCommit()
+
+		result, err := relay.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, RelayResult{Processed: 2}, result)
+		assert.Equal(t, []string{"post.published", "tag.created"}, handled)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ReschedulesFailedEvents", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		relay := NewOutboxRelay(db, time.Second, 0, outbox.Handler{
+			Name: "search",
+			Handle: func(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+				if event.ID == 1 {
+					return errors.New("index unavailable")
+				}
+				return nil
+			},
+		})
+		relay.now = func() time.Time { return now }
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "outbox_events"`).
+			WillReturnRows(sqlmock.NewRows(outboxColumns).
+				AddRow(1, "a1", "post.updated", "post", 3, `{"id":3}`, 2, now).
+				AddRow(2, "b2", "post.updated", "post", 5, `{"id":5}`, 0, now))
+		mock.ExpectExec(`SAVEPOINT outbox_event`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`ROLLBACK TO SAVEPOINT outbox_event`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`UPDATE "outbox_events" SET "attempts"=\$1,"last_error"=\$2,"next_attempt_at"=\$3 WHERE "id" = \$4`).
+			WithArgs(3, "search: index unavailable", now.Add(20*time.Second), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`SAVEPOINT outbox_event`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`UPDATE "outbox_events" SET "attempts"=\$1,"last_error"=\$2,"processed_at"=\$3 WHERE "id" = \$4`).
+			WithArgs(1, "", now, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		result, err := relay.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, RelayResult{Processed: 1, Failed: 1}, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"cms-backend/audit"
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"cms-backend/storage"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// retentionLockKey identifies the Postgres advisory lock held while a replica
+// empties the trash
+const retentionLockKey int64 = 0x636d735f747273
+
+// Retention permanently deletes posts, pages and media that have been in the
+// trash for longer than maxAge
+type Retention struct {
+	db       *gorm.DB
+	maxAge   time.Duration
+	interval time.Duration
+	now      func() time.Time
+}
+
+// PurgeResult counts the rows removed by one retention run
+type PurgeResult struct {
+	Posts int64
+	Pages int64
+	Media int64
+}
+
+// NewRetention creates a job that purges trash older than maxAge every interval
+func NewRetention(db *gorm.DB, maxAge, interval time.Duration) *Retention {
+	return &Retention{db: db, maxAge: maxAge, interval: interval, now: time.Now}
+}
+
+// Start runs the retention job in a background goroutine until ctx is cancelled
+func (r *Retention) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := r.RunOnce(ctx); err != nil {
+				log.Printf("Trash retention run failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Trash retention started (max age %s, interval %s)", r.maxAge, r.interval)
+}
+
+// RunOnce purges everything trashed before the retention cutoff, as a purge
+// through the API would. Stored files of purged media are removed once the
+// rows are gone. It returns a zero result
+// without error when another replica currently holds the retention lock.
+func (r *Retention) RunOnce(ctx context.Context) (PurgeResult, error) {
+	var result PurgeResult
+	cutoff := r.now().Add(-r.maxAge)
+
+	tx := r.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return result, tx.Error
+	}
+
+	var locked bool
+	if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", retentionLockKey).Scan(&locked).Error; err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	if !locked {
+		tx.Rollback()
+		return result, nil
+	}
+
+	media, err := purgeExpired(tx, tx.Preload("Renditions"), models.AuditResourceMedia, func(m *models.Media) uint { return m.ID }, cutoff)
+	if err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	posts, err := purgeExpired(tx, tx, models.RevisionResourcePost, func(p *models.Post) uint { return p.ID }, cutoff)
+	if err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	pages, err := purgeExpired(tx, tx, models.RevisionResourcePage, func(p *models.Page) uint { return p.ID }, cutoff)
+	if err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return PurgeResult{}, err
+	}
+	result = PurgeResult{Posts: int64(len(posts)), Pages: int64(len(pages)), Media: int64(len(media))}
+
+	for i := range media {
+		storage.Remove(ctx, media[i].StorageDriver, media[i].StorageKeys()...)
+	}
+	if result.Posts+result.Pages+result.Media > 0 {
+		outbox.Notify()
+		log.Printf("Trash retention purged %d posts, %d pages and %d media", result.Posts, result.Pages, result.Media)
+	}
+	return result, nil
+}
+
+// purgeExpired deletes the rows of T trashed before cutoff, loaded through
+// query, and returns them. Each purge is recorded in the audit log with no actor and published as an
+// event, and the revisions and slug redirects of posts and pages go with them.
+func purgeExpired[T any](tx, query *gorm.DB, resourceType string, id func(*T) uint, cutoff time.Time) ([]T, error) {
+	var rows []T
+	if err := query.Unscoped().Where("deleted_at < ?", cutoff).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	if err := tx.Unscoped().Delete(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(rows))
+	for i := range rows {
+		ids[i] = id(&rows[i])
+		if err := audit.Record(tx, audit.Actor{}, resourceType, ids[i], models.AuditActionPurge, audit.Snapshot(&rows[i]), nil); err != nil {
+			return nil, err
+		}
+		if err := outbox.Publish(tx, resourceType, ids[i], models.EventPurged, &rows[i]); err != nil {
+			return nil, err
+		}
+	}
+	if resourceType != models.AuditResourceMedia {
+		if err := models.DeleteHistory(tx, resourceType, ids...); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
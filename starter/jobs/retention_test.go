@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"cms-backend/models"
+	"cms-backend/storage"
+	"cms-backend/utils"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectPurgeRecorded expects the audit entry and outbox event written for
+// one purged row
+func expectPurgeRecorded(mock sqlmock.Sqlmock, resourceType string, id uint) {
+	mock.ExpectQuery(`INSERT INTO "audit_entries"`).
+		WithArgs(nil, "", "", resourceType, id, models.AuditActionPurge, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+		WithArgs(sqlmock.AnyArg(), resourceType+".purged", resourceType, id, sqlmock.AnyArg(), 0, sqlmock.AnyArg(), "", nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+func TestRetentionRunOnce(t *testing.T) {
+	t.Run("PurgesExpiredTrash", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		root := t.TempDir()
+		driver, err := storage.NewLocal(root, "")
+		require.NoError(t, err)
+		storage.SetDefault(driver)
+		t.Cleanup(func() { storage.SetDefault(nil) })
+		for _, name := range []string{"abc.png", "abc-w150.png"} {
+			require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte("png"), 0o644))
+		}
+
+		now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		retention := NewRetention(db, 30*24*time.Hour, time.Hour)
+		retention.now = func() time.Time { return now }
+		cutoff := now.AddDate(0, 0, -30)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+			WithArgs(retentionLockKey).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(true))
+		mock.ExpectQuery(`SELECT \* FROM "media" WHERE deleted_at < \$1`).
+			WithArgs(cutoff).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "storage_driver", "storage_key", "deleted_at"}).
+				AddRow(5, "local", "abc.png", cutoff.Add(-time.Hour)))
+		mock.ExpectQuery(`SELECT \* FROM "media_renditions" WHERE "media_renditions"\."media_id" = \$1`).
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "media_id", "storage_key"}).AddRow(1, 5, "abc-w150.png"))
+		mock.ExpectExec(`DELETE FROM "media" WHERE "media"\."id" = \$1`).
+			WithArgs(5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectPurgeRecorded(mock, "media", 5)
+		mock.ExpectQuery(`SELECT \* FROM "posts" WHERE deleted_at < \$1`).
+			WithArgs(cutoff).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "deleted_at"}).
+				AddRow(7, "Old", cutoff.Add(-time.Hour)).
+				AddRow(8, "Older", cutoff.Add(-2*time.Hour)))
+		mock.ExpectExec(`DELETE FROM "posts" WHERE "posts"\."id" IN \(\$1,\$2\)`).
+			WithArgs(7, 8).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		expectPurgeRecorded(mock, "post", 7)
+		expectPurgeRecorded(mock, "post", 8)
+		mock.ExpectExec(`DELETE FROM "revisions" WHERE resource_type = \$1 AND resource_id IN \(\$2,\$3\)`).
+			WithArgs("post", 7, 8).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(`DELETE FROM "slug_redirects" WHERE resource_type = \$1 AND resource_id IN \(\$2,\$3\)`).
+			WithArgs("post", 7, 8).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT \* FROM "pages" WHERE deleted_at < \$1`).
+			WithArgs(cutoff).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectCommit()
+
+		result, err := retention.RunOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, PurgeResult{Posts: 2, Media: 1}, result)
+		assert.NoFileExists(t, filepath.Join(root, "abc.png"))
+		assert.NoFileExists(t, filepath.Join(root, "abc-w150.png"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("SkipsWhenLockHeldElsewhere", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		retention := NewRetention(db, 30*24*time.Hour, time.Hour)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+			WithArgs(retentionLockKey).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(false))
+		mock.ExpectRollback()
+
+		result, err := retention.RunOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, PurgeResult{}, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("KeepsFilesOnError", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		root := t.TempDir()
+		driver, err := storage.NewLocal(root, "")
+		require.NoError(t, err)
+		storage.SetDefault(driver)
+		t.Cleanup(func() { storage.SetDefault(nil) })
+		require.NoError(t, os.WriteFile(filepath.Join(root, "abc.png"), []byte("png"), 0o644))
+		retention := NewRetention(db, 30*24*time.Hour, time.Hour)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(true))
+		mock.ExpectQuery(`SELECT \* FROM "media"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "storage_driver", "storage_key"}).AddRow(5, "local", "abc.png"))
+		mock.ExpectQuery(`SELECT \* FROM "media_renditions"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec(`DELETE FROM "media"`).WillReturnError(assert.AnError)
+		mock.ExpectRollback()
+
+		_, err = retention.RunOnce(context.Background())
+		assert.Error(t, err)
+		assert.FileExists(t, filepath.Join(root, "abc.png"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
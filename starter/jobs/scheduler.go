@@ -0,0 +1,152 @@
+package jobs
+
+import (
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// schedulerLockKey identifies the Postgres advisory lock held while a replica
+// applies due schedules, so only one replica fires them at a time
+const schedulerLockKey int64 = 0x636d735f736368
+
+// Scheduler publishes and unpublishes posts and pages whose publish_at or
+// unpublish_at has passed
+type Scheduler struct {
+	db       *gorm.DB
+	interval time.Duration
+	now      func() time.Time
+}
+
+// ScheduleResult counts the rows changed by one scheduler run
+type ScheduleResult struct {
+	PostsPublished   int64
+	PostsUnpublished int64
+	PagesPublished   int64
+	PagesUnpublished int64
+}
+
+// NewScheduler creates a scheduler that checks for due content every interval
+func NewScheduler(db *gorm.DB, interval time.Duration) *Scheduler {
+	return &Scheduler{db: db, interval: interval, now: time.Now}
+}
+
+// Start runs the scheduler in a background goroutine until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.RunOnce(ctx); err != nil {
+				log.Printf("Scheduler run failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Publishing scheduler started (interval %s)", s.interval)
+}
+
+// RunOnce applies every schedule that is due. It returns a zero result without
+// error when another replica currently holds the scheduler lock.
+func (s *Scheduler) RunOnce(ctx context.Context) (ScheduleResult, error) {
+	var result ScheduleResult
+	now := s.now()
+
+	tx := s.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return result, tx.Error
+	}
+
+	var locked bool
+	if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", schedulerLockKey).Scan(&locked).Error; err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	if !locked {
+		tx.Rollback()
+		return result, nil
+	}
+
+	var err error
+	if result.PostsPublished, result.PostsUnpublished, err = applySchedule(tx, models.RevisionResourcePost, func(post *models.Post) uint { return post.ID }, now); err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	if result.PagesPublished, result.PagesUnpublished, err = applySchedule(tx, models.RevisionResourcePage, func(page *models.Page) uint { return page.ID }, now); err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return ScheduleResult{}, err
+	}
+
+	if result.PostsPublished+result.PostsUnpublished+result.PagesPublished+result.PagesUnpublished > 0 {
+		outbox.Notify()
+	}
+	if result.PostsPublished+result.PostsUnpublished > 0 {
+		log.Printf("Scheduler published %d and unpublished %d posts", result.PostsPublished, result.PostsUnpublished)
+	}
+	if result.PagesPublished+result.PagesUnpublished > 0 {
+		log.Printf("Scheduler published %d and unpublished %d pages", result.PagesPublished, result.PagesUnpublished)
+	}
+	return result, nil
+}
+
+// applySchedule flips the status of due rows of T and clears the schedule
+// fields that have fired, including those that no longer apply because the
+// content was moved by hand in the meantime. An event is published for every
+// row whose status changed.
+func applySchedule[T any](tx *gorm.DB, resourceType string, id func(*T) uint, now time.Time) (int64, int64, error) {
+	var model T
+	var published []T
+	if err := tx.Model(&published).Clauses(clause.Returning{}).
+		Where("publish_at <= ? AND status IN ?", now, models.TransitionSources(models.ActionPublish)).
+		Updates(map[string]interface{}{
+			"status":       models.StatusPublished,
+			"published_at": gorm.Expr("publish_at"),
+			"publish_at":   nil,
+			"version":      gorm.Expr("version + 1"),
+		}).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Model(&model).Where("publish_at <= ?", now).
+		Updates(map[string]interface{}{"publish_at": nil, "version": gorm.Expr("version + 1")}).Error; err != nil {
+		return 0, 0, err
+	}
+
+	var unpublished []T
+	if err := tx.Model(&unpublished).Clauses(clause.Returning{}).
+		Where("unpublish_at <= ? AND status IN ?", now, models.TransitionSources(models.ActionUnpublish)).
+		Updates(map[string]interface{}{
+			"status":       models.StatusDraft,
+			"unpublish_at": nil,
+			"version":      gorm.Expr("version + 1"),
+		}).Error; err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Model(&model).Where("unpublish_at <= ?", now).
+		Updates(map[string]interface{}{"unpublish_at": nil, "version": gorm.Expr("version + 1")}).Error; err != nil {
+		return 0, 0, err
+	}
+
+	for i := range published {
+		if err := outbox.Publish(tx, resourceType, id(&published[i]), models.EventPublished, &published[i]); err != nil {
+			return 0, 0, err
+		}
+	}
+	for i := range unpublished {
+		if err := outbox.Publish(tx, resourceType, id(&unpublished[i]), models.EventUnpublished, &unpublished[i]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return int64(len(published)), int64(len(unpublished)), nil
+}
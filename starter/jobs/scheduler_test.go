@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	gin.SetMode(gin.ReleaseMode)
+}
+
+func expectSchedule(mock sqlmock.Sqlmock, table string, published, unpublished int) {
+	rows := func(n int) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "status"})
+		for i := 1; i <= n; i++ {
+			rows.AddRow(i, models.StatusPublished)
+		}
+		return rows
+	}
+	mock.ExpectQuery(`UPDATE "`+table+`" SET "publish_at"=\$1,"published_at"=publish_at,"status"=\$2,"version"=version \+ 1,"updated_at"=\$3 WHERE \(publish_at <= \$4 AND status IN \(\$5,\$6,\$7\)\) AND "`+table+`"\."deleted_at" IS NULL RETURNING \*`).
+		WithArgs(nil, models.StatusPublished, sqlmock.AnyArg(), sqlmock.AnyArg(), models.StatusDraft, models.StatusInReview, models.StatusArchived).
+		WillReturnRows(rows(published))
+	mock.ExpectExec(`UPDATE "` + table + `" SET "publish_at"=\$1,"version"=version \+ 1,"updated_at"=\$2 WHERE publish_at <= \$3 AND "` + table + `"\."deleted_at" IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`UPDATE "`+table+`" SET "status"=\$1,"unpublish_at"=\$2,"version"=version \+ 1,"updated_at"=\$3 WHERE \(unpublish_at <= \$4 AND status IN \(\$5\)\) AND "`+table+`"\."deleted_at" IS NULL RETURNING \*`).
+		WithArgs(models.StatusDraft, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), models.StatusPublished).
+		WillReturnRows(rows(unpublished))
+	mock.ExpectExec(`UPDATE "` + table + `" SET "unpublish_at"=\$1,"version"=version \+ 1,"updated_at"=\$2 WHERE unpublish_at <= \$3`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	resource := strings.TrimSuffix(table, "s")
+	for i := 0; i < published; i++ {
+		mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+			WithArgs(sqlmock.AnyArg(), resource+".published", resource, sqlmock.AnyArg(), sqlmock.AnyArg(), 0, sqlmock.AnyArg(), "", nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(i + 1))
+	}
+	for i := 0; i < unpublished; i++ {
+		mock.ExpectQuery(`INSERT INTO "outbox_events"`).
+			WithArgs(sqlmock.AnyArg(), resource+".unpublished", resource, sqlmock.AnyArg(), sqlmock.AnyArg(), 0, sqlmock.AnyArg(), "", nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(i + 1))
+	}
+}
+
+func TestSchedulerRunOnce(t *testing.T) {
+	t.Run("AppliesDueSchedules", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		scheduler := NewScheduler(db, time.Minute)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+			WithArgs(schedulerLockKey).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(true))
+		expectSchedule(mock, "posts", 2, 1)
+		expectSchedule(mock, "pages", 0, 1)
+		mock.ExpectCommit()
+
+		result, err := scheduler.RunOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, ScheduleResult{PostsPublished: 2, PostsUnpublished: 1, PagesUnpublished: 1}, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("SkipsWhenLockHeldElsewhere", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		scheduler := NewScheduler(db, time.Minute)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(false))
+		mock.ExpectRollback()
+
+		result, err := scheduler.RunOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, ScheduleResult{}, result)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("RollsBackOnError", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		scheduler := NewScheduler(db, time.Minute)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock\(\$1\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(true))
+		mock.ExpectQuery(`UPDATE "posts"`).WillReturnError(assert.AnError)
+		mock.ExpectRollback()
+
+		_, err := scheduler.RunOnce(context.Background())
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
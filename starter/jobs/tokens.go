@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"cms-backend/models"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TokenCleanup deletes revoked tokens once they have expired. An expired
+// token is rejected by its signature check alone, so its row is no longer
+// needed.
+type TokenCleanup struct {
+	db       *gorm.DB
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewTokenCleanup creates a job that prunes revoked tokens every interval
+func NewTokenCleanup(db *gorm.DB, interval time.Duration) *TokenCleanup {
+	return &TokenCleanup{db: db, interval: interval, now: time.Now}
+}
+
+// Start runs the cleanup in a background goroutine until ctx is cancelled
+func (t *TokenCleanup) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := t.RunOnce(ctx); err != nil {
+				log.Printf("Revoked token cleanup failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Revoked token cleanup started (interval %s)", t.interval)
+}
+
+// RunOnce deletes the revoked tokens that have expired and returns how many
+// were removed. Replicas may run it concurrently; the delete is idempotent.
+func (t *TokenCleanup) RunOnce(ctx context.Context) (int64, error) {
+	deleted := t.db.WithContext(ctx).Where("expires_at < ?", t.now()).Delete(&models.RevokedToken{})
+	return deleted.RowsAffected, deleted.Error
+}
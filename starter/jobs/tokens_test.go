@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"cms-backend/utils"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenCleanupRunOnce(t *testing.T) {
+	_, db, mock := utils.SetupRouterAndMockDB(t)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cleanup := NewTokenCleanup(db, time.Hour)
+	cleanup.now = func() time.Time { return now }
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "revoked_tokens" WHERE expires_at < \$1`).
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	deleted, err := cleanup.RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
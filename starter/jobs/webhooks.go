@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"cms-backend/models"
+	"cms-backend/webhooks"
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// webhookBatchSize is how many due deliveries one run claims
+const webhookBatchSize = 50
+
+// WebhookDispatcher sends queued webhook deliveries that are due and
+// reschedules the ones that fail
+type WebhookDispatcher struct {
+	db       *gorm.DB
+	sender   *webhooks.Sender
+	interval time.Duration
+	lease    time.Duration
+	now      func() time.Time
+}
+
+// NewWebhookDispatcher creates a dispatcher that looks for due deliveries
+// every interval. Claimed deliveries are held for lease, after which another
+// replica may retry them if this one died mid-batch.
+func NewWebhookDispatcher(db *gorm.DB, sender *webhooks.Sender, interval, lease time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{db: db, sender: sender, interval: interval, lease: lease, now: time.Now}
+}
+
+// Start runs the dispatcher in a background goroutine until ctx is cancelled
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := d.RunOnce(ctx); err != nil {
+				log.Printf("Webhook dispatch run failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Webhook dispatcher started (interval %s)", d.interval)
+}
+
+// RunOnce claims up to webhookBatchSize due deliveries of active webhooks and
+// attempts each of them, returning how many were attempted. Claiming pushes
+// next_attempt_at out by the lease and skips rows locked by other replicas,
+// so replicas never send the same delivery at once.
+func (d *WebhookDispatcher) RunOnce(ctx context.Context) (int, error) {
+	now := d.now()
+	db := d.db.WithContext(ctx)
+
+	var deliveries []models.WebhookDelivery
+	if err := db.Raw(`UPDATE webhook_deliveries SET next_attempt_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ? AND webhook_id IN (SELECT id FROM webhooks WHERE active)
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now.Add(d.lease), now, models.DeliveryPending, now, webhookBatchSize).Scan(&deliveries).Error; err != nil {
+		return 0, err
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uint, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.WebhookID)
+	}
+	var hooks []models.Webhook
+	if err := db.Find(&hooks, ids).Error; err != nil {
+		return 0, err
+	}
+	byID := make(map[uint]*models.Webhook, len(hooks))
+	for i := range hooks {
+		byID[hooks[i].ID] = &hooks[i]
+	}
+
+	failed := 0
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		delivery.Webhook = byID[delivery.WebhookID]
+		if err := d.sender.Attempt(ctx, d.db, delivery); err != nil {
+			log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+		}
+		if delivery.Status != models.DeliveryDelivered {
+			failed++
+		}
+	}
+	if failed > 0 {
+		log.Printf("Webhook dispatcher attempted %d deliveries, %d failed", len(deliveries), failed)
+	}
+	return len(deliveries), nil
+}
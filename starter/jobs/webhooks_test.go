@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"cms-backend/webhooks"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var deliveryColumns = []string{"id", "webhook_id", "event", "payload", "status", "attempts", "next_attempt_at", "created_at"}
+
+func TestWebhookDispatcherRunOnce(t *testing.T) {
+	t.Run("DeliversSignedRequests", func(t *testing.T) {
+		const secret = "0123456789abcdef0123456789abcdef"
+		var mu sync.Mutex
+		var requests []*http.Request
+		var bodies [][]byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			defer mu.Unlock()
+			requests = append(requests, r)
+			bodies = append(bodies, body)
+			if r.URL.Path == "/broken" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		sender := webhooks.NewSender(time.Second, webhooks.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour})
+		sender.Now = func() time.Time { return now }
+		dispatcher := NewWebhookDispatcher(db, sender, time.Second, 10*time.Minute)
+		dispatcher.now = func() time.Time { return now }
+
+		mock.ExpectQuery(`UPDATE webhook_deliveries SET next_attempt_at = \$1, updated_at = \$2\s+WHERE id IN \(\s+SELECT id FROM webhook_deliveries\s+WHERE status = \$3 AND next_attempt_at <= \$4 AND webhook_id IN \(SELECT id FROM webhooks WHERE active\)\s+ORDER BY next_attempt_at, id\s+LIMIT \$5\s+FOR UPDATE SKIP LOCKED\s+\)\s+RETURNING \*`).
+			WithArgs(now.Add(10*time.Minute), now, models.DeliveryPending, now, webhookBatchSize).
+			WillReturnRows(sqlmock.NewRows(deliveryColumns).
+				AddRow(7, 1, "post.published", `{"id":1,"title":"Hello"}`, models.DeliveryPending, 0, now, now.Add(-time.Minute)).
+				AddRow(8, 2, "post.published", `{"id":1,"title":"Hello"}`, models.DeliveryPending, 1, now, now.Add(-time.Hour)))
+		mock.ExpectQuery(`SELECT \* FROM "webhooks" WHERE "webhooks"\."id" IN \(\$1,\$2\)`).
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "url", "secret", "events", "active"}).
+				AddRow(1, server.URL+"/ok", secret, `["post.*"]`, true).
+				AddRow(2, server.URL+"/broken", "other-secret", `["*"]`, true))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "webhook_deliveries" SET "status"=\$1,"attempts"=\$2,"next_attempt_at"=\$3`).
+			WithArgs(models.DeliveryDelivered, 1, nil, now, http.StatusNoContent, "", "", now, sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "webhook_deliveries" SET "status"=\$1,"attempts"=\$2,"next_attempt_at"=\$3`).
+			WithArgs(models.DeliveryPending, 2, now.Add(2*time.Minute), now, http.StatusInternalServerError,
+				"", "receiver responded with 500", nil, sqlmock.AnyArg(), 8).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		attempted, err := dispatcher.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, attempted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		require.Len(t, requests, 2)
+		req, body := requests[0], bodies[0]
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, "post.published", req.Header.Get(webhooks.HeaderEvent))
+		assert.Equal(t, "7", req.Header.Get(webhooks.HeaderDelivery))
+		assert.Equal(t, strconv.FormatInt(now.Unix(), 10), req.Header.Get(webhooks.HeaderTimestamp))
+		assert.Equal(t, webhooks.Sign(secret, now.Unix(), body), req.Header.Get(webhooks.HeaderSignature))
+		var payload map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.EqualValues(t, 7, payload["id"])
+		assert.Equal(t, "post.published", payload["event"])
+		assert.Equal(t, map[string]interface{}{"id": float64(1), "title": "Hello"}, payload["data"])
+	})
+
+	t.Run("NothingDue", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		dispatcher := NewWebhookDispatcher(db, webhooks.NewSender(time.Second, webhooks.DefaultRetryPolicy), time.Second, time.Minute)
+
+		mock.ExpectQuery(`UPDATE webhook_deliveries SET next_attempt_at`).
+			WillReturnRows(sqlmock.NewRows(deliveryColumns))
+
+		attempted, err := dispatcher.RunOnce(context.Background())
+		assert.NoError(t, err)
+		assert.Zero(t, attempted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -2,18 +2,27 @@
 package main
 
 import (
+	"cms-backend/controllers"
+	"cms-backend/jobs"
 	"cms-backend/middleware"
 	"cms-backend/models"
+	"cms-backend/outbox"
 	"cms-backend/routes"
+	"cms-backend/search"
+	"cms-backend/storage"
 	"cms-backend/utils"
+	"cms-backend/webhooks"
+	"context"
 	"log"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/joho/godotenv/autoload"
 	"golang.org/x/time/rate"
+	"gorm.io/gorm"
 )
 
 // @title CMS Backend API
@@ -74,6 +83,38 @@ func SecureHeader() gin.HandlerFunc {
 	}
 }
 
+// seedAdminUser creates the initial account from ADMIN_USERNAME / ADMIN_PASSWORD
+// when the users table is still empty, so a fresh install can log in
+func seedAdminUser(db *gorm.DB) error {
+	username := os.Getenv("ADMIN_USERNAME")
+	password := os.Getenv("ADMIN_PASSWORD")
+	if username == "" || password == "" {
+		return nil
+	}
+
+	var count int64
+	if err := db.Model(&models.User{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	email := os.Getenv("ADMIN_EMAIL")
+	if email == "" {
+		email = username + "@localhost"
+	}
+	admin := models.User{Username: username, Email: email, Role: models.RoleAdmin}
+	if err := admin.SetPassword(password); err != nil {
+		return err
+	}
+	if err := db.Create(&admin).Error; err != nil {
+		return err
+	}
+	log.Printf("Created initial admin user %q", username)
+	return nil
+}
+
 func main() {
 	log.Println("Initializing database connection...")
 	dbRes, err := utils.ConnectDBWithRetry(10, 5*time.Second)
@@ -82,6 +123,14 @@ func main() {
 	}
 	defer utils.CloseDatabase(dbRes)
 
+	// "reindex" rebuilds the external search index instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		if err := runReindex(dbRes.GormDB, os.Args[2:]); err != nil {
+			log.Fatalf("Reindex failed: %v", err)
+		}
+		return
+	}
+
 	// Get the environment variable
 	env := os.Getenv("ENV")
 	if env == "" {
@@ -91,11 +140,15 @@ func main() {
 	// Conditionally run AutoMigrate in development environment
 	if env == "development" {
 		log.Println("Running AutoMigrate...")
-		if err := dbRes.GormDB.AutoMigrate(&models.Page{}, &models.Post{}, &models.Media{}); err != nil {
+		if err := dbRes.GormDB.AutoMigrate(&models.Page{}, &models.Post{}, &models.Media{}, &models.User{}, &models.RevokedToken{}, &models.Revision{}, &models.SlugRedirect{}, &models.Category{}, &models.Tag{}, &models.MediaRendition{}, &models.AuditEntry{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.OutboxEvent{}); err != nil {
 			log.Fatalf("Failed to automigrate database: %v", err)
 		}
 	}
 
+	if err := seedAdminUser(dbRes.GormDB); err != nil {
+		log.Fatalf("Failed to seed admin user: %v", err)
+	}
+
 	// Set Gin mode based on environment
 	if env == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -107,16 +160,116 @@ func main() {
 	router.Use(middleware.GzipMiddleware())
 
 	middleware.InitializeCache()
-	router.Use(middleware.RedisCacheMiddleware(5 * time.Minute))
+	router.Use(middleware.RedisCacheMiddlewareWithOptions(utils.DurationFromEnv("CACHE_TTL", 5*time.Minute), middleware.CacheOptions{
+		StaleWhileRevalidate: utils.DurationFromEnv("CACHE_STALE_WHILE_REVALIDATE", 30*time.Second),
+		StaleIfError:         utils.DurationFromEnv("CACHE_STALE_IF_ERROR", 10*time.Minute),
+		LockTimeout:          utils.DurationFromEnv("CACHE_LOCK_TIMEOUT", 5*time.Second),
+	}))
 
 	router.Use(SecureHeader())
 
 	limiter := newIPRateLimiter(rate.Every(time.Minute/200), 200)
 	router.Use(RateLimitMiddleware(limiter))
 
+	storageDriver, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure media storage: %v", err)
+	}
+	storage.SetDefault(storageDriver)
+
+	// Search queries use this text search configuration unless they pass lang
+	if language := os.Getenv("SEARCH_LANGUAGE"); language != "" {
+		if !search.ValidLanguage(language) {
+			log.Fatalf("Invalid SEARCH_LANGUAGE %q", language)
+		}
+		search.DefaultLanguage = language
+	}
+	if value := os.Getenv("SEARCH_SUGGEST_THRESHOLD"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			log.Fatalf("Invalid SEARCH_SUGGEST_THRESHOLD %q", value)
+		}
+		search.SuggestThreshold = threshold
+	}
+	// SEARCH_DRIVER=meilisearch moves full-text search to an external index,
+	// which the outbox relay keeps in step with the content
+	indexer, err := search.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure search: %v", err)
+	}
+	if indexer != nil {
+		setupCtx, cancelSetup := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := indexer.Setup(setupCtx); err != nil {
+			log.Printf("Failed to set up the search index: %v", err)
+		}
+		cancelSetup()
+		search.SetDefault(indexer)
+	}
+
 	// Initialize routes
 	routes.InitializeRoutes(router, dbRes.GormDB)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Every replica runs the scheduler; an advisory lock makes sure only one
+	// applies due schedules at a time
+	if os.Getenv("SCHEDULER_ENABLED") != "false" {
+		jobs.NewScheduler(dbRes.GormDB, utils.DurationFromEnv("SCHEDULER_INTERVAL", time.Minute)).Start(ctx)
+	}
+
+	// Trashed content is purged after TRASH_RETENTION_DAYS; 0 keeps it until
+	// it is purged by hand
+	retentionDays := 30
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			log.Fatalf("Invalid TRASH_RETENTION_DAYS %q", value)
+		}
+		retentionDays = days
+	}
+	if retentionDays > 0 {
+		maxAge := time.Duration(retentionDays) * 24 * time.Hour
+		jobs.NewRetention(dbRes.GormDB, maxAge, utils.DurationFromEnv("TRASH_PURGE_INTERVAL", time.Hour)).Start(ctx)
+	}
+
+	// Revoked tokens only need keeping until they expire
+	jobs.NewTokenCleanup(dbRes.GormDB, utils.DurationFromEnv("TOKEN_CLEANUP_INTERVAL", time.Hour)).Start(ctx)
+
+	// Deliveries are queued with the writes that trigger them; the dispatcher
+	// sends them and retries failures with exponential backoff
+	policy := webhooks.DefaultRetryPolicy
+	if value := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 1 {
+			log.Fatalf("Invalid WEBHOOK_MAX_ATTEMPTS %q", value)
+		}
+		policy.MaxAttempts = attempts
+	}
+	policy.BaseDelay = utils.DurationFromEnv("WEBHOOK_RETRY_BASE_DELAY", policy.BaseDelay)
+	policy.MaxDelay = utils.DurationFromEnv("WEBHOOK_RETRY_MAX_DELAY", policy.MaxDelay)
+	webhookSender := webhooks.NewSender(utils.DurationFromEnv("WEBHOOK_TIMEOUT", 10*time.Second), policy)
+	webhooks.SetDefault(webhookSender)
+	if os.Getenv("WEBHOOK_DISPATCHER_ENABLED") != "false" {
+		jobs.NewWebhookDispatcher(dbRes.GormDB, webhookSender, utils.DurationFromEnv("WEBHOOK_DISPATCH_INTERVAL", 10*time.Second), 10*time.Minute).Start(ctx)
+	}
+
+	// Writes record outbox events in their own transaction; the relay
+	// invalidates caches, queues webhook deliveries and updates the search
+	// index from them, retrying until each handler succeeds
+	if os.Getenv("OUTBOX_RELAY_ENABLED") != "false" {
+		handlers := []outbox.Handler{controllers.CacheInvalidation, webhooks.Handler}
+		if indexer != nil {
+			handlers = append(handlers, search.IndexHandler(indexer))
+		}
+		jobs.NewOutboxRelay(dbRes.GormDB,
+			utils.DurationFromEnv("OUTBOX_RELAY_INTERVAL", 2*time.Second),
+			utils.DurationFromEnv("OUTBOX_RETENTION", 7*24*time.Hour),
+			handlers...).Start(ctx)
+	} else {
+		log.Println("Warning: outbox relay disabled; cached responses are only invalidated once a relay on another instance handles the events")
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -0,0 +1,306 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// mp3ScanLimit bounds how far past an ID3 tag the first MP3 frame is looked for
+const mp3ScanLimit = 64 << 10
+
+// parseMP4 reads the duration from the movie header and the dimensions of the
+// first video track of an MP4, MOV or M4A file
+func parseMP4(r io.ReaderAt, size int64) (Info, error) {
+	var info Info
+	var timescale, duration uint64
+	err := walkBoxes(r, 0, size, func(typ string, start, end int64) error {
+		if typ != "moov" {
+			return nil
+		}
+		return walkBoxes(r, start, end, func(typ string, start, end int64) error {
+			switch typ {
+			case "mvhd":
+				var err error
+				timescale, duration, err = readMovieHeader(r, start, end)
+				return err
+			case "trak":
+				if info.Width > 0 {
+					return nil
+				}
+				return walkBoxes(r, start, end, func(typ string, start, end int64) error {
+					if typ == "tkhd" {
+						info.Width, info.Height = readTrackHeader(r, start, end)
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return Info{}, err
+	}
+	// An all-ones duration means unknown
+	if timescale > 0 && duration != 1<<32-1 && duration != 1<<64-1 {
+		info.Duration = float64(duration) / float64(timescale)
+	}
+	return playback(info, size), nil
+}
+
+// walkBoxes calls fn with the type and body of every ISO BMFF box between
+// start and end
+func walkBoxes(r io.ReaderAt, start, end int64, fn func(typ string, start, end int64) error) error {
+	for pos := start; pos+8 <= end; {
+		header, err := readAt(r, pos, 8)
+		if err != nil {
+			return err
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			// The last box runs to the end of the file
+			boxSize = end - pos
+		case 1:
+			large, err := readAt(r, pos+8, 8)
+			if err != nil {
+				return err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(large))
+			headerSize = 16
+		}
+		if boxSize < headerSize || pos+boxSize > end {
+			return ErrMalformed
+		}
+		if err := fn(string(header[4:]), pos+headerSize, pos+boxSize); err != nil {
+			return err
+		}
+		pos += boxSize
+	}
+	return nil
+}
+
+func readMovieHeader(r io.ReaderAt, start, end int64) (timescale, duration uint64, err error) {
+	if end-start < 20 {
+		return 0, 0, ErrMalformed
+	}
+	header, err := readAt(r, start, int(min(end-start, 32)))
+	if err != nil {
+		return 0, 0, err
+	}
+	if header[0] == 1 {
+		if len(header) < 32 {
+			return 0, 0, ErrMalformed
+		}
+		return uint64(binary.BigEndian.Uint32(header[20:])), binary.BigEndian.Uint64(header[24:]), nil
+	}
+	return uint64(binary.BigEndian.Uint32(header[12:])), uint64(binary.BigEndian.Uint32(header[16:])), nil
+}
+
+// readTrackHeader returns the presentation size of a track, 0x0 for audio. A
+// transformation matrix rotating by 90 or 270 degrees swaps the dimensions.
+func readTrackHeader(r io.ReaderAt, start, end int64) (width, height int) {
+	header, err := readAt(r, start, int(min(end-start, 96)))
+	if err != nil || len(header) < 84 {
+		return 0, 0
+	}
+	base := 24
+	if header[0] == 1 {
+		base = 36
+	}
+	if len(header) < base+60 {
+		return 0, 0
+	}
+	matrix := header[base+16:]
+	width = int(binary.BigEndian.Uint32(header[base+52:]) >> 16)
+	height = int(binary.BigEndian.Uint32(header[base+56:]) >> 16)
+	if binary.BigEndian.Uint32(matrix) == 0 && binary.BigEndian.Uint32(matrix[4:]) != 0 {
+		width, height = height, width
+	}
+	return width, height
+}
+
+var (
+	mp3Bitrates = [2][16]int{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	}
+	mp3SampleRates = [4][3]int{
+		{11025, 12000, 8000},
+		{},
+		{22050, 24000, 16000},
+		{44100, 48000, 32000},
+	}
+)
+
+// mp3Frame is a decoded MPEG audio Layer III frame header
+type mp3Frame struct {
+	mpeg1      bool
+	mono       bool
+	bitrate    int
+	sampleRate int
+	length     int
+}
+
+func parseMP3Frame(header []byte) (mp3Frame, bool) {
+	if len(header) < 4 || header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return mp3Frame{}, false
+	}
+	version := header[1] >> 3 & 3
+	layer := header[1] >> 1 & 3
+	bitrateIndex := header[2] >> 4
+	rateIndex := header[2] >> 2 & 3
+	if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mp3Frame{}, false
+	}
+	frame := mp3Frame{
+		mpeg1:      version == 3,
+		mono:       header[3]>>6 == 3,
+		sampleRate: mp3SampleRates[version][rateIndex],
+	}
+	table, coefficient := 1, 72
+	if frame.mpeg1 {
+		table, coefficient = 0, 144
+	}
+	frame.bitrate = mp3Bitrates[table][bitrateIndex] * 1000
+	frame.length = coefficient*frame.bitrate/frame.sampleRate + int(header[2]>>1&1)
+	return frame, true
+}
+
+// samples is the number of samples per channel in a frame
+func (f mp3Frame) samples() int {
+	if f.mpeg1 {
+		return 1152
+	}
+	return 576
+}
+
+// sideInfo is the size of the side information following the frame header
+func (f mp3Frame) sideInfo() int {
+	switch {
+	case f.mpeg1 && f.mono:
+		return 17
+	case f.mpeg1:
+		return 32
+	case f.mono:
+		return 9
+	}
+	return 17
+}
+
+// parseMP3 reads the Xing/Info header of a VBR file, or estimates the duration
+// of a constant bitrate file from the first frame
+func parseMP3(r io.ReaderAt, size int64) (Info, error) {
+	start := int64(0)
+	if header, err := readAt(r, 0, 10); err == nil && string(header[:3]) == "ID3" {
+		tagSize := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+		start = 10 + tagSize
+		if header[5]&0x10 != 0 {
+			start += 10
+		}
+	}
+	end := size
+	if size >= 128 {
+		if trailer, err := readAt(r, size-128, 3); err == nil && string(trailer) == "TAG" {
+			end -= 128
+		}
+	}
+	if start >= end {
+		return Info{}, ErrMalformed
+	}
+	buf, err := readAt(r, start, int(min(end-start, mp3ScanLimit)))
+	if err != nil {
+		return Info{}, err
+	}
+	for i := 0; i+4 <= len(buf); i++ {
+		frame, ok := parseMP3Frame(buf[i:])
+		if !ok {
+			continue
+		}
+		// A real frame is followed by another one, or by the end of the file
+		next := i + frame.length
+		if next+4 <= len(buf) {
+			if _, ok := parseMP3Frame(buf[next:]); !ok {
+				continue
+			}
+		} else if start+int64(next) < end {
+			continue
+		}
+		audio := end - start - int64(i)
+		info := Info{}
+		xing := i + 4 + frame.sideInfo()
+		if xing+12 <= len(buf) && (bytes.HasPrefix(buf[xing:], []byte("Xing")) || bytes.HasPrefix(buf[xing:], []byte("Info"))) {
+			flags := binary.BigEndian.Uint32(buf[xing+4:])
+			if flags&1 != 0 {
+				frames := binary.BigEndian.Uint32(buf[xing+8:])
+				info.Duration = float64(frames) * float64(frame.samples()) / float64(frame.sampleRate)
+				if flags&2 != 0 && xing+16 <= len(buf) {
+					audio = int64(binary.BigEndian.Uint32(buf[xing+12:]))
+				}
+			}
+		}
+		if info.Duration == 0 {
+			info.Duration = float64(audio) * 8 / float64(frame.bitrate)
+			info.Bitrate = frame.bitrate
+		} else {
+			info.Bitrate = int(float64(audio) * 8 / info.Duration)
+		}
+		return playback(info, size), nil
+	}
+	return Info{}, ErrMalformed
+}
+
+// parseWAV divides the size of the data chunk by the byte rate of the fmt chunk
+func parseWAV(r io.ReaderAt, size int64) (Info, error) {
+	header, err := readAt(r, 0, 12)
+	if err != nil {
+		return Info{}, err
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:]) != "WAVE" {
+		return Info{}, ErrMalformed
+	}
+	var byteRate, dataSize int64
+	for pos := int64(12); pos+8 <= size; {
+		chunk, err := readAt(r, pos, 8)
+		if err != nil {
+			return Info{}, err
+		}
+		length := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		switch string(chunk[:4]) {
+		case "fmt ":
+			format, err := readAt(r, pos+8, 12)
+			if err != nil {
+				return Info{}, err
+			}
+			byteRate = int64(binary.LittleEndian.Uint32(format[8:]))
+		case "data":
+			// Streaming writers leave the size unset
+			dataSize = min(length, size-pos-8)
+		}
+		if byteRate > 0 && dataSize > 0 {
+			info := Info{Duration: float64(dataSize) / float64(byteRate), Bitrate: int(byteRate * 8)}
+			return playback(info, size), nil
+		}
+		pos += 8 + length + length%2
+	}
+	return Info{}, ErrMalformed
+}
+
+// parseFLAC reads the sample rate and sample count of the STREAMINFO block
+func parseFLAC(r io.ReaderAt, size int64) (Info, error) {
+	header, err := readAt(r, 0, 4+4+18)
+	if err != nil {
+		return Info{}, err
+	}
+	if string(header[:4]) != "fLaC" || header[4]&0x7F != 0 {
+		return Info{}, ErrMalformed
+	}
+	streamInfo := header[8:]
+	sampleRate := int64(streamInfo[10])<<12 | int64(streamInfo[11])<<4 | int64(streamInfo[12])>>4
+	samples := int64(streamInfo[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(streamInfo[14:]))
+	if sampleRate == 0 {
+		return Info{}, ErrMalformed
+	}
+	return playback(Info{Duration: float64(samples) / float64(sampleRate)}, size), nil
+}
@@ -0,0 +1,162 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func box(typ string, children ...[]byte) []byte {
+	var body []byte
+	for _, child := range children {
+		body = append(body, child...)
+	}
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(out, typ...), body...)
+}
+
+func movieHeader(timescale, duration uint32) []byte {
+	body := make([]byte, 100)
+	binary.BigEndian.PutUint32(body[12:], timescale)
+	binary.BigEndian.PutUint32(body[16:], duration)
+	return box("mvhd", body)
+}
+
+// trackHeader builds a version 0 tkhd; rotated uses the 90 degree matrix
+func trackHeader(width, height uint32, rotated bool) []byte {
+	body := make([]byte, 84)
+	matrix := body[40:]
+	if rotated {
+		binary.BigEndian.PutUint32(matrix[4:], 1<<16)
+		binary.BigEndian.PutUint32(matrix[12:], 0xFFFF0000)
+	} else {
+		binary.BigEndian.PutUint32(matrix[0:], 1<<16)
+		binary.BigEndian.PutUint32(matrix[16:], 1<<16)
+	}
+	binary.BigEndian.PutUint32(body[76:], width<<16)
+	binary.BigEndian.PutUint32(body[80:], height<<16)
+	return box("tkhd", body)
+}
+
+func extract(t *testing.T, file []byte, contentType string) Info {
+	t.Helper()
+	info, err := Extract(bytes.NewReader(file), int64(len(file)), contentType)
+	require.NoError(t, err)
+	return info
+}
+
+func TestParseMP4(t *testing.T) {
+	for _, rotated := range []bool{false, true} {
+		file := append(box("ftyp", []byte("isom\x00\x00\x02\x00")), box("mdat", make([]byte, 2000))...)
+		// The movie box may come after the media data
+		file = append(file, box("moov",
+			movieHeader(1000, 12500),
+			box("trak", box("mdia")),
+			box("trak", trackHeader(1920, 1080, rotated)),
+			box("trak", trackHeader(0, 0, false)),
+		)...)
+
+		info := extract(t, file, "video/mp4")
+		assert.Equal(t, 12.5, info.Duration)
+		assert.Equal(t, len(file)*8*2/25, info.Bitrate)
+		if rotated {
+			assert.Equal(t, 1080, info.Width)
+			assert.Equal(t, 1920, info.Height)
+		} else {
+			assert.Equal(t, 1920, info.Width)
+			assert.Equal(t, 1080, info.Height)
+		}
+	}
+
+	t.Run("AudioOnly", func(t *testing.T) {
+		file := box("moov", movieHeader(44100, 44100*3), box("trak", trackHeader(0, 0, false)))
+		info := extract(t, file, "audio/x-m4a")
+		assert.Equal(t, 3.0, info.Duration)
+		assert.Zero(t, info.Width)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		file := box("moov", movieHeader(1000, 1000))
+		_, err := Extract(bytes.NewReader(file[:40]), 40, "video/mp4")
+		assert.ErrorIs(t, err, ErrMalformed)
+	})
+}
+
+// mp3Frames builds count MPEG-1 Layer III frames at 128 kbps and 44.1 kHz,
+// 417 bytes each; the first frame's payload is first
+func mp3Frames(count int, first []byte) []byte {
+	var out []byte
+	for i := 0; i < count; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+		if i == 0 {
+			copy(frame[4:], first)
+		}
+		out = append(out, frame...)
+	}
+	return out
+}
+
+func TestParseMP3(t *testing.T) {
+	t.Run("ConstantBitrate", func(t *testing.T) {
+		tag := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x14"), make([]byte, 20)...)
+		file := append(tag, mp3Frames(100, nil)...)
+		info := extract(t, file, "audio/mpeg")
+		assert.Equal(t, 128000, info.Bitrate)
+		assert.Equal(t, 2.606, info.Duration)
+	})
+
+	t.Run("Xing", func(t *testing.T) {
+		xing := make([]byte, 32)
+		xing = append(xing, "Xing"...)
+		xing = binary.BigEndian.AppendUint32(xing, 3)
+		xing = binary.BigEndian.AppendUint32(xing, 1000)
+		xing = binary.BigEndian.AppendUint32(xing, 3_200_000)
+		info := extract(t, mp3Frames(3, xing), "audio/mpeg")
+		assert.Equal(t, 26.122, info.Duration)
+		assert.InDelta(t, 980_000, info.Bitrate, 1000)
+	})
+
+	t.Run("NoFrames", func(t *testing.T) {
+		_, err := Extract(bytes.NewReader(make([]byte, 500)), 500, "audio/mpeg")
+		assert.ErrorIs(t, err, ErrMalformed)
+	})
+}
+
+func TestParseWAV(t *testing.T) {
+	format := binary.LittleEndian.AppendUint16(nil, 1)
+	format = binary.LittleEndian.AppendUint16(format, 2)
+	format = binary.LittleEndian.AppendUint32(format, 44100)
+	format = binary.LittleEndian.AppendUint32(format, 176400)
+	format = binary.LittleEndian.AppendUint16(format, 4)
+	format = binary.LittleEndian.AppendUint16(format, 16)
+
+	file := []byte("RIFF\x00\x00\x00\x00WAVE")
+	file = append(file, "fmt "...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(format)))
+	file = append(file, format...)
+	file = append(file, "LIST\x03\x00\x00\x00abc\x00"...)
+	file = append(file, "data"...)
+	file = binary.LittleEndian.AppendUint32(file, 352800)
+	file = append(file, make([]byte, 352800)...)
+
+	info := extract(t, file, "audio/wav")
+	assert.Equal(t, 2.0, info.Duration)
+	assert.Equal(t, 1411200, info.Bitrate)
+}
+
+func TestParseFLAC(t *testing.T) {
+	streamInfo := make([]byte, 34)
+	// 48 kHz, stereo, 16 bit, 96000 samples
+	binary.BigEndian.PutUint32(streamInfo[10:], 48000<<12|1<<9|15<<4)
+	binary.BigEndian.PutUint32(streamInfo[14:], 96000)
+	file := append([]byte("fLaC\x80\x00\x00\x22"), streamInfo...)
+	file = append(file, make([]byte, 1000)...)
+
+	info := extract(t, file, "audio/flac")
+	assert.Equal(t, 2.0, info.Duration)
+	assert.Equal(t, len(file)*4, info.Bitrate)
+}
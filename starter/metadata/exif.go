@@ -0,0 +1,508 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxEXIFSize bounds the TIFF block read into memory
+const maxEXIFSize = 1 << 20
+
+// TIFF tags read from IFD0, the Exif IFD and the GPS IFD
+const (
+	tagMake              = 0x010F
+	tagModel             = 0x0110
+	tagOrientation       = 0x0112
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagGPSIFD            = 0x8825
+	tagExposureTime      = 0x829A
+	tagFNumber           = 0x829D
+	tagISO               = 0x8827
+	tagDateTimeOriginal  = 0x9003
+	tagOffsetTimeOrig    = 0x9011
+	tagFocalLength       = 0x920A
+	tagLensModel         = 0xA434
+	tagGPSLatitudeRef    = 0x0001
+	tagGPSLatitude       = 0x0002
+	tagGPSLongitudeRef   = 0x0003
+	tagGPSLongitude      = 0x0004
+	tagGPSAltitudeRef    = 0x0005
+	tagGPSAltitude       = 0x0006
+	exifDateTimeLayout   = "2006:01:02 15:04:05"
+	exifDateTimeZoneForm = "2006:01:02 15:04:05-07:00"
+)
+
+// typeSizes is the byte size of each TIFF field type, indexed by type
+var typeSizes = [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// exifBlock locates the TIFF structure inside a file. For PNG, chunk is the
+// offset of the eXIf chunk, whose CRC has to be rewritten after a change.
+type exifBlock struct {
+	offset int64
+	length int64
+	chunk  int64
+}
+
+// locateEXIF finds the EXIF block of a JPEG, PNG or WebP file
+func locateEXIF(r io.ReaderAt, size int64) (exifBlock, bool, error) {
+	head, err := readAt(r, 0, min(12, int(size)))
+	if err != nil {
+		return exifBlock{}, false, err
+	}
+	var block exifBlock
+	var found bool
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8}):
+		block, found, err = locateJPEG(r, size)
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		block, found, err = locatePNG(r, size)
+	case len(head) == 12 && string(head[:4]) == "RIFF" && string(head[8:]) == "WEBP":
+		block, found, err = locateWebP(r, size)
+	}
+	if err != nil || !found {
+		return block, false, err
+	}
+	// Some writers keep the JPEG APP1 prefix in PNG and WebP chunks too
+	if block.length >= int64(len(exifHeader)) {
+		if prefix, err := readAt(r, block.offset, len(exifHeader)); err == nil && bytes.Equal(prefix, exifHeader) {
+			block.offset += int64(len(exifHeader))
+			block.length -= int64(len(exifHeader))
+		}
+	}
+	if block.length > maxEXIFSize || block.offset+block.length > size {
+		return block, false, ErrMalformed
+	}
+	return block, true, nil
+}
+
+// locateJPEG walks the segments before the image data looking for APP1 Exif
+func locateJPEG(r io.ReaderAt, size int64) (exifBlock, bool, error) {
+	pos := int64(2)
+	for pos+4 <= size {
+		header, err := readAt(r, pos, 4)
+		if err != nil {
+			return exifBlock{}, false, err
+		}
+		if header[0] != 0xFF {
+			return exifBlock{}, false, ErrMalformed
+		}
+		marker := header[1]
+		if marker == 0xFF {
+			// Fill byte before a marker
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return exifBlock{}, false, nil
+		}
+		length := int64(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 {
+			return exifBlock{}, false, ErrMalformed
+		}
+		if marker == 0xE1 && length >= 2+int64(len(exifHeader)) {
+			prefix, err := readAt(r, pos+4, len(exifHeader))
+			if err != nil {
+				return exifBlock{}, false, err
+			}
+			if bytes.Equal(prefix, exifHeader) {
+				return exifBlock{offset: pos + 4, length: length - 2, chunk: -1}, true, nil
+			}
+		}
+		pos += 2 + length
+	}
+	return exifBlock{}, false, nil
+}
+
+func locatePNG(r io.ReaderAt, size int64) (exifBlock, bool, error) {
+	pos := int64(8)
+	for pos+12 <= size {
+		header, err := readAt(r, pos, 8)
+		if err != nil {
+			return exifBlock{}, false, err
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		switch string(header[4:]) {
+		case "eXIf":
+			return exifBlock{offset: pos + 8, length: length, chunk: pos}, true, nil
+		case "IEND":
+			return exifBlock{}, false, nil
+		}
+		pos += 12 + length
+	}
+	return exifBlock{}, false, nil
+}
+
+func locateWebP(r io.ReaderAt, size int64) (exifBlock, bool, error) {
+	pos := int64(12)
+	for pos+8 <= size {
+		header, err := readAt(r, pos, 8)
+		if err != nil {
+			return exifBlock{}, false, err
+		}
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		if string(header[:4]) == "EXIF" {
+			return exifBlock{offset: pos + 8, length: length, chunk: -1}, true, nil
+		}
+		pos += 8 + length + length%2
+	}
+	return exifBlock{}, false, nil
+}
+
+// tiff is an EXIF block held in memory
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry is one field of an IFD; value is the offset of its value, which is
+// inline in the entry when size is at most 4
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value int
+	size  int
+}
+
+func parseTIFF(data []byte) (*tiff, uint32, error) {
+	if len(data) < 8 {
+		return nil, 0, ErrMalformed
+	}
+	t := &tiff{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, 0, ErrMalformed
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, 0, ErrMalformed
+	}
+	return t, t.order.Uint32(data[4:]), nil
+}
+
+// ifd reads the entries of the IFD at offset. Entries whose value lies outside
+// the block are dropped.
+func (t *tiff) ifd(offset uint32) ([]ifdEntry, error) {
+	start := int(offset)
+	if offset < 8 || start+2 > len(t.data) {
+		return nil, ErrMalformed
+	}
+	count := int(t.order.Uint16(t.data[start:]))
+	if start+2+count*12 > len(t.data) {
+		return nil, ErrMalformed
+	}
+	entries := make([]ifdEntry, 0, count)
+	for i := 0; i < count; i++ {
+		at := start + 2 + i*12
+		e := ifdEntry{
+			tag:   t.order.Uint16(t.data[at:]),
+			typ:   t.order.Uint16(t.data[at+2:]),
+			count: t.order.Uint32(t.data[at+4:]),
+			value: at + 8,
+		}
+		if int(e.typ) >= len(typeSizes) || typeSizes[e.typ] == 0 || e.count > maxEXIFSize {
+			continue
+		}
+		e.size = typeSizes[e.typ] * int(e.count)
+		if e.size > 4 {
+			e.value = int(t.order.Uint32(t.data[at+8:]))
+		}
+		if e.value < 0 || e.value+e.size > len(t.data) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (t *tiff) ascii(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	value := string(t.data[e.value : e.value+e.size])
+	if i := strings.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// uint reads the first value of a BYTE, SHORT or LONG field
+func (t *tiff) uint(e ifdEntry) (uint32, bool) {
+	if e.count == 0 {
+		return 0, false
+	}
+	switch e.typ {
+	case 1:
+		return uint32(t.data[e.value]), true
+	case 3:
+		return uint32(t.order.Uint16(t.data[e.value:])), true
+	case 4:
+		return t.order.Uint32(t.data[e.value:]), true
+	}
+	return 0, false
+}
+
+// rational reads the i-th value of a RATIONAL or SRATIONAL field
+func (t *tiff) rational(e ifdEntry, i int) (num, den int64, ok bool) {
+	if (e.typ != 5 && e.typ != 10) || uint32(i) >= e.count {
+		return 0, 0, false
+	}
+	at := e.value + i*8
+	if e.typ == 10 {
+		num, den = int64(int32(t.order.Uint32(t.data[at:]))), int64(int32(t.order.Uint32(t.data[at+4:])))
+	} else {
+		num, den = int64(t.order.Uint32(t.data[at:])), int64(t.order.Uint32(t.data[at+4:]))
+	}
+	return num, den, den != 0
+}
+
+func (t *tiff) float(e ifdEntry, i int) (float64, bool) {
+	num, den, ok := t.rational(e, i)
+	if !ok {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// ReadEXIF reads the camera fields of a JPEG, PNG or WebP file. A file without
+// EXIF gives nil.
+func ReadEXIF(r io.ReaderAt, size int64) (*EXIF, error) {
+	block, found, err := locateEXIF(r, size)
+	if err != nil || !found {
+		return nil, err
+	}
+	data, err := readAt(r, block.offset, int(block.length))
+	if err != nil {
+		return nil, err
+	}
+	t, offset, err := parseTIFF(data)
+	if err != nil {
+		return nil, err
+	}
+	ifd0, err := t.ifd(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	exif := &EXIF{}
+	var dateTime, dateTimeOriginal, offsetTime string
+	for _, e := range ifd0 {
+		switch e.tag {
+		case tagMake:
+			exif.Make = t.ascii(e)
+		case tagModel:
+			exif.Model = t.ascii(e)
+		case tagOrientation:
+			if value, ok := t.uint(e); ok && value >= 1 && value <= 8 {
+				exif.Orientation = int(value)
+			}
+		case tagDateTime:
+			dateTime = t.ascii(e)
+		case tagExifIFD:
+			sub, ok := t.uint(e)
+			if !ok {
+				continue
+			}
+			entries, err := t.ifd(sub)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				switch e.tag {
+				case tagDateTimeOriginal:
+					dateTimeOriginal = t.ascii(e)
+				case tagOffsetTimeOrig:
+					offsetTime = t.ascii(e)
+				case tagExposureTime:
+					if num, den, ok := t.rational(e, 0); ok && num > 0 {
+						exif.ExposureTime = exposure(num, den)
+					}
+				case tagFNumber:
+					exif.FNumber, _ = t.float(e, 0)
+				case tagISO:
+					if value, ok := t.uint(e); ok {
+						exif.ISO = int(value)
+					}
+				case tagFocalLength:
+					exif.FocalLength, _ = t.float(e, 0)
+				case tagLensModel:
+					exif.LensModel = t.ascii(e)
+				}
+			}
+		case tagGPSIFD:
+			if sub, ok := t.uint(e); ok {
+				exif.GPS = t.gps(sub)
+			}
+		}
+	}
+	if dateTimeOriginal == "" {
+		dateTimeOriginal = dateTime
+	}
+	exif.TakenAt = parseDateTime(dateTimeOriginal, offsetTime)
+	return exif, nil
+}
+
+// gps reads a position from the GPS IFD; a missing latitude or longitude gives nil
+func (t *tiff) gps(offset uint32) *GPS {
+	entries, err := t.ifd(offset)
+	if err != nil {
+		return nil
+	}
+	fields := make(map[uint16]ifdEntry, len(entries))
+	for _, e := range entries {
+		fields[e.tag] = e
+	}
+	lat, okLat := t.degrees(fields[tagGPSLatitude])
+	lon, okLon := t.degrees(fields[tagGPSLongitude])
+	if !okLat || !okLon {
+		return nil
+	}
+	if t.ascii(fields[tagGPSLatitudeRef]) == "S" {
+		lat = -lat
+	}
+	if t.ascii(fields[tagGPSLongitudeRef]) == "W" {
+		lon = -lon
+	}
+	gps := &GPS{Latitude: lat, Longitude: lon}
+	if alt, ok := t.float(fields[tagGPSAltitude], 0); ok {
+		if ref, ok := t.uint(fields[tagGPSAltitudeRef]); ok && ref == 1 {
+			alt = -alt
+		}
+		gps.Altitude = &alt
+	}
+	return gps
+}
+
+// degrees converts degrees, minutes and seconds to decimal degrees
+func (t *tiff) degrees(e ifdEntry) (float64, bool) {
+	if e.count < 3 {
+		return 0, false
+	}
+	var value float64
+	for i, unit := range []float64{1, 60, 3600} {
+		part, ok := t.float(e, i)
+		if !ok {
+			return 0, false
+		}
+		value += part / unit
+	}
+	return value, true
+}
+
+// exposure formats an exposure time the way cameras show it, e.g. "1/250" or "2.5"
+func exposure(num, den int64) string {
+	if num < den {
+		return fmt.Sprintf("1/%d", (den+num/2)/num)
+	}
+	return strconv.FormatFloat(float64(num)/float64(den), 'f', -1, 64)
+}
+
+// parseDateTime reads an EXIF timestamp. Without an offset tag the camera's
+// local time is kept as if it were UTC.
+func parseDateTime(value, offset string) *time.Time {
+	var parsed time.Time
+	var err error
+	if offset != "" {
+		parsed, err = time.Parse(exifDateTimeZoneForm, value+offset)
+	}
+	if offset == "" || err != nil {
+		parsed, err = time.Parse(exifDateTimeLayout, value)
+	}
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
+
+// StripGPS removes the GPS IFD of a JPEG, PNG or WebP file in place: its
+// entries and values are zeroed, leaving an empty IFD behind so no offsets
+// move. It reports whether there was anything to remove.
+func StripGPS(f interface {
+	io.ReaderAt
+	io.WriterAt
+}, size int64) (bool, error) {
+	block, found, err := locateEXIF(f, size)
+	if err != nil || !found {
+		return false, err
+	}
+	data, err := readAt(f, block.offset, int(block.length))
+	if err != nil {
+		return false, err
+	}
+	t, offset, err := parseTIFF(data)
+	if err != nil {
+		return false, err
+	}
+	ifd0, err := t.ifd(offset)
+	if err != nil {
+		return false, err
+	}
+	stripped := false
+	for _, e := range ifd0 {
+		if e.tag != tagGPSIFD {
+			continue
+		}
+		sub, ok := t.uint(e)
+		if !ok {
+			continue
+		}
+		entries, err := t.ifd(sub)
+		if err != nil {
+			return false, err
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		for _, gps := range entries {
+			if gps.size > 4 {
+				clear(data[gps.value : gps.value+gps.size])
+			}
+		}
+		start := int(sub)
+		count := int(t.order.Uint16(data[start:]))
+		clear(data[start:min(start+2+count*12+4, len(data))])
+		stripped = true
+	}
+	if !stripped {
+		return false, nil
+	}
+	if _, err := f.WriteAt(data, block.offset); err != nil {
+		return false, err
+	}
+	if block.chunk >= 0 {
+		return true, rewritePNGChecksum(f, block.chunk)
+	}
+	return true, nil
+}
+
+// rewritePNGChecksum recomputes the CRC of the chunk at offset
+func rewritePNGChecksum(f interface {
+	io.ReaderAt
+	io.WriterAt
+}, offset int64) error {
+	header, err := readAt(f, offset, 4)
+	if err != nil {
+		return err
+	}
+	length := int64(binary.BigEndian.Uint32(header))
+	chunk, err := readAt(f, offset+4, int(4+length))
+	if err != nil {
+		return err
+	}
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(chunk))
+	_, err = f.WriteAt(sum[:], offset+8+length)
+	return err
+}
@@ -0,0 +1,279 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// tiffBuilder lays out IFDs one after another, each followed by the values
+// that do not fit in its entries
+type tiffBuilder struct {
+	order byteOrder
+	buf   []byte
+}
+
+type field struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func newTIFF(order byteOrder) *tiffBuilder {
+	b := &tiffBuilder{order: order, buf: []byte("II*\x00\x00\x00\x00\x00")}
+	if order == binary.BigEndian {
+		b.buf = []byte("MM\x00*\x00\x00\x00\x00")
+	}
+	return b
+}
+
+func (b *tiffBuilder) ascii(tag uint16, value string) field {
+	return field{tag, 2, uint32(len(value) + 1), append([]byte(value), 0)}
+}
+
+func (b *tiffBuilder) short(tag uint16, value uint16) field {
+	return field{tag, 3, 1, b.order.AppendUint16(nil, value)}
+}
+
+func (b *tiffBuilder) long(tag uint16, value uint32) field {
+	return field{tag, 4, 1, b.order.AppendUint32(nil, value)}
+}
+
+func (b *tiffBuilder) rationals(tag uint16, values ...uint32) field {
+	var data []byte
+	for _, v := range values {
+		data = b.order.AppendUint32(data, v)
+	}
+	return field{tag, 5, uint32(len(values) / 2), data}
+}
+
+func (b *tiffBuilder) ifd(fields ...field) uint32 {
+	offset := len(b.buf)
+	dataAt := offset + 2 + 12*len(fields) + 4
+	var data []byte
+	b.buf = b.order.AppendUint16(b.buf, uint16(len(fields)))
+	for _, f := range fields {
+		b.buf = b.order.AppendUint16(b.buf, f.tag)
+		b.buf = b.order.AppendUint16(b.buf, f.typ)
+		b.buf = b.order.AppendUint32(b.buf, f.count)
+		if len(f.value) <= 4 {
+			b.buf = append(b.buf, append(f.value, make([]byte, 4-len(f.value))...)...)
+			continue
+		}
+		b.buf = b.order.AppendUint32(b.buf, uint32(dataAt+len(data)))
+		data = append(data, f.value...)
+		if len(data)%2 == 1 {
+			data = append(data, 0)
+		}
+	}
+	b.buf = append(b.order.AppendUint32(b.buf, 0), data...)
+	return uint32(offset)
+}
+
+func (b *tiffBuilder) finish(ifd0 uint32) []byte {
+	b.order.PutUint32(b.buf[4:], ifd0)
+	return b.buf
+}
+
+// cameraEXIF is a photo taken in New York, rotated 90 degrees
+func cameraEXIF(order byteOrder, withGPS bool) []byte {
+	b := newTIFF(order)
+	exifIFD := b.ifd(
+		b.rationals(tagExposureTime, 1, 250),
+		b.rationals(tagFNumber, 28, 10),
+		b.short(tagISO, 400),
+		b.ascii(tagDateTimeOriginal, "2024:05:17 14:03:09"),
+		b.ascii(tagOffsetTimeOrig, "-04:00"),
+		b.rationals(tagFocalLength, 50, 1),
+		b.ascii(tagLensModel, "RF24-105mm F4 L IS USM"),
+	)
+	fields := []field{
+		b.ascii(tagMake, "Canon"),
+		b.ascii(tagModel, "Canon EOS R5"),
+		b.short(tagOrientation, 6),
+		b.long(tagExifIFD, exifIFD),
+	}
+	if withGPS {
+		gpsIFD := b.ifd(
+			b.ascii(tagGPSLatitudeRef, "N"),
+			b.rationals(tagGPSLatitude, 40, 1, 41, 1, 2112, 100),
+			b.ascii(tagGPSLongitudeRef, "W"),
+			b.rationals(tagGPSLongitude, 74, 1, 2, 1, 4020, 100),
+			field{tagGPSAltitudeRef, 1, 1, []byte{0}},
+			b.rationals(tagGPSAltitude, 93, 10),
+		)
+		fields = append(fields, b.long(tagGPSIFD, gpsIFD))
+	}
+	return b.finish(b.ifd(fields...))
+}
+
+func testJPEG(t *testing.T, width, height int, tiff []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil))
+	encoded := buf.Bytes()
+	if tiff == nil {
+		return encoded
+	}
+	segment := append(append([]byte{}, exifHeader...), tiff...)
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	return append(append(out, segment...), encoded[2:]...)
+}
+
+func testPNG(t *testing.T, width, height int, tiff []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	encoded := buf.Bytes()
+	if tiff == nil {
+		return encoded
+	}
+	// Signature and IHDR come first
+	ihdrEnd := 8 + 12 + 13
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(tiff)))
+	chunk = append(append(chunk, "eXIf"...), tiff...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	out := append(append([]byte{}, encoded[:ihdrEnd]...), chunk...)
+	return append(out, encoded[ihdrEnd:]...)
+}
+
+func TestReadEXIF(t *testing.T) {
+	for name, order := range map[string]byteOrder{"LittleEndian": binary.LittleEndian, "BigEndian": binary.BigEndian} {
+		t.Run(name, func(t *testing.T) {
+			file := testJPEG(t, 40, 20, cameraEXIF(order, true))
+			exif, err := ReadEXIF(bytes.NewReader(file), int64(len(file)))
+			require.NoError(t, err)
+			require.NotNil(t, exif)
+
+			assert.Equal(t, "Canon", exif.Make)
+			assert.Equal(t, "Canon EOS R5", exif.Model)
+			assert.Equal(t, "RF24-105mm F4 L IS USM", exif.LensModel)
+			assert.Equal(t, 6, exif.Orientation)
+			assert.Equal(t, "1/250", exif.ExposureTime)
+			assert.Equal(t, 2.8, exif.FNumber)
+			assert.Equal(t, 400, exif.ISO)
+			assert.Equal(t, 50.0, exif.FocalLength)
+			require.NotNil(t, exif.TakenAt)
+			assert.True(t, exif.TakenAt.Equal(time.Date(2024, 5, 17, 18, 3, 9, 0, time.UTC)))
+
+			require.NotNil(t, exif.GPS)
+			assert.InDelta(t, 40.689200, exif.GPS.Latitude, 1e-6)
+			assert.InDelta(t, -74.044500, exif.GPS.Longitude, 1e-6)
+			require.NotNil(t, exif.GPS.Altitude)
+			assert.InDelta(t, 9.3, *exif.GPS.Altitude, 1e-9)
+		})
+	}
+
+	t.Run("NoEXIF", func(t *testing.T) {
+		file := testJPEG(t, 4, 4, nil)
+		exif, err := ReadEXIF(bytes.NewReader(file), int64(len(file)))
+		assert.NoError(t, err)
+		assert.Nil(t, exif)
+	})
+
+	t.Run("PNG", func(t *testing.T) {
+		file := testPNG(t, 4, 4, cameraEXIF(binary.LittleEndian, false))
+		exif, err := ReadEXIF(bytes.NewReader(file), int64(len(file)))
+		require.NoError(t, err)
+		require.NotNil(t, exif)
+		assert.Equal(t, "Canon EOS R5", exif.Model)
+		assert.Nil(t, exif.GPS)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		tiff := cameraEXIF(binary.LittleEndian, true)
+		binary.LittleEndian.PutUint32(tiff[4:], 1<<30)
+		file := testJPEG(t, 40, 20, tiff)
+		_, err := ReadEXIF(bytes.NewReader(file), int64(len(file)))
+		assert.ErrorIs(t, err, ErrMalformed)
+
+		info, err := Extract(bytes.NewReader(file), int64(len(file)), "image/jpeg")
+		require.NoError(t, err, "broken EXIF still gives the dimensions")
+		assert.Equal(t, 40, info.Width)
+		assert.Nil(t, info.EXIF)
+	})
+}
+
+func TestExtractImage(t *testing.T) {
+	file := testJPEG(t, 40, 20, cameraEXIF(binary.LittleEndian, false))
+	info, err := Extract(bytes.NewReader(file), int64(len(file)), "image/jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, 20, info.Width, "orientation 6 is displayed rotated")
+	assert.Equal(t, 40, info.Height)
+	require.NotNil(t, info.EXIF)
+	assert.Zero(t, info.Duration)
+
+	file = testPNG(t, 30, 10, nil)
+	info, err = Extract(bytes.NewReader(file), int64(len(file)), "image/png")
+	require.NoError(t, err)
+	assert.Equal(t, Info{Width: 30, Height: 10}, info)
+
+	info, err = Extract(bytes.NewReader([]byte("%PDF-1.4")), 8, "application/pdf")
+	require.NoError(t, err)
+	assert.Equal(t, Info{}, info)
+}
+
+// tempFile writes data to a file StripGPS can modify
+func tempFile(t *testing.T, data []byte) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "upload")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestStripGPS(t *testing.T) {
+	cases := map[string]struct {
+		file   []byte
+		decode func(*os.File) error
+	}{
+		"JPEG": {testJPEG(t, 40, 20, cameraEXIF(binary.BigEndian, true)), func(f *os.File) error { _, err := jpeg.Decode(f); return err }},
+		"PNG":  {testPNG(t, 40, 20, cameraEXIF(binary.LittleEndian, true)), func(f *os.File) error { _, err := png.Decode(f); return err }},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := tempFile(t, tc.file)
+			size := int64(len(tc.file))
+
+			stripped, err := StripGPS(f, size)
+			require.NoError(t, err)
+			assert.True(t, stripped)
+
+			exif, err := ReadEXIF(f, size)
+			require.NoError(t, err)
+			require.NotNil(t, exif)
+			assert.Nil(t, exif.GPS)
+			assert.Equal(t, "Canon EOS R5", exif.Model, "other fields are kept")
+			assert.Equal(t, "1/250", exif.ExposureTime)
+
+			contents, err := os.ReadFile(f.Name())
+			require.NoError(t, err)
+			assert.Len(t, contents, len(tc.file))
+			assert.NotContains(t, string(contents), "\x00\x00\x00\x4a\x00\x00\x00\x01", "coordinates are zeroed")
+			require.NoError(t, tc.decode(f), "the image must still decode")
+
+			stripped, err = StripGPS(f, size)
+			require.NoError(t, err)
+			assert.False(t, stripped, "nothing left to strip")
+		})
+	}
+}
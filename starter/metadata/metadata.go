@@ -0,0 +1,113 @@
+// Package metadata reads dimensions, EXIF fields and playback details from
+// uploaded files without decoding their content
+package metadata
+
+import (
+	"cms-backend/imaging"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// ErrMalformed is returned when a file's structure cannot be parsed
+var ErrMalformed = errors.New("metadata: malformed file")
+
+// Info is everything that could be read from a file. Fields that do not apply
+// or could not be parsed are left zero.
+type Info struct {
+	Width  int
+	Height int
+	// Duration is in seconds and Bitrate in bits per second
+	Duration float64
+	Bitrate  int
+	EXIF     *EXIF
+}
+
+// EXIF holds the camera fields of a photo
+type EXIF struct {
+	Make         string
+	Model        string
+	LensModel    string
+	TakenAt      *time.Time
+	Orientation  int
+	ExposureTime string
+	FNumber      float64
+	ISO          int
+	FocalLength  float64
+	GPS          *GPS
+}
+
+// GPS is a position in decimal degrees; Altitude is in meters when present
+type GPS struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  *float64
+}
+
+// Extract reads the metadata of a file of the given sniffed MIME type. Images
+// report their displayed dimensions, so a photo whose EXIF orientation rotates
+// it by 90 degrees has its width and height swapped. Unknown types give an
+// empty Info.
+func Extract(r io.ReaderAt, size int64, contentType string) (Info, error) {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return extractImage(r, size, contentType)
+	case "video/mp4", "video/quicktime", "video/x-m4v", "video/3gpp", "video/3gpp2", "audio/mp4", "audio/x-m4a":
+		return parseMP4(r, size)
+	case "audio/mpeg":
+		return parseMP3(r, size)
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return parseWAV(r, size)
+	case "audio/flac", "audio/x-flac":
+		return parseFLAC(r, size)
+	}
+	return Info{}, nil
+}
+
+func extractImage(r io.ReaderAt, size int64, contentType string) (Info, error) {
+	var info Info
+	if !imaging.Decodable(contentType) {
+		return info, nil
+	}
+	config, err := imaging.DecodeConfig(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return info, err
+	}
+	info.Width, info.Height = config.Width, config.Height
+	// Broken EXIF must not cost the upload its dimensions
+	if exif, err := ReadEXIF(r, size); err == nil {
+		info.EXIF = exif
+		if exif != nil && exif.Orientation >= 5 && exif.Orientation <= 8 {
+			info.Width, info.Height = info.Height, info.Width
+		}
+	}
+	return info, nil
+}
+
+// playback fills in the bitrate from the file size when the format does not
+// record it and rounds the duration to milliseconds
+func playback(info Info, size int64) Info {
+	if info.Duration <= 0 || math.IsInf(info.Duration, 0) || math.IsNaN(info.Duration) {
+		info.Duration, info.Bitrate = 0, 0
+		return info
+	}
+	if info.Bitrate == 0 {
+		info.Bitrate = int(float64(size) * 8 / info.Duration)
+	}
+	info.Duration = math.Round(info.Duration*1000) / 1000
+	return info
+}
+
+// readAt reads exactly n bytes at off
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, off)
+	if read == n {
+		return buf, nil
+	}
+	if err == nil || err == io.EOF {
+		err = ErrMalformed
+	}
+	return nil, err
+}
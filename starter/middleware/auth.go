@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const claimsContextKey = "claims"
+
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// IsTokenRevoked reports whether the token ID has been revoked via logout or rotation
+func IsTokenRevoked(db *gorm.DB, jti string) (bool, error) {
+	var count int64
+	if err := db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// authenticate validates the bearer token and returns its claims, or the status
+// and message to reject the request with. The role is reloaded from the user's
+// row, so a role change or deletion applies to tokens already issued, and a
+// password change invalidates them.
+func authenticate(c *gin.Context) (*utils.Claims, int, string) {
+	token := bearerToken(c)
+	if token == "" {
+		return nil, http.StatusUnauthorized, "Authorization token required"
+	}
+
+	claims, err := utils.ParseToken(token, utils.AccessTokenType)
+	if err != nil {
+		return nil, http.StatusUnauthorized, "Invalid or expired token"
+	}
+
+	if db, ok := c.Get("db"); ok {
+		revoked, err := IsTokenRevoked(db.(*gorm.DB), claims.ID)
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Failed to verify token"
+		}
+		if revoked {
+			return nil, http.StatusUnauthorized, "Token has been revoked"
+		}
+
+		var user models.User
+		if err := db.(*gorm.DB).Select("id", "role", "password_changed_at").Take(&user, claims.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, http.StatusUnauthorized, "User no longer exists"
+			}
+			return nil, http.StatusInternalServerError, "Failed to verify token"
+		}
+		if !user.AcceptsToken(claims.IssuedAt.Time) {
+			return nil, http.StatusUnauthorized, "Token was issued before a password change"
+		}
+		claims.Role = user.Role
+	}
+	return claims, http.StatusOK, ""
+}
+
+// AuthRequired rejects requests without a valid, unrevoked access token
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, status, message := authenticate(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(status, utils.HTTPError{Code: status, Message: message})
+			return
+		}
+
+		c.Set(claimsContextKey, claims)
+		c.Next()
+	}
+}
+
+// OptionalAuth attaches the caller's claims when a valid token is present but
+// lets anonymous requests through, for public routes whose output depends on the caller
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if bearerToken(c) != "" {
+			if claims, _, _ := authenticate(c); claims != nil {
+				c.Set(claimsContextKey, claims)
+			}
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the claims of the authenticated caller, if any
+func CurrentUser(c *gin.Context) (*utils.Claims, bool) {
+	value, exists := c.Get(claimsContextKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*utils.Claims)
+	return claims, ok
+}
+
+// SetCurrentUser attaches claims to the request context
+func SetCurrentUser(c *gin.Context, claims *utils.Claims) {
+	c.Set(claimsContextKey, claims)
+}
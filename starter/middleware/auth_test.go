@@ -0,0 +1,235 @@
+package middleware
+
+import (
+	"cms-backend/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAuthRouter(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	gin.SetMode(gin.TestMode)
+	router, _, mock := utils.SetupRouterAndMockDB(t)
+	router.POST("/protected", AuthRequired(), func(c *gin.Context) {
+		claims, ok := CurrentUser(c)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"username": claims.Username})
+	})
+	return router, mock
+}
+
+// expectUserLookup answers the user row authenticate loads after the
+// revocation check
+func expectUserLookup(mock sqlmock.Sqlmock, role string, passwordChangedAt interface{}) {
+	mock.ExpectQuery(`SELECT "id","role","password_changed_at" FROM "users" WHERE "users"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role", "password_changed_at"}).AddRow(1, role, passwordChangedAt))
+}
+
+func TestAuthRequired(t *testing.T) {
+	t.Run("MissingToken", func(t *testing.T) {
+		router, _ := setupAuthRouter(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/protected", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		router, _ := setupAuthRouter(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer garbage")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("RefreshTokenRejected", func(t *testing.T) {
+		router, _ := setupAuthRouter(t)
+		pair, err := utils.GenerateTokenPair(1, "alice", "author")
+		assert.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.RefreshToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("ValidToken", func(t *testing.T) {
+		router, mock := setupAuthRouter(t)
+		pair, err := utils.GenerateTokenPair(1, "alice", "author")
+		assert.NoError(t, err)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "revoked_tokens" WHERE jti = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		expectUserLookup(mock, "author", nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"username":"alice"`)
+	})
+
+	t.Run("RoleReloaded", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+		router.POST("/protected", AuthRequired(), func(c *gin.Context) {
+			claims, _ := CurrentUser(c)
+			c.JSON(http.StatusOK, gin.H{"role": claims.Role})
+		})
+		pair, err := utils.GenerateTokenPair(1, "alice", "editor")
+		assert.NoError(t, err)
+
+		// Demoted after the token was issued
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "revoked_tokens" WHERE jti = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		expectUserLookup(mock, "viewer", nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"role":"viewer"`)
+	})
+
+	t.Run("DeletedUser", func(t *testing.T) {
+		router, mock := setupAuthRouter(t)
+		pair, err := utils.GenerateTokenPair(1, "alice", "author")
+		assert.NoError(t, err)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "revoked_tokens" WHERE jti = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(`FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("PasswordChanged", func(t *testing.T) {
+		router, mock := setupAuthRouter(t)
+		pair, err := utils.GenerateTokenPair(1, "alice", "author")
+		assert.NoError(t, err)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "revoked_tokens" WHERE jti = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		expectUserLookup(mock, "author", time.Now().Add(time.Minute))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("PasswordChangedSameSecond", func(t *testing.T) {
+		router, mock := setupAuthRouter(t)
+		pair, err := utils.GenerateTokenPair(1, "alice", "author")
+		assert.NoError(t, err)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "revoked_tokens" WHERE jti = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		expectUserLookup(mock, "author", time.Now())
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "a token from the second of the change is rejected")
+	})
+
+	t.Run("RevokedToken", func(t *testing.T) {
+		router, mock := setupAuthRouter(t)
+		pair, err := utils.GenerateTokenPair(1, "alice", "author")
+		assert.NoError(t, err)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "revoked_tokens" WHERE jti = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestOptionalAuth(t *testing.T) {
+	setup := func(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+		gin.SetMode(gin.TestMode)
+		router, _, mock := utils.SetupRouterAndMockDB(t)
+		router.GET("/public", OptionalAuth(), func(c *gin.Context) {
+			if claims, ok := CurrentUser(c); ok {
+				c.JSON(http.StatusOK, gin.H{"username": claims.Username})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"username": ""})
+		})
+		return router, mock
+	}
+
+	t.Run("Anonymous", func(t *testing.T) {
+		router, _ := setup(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/public", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"username":""`)
+	})
+
+	t.Run("InvalidTokenIgnored", func(t *testing.T) {
+		router, _ := setup(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/public", nil)
+		req.Header.Set("Authorization", "Bearer garbage")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"username":""`)
+	})
+
+	t.Run("ValidToken", func(t *testing.T) {
+		router, mock := setup(t)
+		pair, err := utils.GenerateTokenPair(1, "alice", "editor")
+		assert.NoError(t, err)
+
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "revoked_tokens" WHERE jti = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		expectUserLookup(mock, "author", nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/public", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"username":"alice"`)
+	})
+}
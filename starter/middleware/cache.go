@@ -13,19 +13,71 @@ import (
 )
 
 type CacheItem struct {
-	Data      []byte
-	Headers   map[string]string
-	ExpiresAt time.Time
+	Data         []byte
+	Headers      map[string]string
+	ExpiresAt    time.Time
+	ETag         string
+	LastModified time.Time
+	// StaleUntil ends the stale-while-revalidate window: after ExpiresAt the
+	// item is still served while one request refreshes it
+	StaleUntil time.Time
+	// StaleIfErrorUntil ends the window in which the item replaces a failed
+	// refresh
+	StaleIfErrorUntil time.Time
+}
+
+// newCacheItem stores a response together with the validators used to answer
+// conditional GETs. A handler-supplied ETag (such as a row version) wins over
+// the body hash.
+func newCacheItem(data []byte, headers map[string]string, ttl time.Duration) *CacheItem {
+	now := time.Now()
+	item := &CacheItem{
+		Data:         data,
+		Headers:      headers,
+		ExpiresAt:    now.Add(ttl),
+		ETag:         headers[http.CanonicalHeaderKey("ETag")],
+		LastModified: now,
+	}
+	if item.ETag == "" {
+		item.ETag = bodyETag(data)
+	}
+	if modified, err := http.ParseTime(headers["Last-Modified"]); err == nil {
+		item.LastModified = modified
+	}
+	return item
+}
+
+// Fresh reports whether the item can be served without a refresh
+func (i *CacheItem) Fresh(now time.Time) bool {
+	return now.Before(i.ExpiresAt)
+}
+
+// storeUntil is when the item stops being of any use, stale windows included
+func (i *CacheItem) storeUntil() time.Time {
+	until := i.ExpiresAt
+	if i.StaleUntil.After(until) {
+		until = i.StaleUntil
+	}
+	if i.StaleIfErrorUntil.After(until) {
+		until = i.StaleIfErrorUntil
+	}
+	return until
 }
 
 type InMemoryCache struct {
 	items map[string]*CacheItem
-	mutex sync.RWMutex
+	// tags maps each tag to the keys of the entries carrying it, and keyTags
+	// each key back to its tags so an entry can be untagged without a scan
+	tags    map[string]map[string]struct{}
+	keyTags map[string][]string
+	mutex   sync.RWMutex
 }
 
 func NewInMemoryCache() *InMemoryCache {
 	cache := &InMemoryCache{
-		items: make(map[string]*CacheItem),
+		items:   make(map[string]*CacheItem),
+		tags:    make(map[string]map[string]struct{}),
+		keyTags: make(map[string][]string),
 	}
 
 	go cache.cleanup()
@@ -41,8 +93,9 @@ func (c *InMemoryCache) cleanup() {
 		c.mutex.Lock()
 		now := time.Now()
 		for key, item := range c.items {
-			if now.After(item.ExpiresAt) {
+			if now.After(item.storeUntil()) {
 				delete(c.items, key)
+				c.untag(key)
 			}
 		}
 		c.mutex.Unlock()
@@ -58,7 +111,7 @@ func (c *InMemoryCache) Get(key string) (*CacheItem, bool) {
 		return nil, false
 	}
 
-	if time.Now().After(item.ExpiresAt) {
+	if time.Now().After(item.storeUntil()) {
 		return nil, false
 	}
 
@@ -66,13 +119,23 @@ func (c *InMemoryCache) Get(key string) (*CacheItem, bool) {
 }
 
 func (c *InMemoryCache) Set(key string, data []byte, headers map[string]string, ttl time.Duration) error {
+	return c.SetItem(key, newCacheItem(data, headers, ttl), nil)
+}
+
+func (c *InMemoryCache) SetItem(key string, item *CacheItem, tags []string) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.items[key] = &CacheItem{
-		Data:      data,
-		Headers:   headers,
-		ExpiresAt: time.Now().Add(ttl),
+	c.untag(key)
+	c.items[key] = item
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+	if len(tags) > 0 {
+		c.keyTags[key] = append([]string(nil), tags...)
 	}
 	return nil
 }
@@ -82,14 +145,31 @@ func (c *InMemoryCache) Delete(key string) error {
 	defer c.mutex.Unlock()
 
 	delete(c.items, key)
+	c.untag(key)
 	return nil
 }
 
+// untag removes key from the tag index, dropping tags left with no keys.
+// The caller must hold the write lock.
+func (c *InMemoryCache) untag(key string) {
+	for _, tag := range c.keyTags[key] {
+		if keys, ok := c.tags[tag]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
+		}
+	}
+	delete(c.keyTags, key)
+}
+
 func (c *InMemoryCache) Clear() error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	c.items = make(map[string]*CacheItem)
+	c.tags = make(map[string]map[string]struct{})
+	c.keyTags = make(map[string][]string)
 	return nil
 }
 
@@ -100,11 +180,31 @@ func (c *InMemoryCache) InvalidatePattern(pattern string) error {
 	for key := range c.items {
 		if strings.Contains(key, pattern) {
 			delete(c.items, key)
+			c.untag(key)
 		}
 	}
 	return nil
 }
 
+func (c *InMemoryCache) InvalidateTags(tags ...string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			delete(c.items, key)
+			c.untag(key)
+		}
+	}
+	return nil
+}
+
+// TryLock always succeeds: within a process, requests for the same key are
+// already coalesced by the middleware
+func (c *InMemoryCache) TryLock(key string, ttl time.Duration) (func(), bool) {
+	return func() {}, true
+}
+
 func (c *InMemoryCache) GetStats() CacheStats {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
@@ -133,56 +233,88 @@ func generateCacheKey(c *gin.Context) string {
 
 func CacheMiddleware(ttl time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.Request.Method != "GET" {
+		if c.Request.Method != "GET" || isFileDownload(c.Request) {
 			c.Next()
 			return
 		}
 
+		// Authenticated callers may see unpublished content, so their responses
+		// must neither be served from nor written to the shared cache
+		if c.GetHeader("Authorization") != "" {
+			serveUncached(c)
+			return
+		}
+
 		cacheKey := generateCacheKey(c)
 
 		if cachedItem, exists := globalCache.Get(cacheKey); exists {
-			for key, value := range cachedItem.Headers {
-				c.Header(key, value)
-			}
 			c.Header("X-Cache", "HIT")
-			c.Data(http.StatusOK, cachedItem.Headers["Content-Type"], cachedItem.Data)
+			writeCachedResponse(c, cachedItem)
 			c.Abort()
 			return
 		}
 
-		writer := &responseWriter{
-			ResponseWriter: c.Writer,
-			body:           make([]byte, 0),
-			headers:        make(map[string]string),
-		}
-		c.Writer = writer
+		writer := newResponseWriter(c)
 
 		c.Next()
 
 		if c.Writer.Status() >= 200 && c.Writer.Status() < 300 {
+			setValidators(c.Writer.Header(), writer.body, time.Now())
 			for key, values := range c.Writer.Header() {
 				if len(values) > 0 {
 					writer.headers[key] = values[0]
 				}
 			}
 
-			if err := globalCache.Set(cacheKey, writer.body, writer.headers, ttl); err != nil {
+			if err := globalCache.SetItem(cacheKey, newCacheItem(writer.body, writer.headers, ttl), responseCacheTags(c)); err != nil {
 				log.Printf("Failed to cache response: %v", err)
 			}
 			c.Header("X-Cache", "MISS")
 		}
+		writer.flush(c.Request)
 	}
 }
 
+// responseWriter buffers the handler's body so the cache middleware can store
+// it and still turn the response into a 304 before anything reaches the client
 type responseWriter struct {
 	gin.ResponseWriter
 	body    []byte
 	headers map[string]string
 }
 
+func newResponseWriter(c *gin.Context) *responseWriter {
+	writer := &responseWriter{
+		ResponseWriter: c.Writer,
+		body:           make([]byte, 0),
+		headers:        make(map[string]string),
+	}
+	c.Writer = writer
+	return writer
+}
+
 func (w *responseWriter) Write(data []byte) (int, error) {
 	w.body = append(w.body, data...)
-	return w.ResponseWriter.Write(data)
+	return len(data), nil
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// flush sends the buffered response, or an empty 304 when the request's
+// validators match the ETag / Last-Modified headers
+func (w *responseWriter) flush(r *http.Request) {
+	if w.Status() == http.StatusOK {
+		lastModified, _ := http.ParseTime(w.Header().Get("Last-Modified"))
+		if notModified(r, w.Header().Get("ETag"), lastModified) {
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	if len(w.body) > 0 {
+		w.ResponseWriter.Write(w.body)
+	}
 }
 
 func InvalidateCachePattern(pattern string) {
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheOptions controls how RedisCacheMiddlewareWithOptions refreshes
+// expired entries
+type CacheOptions struct {
+	// StaleWhileRevalidate keeps serving an expired entry for this long while
+	// a single request refreshes it
+	StaleWhileRevalidate time.Duration
+	// StaleIfError keeps an expired entry for this long to answer in place
+	// of a refresh that fails with a 5xx
+	StaleIfError time.Duration
+	// LockTimeout bounds the refresh lock and how long a request waits for a
+	// refresh running on another replica; it defaults to 5 seconds
+	LockTimeout time.Duration
+}
+
+// lockPollInterval is how often a request waiting on another replica's
+// refresh checks the cache
+const lockPollInterval = 50 * time.Millisecond
+
+// flight is a refresh in progress within this process. item is set before
+// done is closed, and stays nil when the refresh produced nothing cacheable.
+type flight struct {
+	done chan struct{}
+	item *CacheItem
+}
+
+// flightGroup coalesces concurrent misses on the same key so that only one
+// of them reaches the handler
+type flightGroup struct {
+	mutex   sync.Mutex
+	flights map[string]*flight
+}
+
+var cacheFlights = &flightGroup{flights: make(map[string]*flight)}
+
+// join returns the key's flight, starting one when there is none. leader is
+// true for the caller that started it, which must call finish.
+func (g *flightGroup) join(key string) (f *flight, leader bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if f, ok := g.flights[key]; ok {
+		return f, false
+	}
+	f = &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	return f, true
+}
+
+func (g *flightGroup) finish(key string, f *flight, item *CacheItem) {
+	g.mutex.Lock()
+	delete(g.flights, key)
+	g.mutex.Unlock()
+
+	f.item = item
+	close(f.done)
+}
+
+// withinStaleWindow reports whether an expired item may still be served
+// while it is being refreshed
+func withinStaleWindow(item *CacheItem, now time.Time) bool {
+	return item != nil && now.Before(item.StaleUntil)
+}
+
+// serveCached writes an item with its X-Cache status
+func serveCached(c *gin.Context, cacheKey, status string, item *CacheItem) {
+	c.Header("X-Cache", status)
+	c.Header("X-Cache-Key", cacheKey[:8]) // First 8 chars for debugging
+	writeCachedResponse(c, item)
+	c.Abort()
+}
+
+// awaitRefresh polls the cache until a refresh running elsewhere stores a
+// fresh entry, giving up after timeout or when the client goes away
+func awaitRefresh(c *gin.Context, cacheKey string, timeout time.Duration) *CacheItem {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return nil
+		case <-deadline.C:
+			return nil
+		case <-ticker.C:
+			if item, ok := cacheManager.Get(cacheKey); ok && item.Fresh(time.Now()) {
+				return item
+			}
+		}
+	}
+}
+
+// refreshCache runs the handler and stores a successful response with its
+// stale windows. A 5xx is replaced by stale while that is still allowed.
+// It returns the stored item, or nil when nothing was cached.
+func refreshCache(c *gin.Context, cacheKey string, ttl time.Duration, opts CacheOptions, stale *CacheItem) *CacheItem {
+	writer := newResponseWriter(c)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	if status >= 200 && status < 300 && len(writer.body) > 0 {
+		setValidators(c.Writer.Header(), writer.body, time.Now())
+		for key, values := range c.Writer.Header() {
+			if len(values) > 0 {
+				writer.headers[key] = values[0]
+			}
+		}
+
+		item := newCacheItem(writer.body, writer.headers, ttl)
+		item.StaleUntil = item.ExpiresAt.Add(opts.StaleWhileRevalidate)
+		item.StaleIfErrorUntil = item.ExpiresAt.Add(opts.StaleIfError)
+		if err := cacheManager.SetItem(cacheKey, item, responseCacheTags(c)); err != nil {
+			log.Printf("Failed to cache response: %v", err)
+		}
+
+		c.Header("X-Cache", "MISS")
+		c.Header("X-Cache-Key", cacheKey[:8])
+		writer.flush(c.Request)
+		return item
+	}
+
+	if status >= http.StatusInternalServerError && stale != nil && time.Now().Before(stale.StaleIfErrorUntil) {
+		// Nothing has reached the client yet, so the error body can be dropped
+		c.Writer = writer.ResponseWriter
+		serveCached(c, cacheKey, "STALE", stale)
+		return nil
+	}
+
+	writer.flush(c.Request)
+	return nil
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStampedeRouter serves /posts through the Redis cache middleware against
+// miniredis, calling handler for every request that reaches it
+func newStampedeRouter(t *testing.T, ttl time.Duration, opts CacheOptions, handler gin.HandlerFunc) (*gin.Engine, *RedisCache) {
+	gin.SetMode(gin.TestMode)
+	redisCache, _ := newTestRedisCache(t)
+	previous := cacheManager
+	cacheManager = &CacheManager{primary: redisCache, fallback: NewInMemoryCache()}
+	t.Cleanup(func() { cacheManager = previous })
+
+	router := gin.New()
+	router.Use(RedisCacheMiddlewareWithOptions(ttl, opts))
+	router.GET("/posts", handler)
+	return router, redisCache
+}
+
+func getPosts(router *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/posts", nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRedisCacheMiddlewareCoalescesMisses(t *testing.T) {
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router, _ := newStampedeRouter(t, time.Minute, CacheOptions{}, func(c *gin.Context) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		c.String(http.StatusOK, "posts")
+	})
+
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, 20)
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = getPosts(router)
+		}(i)
+	}
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "concurrent misses share one handler run")
+	for _, w := range responses {
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "posts", w.Body.String())
+	}
+}
+
+func TestRedisCacheMiddlewareStaleWhileRevalidate(t *testing.T) {
+	var version int32
+	refreshing := make(chan struct{})
+	release := make(chan struct{})
+	router, _ := newStampedeRouter(t, 20*time.Millisecond, CacheOptions{StaleWhileRevalidate: time.Minute}, func(c *gin.Context) {
+		if atomic.AddInt32(&version, 1) > 1 {
+			close(refreshing)
+			<-release
+		}
+		c.String(http.StatusOK, "v%d", atomic.LoadInt32(&version))
+	})
+
+	assert.Equal(t, "MISS", getPosts(router).Header().Get("X-Cache"))
+	time.Sleep(30 * time.Millisecond)
+
+	refreshed := make(chan *httptest.ResponseRecorder)
+	go func() { refreshed <- getPosts(router) }()
+	<-refreshing
+
+	w := getPosts(router)
+	assert.Equal(t, "STALE", w.Header().Get("X-Cache"), "others get the expired body during the refresh")
+	assert.Equal(t, "v1", w.Body.String())
+
+	close(release)
+	w = <-refreshed
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, "v2", w.Body.String())
+
+	w = getPosts(router)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "v2", w.Body.String())
+}
+
+func TestRedisCacheMiddlewareStaleIfError(t *testing.T) {
+	var calls int32
+	router, _ := newStampedeRouter(t, 20*time.Millisecond, CacheOptions{StaleIfError: time.Minute}, func(c *gin.Context) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database unavailable"})
+			return
+		}
+		c.String(http.StatusOK, "posts")
+	})
+
+	assert.Equal(t, "MISS", getPosts(router).Header().Get("X-Cache"))
+	time.Sleep(30 * time.Millisecond)
+
+	w := getPosts(router)
+	assert.Equal(t, http.StatusOK, w.Code, "a failed refresh is answered from the stale entry")
+	assert.Equal(t, "STALE", w.Header().Get("X-Cache"))
+	assert.Equal(t, "posts", w.Body.String())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestRedisCacheMiddlewareWaitsForLockHolder(t *testing.T) {
+	var calls int32
+	router, redisCache := newStampedeRouter(t, time.Minute, CacheOptions{LockTimeout: 2 * time.Second}, func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		c.String(http.StatusOK, "local")
+	})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodGet, "/posts", nil)
+	cacheKey := generateRedisCacheKey(c)
+
+	// Another replica holds the lock and stores its response a moment later
+	unlock, ok := redisCache.TryLock(cacheKey, time.Minute)
+	require.True(t, ok)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		redisCache.SetItem(cacheKey, newCacheItem([]byte("remote"), map[string]string{}, time.Minute), nil)
+		unlock()
+	}()
+
+	w := getPosts(router)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "remote", w.Body.String())
+	assert.Zero(t, atomic.LoadInt32(&calls))
+}
+
+func TestRedisCacheTryLock(t *testing.T) {
+	cache, server := newTestRedisCache(t)
+
+	unlock, ok := cache.TryLock("posts:list", time.Second)
+	require.True(t, ok)
+	_, ok = cache.TryLock("posts:list", time.Second)
+	assert.False(t, ok, "the lock is held")
+	unlock()
+	_, ok = cache.TryLock("posts:list", time.Second)
+	assert.True(t, ok, "released locks can be taken again")
+
+	// A lock that lapsed is not released by its former holder
+	server.FastForward(2 * time.Second)
+	unlock, ok = cache.TryLock("other", time.Second)
+	require.True(t, ok)
+	server.FastForward(2 * time.Second)
+	_, ok = cache.TryLock("other", time.Minute)
+	require.True(t, ok)
+	unlock()
+	_, ok = cache.TryLock("other", time.Minute)
+	assert.False(t, ok)
+
+	assert.False(t, cache.isEntry("test_cache:cachelock:posts:list"))
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cached responses are tagged with what they contain, so a write drops only
+// the responses it makes stale. Handlers add entity tags ("post:42") for each
+// item in the response and a list tag ("posts:list") on collection endpoints;
+// the middleware adds the resource ("posts") to every response.
+
+// cacheTagsKey holds the tags handlers add to the response being built
+const cacheTagsKey = "cache_tags"
+
+// EntityTag tags the responses that show one item, such as "post:42"
+func EntityTag(resource string, id uint) string {
+	return fmt.Sprintf("%s:%d", resource, id)
+}
+
+// ListTag tags the list responses of a resource, such as "posts:list"
+func ListTag(resource string) string {
+	return resource + ":list"
+}
+
+// TagCache records that the response being built shows what the tags name
+func TagCache(c *gin.Context, tags ...string) {
+	c.Set(cacheTagsKey, append(c.GetStringSlice(cacheTagsKey), tags...))
+}
+
+// responseCacheTags returns the tags the response is stored under
+func responseCacheTags(c *gin.Context) []string {
+	tags := c.GetStringSlice(cacheTagsKey)
+	if resource := cacheResource(c); resource != "" {
+		tags = append(tags, resource)
+	}
+	return tags
+}
+
+// InvalidateCacheTags drops every cached response carrying any of the tags
+func InvalidateCacheTags(tags ...string) error {
+	// Responses stored by CacheMiddleware carry the same tags
+	globalCache.InvalidateTags(tags...)
+	if cacheManager == nil {
+		return fmt.Errorf("cache manager not initialized")
+	}
+	return cacheManager.InvalidateTags(tags...)
+}
+
+// InvalidatePost drops the responses showing one post and the post lists,
+// whose membership or order a write may change
+func InvalidatePost(id uint) error {
+	if err := InvalidateCacheTags(EntityTag("post", id), ListTag("posts")); err != nil {
+		return err
+	}
+	// Tag counts only include published posts
+	return InvalidateTagCache()
+}
+
+// InvalidatePage drops the responses showing one page and the page lists
+func InvalidatePage(id uint) error {
+	return InvalidateCacheTags(EntityTag("page", id), ListTag("pages"))
+}
+
+// InvalidateMedia drops the responses showing one media item and the media
+// lists
+func InvalidateMedia(id uint) error {
+	return InvalidateCacheTags(EntityTag("media", id), ListTag("media"))
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheTags(t *testing.T) {
+	redisCache, server := newTestRedisCache(t)
+	for name, cache := range map[string]CacheInterface{"Redis": redisCache, "InMemory": NewInMemoryCache()} {
+		t.Run(name, func(t *testing.T) {
+			headers := map[string]string{"Content-Type": "application/json"}
+			require.NoError(t, cache.SetItem("posts:one", newCacheItem([]byte("1"), headers, time.Minute), []string{"post:1", "posts"}))
+			require.NoError(t, cache.SetItem("posts:two", newCacheItem([]byte("2"), headers, time.Minute), []string{"post:2", "posts"}))
+			require.NoError(t, cache.SetItem("posts:list", newCacheItem([]byte("[1,2]"), headers, time.Minute), []string{"posts:list", "post:1", "post:2", "posts"}))
+			require.NoError(t, cache.Set("untagged", []byte("x"), headers, time.Minute))
+
+			require.NoError(t, cache.InvalidateTags("post:1"))
+			_, found := cache.Get("posts:one")
+			assert.False(t, found, "the post's own response is dropped")
+			_, found = cache.Get("posts:list")
+			assert.False(t, found, "lists showing the post are dropped")
+			_, found = cache.Get("posts:two")
+			assert.True(t, found, "other posts stay cached")
+
+			// The tag set went with the invalidation; a new entry starts a new one
+			require.NoError(t, cache.SetItem("posts:one", newCacheItem([]byte("1"), headers, time.Minute), []string{"post:1", "posts"}))
+			require.NoError(t, cache.InvalidateTags("posts", "missing"))
+			for _, key := range []string{"posts:one", "posts:two"} {
+				_, found = cache.Get(key)
+				assert.False(t, found, key)
+			}
+			_, found = cache.Get("untagged")
+			assert.True(t, found)
+			require.NoError(t, cache.InvalidateTags())
+		})
+	}
+
+	t.Run("InMemoryDeleteUntags", func(t *testing.T) {
+		cache := NewInMemoryCache()
+		require.NoError(t, cache.SetItem("posts:one", newCacheItem([]byte("1"), nil, time.Minute), []string{"post:1", "posts"}))
+		require.NoError(t, cache.SetItem("posts:two", newCacheItem([]byte("2"), nil, time.Minute), []string{"post:2", "posts"}))
+
+		require.NoError(t, cache.Delete("posts:one"))
+		assert.NotContains(t, cache.tags, "post:1", "tags left with no keys are dropped")
+		assert.Equal(t, map[string]struct{}{"posts:two": {}}, cache.tags["posts"])
+
+		require.NoError(t, cache.InvalidatePattern("posts:"))
+		assert.Empty(t, cache.tags)
+		assert.Empty(t, cache.keyTags)
+
+		// Retagging a key drops the tags it no longer carries
+		require.NoError(t, cache.SetItem("pages:one", newCacheItem([]byte("1"), nil, time.Minute), []string{"page:1", "pages"}))
+		require.NoError(t, cache.SetItem("pages:one", newCacheItem([]byte("1"), nil, time.Minute), []string{"pages"}))
+		assert.NotContains(t, cache.tags, "page:1")
+		require.NoError(t, cache.InvalidateTags("pages"))
+		assert.Empty(t, cache.tags)
+		assert.Empty(t, cache.keyTags)
+	})
+
+	t.Run("RedisTagSetsExpire", func(t *testing.T) {
+		require.NoError(t, redisCache.SetItem("pages:one", newCacheItem([]byte("1"), nil, time.Minute), []string{"page:1"}))
+		assert.InDelta(t, float64(time.Minute), float64(server.TTL("test_cache:cachetag:page:1")), float64(time.Second))
+		members, err := server.Members("test_cache:cachetag:page:1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"test_cache:pages:one"}, members)
+	})
+}
+
+func TestRedisCacheMiddlewareTags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	redisCache, _ := newTestRedisCache(t)
+	previous := cacheManager
+	cacheManager = &CacheManager{primary: redisCache, fallback: NewInMemoryCache()}
+	t.Cleanup(func() { cacheManager = previous })
+
+	router := gin.New()
+	router.Use(RedisCacheMiddleware(time.Minute))
+	router.GET("/posts/:id", func(c *gin.Context) {
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		TagCache(c, EntityTag("post", uint(id)))
+		c.JSON(http.StatusOK, gin.H{"id": id, "time": time.Now().UnixNano()})
+	})
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(w, req)
+		return w.Header().Get("X-Cache")
+	}
+
+	assert.Equal(t, "MISS", get("/posts/1"))
+	assert.Equal(t, "MISS", get("/posts/2"))
+	require.NoError(t, InvalidatePost(1))
+	assert.Equal(t, "MISS", get("/posts/1"))
+	assert.Equal(t, "HIT", get("/posts/2"), "updating one post keeps the others cached")
+
+	// The middleware tags every response with its resource
+	require.NoError(t, InvalidatePostCache())
+	assert.Equal(t, "MISS", get("/posts/2"))
+}
+
+func TestCacheMiddlewareTags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previousCache, previousManager := globalCache, cacheManager
+	globalCache = NewInMemoryCache()
+	cacheManager = &CacheManager{primary: NewInMemoryCache(), fallback: NewInMemoryCache()}
+	t.Cleanup(func() { globalCache, cacheManager = previousCache, previousManager })
+
+	router := gin.New()
+	router.Use(CacheMiddleware(time.Minute))
+	router.GET("/posts/:id", func(c *gin.Context) {
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		TagCache(c, EntityTag("post", uint(id)))
+		c.JSON(http.StatusOK, gin.H{"id": id, "time": time.Now().UnixNano()})
+	})
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(w, req)
+		return w.Header().Get("X-Cache")
+	}
+
+	assert.Equal(t, "MISS", get("/posts/1"))
+	assert.Equal(t, "MISS", get("/posts/2"))
+	require.NoError(t, InvalidatePost(1))
+	assert.Equal(t, "MISS", get("/posts/1"))
+	assert.Equal(t, "HIT", get("/posts/2"))
+
+	require.NoError(t, InvalidatePostCache())
+	assert.Equal(t, "MISS", get("/posts/2"))
+}
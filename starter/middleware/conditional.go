@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"cms-backend/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyETag derives a weak validator from a response body
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since only
+// when the client sent no entity tags
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etag != "" && utils.ETagMatchesWeak(header, etag)
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// setValidators fills in ETag and Last-Modified on a buffered 2xx response
+// that does not already carry them
+func setValidators(header http.Header, body []byte, now time.Time) {
+	if header.Get("ETag") == "" {
+		header.Set("ETag", bodyETag(body))
+	}
+	if header.Get("Last-Modified") == "" {
+		header.Set("Last-Modified", now.UTC().Format(http.TimeFormat))
+	}
+}
+
+// writeCachedResponse replays a cached item, or answers 304 when the client's
+// copy is still current
+func writeCachedResponse(c *gin.Context, item *CacheItem) {
+	for key, value := range item.Headers {
+		c.Header(key, value)
+	}
+	etag := item.ETag
+	if etag == "" {
+		// Entries cached before validators were stored
+		etag = bodyETag(item.Data)
+	}
+	c.Header("ETag", etag)
+	if !item.LastModified.IsZero() {
+		c.Header("Last-Modified", item.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c.Request, etag, item.LastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, item.Headers["Content-Type"], item.Data)
+}
+
+// serveUncached runs the handler without touching the shared cache but still
+// answers If-None-Match from the body it produces
+func serveUncached(c *gin.Context) {
+	writer := newResponseWriter(c)
+	c.Next()
+	if writer.Status() == http.StatusOK && len(writer.body) > 0 && writer.Header().Get("ETag") == "" {
+		writer.Header().Set("ETag", bodyETag(writer.body))
+	}
+	writer.flush(c.Request)
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupConditionalRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	ClearCache()
+	router := gin.New()
+	router.Use(middlewares...)
+	router.GET("/list", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": []string{"a", "b"}})
+	})
+	router.GET("/item", func(c *gin.Context) {
+		c.Header("ETag", `"post-1-v2"`)
+		c.JSON(http.StatusOK, gin.H{"id": 1})
+	})
+	return router
+}
+
+func conditionalGet(router *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCacheMiddlewareConditionalGet(t *testing.T) {
+	router := setupConditionalRouter(CacheMiddleware(time.Minute))
+
+	first := conditionalGet(router, "/list", nil)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+	etag := first.Header().Get("ETag")
+	lastModified := first.Header().Get("Last-Modified")
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, lastModified)
+
+	t.Run("IfNoneMatch", func(t *testing.T) {
+		w := conditionalGet(router, "/list", map[string]string{"If-None-Match": etag})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+		assert.Empty(t, w.Body.Bytes())
+	})
+
+	t.Run("IfModifiedSince", func(t *testing.T) {
+		w := conditionalGet(router, "/list", map[string]string{"If-Modified-Since": lastModified})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+	})
+
+	t.Run("StaleETag", func(t *testing.T) {
+		w := conditionalGet(router, "/list", map[string]string{"If-None-Match": `W/"stale"`})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.NotEmpty(t, w.Body.Bytes())
+	})
+
+	t.Run("ModifiedSinceEarlier", func(t *testing.T) {
+		earlier := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		w := conditionalGet(router, "/list", map[string]string{"If-Modified-Since": earlier})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestCacheMiddlewareConditionalGetOnMiss(t *testing.T) {
+	router := setupConditionalRouter(CacheMiddleware(time.Minute))
+
+	// The handler's own ETag is kept, so the first request can already be answered with 304
+	w := conditionalGet(router, "/item", map[string]string{"If-None-Match": `"post-1-v2"`})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Empty(t, w.Body.Bytes())
+}
+
+func TestCacheMiddlewareConditionalGetUncached(t *testing.T) {
+	router := setupConditionalRouter(CacheMiddleware(time.Minute))
+	auth := map[string]string{"Authorization": "Bearer token"}
+
+	first := conditionalGet(router, "/list", auth)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get("X-Cache"))
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w := conditionalGet(router, "/list", map[string]string{"Authorization": "Bearer token", "If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+}
+
+func TestGzipNotModified(t *testing.T) {
+	router := setupConditionalRouter(GzipMiddleware(), CacheMiddleware(time.Minute))
+	gzip := map[string]string{"Accept-Encoding": "gzip"}
+
+	first := conditionalGet(router, "/list", gzip)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "gzip", first.Header().Get("Content-Encoding"))
+
+	w := conditionalGet(router, "/list", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": first.Header().Get("ETag")})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Body.Bytes())
+}
@@ -2,24 +2,52 @@ package middleware
 
 import (
 	"compress/gzip"
-	"io"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// gzipWriter compresses the body lazily, so bodiless responses such as 304
+// are sent without a gzip stream or a Content-Encoding header
 type gzipWriter struct {
 	gin.ResponseWriter
-	writer io.Writer
+	writer *gzip.Writer
 }
 
-func (g gzipWriter) Write(data []byte) (int, error) {
+func (g *gzipWriter) WriteHeader(code int) {
+	if code == http.StatusNotModified || code == http.StatusNoContent {
+		g.Header().Del("Content-Encoding")
+	}
+	g.ResponseWriter.WriteHeader(code)
+}
+
+func (g *gzipWriter) Write(data []byte) (int, error) {
+	if g.writer == nil {
+		g.writer = gzip.NewWriter(g.ResponseWriter)
+	}
 	return g.writer.Write(data)
 }
 
+func (g *gzipWriter) WriteString(s string) (int, error) {
+	return g.Write([]byte(s))
+}
+
+func (g *gzipWriter) close() {
+	if g.writer != nil {
+		g.writer.Close()
+	}
+}
+
+// isFileDownload reports whether the request streams a stored media file or
+// rendition, which is neither gzipped again nor buffered into the response cache
+func isFileDownload(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, "/file") || strings.HasSuffix(r.URL.Path, "/render")
+}
+
 func GzipMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+		if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") || isFileDownload(c.Request) {
 			c.Next()
 			return
 		}
@@ -33,13 +61,9 @@ func GzipMiddleware() gin.HandlerFunc {
 		c.Header("Content-Encoding", "gzip")
 		c.Header("Vary", "Accept-Encoding")
 
-		gz := gzip.NewWriter(c.Writer)
-		defer gz.Close()
-
-		c.Writer = &gzipWriter{
-			ResponseWriter: c.Writer,
-			writer:         gz,
-		}
+		gz := &gzipWriter{ResponseWriter: c.Writer}
+		defer gz.close()
+		c.Writer = gz
 
 		c.Next()
 	}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"cms-backend/utils"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ifMatchRequired reports whether writes must carry If-Match (REQUIRE_IF_MATCH=true)
+func ifMatchRequired() bool {
+	value := strings.ToLower(os.Getenv("REQUIRE_IF_MATCH"))
+	return value == "true" || value == "1"
+}
+
+// PreconditionFailed writes the standard 412 response
+func PreconditionFailed(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusPreconditionFailed, utils.HTTPError{Code: 412, Message: "Resource has been modified; fetch it again and retry with the new ETag"})
+}
+
+// CheckIfMatch compares the request's If-Match header with the resource's current
+// ETag. It writes 412 on a mismatch and 428 when REQUIRE_IF_MATCH is set and the
+// header is missing, returning false in both cases.
+func CheckIfMatch(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if ifMatchRequired() {
+			c.AbortWithStatusJSON(http.StatusPreconditionRequired, utils.HTTPError{Code: 428, Message: "If-Match header is required"})
+			return false
+		}
+		return true
+	}
+	if !utils.ETagMatches(header, etag) {
+		PreconditionFailed(c)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const etag = `"post-1-v2"`
+
+	tests := []struct {
+		name     string
+		header   string
+		required string
+		expected int
+	}{
+		{"NoHeader", "", "", http.StatusOK},
+		{"NoHeaderRequired", "", "true", http.StatusPreconditionRequired},
+		{"Match", etag, "true", http.StatusOK},
+		{"Wildcard", "*", "", http.StatusOK},
+		{"Mismatch", `"post-1-v1"`, "", http.StatusPreconditionFailed},
+		{"Weak", "W/" + etag, "", http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REQUIRE_IF_MATCH", tt.required)
+			router := gin.New()
+			router.PUT("/resource", func(c *gin.Context) {
+				if CheckIfMatch(c, etag) {
+					c.Status(http.StatusOK)
+				}
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut, "/resource", nil)
+			if tt.header != "" {
+				req.Header.Set("If-Match", tt.header)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"cms-backend/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Scope describes how far a permission reaches for a role
+type Scope string
+
+const (
+	ScopeNone Scope = ""
+	ScopeOwn  Scope = "own"
+	ScopeAny  Scope = "any"
+)
+
+// Permissions checked by routes and controllers
+const (
+	PermPagesCreate    = "pages:create"
+	PermPagesUpdate    = "pages:update"
+	PermPagesDelete    = "pages:delete"
+	PermPagesPublish   = "pages:publish"
+	PermPostsCreate    = "posts:create"
+	PermPostsUpdate    = "posts:update"
+	PermPostsDelete    = "posts:delete"
+	PermPostsSubmit    = "posts:submit"
+	PermPostsPublish   = "posts:publish"
+	PermMediaCreate    = "media:create"
+	PermMediaUpdate    = "media:update"
+	PermMediaDelete    = "media:delete"
+	PermTaxonomyManage = "taxonomy:manage"
+	PermCacheManage    = "cache:manage"
+	PermUsersManage    = "users:manage"
+	PermAuditRead      = "audit:read"
+	PermWebhooksManage = "webhooks:manage"
+)
+
+// Policy maps a role to the permissions it holds. A permission key may use a
+// trailing wildcard ("posts:*") or be "*" for everything.
+type Policy map[string]map[string]Scope
+
+// DefaultPolicy is used unless RBAC_POLICY_FILE points at a JSON file of the same shape
+var DefaultPolicy = Policy{
+	"admin": {
+		"*": ScopeAny,
+	},
+	"editor": {
+		"pages:*":          ScopeAny,
+		"posts:*":          ScopeAny,
+		"media:*":          ScopeAny,
+		PermTaxonomyManage: ScopeAny,
+	},
+	"author": {
+		PermPostsCreate: ScopeAny,
+		PermPostsUpdate: ScopeOwn,
+		PermPostsDelete: ScopeOwn,
+		PermPostsSubmit: ScopeOwn,
+		PermMediaCreate: ScopeAny,
+	},
+	"viewer": {},
+}
+
+var (
+	activePolicy   Policy
+	activePolicyMu sync.RWMutex
+	policyOnce     sync.Once
+)
+
+// LoadPolicy reads a JSON policy file, e.g. {"author": {"posts:update": "own"}}
+func LoadPolicy(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	for role, perms := range policy {
+		for perm, scope := range perms {
+			if scope != ScopeOwn && scope != ScopeAny {
+				return nil, fmt.Errorf("invalid scope %q for %s/%s", scope, role, perm)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// SetPolicy replaces the active permission matrix
+func SetPolicy(policy Policy) {
+	policyOnce.Do(func() {})
+	activePolicyMu.Lock()
+	defer activePolicyMu.Unlock()
+	activePolicy = policy
+}
+
+func currentPolicy() Policy {
+	policyOnce.Do(func() {
+		policy := DefaultPolicy
+		if path := os.Getenv("RBAC_POLICY_FILE"); path != "" {
+			loaded, err := LoadPolicy(path)
+			if err != nil {
+				log.Printf("Falling back to default RBAC policy: %v", err)
+			} else {
+				log.Printf("Loaded RBAC policy from %s", path)
+				policy = loaded
+			}
+		}
+		activePolicyMu.Lock()
+		activePolicy = policy
+		activePolicyMu.Unlock()
+	})
+	activePolicyMu.RLock()
+	defer activePolicyMu.RUnlock()
+	return activePolicy
+}
+
+// ScopeFor returns the broadest scope the role holds for a permission
+func (p Policy) ScopeFor(role, permission string) Scope {
+	perms, ok := p[role]
+	if !ok {
+		return ScopeNone
+	}
+	best := ScopeNone
+	consider := func(scope Scope) {
+		if scope == ScopeAny || (scope == ScopeOwn && best == ScopeNone) {
+			best = scope
+		}
+	}
+	if scope, ok := perms[permission]; ok {
+		consider(scope)
+	}
+	if i := strings.Index(permission, ":"); i > 0 {
+		if scope, ok := perms[permission[:i]+":*"]; ok {
+			consider(scope)
+		}
+	}
+	if scope, ok := perms["*"]; ok {
+		consider(scope)
+	}
+	return best
+}
+
+// Forbidden writes the standard 403 response
+func Forbidden(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, utils.HTTPError{Code: 403, Message: "You do not have permission to perform this action"})
+}
+
+// RequirePermission allows the request through when the caller's role holds the
+// permission at any scope. Ownership for "own" scopes is checked by the controller
+// with HasPermission once the resource is loaded.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := CurrentUser(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.HTTPError{Code: 401, Message: "Authorization token required"})
+			return
+		}
+		if currentPolicy().ScopeFor(claims.Role, permission) == ScopeNone {
+			Forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasPermission reports whether the caller may act on a resource owned by ownerID
+func HasPermission(c *gin.Context, permission string, ownerID *uint) bool {
+	claims, ok := CurrentUser(c)
+	if !ok {
+		return false
+	}
+	switch currentPolicy().ScopeFor(claims.Role, permission) {
+	case ScopeAny:
+		return true
+	case ScopeOwn:
+		return ownerID != nil && *ownerID == claims.UserID
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"cms-backend/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyScopeFor(t *testing.T) {
+	testCases := []struct {
+		role       string
+		permission string
+		expected   Scope
+	}{
+		{"admin", PermCacheManage, ScopeAny},
+		{"admin", PermUsersManage, ScopeAny},
+		{"editor", PermPagesUpdate, ScopeAny},
+		{"editor", PermPostsDelete, ScopeAny},
+		{"editor", PermCacheManage, ScopeNone},
+		{"author", PermPostsCreate, ScopeAny},
+		{"author", PermPostsUpdate, ScopeOwn},
+		{"author", PermPagesUpdate, ScopeNone},
+		{"viewer", PermPostsCreate, ScopeNone},
+		{"unknown", PermPostsCreate, ScopeNone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.role+"_"+tc.permission, func(t *testing.T) {
+			assert.Equal(t, tc.expected, DefaultPolicy.ScopeFor(tc.role, tc.permission))
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(claims *utils.Claims) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if claims != nil {
+				SetCurrentUser(c, claims)
+			}
+			c.Next()
+		})
+		router.POST("/cache/clear", RequirePermission(PermCacheManage), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return router
+	}
+
+	testCases := []struct {
+		name     string
+		claims   *utils.Claims
+		expected int
+	}{
+		{"Anonymous", nil, http.StatusUnauthorized},
+		{"Editor", &utils.Claims{UserID: 2, Role: "editor"}, http.StatusForbidden},
+		{"Admin", &utils.Claims{UserID: 1, Role: "admin"}, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/cache/clear", nil)
+			newRouter(tc.claims).ServeHTTP(w, req)
+			assert.Equal(t, tc.expected, w.Code)
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	owner := uint(5)
+	other := uint(6)
+
+	check := func(claims *utils.Claims, ownerID *uint) bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if claims != nil {
+			SetCurrentUser(c, claims)
+		}
+		return HasPermission(c, PermPostsUpdate, ownerID)
+	}
+
+	assert.True(t, check(&utils.Claims{UserID: 5, Role: "author"}, &owner))
+	assert.False(t, check(&utils.Claims{UserID: 5, Role: "author"}, &other))
+	assert.False(t, check(&utils.Claims{UserID: 5, Role: "author"}, nil))
+	assert.True(t, check(&utils.Claims{UserID: 9, Role: "editor"}, &other))
+	assert.False(t, check(nil, &owner))
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "policy.json")
+	os.WriteFile(valid, []byte(`{"author": {"posts:*": "own", "pages:create": "any"}}`), 0o600)
+	policy, err := LoadPolicy(valid)
+	assert.NoError(t, err)
+	assert.Equal(t, ScopeOwn, policy.ScopeFor("author", PermPostsDelete))
+	assert.Equal(t, ScopeAny, policy.ScopeFor("author", PermPagesCreate))
+
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"author": {"posts:*": "everything"}}`), 0o600)
+	_, err = LoadPolicy(invalid)
+	assert.Error(t, err)
+}
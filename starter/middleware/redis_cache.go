@@ -3,14 +3,15 @@ package middleware
 import (
 	"context"
 	"crypto/md5"
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,11 +19,21 @@ import (
 )
 
 type CacheInterface interface {
+	// Get returns the item until its stale windows end; check Fresh before
+	// serving it as is
 	Get(key string) (*CacheItem, bool)
 	Set(key string, data []byte, headers map[string]string, ttl time.Duration) error
+	// SetItem stores an item, keeping it through its stale windows, and
+	// records it under each tag
+	SetItem(key string, item *CacheItem, tags []string) error
 	Delete(key string) error
 	Clear() error
 	InvalidatePattern(pattern string) error
+	// InvalidateTags deletes every entry recorded under any of the tags
+	InvalidateTags(tags ...string) error
+	// TryLock takes the lock guarding a key's refresh, returning false while
+	// someone else holds it. The lock lapses after ttl if never released.
+	TryLock(key string, ttl time.Duration) (unlock func(), ok bool)
 	GetStats() CacheStats
 	ResetStats() error
 }
@@ -39,7 +50,9 @@ type CacheStats struct {
 type RedisCache struct {
 	client *redis.Client
 	prefix string
-	stats  CacheStats
+	// statsMutex guards stats, which concurrent requests update
+	statsMutex sync.Mutex
+	stats      CacheStats
 }
 
 func NewRedisCache() (*RedisCache, error) {
@@ -85,13 +98,17 @@ func NewRedisCache() (*RedisCache, error) {
 
 	log.Printf("Connected to Redis at %s:%s (DB: %d)", redisHost, redisPort, redisDB)
 
+	return newRedisCacheWithClient(rdb, prefix), nil
+}
+
+func newRedisCacheWithClient(client *redis.Client, prefix string) *RedisCache {
 	return &RedisCache{
-		client: rdb,
+		client: client,
 		prefix: prefix,
 		stats: CacheStats{
 			CacheType: "redis",
 		},
-	}, nil
+	}
 }
 
 func (r *RedisCache) Get(key string) (*CacheItem, bool) {
@@ -101,39 +118,87 @@ func (r *RedisCache) Get(key string) (*CacheItem, bool) {
 	data, err := r.client.Get(ctx, fullKey).Result()
 	if err != nil {
 		if err == redis.Nil {
-			r.stats.Misses++
+			r.countLookup(false)
 			return nil, false
 		}
 		log.Printf("Redis GET error: %v", err)
-		r.stats.Misses++
+		r.countLookup(false)
 		return nil, false
 	}
 
 	var item CacheItem
 	if err := json.Unmarshal([]byte(data), &item); err != nil {
 		log.Printf("Failed to unmarshal cache item: %v", err)
-		r.stats.Misses++
+		r.countLookup(false)
 		return nil, false
 	}
 
-	if time.Now().After(item.ExpiresAt) {
+	if time.Now().After(item.storeUntil()) {
 		r.Delete(key)
-		r.stats.Misses++
+		r.countLookup(false)
 		return nil, false
 	}
 
-	r.stats.Hits++
+	r.countLookup(true)
 	return &item, true
 }
 
+func (r *RedisCache) countLookup(hit bool) {
+	r.statsMutex.Lock()
+	defer r.statsMutex.Unlock()
+
+	if hit {
+		r.stats.Hits++
+	} else {
+		r.stats.Misses++
+	}
+}
+
 func (r *RedisCache) Set(key string, data []byte, headers map[string]string, ttl time.Duration) error {
+	return r.SetItem(key, newCacheItem(data, headers, ttl), nil)
+}
+
+// tagKey is the set holding the keys of the entries carrying a tag
+func (r *RedisCache) tagKey(tag string) string {
+	return r.prefix + "cachetag:" + tag
+}
+
+// keysKey is the sorted set tracking every entry's key, scored by its expiry
+// in Unix milliseconds, so KeyCount needs no scan
+func (r *RedisCache) keysKey() string {
+	return r.prefix + "cachekeys"
+}
+
+// lockKey guards the refresh of an entry across replicas
+func (r *RedisCache) lockKey(key string) string {
+	return r.prefix + "cachelock:" + key
+}
+
+// isEntry reports whether a key found by SCAN holds a cached response rather
+// than tracking data or a lock
+func (r *RedisCache) isEntry(fullKey string) bool {
+	return fullKey != r.keysKey() &&
+		!strings.HasPrefix(fullKey, r.prefix+"cachetag:") &&
+		!strings.HasPrefix(fullKey, r.prefix+"cachelock:")
+}
+
+// Batch sizes for scans and deletions; each round trip stays small enough not
+// to stall the server
+const (
+	scanCount   = 1000
+	unlinkBatch = 500
+)
+
+// SetItem stores the entry until its stale windows end, tracks its key and
+// adds it to the set of each tag in one transaction. Every write gives the
+// tag sets the entry's TTL; responses all share the middleware settings, so
+// a set outlives the entries it lists.
+func (r *RedisCache) SetItem(key string, item *CacheItem, tags []string) error {
 	ctx := context.Background()
 	fullKey := r.prefix + key
-
-	item := CacheItem{
-		Data:      data,
-		Headers:   headers,
-		ExpiresAt: time.Now().Add(ttl),
+	ttl := time.Until(item.storeUntil())
+	if ttl <= 0 {
+		return nil
 	}
 
 	jsonData, err := json.Marshal(item)
@@ -141,7 +206,16 @@ func (r *RedisCache) Set(key string, data []byte, headers map[string]string, ttl
 		return fmt.Errorf("failed to marshal cache item: %v", err)
 	}
 
-	if err := r.client.Set(ctx, fullKey, jsonData, ttl).Err(); err != nil {
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, fullKey, jsonData, ttl)
+		pipe.ZAdd(ctx, r.keysKey(), redis.Z{Score: float64(item.storeUntil().UnixMilli()), Member: fullKey})
+		for _, tag := range tags {
+			pipe.SAdd(ctx, r.tagKey(tag), fullKey)
+			pipe.Expire(ctx, r.tagKey(tag), ttl)
+		}
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to set cache item: %v", err)
 	}
 
@@ -152,61 +226,177 @@ func (r *RedisCache) Delete(key string) error {
 	ctx := context.Background()
 	fullKey := r.prefix + key
 
-	if err := r.client.Del(ctx, fullKey).Err(); err != nil {
+	if err := r.unlink(ctx, []string{fullKey}); err != nil {
 		return fmt.Errorf("failed to delete cache item: %v", err)
 	}
 
 	return nil
 }
 
-func (r *RedisCache) Clear() error {
-	ctx := context.Background()
-	pattern := r.prefix + "*"
-
-	keys, err := r.client.Keys(ctx, pattern).Result()
-	if err != nil {
-		return fmt.Errorf("failed to get keys: %v", err)
+// unlink removes keys in pipelined batches of UNLINK, which frees memory in
+// the background, and stops tracking them
+func (r *RedisCache) unlink(ctx context.Context, keys []string) error {
+	for start := 0; start < len(keys); start += unlinkBatch {
+		end := start + unlinkBatch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+		members := make([]interface{}, len(batch))
+		for i, key := range batch {
+			members[i] = key
+		}
+		if _, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Unlink(ctx, batch...)
+			pipe.ZRem(ctx, r.keysKey(), members...)
+			return nil
+		}); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	if len(keys) == 0 {
-		return nil
+// unlinkMatching walks the keyspace with SCAN instead of KEYS, so the server
+// keeps answering other clients, and unlinks the matching entries batch by
+// batch. It returns how many entries it removed.
+func (r *RedisCache) unlinkMatching(ctx context.Context, match string, entriesOnly bool) (int, error) {
+	removed := 0
+	iter := r.client.Scan(ctx, 0, match, scanCount).Iterator()
+	batch := make([]string, 0, unlinkBatch)
+	for iter.Next(ctx) {
+		if entriesOnly && !r.isEntry(iter.Val()) {
+			continue
+		}
+		batch = append(batch, iter.Val())
+		if len(batch) == unlinkBatch {
+			if err := r.unlink(ctx, batch); err != nil {
+				return removed, err
+			}
+			removed += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return removed, err
+	}
+	if err := r.unlink(ctx, batch); err != nil {
+		return removed, err
 	}
+	return removed + len(batch), nil
+}
 
-	if err := r.client.Del(ctx, keys...).Err(); err != nil {
-		return fmt.Errorf("failed to delete keys: %v", err)
+// Clear removes every key under the prefix, tag sets and the key tracking
+// included
+func (r *RedisCache) Clear() error {
+	removed, err := r.unlinkMatching(context.Background(), r.prefix+"*", false)
+	if err != nil {
+		return fmt.Errorf("failed to clear cache: %v", err)
 	}
 
-	log.Printf("Cleared %d cache items", len(keys))
+	log.Printf("Cleared %d cache items", removed)
 	return nil
 }
 
 func (r *RedisCache) InvalidatePattern(pattern string) error {
+	removed, err := r.unlinkMatching(context.Background(), r.prefix+"*"+pattern+"*", true)
+	if err != nil {
+		return fmt.Errorf("failed to delete keys for pattern %s: %v", pattern, err)
+	}
+	if removed > 0 {
+		log.Printf("Invalidated %d cache items matching pattern: %s", removed, pattern)
+	}
+	return nil
+}
+
+// InvalidateTags reads and deletes the tag sets in one transaction, so an
+// entry stored meanwhile lands in a fresh set instead of being lost, then
+// deletes the entries they listed. The cost grows with the number of tags and
+// matching entries, not with the size of the cache.
+func (r *RedisCache) InvalidateTags(tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
 	ctx := context.Background()
-	searchPattern := r.prefix + "*" + pattern + "*"
 
-	keys, err := r.client.Keys(ctx, searchPattern).Result()
+	members := make([]*redis.StringSliceCmd, len(tags))
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, tag := range tags {
+			members[i] = pipe.SMembers(ctx, r.tagKey(tag))
+			pipe.Del(ctx, r.tagKey(tag))
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get keys for pattern %s: %v", pattern, err)
+		return fmt.Errorf("failed to read cache tags %v: %v", tags, err)
 	}
 
+	var keys []string
+	for _, cmd := range members {
+		keys = append(keys, cmd.Val()...)
+	}
 	if len(keys) == 0 {
 		return nil
 	}
 
-	if err := r.client.Del(ctx, keys...).Err(); err != nil {
-		return fmt.Errorf("failed to delete keys for pattern %s: %v", pattern, err)
+	if err := r.unlink(ctx, keys); err != nil {
+		return fmt.Errorf("failed to delete keys for cache tags %v: %v", tags, err)
 	}
 
-	log.Printf("Invalidated %d cache items matching pattern: %s", len(keys), pattern)
+	log.Printf("Invalidated %d cache items tagged %v", len(keys), tags)
 	return nil
 }
 
+// unlockScript deletes a lock only while it still holds the caller's token,
+// so a refresh that outlived its lock cannot release the next holder's
+var unlockScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// TryLock takes the lock with SET NX so that one replica at a time refreshes
+// a key. When Redis fails the lock is granted: a request is better served
+// twice than not at all.
+func (r *RedisCache) TryLock(key string, ttl time.Duration) (func(), bool) {
+	ctx := context.Background()
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return func() {}, true
+	}
+	value := hex.EncodeToString(token)
+
+	ok, err := r.client.SetNX(ctx, r.lockKey(key), value, ttl).Result()
+	if err != nil {
+		log.Printf("Redis lock error: %v", err)
+		return func() {}, true
+	}
+	if !ok {
+		return nil, false
+	}
+	return func() {
+		if err := unlockScript.Run(ctx, r.client, []string{r.lockKey(key)}, value).Err(); err != nil {
+			log.Printf("Redis unlock error: %v", err)
+		}
+	}, true
+}
+
+// GetStats counts entries from the tracking set after dropping the ones past
+// their expiry, in O(log N) instead of listing every key. Entries evicted
+// under memory pressure are still counted until they would have expired.
 func (r *RedisCache) GetStats() CacheStats {
 	ctx := context.Background()
-	pattern := r.prefix + "*"
-	keys, err := r.client.Keys(ctx, pattern).Result()
+	var count *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, r.keysKey(), "-inf", strconv.FormatInt(time.Now().UnixMilli(), 10))
+		count = pipe.ZCard(ctx, r.keysKey())
+		return nil
+	})
+
+	r.statsMutex.Lock()
+	defer r.statsMutex.Unlock()
+
 	if err == nil {
-		r.stats.KeyCount = int64(len(keys))
+		r.stats.KeyCount = count.Val()
 	}
 
 	total := r.stats.Hits + r.stats.Misses
@@ -218,6 +408,9 @@ func (r *RedisCache) GetStats() CacheStats {
 }
 
 func (r *RedisCache) ResetStats() error {
+	r.statsMutex.Lock()
+	defer r.statsMutex.Unlock()
+
 	r.stats.Hits = 0
 	r.stats.Misses = 0
 	r.stats.HitRatio = 0.0
@@ -277,6 +470,17 @@ func (cm *CacheManager) Set(key string, data []byte, headers map[string]string,
 	return nil
 }
 
+func (cm *CacheManager) SetItem(key string, item *CacheItem, tags []string) error {
+	if err := cm.primary.SetItem(key, item, tags); err != nil {
+		if !cm.useFallback {
+			log.Printf("Primary cache set failed, using fallback: %v", err)
+			return cm.fallback.SetItem(key, item, tags)
+		}
+		return err
+	}
+	return nil
+}
+
 func (cm *CacheManager) Delete(key string) error {
 	err1 := cm.primary.Delete(key)
 	if !cm.useFallback {
@@ -313,6 +517,22 @@ func (cm *CacheManager) InvalidatePattern(pattern string) error {
 	return err1
 }
 
+func (cm *CacheManager) InvalidateTags(tags ...string) error {
+	err1 := cm.primary.InvalidateTags(tags...)
+	if !cm.useFallback {
+		err2 := cm.fallback.InvalidateTags(tags...)
+		if err1 != nil {
+			return err1
+		}
+		return err2
+	}
+	return err1
+}
+
+func (cm *CacheManager) TryLock(key string, ttl time.Duration) (func(), bool) {
+	return cm.primary.TryLock(key, ttl)
+}
+
 func (cm *CacheManager) GetStats() CacheStats {
 	return cm.primary.GetStats()
 }
@@ -327,19 +547,33 @@ func InitializeCache() {
 	cacheManager = NewCacheManager()
 }
 
-func generateRedisCacheKey(c *gin.Context) string {
+// cacheResource names the resource a request reads, or "" for other routes.
+// It prefixes the cache key and tags the response, so InvalidatePostCache and
+// friends can drop all of a resource's responses.
+func cacheResource(c *gin.Context) string {
 	url := c.Request.URL.String()
-	method := c.Request.Method
-	userAgent := c.GetHeader("User-Agent")
-
-	resource := ""
-	if strings.Contains(url, "/posts") {
-		resource = "posts"
+	if strings.Contains(c.Request.URL.Path, "/search") {
+		// Search hits are built from posts, pages and media alike
+		return "search"
+	} else if strings.Contains(url, "/posts") {
+		return "posts"
 	} else if strings.Contains(url, "/pages") {
-		resource = "pages"
+		return "pages"
 	} else if strings.Contains(url, "/media") {
-		resource = "media"
+		return "media"
+	} else if strings.Contains(url, "/categories") {
+		return "categories"
+	} else if strings.Contains(url, "/tags") {
+		return "tags"
 	}
+	return ""
+}
+
+func generateRedisCacheKey(c *gin.Context) string {
+	url := c.Request.URL.String()
+	method := c.Request.Method
+	userAgent := c.GetHeader("User-Agent")
+	resource := cacheResource(c)
 
 	key := fmt.Sprintf("%s:%s:%s", method, url, userAgent)
 	hash := md5.Sum([]byte(key))
@@ -352,10 +586,23 @@ func generateRedisCacheKey(c *gin.Context) string {
 }
 
 func RedisCacheMiddleware(ttl time.Duration) gin.HandlerFunc {
+	return RedisCacheMiddlewareWithOptions(ttl, CacheOptions{})
+}
+
+// RedisCacheMiddlewareWithOptions caches public GET responses for ttl and
+// guards their refresh against stampedes. Concurrent misses on a key within
+// the process share one handler run, and a Redis lock lets a single replica
+// refresh the key while the others wait for its result. Expired entries are
+// served as STALE while the refresh runs, or when it fails, within the
+// windows set in opts.
+func RedisCacheMiddlewareWithOptions(ttl time.Duration, opts CacheOptions) gin.HandlerFunc {
 
 	if cacheManager == nil {
 		InitializeCache()
 	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = 5 * time.Second
+	}
 
 	return func(c *gin.Context) {
 		if c.Request.Method != "GET" {
@@ -364,48 +611,68 @@ func RedisCacheMiddleware(ttl time.Duration) gin.HandlerFunc {
 		}
 
 		if strings.Contains(c.Request.URL.Path, "/admin") ||
-			strings.Contains(c.Request.URL.Path, "/auth") {
+			strings.Contains(c.Request.URL.Path, "/auth") ||
+			isFileDownload(c.Request) {
 			c.Next()
 			return
 		}
 
-		cacheKey := generateRedisCacheKey(c)
+		// Authenticated callers may see unpublished content, so their responses
+		// must neither be served from nor written to the shared cache
+		if c.GetHeader("Authorization") != "" {
+			serveUncached(c)
+			return
+		}
 
-		if cachedItem, exists := cacheManager.Get(cacheKey); exists {
+		cacheKey := generateRedisCacheKey(c)
 
-			for key, value := range cachedItem.Headers {
-				c.Header(key, value)
-			}
-			c.Header("X-Cache", "HIT")
-			c.Header("X-Cache-Key", cacheKey[:8]) // First 8 chars for debugging
-			c.Data(http.StatusOK, cachedItem.Headers["Content-Type"], cachedItem.Data)
-			c.Abort()
+		cachedItem, exists := cacheManager.Get(cacheKey)
+		if exists && cachedItem.Fresh(time.Now()) {
+			serveCached(c, cacheKey, "HIT", cachedItem)
 			return
 		}
 
-		writer := &responseWriter{
-			ResponseWriter: c.Writer,
-			body:           make([]byte, 0),
-			headers:        make(map[string]string),
+		f, leader := cacheFlights.join(cacheKey)
+		if !leader {
+			if withinStaleWindow(cachedItem, time.Now()) {
+				serveCached(c, cacheKey, "STALE", cachedItem)
+				return
+			}
+			select {
+			case <-f.done:
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+			if f.item != nil {
+				serveCached(c, cacheKey, "HIT", f.item)
+				return
+			}
+			// The shared refresh failed; try again for this request
+			refreshCache(c, cacheKey, ttl, opts, cachedItem)
+			return
 		}
-		c.Writer = writer
 
-		c.Next()
+		var refreshed *CacheItem
+		defer func() { cacheFlights.finish(cacheKey, f, refreshed) }()
 
-		if c.Writer.Status() >= 200 && c.Writer.Status() < 300 && len(writer.body) > 0 {
-			for key, values := range c.Writer.Header() {
-				if len(values) > 0 {
-					writer.headers[key] = values[0]
-				}
+		unlock, locked := cacheManager.TryLock(cacheKey, opts.LockTimeout)
+		if !locked {
+			// Another replica is refreshing the key
+			if withinStaleWindow(cachedItem, time.Now()) {
+				serveCached(c, cacheKey, "STALE", cachedItem)
+				return
 			}
-
-			if err := cacheManager.Set(cacheKey, writer.body, writer.headers, ttl); err != nil {
-				log.Printf("Failed to cache response: %v", err)
+			if refreshed = awaitRefresh(c, cacheKey, opts.LockTimeout); refreshed != nil {
+				serveCached(c, cacheKey, "HIT", refreshed)
+				return
 			}
-
-			c.Header("X-Cache", "MISS")
-			c.Header("X-Cache-Key", cacheKey[:8])
+			// The holder is slow or gone, so render the response here
+		} else {
+			defer unlock()
 		}
+
+		refreshed = refreshCache(c, cacheKey, ttl, opts, cachedItem)
 	}
 }
 
@@ -416,6 +683,11 @@ func GetCacheStats() CacheStats {
 	return cacheManager.GetStats()
 }
 
+// CacheEnabled reports whether InitializeCache has set up the response cache
+func CacheEnabled() bool {
+	return cacheManager != nil
+}
+
 func InvalidateCache(pattern string) error {
 	if cacheManager == nil {
 		return fmt.Errorf("cache manager not initialized")
@@ -431,13 +703,29 @@ func ClearAllCache() error {
 }
 
 func InvalidateMediaCache() error {
-	return InvalidateCache("media")
+	return InvalidateCacheTags("media")
 }
 
 func InvalidatePostCache() error {
-	return InvalidateCache("posts")
+	if err := InvalidateCacheTags("posts"); err != nil {
+		return err
+	}
+	// Tag counts only include published posts
+	return InvalidateTagCache()
 }
 
 func InvalidatePageCache() error {
-	return InvalidateCache("pages")
+	return InvalidateCacheTags("pages")
+}
+
+func InvalidateCategoryCache() error {
+	return InvalidateCacheTags("categories")
+}
+
+func InvalidateTagCache() error {
+	return InvalidateCacheTags("tags")
+}
+
+func InvalidateSearchCache() error {
+	return InvalidateCacheTags("search")
 }
@@ -2,14 +2,18 @@ package middleware
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -124,6 +128,20 @@ func (suite *RedisCacheTestSuite) TestPOSTRequestsNotCached() {
 	assert.Empty(suite.T(), w.Header().Get("X-Cache"), "POST requests should not be cached")
 }
 
+func (suite *RedisCacheTestSuite) TestAuthenticatedRequestsNotCached() {
+	cacheManager.Clear()
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		suite.router.ServeHTTP(w, req)
+
+		assert.Equal(suite.T(), http.StatusOK, w.Code)
+		assert.Empty(suite.T(), w.Header().Get("X-Cache"), "Authenticated requests should bypass the cache")
+	}
+}
+
 func (suite *RedisCacheTestSuite) TestCacheInvalidation() {
 	testKey := "test_key"
 	testData := []byte("test data")
@@ -232,6 +250,94 @@ func TestRedisCacheTestSuite(t *testing.T) {
 	suite.Run(t, new(RedisCacheTestSuite))
 }
 
+// newTestRedisCache runs a RedisCache against an in-process miniredis server
+func newTestRedisCache(tb testing.TB) (*RedisCache, *miniredis.Miniredis) {
+	server := miniredis.RunT(tb)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	tb.Cleanup(func() { client.Close() })
+	return newRedisCacheWithClient(client, "test_cache:"), server
+}
+
+// fillRedisCache writes n tracked entries straight into the server, one in a
+// hundred under "posts" and the rest under "pages"
+func fillRedisCache(tb testing.TB, cache *RedisCache, server *miniredis.Miniredis, n int) {
+	expires := float64(time.Now().Add(time.Hour).UnixMilli())
+	for i := 0; i < n; i++ {
+		resource := "pages"
+		if i%100 == 0 {
+			resource = "posts"
+		}
+		key := fmt.Sprintf("%s%s:%08d", cache.prefix, resource, i)
+		if err := server.Set(key, "{}"); err != nil {
+			tb.Fatal(err)
+		}
+		if _, err := server.ZAdd(cache.keysKey(), expires, key); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestRedisCacheScans(t *testing.T) {
+	cache, server := newTestRedisCache(t)
+	fillRedisCache(t, cache, server, 2500)
+	require.NoError(t, cache.SetItem("posts:tagged", newCacheItem([]byte("{}"), nil, time.Minute), []string{"post:1"}))
+	require.NoError(t, server.Set("other_app:posts", "kept"))
+
+	assert.EqualValues(t, 2501, cache.GetStats().KeyCount)
+
+	require.NoError(t, cache.InvalidatePattern("posts"))
+	assert.EqualValues(t, 2475, cache.GetStats().KeyCount, "25 filled posts and the tagged one are gone")
+	assert.True(t, server.Exists("test_cache:pages:00000001"))
+	assert.True(t, server.Exists("test_cache:cachetag:post:1"), "tracking keys are not entries")
+
+	require.NoError(t, cache.Delete("pages:00000001"))
+	assert.EqualValues(t, 2474, cache.GetStats().KeyCount)
+
+	// Expired entries drop out of the count without a scan
+	require.NoError(t, cache.Set("short", []byte("{}"), nil, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	assert.EqualValues(t, 2474, cache.GetStats().KeyCount)
+
+	require.NoError(t, cache.Clear())
+	assert.Equal(t, []string{"other_app:posts"}, server.Keys(), "keys outside the prefix survive")
+	assert.Zero(t, cache.GetStats().KeyCount)
+}
+
+func BenchmarkRedisCacheClear100k(b *testing.B) {
+	cache, server := newTestRedisCache(b)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		fillRedisCache(b, cache, server, 100000)
+		b.StartTimer()
+		if err := cache.Clear(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRedisCacheInvalidatePattern100k(b *testing.B) {
+	cache, server := newTestRedisCache(b)
+	fillRedisCache(b, cache, server, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Only the first run finds the 1,000 posts; the rest measure the scan
+		if err := cache.InvalidatePattern("posts"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRedisCacheKeyCount100k(b *testing.B) {
+	cache, server := newTestRedisCache(b)
+	fillRedisCache(b, cache, server, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if count := cache.GetStats().KeyCount; count != 100000 {
+			b.Fatalf("counted %d keys", count)
+		}
+	}
+}
+
 func BenchmarkCacheSet(b *testing.B) {
 	InitializeCache()
 
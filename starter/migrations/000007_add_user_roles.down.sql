@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_owner_id;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS owner_id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
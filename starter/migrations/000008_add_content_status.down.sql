@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_pages_status;
+DROP INDEX IF EXISTS idx_posts_status;
+
+ALTER TABLE pages DROP COLUMN IF EXISTS published_at;
+ALTER TABLE pages DROP COLUMN IF EXISTS status;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS published_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS status;
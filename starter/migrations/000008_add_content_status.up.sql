@@ -0,0 +1,12 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'draft';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS published_at TIMESTAMP;
+
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'draft';
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS published_at TIMESTAMP;
+
+-- Everything that existed before the workflow was already public
+UPDATE posts SET status = 'published', published_at = created_at;
+UPDATE pages SET status = 'published', published_at = created_at;
+
+CREATE INDEX IF NOT EXISTS idx_posts_status ON posts(status);
+CREATE INDEX IF NOT EXISTS idx_pages_status ON pages(status);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_pages_unpublish_at;
+DROP INDEX IF EXISTS idx_pages_publish_at;
+DROP INDEX IF EXISTS idx_posts_unpublish_at;
+DROP INDEX IF EXISTS idx_posts_publish_at;
+
+ALTER TABLE pages DROP COLUMN IF EXISTS unpublish_at;
+ALTER TABLE pages DROP COLUMN IF EXISTS publish_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS unpublish_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS publish_at;
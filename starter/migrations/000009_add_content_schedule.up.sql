@@ -0,0 +1,11 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMP;
+
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMP;
+
+-- The scheduler only ever looks at rows with a pending schedule
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts(publish_at) WHERE publish_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_posts_unpublish_at ON posts(unpublish_at) WHERE unpublish_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_pages_publish_at ON pages(publish_at) WHERE publish_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_pages_unpublish_at ON pages(unpublish_at) WHERE unpublish_at IS NOT NULL;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS revisions (
+    id SERIAL PRIMARY KEY,
+    resource_type VARCHAR(20) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    author VARCHAR(100),
+    media_ids JSONB,
+    editor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    editor_name VARCHAR(100),
+    restored_from INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_revisions_resource_version ON revisions(resource_type, resource_id, version);
+
+-- Seed version 1 from the current state so existing content has a starting point
+INSERT INTO revisions (resource_type, resource_id, version, title, content, author, media_ids, created_at)
+SELECT 'post', p.id, 1, p.title, p.content, p.author,
+       COALESCE((SELECT jsonb_agg(pm.media_id ORDER BY pm.media_id) FROM post_media pm WHERE pm.post_id = p.id), '[]'::jsonb),
+       p.updated_at
+FROM posts p;
+
+INSERT INTO revisions (resource_type, resource_id, version, title, content, created_at)
+SELECT 'page', id, 1, title, content, updated_at
+FROM pages;
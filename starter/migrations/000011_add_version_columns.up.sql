@@ -0,0 +1,4 @@
+-- Row versions back the ETag / If-Match optimistic concurrency checks
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,28 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug VARCHAR(255);
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS slug VARCHAR(255);
+
+-- Backfill from the title; duplicates and titles without any usable characters
+-- fall back to the row ID so the unique index can be built
+UPDATE posts SET slug = NULLIF(trim(both '-' from left(regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g'), 100)), '')
+WHERE slug IS NULL;
+UPDATE posts p SET slug = COALESCE(p.slug || '-', 'post-') || p.id
+WHERE p.slug IS NULL OR EXISTS (SELECT 1 FROM posts o WHERE o.slug = p.slug AND o.id < p.id);
+
+UPDATE pages SET slug = NULLIF(trim(both '-' from left(regexp_replace(lower(title), '[^a-z0-9]+', '-', 'g'), 100)), '')
+WHERE slug IS NULL;
+UPDATE pages p SET slug = COALESCE(p.slug || '-', 'page-') || p.id
+WHERE p.slug IS NULL OR EXISTS (SELECT 1 FROM pages o WHERE o.slug = p.slug AND o.id < p.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_slug ON posts(slug);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pages_slug ON pages(slug);
+
+CREATE TABLE IF NOT EXISTS slug_redirects (
+    id SERIAL PRIMARY KEY,
+    resource_type VARCHAR(20) NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_slug_redirects_resource_slug ON slug_redirects(resource_type, slug);
+CREATE INDEX IF NOT EXISTS idx_slug_redirects_resource_id ON slug_redirects(resource_id);
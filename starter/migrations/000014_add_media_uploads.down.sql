@@ -0,0 +1,6 @@
+ALTER TABLE media DROP COLUMN IF EXISTS storage_key;
+ALTER TABLE media DROP COLUMN IF EXISTS storage_driver;
+ALTER TABLE media DROP COLUMN IF EXISTS checksum;
+ALTER TABLE media DROP COLUMN IF EXISTS size;
+ALTER TABLE media DROP COLUMN IF EXISTS mime_type;
+ALTER TABLE media DROP COLUMN IF EXISTS original_filename;
@@ -0,0 +1,6 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS original_filename VARCHAR(255);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS mime_type VARCHAR(100);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS checksum VARCHAR(64);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS storage_driver VARCHAR(20);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS storage_key VARCHAR(255);
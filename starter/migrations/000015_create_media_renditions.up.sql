@@ -0,0 +1,19 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS width INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS height INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS media_renditions (
+    id SERIAL PRIMARY KEY,
+    media_id INTEGER NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    name VARCHAR(20),
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    mime_type VARCHAR(100),
+    size BIGINT NOT NULL DEFAULT 0,
+    checksum VARCHAR(64),
+    storage_key VARCHAR(255) NOT NULL,
+    url VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_renditions_variant ON media_renditions(media_id, width, format);
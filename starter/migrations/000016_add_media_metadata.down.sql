@@ -0,0 +1,3 @@
+ALTER TABLE media DROP COLUMN IF EXISTS metadata;
+ALTER TABLE media DROP COLUMN IF EXISTS bitrate;
+ALTER TABLE media DROP COLUMN IF EXISTS duration;
@@ -0,0 +1,3 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS duration DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS bitrate INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS metadata JSONB;
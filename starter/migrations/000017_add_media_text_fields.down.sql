@@ -0,0 +1,3 @@
+ALTER TABLE media DROP COLUMN IF EXISTS caption;
+ALTER TABLE media DROP COLUMN IF EXISTS alt_text;
+ALTER TABLE media DROP COLUMN IF EXISTS title;
@@ -0,0 +1,3 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS title VARCHAR(255);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS alt_text VARCHAR(500);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS caption TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE media DROP COLUMN IF EXISTS license;
+ALTER TABLE media DROP COLUMN IF EXISTS credit;
@@ -0,0 +1,2 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS credit VARCHAR(255);
+ALTER TABLE media ADD COLUMN IF NOT EXISTS license VARCHAR(100);
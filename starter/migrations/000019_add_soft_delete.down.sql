@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_media_deleted_at;
+DROP INDEX IF EXISTS idx_pages_deleted_at;
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE media DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE pages DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,7 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE pages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_pages_deleted_at ON pages(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_media_deleted_at ON media(deleted_at);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id SERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    actor_name VARCHAR(100),
+    ip VARCHAR(45),
+    resource_type VARCHAR(20) NOT NULL,
+    resource_id INTEGER,
+    action VARCHAR(30) NOT NULL,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_entries_actor_id ON audit_entries(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_resource ON audit_entries(resource_type, resource_id);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_created_at ON audit_entries(created_at);
+
+-- The audit log is append-only: entries outlive the users and content they
+-- mention, so actor_id carries no foreign key, and rows cannot be changed
+CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_entries_append_only ON audit_entries;
+CREATE TRIGGER audit_entries_append_only
+    BEFORE UPDATE OR DELETE ON audit_entries
+    FOR EACH ROW EXECUTE FUNCTION audit_entries_append_only();
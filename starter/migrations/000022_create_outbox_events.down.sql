@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_event_key;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS event_key;
+DROP TABLE IF EXISTS outbox_events;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    idempotency_key VARCHAR(64) NOT NULL UNIQUE,
+    type VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(20) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    payload JSONB,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    processed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The relay only ever looks for unprocessed events that are due
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(next_attempt_at, id) WHERE processed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_processed_at ON outbox_events(processed_at);
+
+-- A webhook gets one delivery per event however often the event is relayed;
+-- manual redeliveries are copies and carry the same key
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS event_key VARCHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event_key ON webhook_deliveries(webhook_id, event_key) WHERE redelivery_of IS NULL;
@@ -0,0 +1,4 @@
+-- Full-text index over the media text fields, matching the expression the
+-- search endpoint queries; posts and pages use the indexes from 000005
+CREATE INDEX IF NOT EXISTS idx_media_search_text ON media USING gin(to_tsvector('english',
+    COALESCE(title, '') || ' ' || COALESCE(alt_text, '') || ' ' || COALESCE(caption, '') || ' ' || COALESCE(original_filename, '')));
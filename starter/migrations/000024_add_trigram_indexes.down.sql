@@ -0,0 +1,5 @@
+-- The pg_trgm extension is left installed; other objects may depend on it
+DROP INDEX IF EXISTS idx_posts_title_trgm;
+DROP INDEX IF EXISTS idx_pages_title_trgm;
+DROP INDEX IF EXISTS idx_media_original_filename_trgm;
+DROP INDEX IF EXISTS idx_tags_name_trgm;
@@ -0,0 +1,8 @@
+-- Trigram indexes for search-as-you-type suggestions, alongside the full-text
+-- indexes from 000005_add_performance_indexes
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_posts_title_trgm ON posts USING gin(title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_pages_title_trgm ON pages USING gin(title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_media_original_filename_trgm ON media USING gin(original_filename gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_tags_name_trgm ON tags USING gin(name gin_trgm_ops);
@@ -0,0 +1,2 @@
+-- Tokens issued before a password change are rejected
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Resource types recorded in the audit log besides posts and pages
+const (
+	AuditResourceMedia   = "media"
+	AuditResourceCache   = "cache"
+	AuditResourceUser    = "user"
+	AuditResourceWebhook = "webhook"
+)
+
+// Audit actions besides the workflow actions, which are recorded under their own names
+const (
+	AuditActionCreate          = "create"
+	AuditActionUpdate          = "update"
+	AuditActionDelete          = "delete"
+	AuditActionRestore         = "restore"
+	AuditActionPurge           = "purge"
+	AuditActionSchedule        = "schedule"
+	AuditActionRestoreRevision = "restore_revision"
+	AuditActionReplaceFile     = "replace_file"
+	AuditActionClear           = "clear"
+	AuditActionInvalidate      = "invalidate"
+	AuditActionWarmup          = "warmup"
+	AuditActionRotateSecret    = "rotate_secret"
+)
+
+// AuditEntry records one write made through the API: who made it, from where,
+// and the resource as JSON before and after. Entries are append-only; the
+// table refuses updates and deletes.
+type AuditEntry struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	ActorID      *uint           `gorm:"index" json:"actor_id,omitempty"`
+	ActorName    string          `gorm:"size:100" json:"actor_name,omitempty"`
+	IP           string          `gorm:"size:45" json:"ip"`
+	ResourceType string          `gorm:"size:20;not null;index:idx_audit_entries_resource,priority:1" json:"resource_type"`
+	ResourceID   *uint           `gorm:"index:idx_audit_entries_resource,priority:2" json:"resource_id,omitempty"`
+	Action       string          `gorm:"size:30;not null" json:"action"`
+	Before       json.RawMessage `gorm:"serializer:json;type:jsonb" json:"before,omitempty"`
+	After        json.RawMessage `gorm:"serializer:json;type:jsonb" json:"after,omitempty"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime;index" json:"created_at"`
+}
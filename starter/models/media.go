@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // This struct includes fields for:
 // - ID (unsigned integer, primary key)
@@ -18,10 +22,62 @@ type Media struct {
 	
 	//Type field as string with gorm tag for size limit (50) and json tag and binding tag to make it required
     Type      string    `gorm:"size:50" json:"type" binding:"required"`
+
+	//Title, AltText and Caption are editorial text shown alongside the media
+    Title     string    `gorm:"size:255" json:"title,omitempty" validate:"max=255"`
+    AltText   string    `gorm:"size:500" json:"alt_text,omitempty" validate:"max=500"`
+    Caption   string    `gorm:"type:text" json:"caption,omitempty"`
+
+	//Credit and License record who made the media and the terms it may be used under
+    Credit    string    `gorm:"size:255" json:"credit,omitempty" validate:"max=255"`
+    License   string    `gorm:"size:100" json:"license,omitempty" validate:"max=100"`
+
+	//OriginalFilename, MimeType, Size and Checksum describe an uploaded file; MimeType is sniffed from the content
+    OriginalFilename string `gorm:"size:255" json:"original_filename,omitempty"`
+    MimeType  string    `gorm:"size:100" json:"mime_type,omitempty"`
+    Size      int64     `json:"size,omitempty"`
+    Checksum  string    `gorm:"size:64" json:"checksum,omitempty"`
+
+	//Width and Height are the displayed pixel dimensions of an uploaded image or video, 0 for anything else
+    Width     int       `gorm:"not null;default:0" json:"width,omitempty"`
+    Height    int       `gorm:"not null;default:0" json:"height,omitempty"`
+
+	//Duration in seconds and Bitrate in bits per second of uploaded audio and video, 0 when unknown
+    Duration  float64   `gorm:"not null;default:0" json:"duration,omitempty"`
+    Bitrate   int       `gorm:"not null;default:0" json:"bitrate,omitempty"`
+
+	//Metadata holds the EXIF fields read from an uploaded photo
+    Metadata  *MediaMetadata `gorm:"serializer:json;type:jsonb" json:"metadata,omitempty"`
+
+	//StorageDriver and StorageKey locate an uploaded file; both are empty for media created from an external URL
+    StorageDriver string `gorm:"size:20" json:"storage_driver,omitempty"`
+    StorageKey    string `gorm:"size:255" json:"-"`
+
+	//Renditions are the resized and converted copies of an uploaded image
+    Renditions []MediaRendition `gorm:"foreignKey:MediaID;constraint:OnDelete:CASCADE" json:"renditions,omitempty"`
+
+	//Version field as int, bumped on every change and used to build the ETag
+    Version   int       `gorm:"not null;default:1" json:"version"`
 	
 	//CreatedAt field as time.Time with gorm tag for automatic timestamp on creation and json tag
     CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	
 	//UpdatedAt field as time.Time with gorm tag for automatic timestamp on updates and json tag
     UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-}
\ No newline at end of file
+
+	//DeletedAt is set while the media is in the trash; its file is kept until it is purged
+    DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// StorageKeys lists the stored objects of an uploaded file: the original and
+// each rendition. It is empty for media created from an external URL.
+func (m *Media) StorageKeys() []string {
+	if m.StorageKey == "" {
+		return nil
+	}
+	keys := []string{m.StorageKey}
+	for _, rendition := range m.Renditions {
+		keys = append(keys, rendition.StorageKey)
+	}
+	return keys
+}
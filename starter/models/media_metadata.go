@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// MediaMetadata is the EXIF information of an uploaded photo, stored as JSONB
+// on the media row
+type MediaMetadata struct {
+	CameraMake   string     `json:"camera_make,omitempty"`
+	CameraModel  string     `json:"camera_model,omitempty"`
+	LensModel    string     `json:"lens_model,omitempty"`
+	TakenAt      *time.Time `json:"taken_at,omitempty"`
+	Orientation  int        `json:"orientation,omitempty"`
+	ExposureTime string     `json:"exposure_time,omitempty"`
+	FNumber      float64    `json:"f_number,omitempty"`
+	ISO          int        `json:"iso,omitempty"`
+	FocalLength  float64    `json:"focal_length,omitempty"`
+	GPS          *GPSPoint  `json:"gps,omitempty"`
+}
+
+// GPSPoint is where a photo was taken, in decimal degrees and meters
+type GPSPoint struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
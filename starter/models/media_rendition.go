@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// MediaRendition is a resized or re-encoded copy of an uploaded image, stored
+// next to the original. Each media has at most one rendition per width and format.
+type MediaRendition struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	MediaID    uint      `gorm:"not null;uniqueIndex:idx_media_renditions_variant" json:"media_id"`
+	Name       string    `gorm:"size:20" json:"name,omitempty"`
+	Width      int       `gorm:"not null;uniqueIndex:idx_media_renditions_variant" json:"width"`
+	Height     int       `gorm:"not null" json:"height"`
+	Format     string    `gorm:"size:10;not null;uniqueIndex:idx_media_renditions_variant" json:"format"`
+	MimeType   string    `gorm:"size:100" json:"mime_type"`
+	Size       int64     `gorm:"not null;default:0" json:"size"`
+	Checksum   string    `gorm:"size:64" json:"-"`
+	StorageKey string    `gorm:"size:255;not null" json:"-"`
+	URL        string    `gorm:"size:255;not null" json:"url"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
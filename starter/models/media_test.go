@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -86,4 +87,35 @@ func TestMediaModel(t *testing.T) {
 			assert.True(t, len(media.URL) > 0)
 		}
 	})
+
+	t.Run("RenditionJSON", func(t *testing.T) {
+		media := Media{
+			ID:         1,
+			StorageKey: "2024/05/abc.png",
+			Renditions: []MediaRendition{{MediaID: 1, Width: 150, Height: 75, Format: "png", StorageKey: "2024/05/abc-w150.png", URL: "/api/v1/media/1/render?w=150&fmt=png"}},
+		}
+
+		data, err := json.Marshal(media)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"renditions":[{`)
+		assert.Contains(t, string(data), `"url":"/api/v1/media/1/render?w=150\u0026fmt=png"`)
+		assert.NotContains(t, string(data), "2024/05/abc")
+	})
+
+	t.Run("MetadataJSON", func(t *testing.T) {
+		data, err := json.Marshal(Media{ID: 1, Duration: 12.5, Metadata: &MediaMetadata{CameraModel: "Pixel 8", GPS: &GPSPoint{Latitude: 48.8584, Longitude: 2.2945}}})
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"duration":12.5`)
+		assert.Contains(t, string(data), `"metadata":{"camera_model":"Pixel 8","gps":{"latitude":48.8584,"longitude":2.2945}}`)
+
+		data, err = json.Marshal(Media{ID: 2})
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "metadata")
+	})
+
+	t.Run("StorageKeys", func(t *testing.T) {
+		media := Media{StorageKey: "2024/05/abc.png", Renditions: []MediaRendition{{StorageKey: "2024/05/abc-w150.png"}}}
+		assert.Equal(t, []string{"2024/05/abc.png", "2024/05/abc-w150.png"}, media.StorageKeys())
+		assert.Empty(t, (&Media{URL: "https://example.com/image.jpg"}).StorageKeys())
+	})
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Outbox event actions; an event type is "<resource>.<action>", e.g. "post.published"
+const (
+	EventCreated       = "created"
+	EventUpdated       = "updated"
+	EventDeleted       = "deleted"
+	EventRestored      = "restored"
+	EventPurged        = "purged"
+	EventPublished     = "published"
+	EventUnpublished   = "unpublished"
+	EventStatusChanged = "status_changed"
+	EventScheduled     = "scheduled"
+)
+
+// EventType builds the type of an event for an action on a resource type
+func EventType(resourceType, action string) string {
+	return resourceType + "." + action
+}
+
+// OutboxEvent is a domain event written in the same transaction as the change
+// it describes. The relay hands it to every handler and marks it processed
+// once they have all succeeded, so a handler may see an event more than once;
+// IdempotencyKey identifies the event across those attempts.
+type OutboxEvent struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	IdempotencyKey string          `gorm:"size:64;not null;uniqueIndex" json:"idempotency_key"`
+	Type           string          `gorm:"size:50;not null" json:"type"`
+	ResourceType   string          `gorm:"size:20;not null" json:"resource_type"`
+	ResourceID     uint            `gorm:"not null" json:"resource_id"`
+	Payload        json.RawMessage `gorm:"serializer:json;type:jsonb" json:"payload"`
+	Attempts       int             `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time       `gorm:"not null" json:"next_attempt_at"`
+	LastError      string          `gorm:"type:text" json:"last_error,omitempty"`
+	ProcessedAt    *time.Time      `gorm:"index" json:"processed_at,omitempty"`
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
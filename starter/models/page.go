@@ -1,11 +1,23 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Page struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Title     string    `gorm:"size:255;not null" json:"title" binding:"required"`
-	Content   string    `gorm:"type:text;not null" json:"content" binding:"required"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title       string     `gorm:"size:255;not null" json:"title" binding:"required"`
+	Slug        string     `gorm:"size:255;uniqueIndex" json:"slug"`
+	Content     string     `gorm:"type:text;not null" json:"content" binding:"required"`
+	Status      string     `gorm:"size:20;not null;default:draft;index" json:"status"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	PublishAt   *time.Time `gorm:"index" json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `gorm:"index" json:"unpublish_at,omitempty"`
+	Version     int        `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	// DeletedAt is set while the page is in the trash
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 }
@@ -72,3 +72,27 @@ func TestPostMediaModel(t *testing.T) {
 		assert.Equal(t, uint(2), postMedia.MediaID)
 	})
 }
+
+func TestPostTaxonomyModels(t *testing.T) {
+	t.Run("PostTermsRelation", func(t *testing.T) {
+		parentID := uint(1)
+		post := Post{
+			ID:         1,
+			Title:      "Categorised Post",
+			Categories: []Category{{ID: 2, Name: "Local", Slug: "local", ParentID: &parentID}},
+			Tags:       []Tag{{ID: 3, Name: "Go", Slug: "go"}},
+		}
+
+		assert.Len(t, post.Categories, 1)
+		assert.Equal(t, uint(1), *post.Categories[0].ParentID)
+		assert.Equal(t, "go", post.Tags[0].Slug)
+	})
+
+	t.Run("JoinModels", func(t *testing.T) {
+		postCategory := PostCategory{PostID: 1, CategoryID: 2}
+		postTag := PostTag{PostID: 1, TagID: 3}
+
+		assert.Equal(t, uint(2), postCategory.CategoryID)
+		assert.Equal(t, uint(3), postTag.TagID)
+	})
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Resource types that keep a revision history
+const (
+	RevisionResourcePost = "post"
+	RevisionResourcePage = "page"
+)
+
+// Revision is an immutable snapshot of a post or page, written each time it is
+// created, updated or restored
+type Revision struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ResourceType string    `gorm:"size:20;not null;uniqueIndex:idx_revisions_resource_version,priority:1" json:"resource_type"`
+	ResourceID   uint      `gorm:"not null;uniqueIndex:idx_revisions_resource_version,priority:2" json:"resource_id"`
+	Version      int       `gorm:"not null;uniqueIndex:idx_revisions_resource_version,priority:3" json:"version"`
+	Title        string    `gorm:"size:255;not null" json:"title"`
+	Content      string    `gorm:"type:text;not null" json:"content"`
+	Author       string    `gorm:"size:100" json:"author,omitempty"`
+	MediaIDs     []uint    `gorm:"serializer:json;type:jsonb" json:"media_ids,omitempty"`
+	EditorID     *uint     `json:"editor_id,omitempty"`
+	EditorName   string    `gorm:"size:100" json:"editor_name,omitempty"`
+	RestoredFrom *int      `json:"restored_from,omitempty"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// DeleteHistory removes the revisions and slug redirects left behind by posts
+// or pages of resourceType that were purged
+func DeleteHistory(tx *gorm.DB, resourceType string, ids ...uint) error {
+	if err := tx.Where("resource_type = ? AND resource_id IN ?", resourceType, ids).Delete(&Revision{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("resource_type = ? AND resource_id IN ?", resourceType, ids).Delete(&SlugRedirect{}).Error
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevisionModel(t *testing.T) {
+	t.Run("PostRevisionJSON", func(t *testing.T) {
+		editorID := uint(3)
+		rev := Revision{
+			ResourceType: RevisionResourcePost,
+			ResourceID:   1,
+			Version:      2,
+			Title:        "Title",
+			Content:      "Body",
+			Author:       "Author",
+			MediaIDs:     []uint{4, 5},
+			EditorID:     &editorID,
+			EditorName:   "editor",
+		}
+
+		data, err := json.Marshal(rev)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"media_ids":[4,5]`)
+		assert.Contains(t, string(data), `"editor_id":3`)
+		assert.NotContains(t, string(data), "restored_from")
+	})
+
+	t.Run("PageRevisionOmitsPostFields", func(t *testing.T) {
+		restored := 1
+		rev := Revision{ResourceType: RevisionResourcePage, ResourceID: 1, Version: 3, Title: "About", Content: "Body", RestoredFrom: &restored}
+
+		data, err := json.Marshal(rev)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "media_ids")
+		assert.NotContains(t, string(data), "author")
+		assert.Contains(t, string(data), `"restored_from":1`)
+	})
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// SlugRedirect remembers a slug a post or page used to have, so the old
+// permalink can answer 301 with the current one after a rename
+type SlugRedirect struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ResourceType string    `gorm:"size:20;not null;uniqueIndex:idx_slug_redirects_resource_slug,priority:1" json:"resource_type"`
+	Slug         string    `gorm:"size:255;not null;uniqueIndex:idx_slug_redirects_resource_slug,priority:2" json:"slug"`
+	ResourceID   uint      `gorm:"not null;index" json:"resource_id"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
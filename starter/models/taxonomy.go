@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Category is a node in the hierarchical post taxonomy. Top-level categories
+// have no parent.
+type Category struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Name        string     `gorm:"size:100;not null" json:"name"`
+	Slug        string     `gorm:"size:100;not null;uniqueIndex" json:"slug"`
+	Description string     `gorm:"type:text" json:"description,omitempty"`
+	ParentID    *uint      `gorm:"index" json:"parent_id,omitempty"`
+	Children    []Category `gorm:"foreignKey:ParentID" json:"children,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Tag is a flat, free-form label for posts
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:100;not null" json:"name"`
+	Slug      string    `gorm:"size:100;not null;uniqueIndex" json:"slug"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TagCount is a tag with the number of published posts carrying it
+type TagCount struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Count int64  `json:"count"`
+}
+
+type PostCategory struct {
+	PostID     uint `gorm:"primaryKey"`
+	CategoryID uint `gorm:"primaryKey"`
+}
+
+type PostTag struct {
+	PostID uint `gorm:"primaryKey"`
+	TagID  uint `gorm:"primaryKey"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+	RoleViewer = "viewer"
+)
+
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleEditor, RoleAuthor, RoleViewer:
+		return true
+	}
+	return false
+}
+
+type User struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Username     string `gorm:"size:100;not null;uniqueIndex" json:"username"`
+	Email        string `gorm:"size:255;not null;uniqueIndex" json:"email"`
+	PasswordHash string `gorm:"size:255;not null" json:"-"`
+	Role         string `gorm:"size:20;not null;default:viewer" json:"role"`
+	// PasswordChangedAt invalidates the tokens issued before it
+	PasswordChangedAt *time.Time `json:"-"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// SetPassword hashes the plain text password with bcrypt and stores the hash
+func (u *User) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = string(hash)
+	return nil
+}
+
+// CheckPassword reports whether the plain text password matches the stored hash
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// AcceptsToken reports whether a token issued at issuedAt is still good, i.e.
+// it was issued after the password last changed. Token times have second
+// precision, so tokens from the second of the change are rejected too.
+func (u *User) AcceptsToken(issuedAt time.Time) bool {
+	return u.PasswordChangedAt == nil || issuedAt.After(u.PasswordChangedAt.Truncate(time.Second))
+}
+
+// RevokedToken records the ID of a JWT that was invalidated before its expiry,
+// e.g. on logout or refresh token rotation
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64" json:"jti"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
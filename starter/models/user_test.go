@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModel(t *testing.T) {
+	t.Run("AcceptsToken", func(t *testing.T) {
+		changed := time.Date(2024, 6, 1, 12, 0, 0, 400_000_000, time.UTC)
+		user := User{PasswordChangedAt: &changed}
+
+		assert.False(t, user.AcceptsToken(changed.Add(-time.Second).Truncate(time.Second)), "tokens from before the change are rejected")
+		assert.False(t, user.AcceptsToken(changed.Truncate(time.Second)), "so are tokens from the same second")
+		assert.True(t, user.AcceptsToken(changed.Truncate(time.Second).Add(time.Second)))
+		assert.True(t, (&User{}).AcceptsToken(changed), "users who never changed their password accept any token")
+	})
+
+	t.Run("PasswordHashing", func(t *testing.T) {
+		user := User{Username: "alice", Email: "alice@example.com"}
+
+		err := user.SetPassword("s3cret")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, user.PasswordHash)
+		assert.NotEqual(t, "s3cret", user.PasswordHash)
+
+		assert.True(t, user.CheckPassword("s3cret"))
+		assert.False(t, user.CheckPassword("wrong"))
+	})
+
+	t.Run("PasswordHashNotSerialized", func(t *testing.T) {
+		user := User{ID: 1, Username: "alice", PasswordHash: "hash"}
+
+		data, err := json.Marshal(user)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "hash")
+		assert.Contains(t, string(data), `"username":"alice"`)
+	})
+}
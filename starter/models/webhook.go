@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook subscribes a URL to content lifecycle events such as
+// "post.published". Events may also be "<resource>.*" or "*".
+type Webhook struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	URL         string    `gorm:"size:500;not null" json:"url" validate:"required,url,max=500"`
+	Secret      string    `gorm:"size:128;not null" json:"-"`
+	Events      []string  `gorm:"serializer:json;type:jsonb;not null" json:"events" validate:"required,min=1"`
+	Description string    `gorm:"size:255" json:"description,omitempty" validate:"max=255"`
+	Active      bool      `gorm:"not null" json:"active"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// WebhookDelivery is one event queued for one webhook, with the outcome of
+// its latest attempt. EventKey is the idempotency key of the outbox event it
+// was queued for. NextAttemptAt is cleared once it is delivered or has
+// failed for good.
+type WebhookDelivery struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	WebhookID      uint            `gorm:"not null;index;uniqueIndex:idx_webhook_deliveries_event_key,priority:1,where:redelivery_of IS NULL" json:"webhook_id"`
+	Event          string          `gorm:"size:50;not null" json:"event"`
+	EventKey       string          `gorm:"size:64;uniqueIndex:idx_webhook_deliveries_event_key,priority:2" json:"event_key,omitempty"`
+	Payload        json.RawMessage `gorm:"serializer:json;type:jsonb" json:"payload"`
+	Status         string          `gorm:"size:20;not null;default:pending" json:"status"`
+	Attempts       int             `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time      `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	ResponseBody   string          `gorm:"type:text" json:"response_body,omitempty"`
+	Error          string          `gorm:"type:text" json:"error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	RedeliveryOf   *uint           `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Webhook *Webhook `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
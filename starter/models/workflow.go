@@ -0,0 +1,76 @@
+package models
+
+import "fmt"
+
+// Publication statuses shared by posts and pages
+const (
+	StatusDraft     = "draft"
+	StatusInReview  = "in_review"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// Workflow actions that move content between statuses
+const (
+	ActionSubmit    = "submit"
+	ActionApprove   = "approve"
+	ActionReject    = "reject"
+	ActionPublish   = "publish"
+	ActionUnpublish = "unpublish"
+	ActionArchive   = "archive"
+)
+
+// workflowTransitions lists, per action, the statuses it may start from and the status it leads to
+var workflowTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	ActionSubmit:    {from: []string{StatusDraft}, to: StatusInReview},
+	ActionApprove:   {from: []string{StatusInReview}, to: StatusPublished},
+	ActionReject:    {from: []string{StatusInReview}, to: StatusDraft},
+	ActionPublish:   {from: []string{StatusDraft, StatusInReview, StatusArchived}, to: StatusPublished},
+	ActionUnpublish: {from: []string{StatusPublished}, to: StatusDraft},
+	ActionArchive:   {from: []string{StatusDraft, StatusInReview, StatusPublished}, to: StatusArchived},
+}
+
+// IsValidStatus reports whether status is one of the known publication statuses
+func IsValidStatus(status string) bool {
+	switch status {
+	case StatusDraft, StatusInReview, StatusPublished, StatusArchived:
+		return true
+	}
+	return false
+}
+
+// TransitionSources returns the statuses an action may be applied to
+func TransitionSources(action string) []string {
+	return workflowTransitions[action].from
+}
+
+// NextStatus returns the status reached by applying action to current, or an
+// error when the transition is not allowed
+func NextStatus(current, action string) (string, error) {
+	transition, ok := workflowTransitions[action]
+	if !ok {
+		return "", fmt.Errorf("unknown workflow action %q", action)
+	}
+	for _, from := range transition.from {
+		if from == current {
+			return transition.to, nil
+		}
+	}
+	return "", fmt.Errorf("cannot %s content in status %q", action, current)
+}
+
+// StatusChangeEvent returns the outbox event action for a status change:
+// published or unpublished when it changes what is live, status_changed
+// otherwise
+func StatusChangeEvent(from, to string) string {
+	switch {
+	case to == StatusPublished && from != StatusPublished:
+		return EventPublished
+	case from == StatusPublished && to != StatusPublished:
+		return EventUnpublished
+	}
+	return EventStatusChanged
+}
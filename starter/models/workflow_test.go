@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextStatus(t *testing.T) {
+	testCases := []struct {
+		name    string
+		current string
+		action  string
+		want    string
+		wantErr bool
+	}{
+		{"SubmitDraft", StatusDraft, ActionSubmit, StatusInReview, false},
+		{"ApproveInReview", StatusInReview, ActionApprove, StatusPublished, false},
+		{"RejectInReview", StatusInReview, ActionReject, StatusDraft, false},
+		{"PublishDraft", StatusDraft, ActionPublish, StatusPublished, false},
+		{"RepublishArchived", StatusArchived, ActionPublish, StatusPublished, false},
+		{"UnpublishPublished", StatusPublished, ActionUnpublish, StatusDraft, false},
+		{"ArchivePublished", StatusPublished, ActionArchive, StatusArchived, false},
+		{"SubmitPublished", StatusPublished, ActionSubmit, "", true},
+		{"ApproveDraft", StatusDraft, ActionApprove, "", true},
+		{"PublishPublished", StatusPublished, ActionPublish, "", true},
+		{"UnpublishDraft", StatusDraft, ActionUnpublish, "", true},
+		{"ArchiveArchived", StatusArchived, ActionArchive, "", true},
+		{"UnknownAction", StatusDraft, "delete", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NextStatus(tc.current, tc.action)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestIsValidStatus(t *testing.T) {
+	for _, status := range []string{StatusDraft, StatusInReview, StatusPublished, StatusArchived} {
+		assert.True(t, IsValidStatus(status), status)
+	}
+	assert.False(t, IsValidStatus(""))
+	assert.False(t, IsValidStatus("deleted"))
+}
+
+func TestStatusChangeEvent(t *testing.T) {
+	assert.Equal(t, EventPublished, StatusChangeEvent(StatusInReview, StatusPublished))
+	assert.Equal(t, EventUnpublished, StatusChangeEvent(StatusPublished, StatusArchived))
+	assert.Equal(t, EventStatusChanged, StatusChangeEvent(StatusDraft, StatusInReview))
+}
@@ -0,0 +1,64 @@
+// Package outbox records domain events in the same transaction as the change
+// they describe, so side effects such as cache invalidation and webhooks
+// cannot be lost between a commit and the code that follows it
+package outbox
+
+import (
+	"cms-backend/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Handler reacts to a relayed event. It runs inside the relay's transaction,
+// so database writes commit together with the event being marked processed,
+// but it may see the same event again after a failure and must be idempotent.
+type Handler struct {
+	Name   string
+	Handle func(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error
+}
+
+// Publish records an action on a resource, with data as the event payload.
+// Call it inside the write's transaction and call Notify after committing.
+func Publish(tx *gorm.DB, resourceType string, resourceID uint, action string, data interface{}) error {
+	eventType := models.EventType(resourceType, action)
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s payload: %w", eventType, err)
+	}
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("failed to generate idempotency key: %w", err)
+	}
+	return tx.Create(&models.OutboxEvent{
+		IdempotencyKey: hex.EncodeToString(key),
+		Type:           eventType,
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+		Payload:        payload,
+		NextAttemptAt:  time.Now(),
+	}).Error
+}
+
+// wake is buffered so that any number of Notify calls made while the relay is
+// busy collapse into one extra run
+var wake = make(chan struct{}, 1)
+
+// Notify asks the relay in this process to run now instead of waiting for its
+// next tick, so a write's side effects follow it closely
+func Notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Notifications delivers a value after Notify has been called
+func Notifications() <-chan struct{} {
+	return wake
+}
@@ -0,0 +1,58 @@
+package outbox
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyRecorder matches any string argument and keeps it
+type keyRecorder []string
+
+func (r *keyRecorder) Match(v driver.Value) bool {
+	key, ok := v.(string)
+	*r = append(*r, key)
+	return ok
+}
+
+func TestPublish(t *testing.T) {
+	_, db, mock := utils.SetupRouterAndMockDB(t)
+
+	var keys keyRecorder
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "outbox_events" \("idempotency_key","type","resource_type","resource_id","payload","attempts","next_attempt_at","last_error","processed_at","created_at"\)`).
+			WithArgs(&keys, "post.published", models.RevisionResourcePost, 7, `{"id":7,"title":"Hello"}`, 0, sqlmock.AnyArg(), "", nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(i + 1))
+		mock.ExpectCommit()
+	}
+	data := map[string]interface{}{"id": 7, "title": "Hello"}
+	require.NoError(t, Publish(db, models.RevisionResourcePost, 7, models.EventPublished, data))
+	require.NoError(t, Publish(db, models.RevisionResourcePost, 7, models.EventPublished, data))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, keys, 2)
+	assert.Len(t, keys[0], 32)
+	assert.NotEqual(t, keys[0], keys[1], "every event gets its own idempotency key")
+}
+
+func TestNotify(t *testing.T) {
+	// Repeated calls never block and collapse into one notification
+	Notify()
+	Notify()
+	select {
+	case <-Notifications():
+	default:
+		t.Fatal("expected a notification")
+	}
+	select {
+	case <-Notifications():
+		t.Fatal("expected notifications to collapse")
+	default:
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"cms-backend/search"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"gorm.io/gorm"
+)
+
+// runReindex sends every post, page and media item to the external search
+// index configured by SEARCH_DRIVER. Run it after switching drivers, or to
+// repair an index that missed events.
+func runReindex(db *gorm.DB, args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	batchSize := flags.Int("batch-size", 500, "rows loaded and sent per request")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *batchSize < 1 {
+		return errors.New("-batch-size must be at least 1")
+	}
+
+	indexer, err := search.FromEnv()
+	if err != nil {
+		return err
+	}
+	if indexer == nil {
+		return errors.New("SEARCH_DRIVER is postgres, which searches the tables directly; there is no index to rebuild")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := indexer.Setup(ctx); err != nil {
+		return err
+	}
+	total, err := search.Reindex(ctx, db, indexer, *batchSize, func(resourceType string, indexed int) {
+		log.Printf("Indexed %d %s documents", indexed, resourceType)
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Reindexed %d documents", total)
+	return nil
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"cms-backend/controllers"
+	"cms-backend/middleware"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -17,39 +18,137 @@ func InitializeRoutes(router *gin.Engine, db *gorm.DB) {
 
 	api := router.Group("/api/v1")
 
+	// Reads stay public; anything that mutates state requires an access token
+	// and a permission from the RBAC policy. Post and page reads still look at an
+	// optional token so editors can see unpublished content, as does search.
+	authRequired := middleware.AuthRequired()
+	optionalAuth := middleware.OptionalAuth()
+	can := middleware.RequirePermission
+
+	auth := api.Group("/auth")
+	{
+		auth.POST("/login", controllers.Login)
+		auth.POST("/refresh", controllers.Refresh)
+		auth.POST("/logout", authRequired, controllers.Logout)
+		auth.GET("/me", authRequired, controllers.Me)
+	}
+
 	pages := api.Group("/pages")
 	{
-		pages.GET("", controllers.GetPages)
-		pages.GET("/:id", controllers.GetPage)
-		pages.POST("", controllers.CreatePage)
-		pages.PUT("/:id", controllers.UpdatePage)
-		pages.DELETE("/:id", controllers.DeletePage)
+		pages.GET("", optionalAuth, controllers.GetPages)
+		pages.GET("/trash", authRequired, can(middleware.PermPagesDelete), controllers.GetTrashedPages)
+		pages.GET("/:id", optionalAuth, controllers.GetPage)
+		pages.GET("/slug/:slug", optionalAuth, controllers.GetPageBySlug)
+		pages.POST("", authRequired, can(middleware.PermPagesCreate), controllers.CreatePage)
+		pages.PUT("/:id", authRequired, can(middleware.PermPagesUpdate), controllers.UpdatePage)
+		pages.DELETE("/:id", authRequired, can(middleware.PermPagesDelete), controllers.DeletePage)
+		pages.POST("/:id/restore", authRequired, can(middleware.PermPagesDelete), controllers.RestorePage)
+		pages.DELETE("/:id/purge", authRequired, can(middleware.PermPagesDelete), controllers.PurgePage)
+		pages.POST("/:id/submit", authRequired, can(middleware.PermPagesUpdate), controllers.SubmitPage)
+		pages.POST("/:id/approve", authRequired, can(middleware.PermPagesPublish), controllers.ApprovePage)
+		pages.POST("/:id/reject", authRequired, can(middleware.PermPagesPublish), controllers.RejectPage)
+		pages.POST("/:id/publish", authRequired, can(middleware.PermPagesPublish), controllers.PublishPage)
+		pages.POST("/:id/unpublish", authRequired, can(middleware.PermPagesPublish), controllers.UnpublishPage)
+		pages.POST("/:id/archive", authRequired, can(middleware.PermPagesPublish), controllers.ArchivePage)
+		pages.PUT("/:id/schedule", authRequired, can(middleware.PermPagesPublish), controllers.SchedulePage)
+		pages.GET("/:id/revisions", authRequired, can(middleware.PermPagesUpdate), controllers.GetPageRevisions)
+		pages.GET("/:id/revisions/diff", authRequired, can(middleware.PermPagesUpdate), controllers.DiffPageRevisions)
+		pages.GET("/:id/revisions/:version", authRequired, can(middleware.PermPagesUpdate), controllers.GetPageRevision)
+		pages.POST("/:id/revisions/:version/restore", authRequired, can(middleware.PermPagesUpdate), controllers.RestorePageRevision)
 	}
 
 	posts := api.Group("/posts")
 	{
-		posts.GET("", controllers.GetPosts)
-		posts.GET("/:id", controllers.GetPost)
-		posts.POST("", controllers.CreatePost)
-		posts.PUT("/:id", controllers.UpdatePost)
-		posts.DELETE("/:id", controllers.DeletePost)
+		posts.GET("", optionalAuth, controllers.GetPosts)
+		posts.GET("/trash", authRequired, can(middleware.PermPostsDelete), controllers.GetTrashedPosts)
+		posts.GET("/:id", optionalAuth, controllers.GetPost)
+		posts.GET("/slug/:slug", optionalAuth, controllers.GetPostBySlug)
+		posts.POST("", authRequired, can(middleware.PermPostsCreate), controllers.CreatePost)
+		posts.PUT("/:id", authRequired, can(middleware.PermPostsUpdate), controllers.UpdatePost)
+		posts.DELETE("/:id", authRequired, can(middleware.PermPostsDelete), controllers.DeletePost)
+		posts.POST("/:id/restore", authRequired, can(middleware.PermPostsDelete), controllers.RestorePost)
+		posts.DELETE("/:id/purge", authRequired, can(middleware.PermPostsDelete), controllers.PurgePost)
+		posts.POST("/:id/submit", authRequired, can(middleware.PermPostsSubmit), controllers.SubmitPost)
+		posts.POST("/:id/approve", authRequired, can(middleware.PermPostsPublish), controllers.ApprovePost)
+		posts.POST("/:id/reject", authRequired, can(middleware.PermPostsPublish), controllers.RejectPost)
+		posts.POST("/:id/publish", authRequired, can(middleware.PermPostsPublish), controllers.PublishPost)
+		posts.POST("/:id/unpublish", authRequired, can(middleware.PermPostsPublish), controllers.UnpublishPost)
+		posts.POST("/:id/archive", authRequired, can(middleware.PermPostsPublish), controllers.ArchivePost)
+		posts.PUT("/:id/schedule", authRequired, can(middleware.PermPostsPublish), controllers.SchedulePost)
+		posts.GET("/:id/revisions", authRequired, can(middleware.PermPostsUpdate), controllers.GetPostRevisions)
+		posts.GET("/:id/revisions/diff", authRequired, can(middleware.PermPostsUpdate), controllers.DiffPostRevisions)
+		posts.GET("/:id/revisions/:version", authRequired, can(middleware.PermPostsUpdate), controllers.GetPostRevision)
+		posts.POST("/:id/revisions/:version/restore", authRequired, can(middleware.PermPostsUpdate), controllers.RestorePostRevision)
 	}
 
+	api.GET("/schedule", authRequired, controllers.GetSchedule)
+	api.GET("/search", optionalAuth, controllers.Search)
+	api.GET("/search/suggest", optionalAuth, controllers.SuggestSearch)
+
 	media := api.Group("/media")
 	{
 		media.GET("", controllers.GetMedia)
+		media.GET("/trash", authRequired, can(middleware.PermMediaDelete), controllers.GetTrashedMedia)
 		media.GET("/:id", controllers.GetMediaByID)
-		media.POST("", controllers.CreateMedia)
-		media.DELETE("/:id", controllers.DeleteMedia)
+		media.GET("/:id/file", controllers.ServeMediaFile)
+		media.GET("/:id/render", optionalAuth, controllers.RenderMedia)
+		media.GET("/:id/usages", authRequired, can(middleware.PermMediaDelete), controllers.GetMediaUsages)
+		media.POST("", authRequired, can(middleware.PermMediaCreate), controllers.CreateMedia)
+		media.POST("/upload", authRequired, can(middleware.PermMediaCreate), controllers.UploadMedia)
+		media.PUT("/:id", authRequired, can(middleware.PermMediaUpdate), controllers.UpdateMedia)
+		media.PUT("/:id/file", authRequired, can(middleware.PermMediaUpdate), controllers.ReplaceMediaFile)
+		media.DELETE("/:id", authRequired, can(middleware.PermMediaDelete), controllers.DeleteMedia)
+		media.POST("/:id/restore", authRequired, can(middleware.PermMediaDelete), controllers.RestoreMedia)
+		media.DELETE("/:id/purge", authRequired, can(middleware.PermMediaDelete), controllers.PurgeMedia)
+	}
+
+	categories := api.Group("/categories")
+	{
+		categories.GET("", controllers.GetCategories)
+		categories.GET("/:id", controllers.GetCategory)
+		categories.POST("", authRequired, can(middleware.PermTaxonomyManage), controllers.CreateCategory)
+		categories.PUT("/:id", authRequired, can(middleware.PermTaxonomyManage), controllers.UpdateCategory)
+		categories.DELETE("/:id", authRequired, can(middleware.PermTaxonomyManage), controllers.DeleteCategory)
+	}
+
+	tags := api.Group("/tags")
+	{
+		tags.GET("", controllers.GetTags)
+		tags.GET("/counts", controllers.GetTagCounts)
+		tags.GET("/:id", controllers.GetTag)
+		tags.POST("", authRequired, can(middleware.PermTaxonomyManage), controllers.CreateTag)
+		tags.PUT("/:id", authRequired, can(middleware.PermTaxonomyManage), controllers.UpdateTag)
+		tags.DELETE("/:id", authRequired, can(middleware.PermTaxonomyManage), controllers.DeleteTag)
+	}
+
+	users := api.Group("/users", authRequired, can(middleware.PermUsersManage))
+	{
+		users.GET("", controllers.GetUsers)
+		users.POST("", controllers.CreateUser)
+		users.PUT("/:id", controllers.UpdateUser)
+		users.DELETE("/:id", controllers.DeleteUser)
+	}
+
+	api.GET("/audit", authRequired, can(middleware.PermAuditRead), controllers.GetAuditLog)
+
+	hooks := api.Group("/webhooks", authRequired, can(middleware.PermWebhooksManage))
+	{
+		hooks.GET("", controllers.GetWebhooks)
+		hooks.POST("", controllers.CreateWebhook)
+		hooks.GET("/:id", controllers.GetWebhook)
+		hooks.PUT("/:id", controllers.UpdateWebhook)
+		hooks.DELETE("/:id", controllers.DeleteWebhook)
+		hooks.GET("/:id/deliveries", controllers.GetWebhookDeliveries)
+		hooks.POST("/:id/deliveries/:delivery_id/redeliver", controllers.RedeliverWebhookDelivery)
 	}
 
 	cache := api.Group("/cache")
 	{
 		cache.GET("/stats", controllers.GetCacheStats)
-		cache.POST("/clear", controllers.ClearCache)
-		cache.POST("/invalidate", controllers.InvalidateCache)
-		cache.POST("/invalidate/:resource", controllers.InvalidateResourceCache)
-		cache.POST("/warmup", controllers.WarmupCache)
+		cache.POST("/clear", authRequired, can(middleware.PermCacheManage), controllers.ClearCache)
+		cache.POST("/invalidate", authRequired, can(middleware.PermCacheManage), controllers.InvalidateCache)
+		cache.POST("/invalidate/:resource", authRequired, can(middleware.PermCacheManage), controllers.InvalidateResourceCache)
+		cache.POST("/warmup", authRequired, can(middleware.PermCacheManage), controllers.WarmupCache)
 		cache.GET("/health", controllers.CacheHealth)
 	}
 }
@@ -0,0 +1,114 @@
+package search
+
+import (
+	"cms-backend/utils"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Document is what an external index stores for a post, page or media item
+type Document struct {
+	// ID is unique across types, such as "post-7"
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	ResourceID uint   `json:"resource_id"`
+	Title      string `json:"title"`
+	Slug       string `json:"slug,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Status     string `json:"status,omitempty"`
+	// Body is plain text: post and page content without HTML tags, or the
+	// media alt text, caption and filename
+	Body string `json:"body"`
+	// CreatedAt is a Unix timestamp so engines can sort on it
+	CreatedAt int64 `json:"created_at"`
+}
+
+// DocumentID builds the ID of the document for a resource
+func DocumentID(resourceType string, id uint) string {
+	return fmt.Sprintf("%s-%d", resourceType, id)
+}
+
+// Indexer is a search backend. Index and Delete keep it in step with the
+// database; both must be safe to repeat, since outbox events can be relayed
+// more than once.
+type Indexer interface {
+	// Setup prepares the index, for example its filterable fields
+	Setup(ctx context.Context) error
+	// Index adds or replaces documents
+	Index(ctx context.Context, docs ...Document) error
+	// Delete removes documents; deleting a missing document is not an error
+	Delete(ctx context.Context, ids ...string) error
+	// Search runs a query with the same semantics as Run
+	Search(ctx context.Context, query Query) (*Results, error)
+}
+
+// Postgres searches the tables themselves through their text search indexes,
+// so there is nothing to keep in sync
+type Postgres struct {
+	db *gorm.DB
+}
+
+// NewPostgres creates an indexer searching db
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Setup(ctx context.Context) error {
+	return nil
+}
+
+func (p *Postgres) Index(ctx context.Context, docs ...Document) error {
+	return nil
+}
+
+func (p *Postgres) Delete(ctx context.Context, ids ...string) error {
+	return nil
+}
+
+func (p *Postgres) Search(ctx context.Context, query Query) (*Results, error) {
+	return Run(p.db.WithContext(ctx), query)
+}
+
+var (
+	defaultIndexer   Indexer
+	defaultIndexerMu sync.RWMutex
+)
+
+// SetDefault installs the external index used by the search endpoint
+func SetDefault(indexer Indexer) {
+	defaultIndexerMu.Lock()
+	defer defaultIndexerMu.Unlock()
+	defaultIndexer = indexer
+}
+
+// Default returns the configured external index, or nil when the database is
+// searched directly
+func Default() Indexer {
+	defaultIndexerMu.RLock()
+	defer defaultIndexerMu.RUnlock()
+	return defaultIndexer
+}
+
+// FromEnv configures an external index from SEARCH_DRIVER and the matching
+// SEARCH_* variables. It returns nil for the "postgres" driver, the default.
+func FromEnv() (Indexer, error) {
+	switch driver := strings.ToLower(os.Getenv("SEARCH_DRIVER")); driver {
+	case "", "postgres":
+		return nil, nil
+	case "meilisearch":
+		return NewMeilisearch(MeilisearchConfig{
+			URL:     os.Getenv("SEARCH_URL"),
+			Index:   os.Getenv("SEARCH_INDEX"),
+			APIKey:  os.Getenv("SEARCH_API_KEY"),
+			Timeout: utils.DurationFromEnv("SEARCH_TIMEOUT", 5*time.Second),
+		})
+	default:
+		return nil, fmt.Errorf("unknown SEARCH_DRIVER %q", driver)
+	}
+}
@@ -0,0 +1,208 @@
+package search
+
+import (
+	"bytes"
+	"cms-backend/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MeilisearchConfig describes an index on a server speaking the Meilisearch
+// HTTP API
+type MeilisearchConfig struct {
+	URL    string
+	Index  string
+	APIKey string
+	// Timeout bounds each request; it defaults to 5 seconds
+	Timeout time.Duration
+	// Transport overrides the HTTP transport, mainly for tests
+	Transport http.RoundTripper
+}
+
+// Meilisearch keeps documents in a Meilisearch index. Writes are queued as
+// tasks by the server, so a document may take a moment to become searchable.
+type Meilisearch struct {
+	client  *http.Client
+	baseURL string
+	index   string
+	apiKey  string
+}
+
+// NewMeilisearch creates the client without contacting the server
+func NewMeilisearch(cfg MeilisearchConfig) (*Meilisearch, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("SEARCH_URL is required for the meilisearch search driver")
+	}
+	if cfg.Index == "" {
+		cfg.Index = "content"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	return &Meilisearch{
+		client:  &http.Client{Timeout: cfg.Timeout, Transport: cfg.Transport},
+		baseURL: strings.TrimRight(cfg.URL, "/"),
+		index:   cfg.Index,
+		apiKey:  cfg.APIKey,
+	}, nil
+}
+
+// Setup makes type and status filterable, which Search relies on, and limits
+// matching to titles and bodies. The index is created if it is missing.
+func (m *Meilisearch) Setup(ctx context.Context) error {
+	settings := map[string]interface{}{
+		"searchableAttributes": []string{"title", "body"},
+		"filterableAttributes": []string{"type", "status"},
+		"sortableAttributes":   []string{"created_at"},
+	}
+	return m.do(ctx, http.MethodPatch, "/settings", settings, nil)
+}
+
+func (m *Meilisearch) Index(ctx context.Context, docs ...Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	return m.do(ctx, http.MethodPost, "/documents?primaryKey=id", docs, nil)
+}
+
+func (m *Meilisearch) Delete(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return m.do(ctx, http.MethodPost, "/documents/delete-batch", ids, nil)
+}
+
+// meilisearchRequest is the body of a search request
+type meilisearchRequest struct {
+	Q                     string   `json:"q"`
+	Filter                []string `json:"filter,omitempty"`
+	Facets                []string `json:"facets,omitempty"`
+	Limit                 int      `json:"limit"`
+	Offset                int      `json:"offset"`
+	AttributesToCrop      []string `json:"attributesToCrop,omitempty"`
+	CropLength            int      `json:"cropLength,omitempty"`
+	CropMarker            string   `json:"cropMarker,omitempty"`
+	AttributesToHighlight []string `json:"attributesToHighlight,omitempty"`
+	HighlightPreTag       string   `json:"highlightPreTag,omitempty"`
+	HighlightPostTag      string   `json:"highlightPostTag,omitempty"`
+	ShowRankingScore      bool     `json:"showRankingScore,omitempty"`
+}
+
+// meilisearchResponse holds the parts of a search response that are used
+type meilisearchResponse struct {
+	Hits []struct {
+		Document
+		Formatted struct {
+			Body string `json:"body"`
+		} `json:"_formatted"`
+		RankingScore float64 `json:"_rankingScore"`
+	} `json:"hits"`
+	FacetDistribution map[string]map[string]int64 `json:"facetDistribution"`
+}
+
+// Search counts the matches of each type, then fetches the requested page
+// with cropped, highlighted bodies. Hits are ordered by the engine's
+// relevance; query.Language is not used since the engine detects languages
+// itself.
+func (m *Meilisearch) Search(ctx context.Context, query Query) (*Results, error) {
+	types := query.Types
+	if len(types) == 0 {
+		types = Types
+	}
+	var visible []string
+	if !query.UnpublishedPosts {
+		visible = append(visible, fmt.Sprintf(`(type != %q OR status = %q)`, TypePost, models.StatusPublished))
+	}
+	if !query.UnpublishedPages {
+		visible = append(visible, fmt.Sprintf(`(type != %q OR status = %q)`, TypePage, models.StatusPublished))
+	}
+
+	var counts meilisearchResponse
+	if err := m.do(ctx, http.MethodPost, "/search", meilisearchRequest{
+		Q: query.Text, Filter: visible, Facets: []string{"type"}, Limit: 0,
+	}, &counts); err != nil {
+		return nil, err
+	}
+	results := &Results{Hits: []Hit{}, Facets: make(map[string]int64, len(Types))}
+	for _, resourceType := range Types {
+		results.Facets[resourceType] = counts.FacetDistribution["type"][resourceType]
+	}
+	for _, resourceType := range types {
+		results.Total += results.Facets[resourceType]
+	}
+	if results.Total == 0 || query.Offset >= int(results.Total) {
+		return results, nil
+	}
+
+	quoted := make([]string, len(types))
+	for i, resourceType := range types {
+		quoted[i] = fmt.Sprintf("%q", resourceType)
+	}
+	var page meilisearchResponse
+	if err := m.do(ctx, http.MethodPost, "/search", meilisearchRequest{
+		Q:                     query.Text,
+		Filter:                append(visible, "type IN ["+strings.Join(quoted, ", ")+"]"),
+		Limit:                 query.Limit,
+		Offset:                query.Offset,
+		AttributesToCrop:      []string{"body"},
+		CropLength:            30,
+		CropMarker:            " ... ",
+		AttributesToHighlight: []string{"body"},
+		HighlightPreTag:       "<mark>",
+		HighlightPostTag:      "</mark>",
+		ShowRankingScore:      true,
+	}, &page); err != nil {
+		return nil, err
+	}
+	for _, hit := range page.Hits {
+		results.Hits = append(results.Hits, Hit{
+			Type:      hit.Type,
+			ID:        hit.ResourceID,
+			Title:     hit.Title,
+			Slug:      hit.Slug,
+			URL:       hit.URL,
+			Status:    hit.Status,
+			Snippet:   hit.Formatted.Body,
+			Rank:      hit.RankingScore,
+			CreatedAt: time.Unix(hit.CreatedAt, 0).UTC(),
+		})
+	}
+	return results, nil
+}
+
+// do sends a JSON request to a path under the index and decodes the response
+// into out when it is not nil
+func (m *Meilisearch) do(ctx context.Context, method, path string, body, out interface{}) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, m.baseURL+"/indexes/"+url.PathEscape(m.index)+path, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("meilisearch %s %s responded with %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
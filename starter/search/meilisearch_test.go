@@ -0,0 +1,132 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// meilisearchRecorder is a stand-in server keeping the requests it gets
+type meilisearchRecorder struct {
+	requests []recordedRequest
+	respond  func(path string, body []byte) (int, interface{})
+}
+
+type recordedRequest struct {
+	Method, Path, Auth string
+	Body               []byte
+}
+
+func (r *meilisearchRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.requests = append(r.requests, recordedRequest{req.Method, req.URL.RequestURI(), req.Header.Get("Authorization"), body})
+	status, response := http.StatusAccepted, interface{}(map[string]interface{}{"taskUid": len(r.requests)})
+	if r.respond != nil {
+		status, response = r.respond(req.URL.Path, body)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func newTestMeilisearch(t *testing.T, recorder *meilisearchRecorder) *Meilisearch {
+	server := httptest.NewServer(recorder)
+	t.Cleanup(server.Close)
+	indexer, err := NewMeilisearch(MeilisearchConfig{URL: server.URL + "/", Index: "cms", APIKey: "secret"})
+	require.NoError(t, err)
+	return indexer
+}
+
+func TestMeilisearch(t *testing.T) {
+	t.Run("WritesDocuments", func(t *testing.T) {
+		recorder := &meilisearchRecorder{}
+		indexer := newTestMeilisearch(t, recorder)
+
+		require.NoError(t, indexer.Setup(context.Background()))
+		require.NoError(t, indexer.Index(context.Background(), Document{ID: "post-7", Type: TypePost, ResourceID: 7, Title: "Hello"}))
+		require.NoError(t, indexer.Delete(context.Background(), "page-3"))
+		require.NoError(t, indexer.Index(context.Background()), "empty batches are not sent")
+
+		require.Len(t, recorder.requests, 3)
+		assert.Equal(t, http.MethodPatch, recorder.requests[0].Method)
+		assert.Equal(t, "/indexes/cms/settings", recorder.requests[0].Path)
+		assert.Contains(t, string(recorder.requests[0].Body), `"filterableAttributes":["type","status"]`)
+		assert.Equal(t, "/indexes/cms/documents?primaryKey=id", recorder.requests[1].Path)
+		assert.Equal(t, "Bearer secret", recorder.requests[1].Auth)
+		assert.JSONEq(t, `[{"id":"post-7","type":"post","resource_id":7,"title":"Hello","body":"","created_at":0}]`, string(recorder.requests[1].Body))
+		assert.Equal(t, "/indexes/cms/documents/delete-batch", recorder.requests[2].Path)
+		assert.JSONEq(t, `["page-3"]`, string(recorder.requests[2].Body))
+	})
+
+	t.Run("SearchesWithFacets", func(t *testing.T) {
+		created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		recorder := &meilisearchRecorder{respond: func(path string, body []byte) (int, interface{}) {
+			var request meilisearchRequest
+			json.Unmarshal(body, &request)
+			if request.Limit == 0 {
+				return http.StatusOK, map[string]interface{}{
+					"hits":              []interface{}{},
+					"facetDistribution": map[string]interface{}{"type": map[string]int{"post": 3, "media": 2}},
+				}
+			}
+			return http.StatusOK, map[string]interface{}{"hits": []interface{}{map[string]interface{}{
+				"id": "post-4", "type": "post", "resource_id": 4, "title": "Go modules", "slug": "go-modules",
+				"status": "published", "body": "Using Go modules", "created_at": created.Unix(),
+				"_formatted": map[string]string{"body": "Using <mark>Go</mark> modules"}, "_rankingScore": 0.9,
+			}}}
+		}}
+		indexer := newTestMeilisearch(t, recorder)
+
+		results, err := indexer.Search(context.Background(), Query{Text: "go", Types: []string{TypePost}, UnpublishedPages: true, Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int64{"post": 3, "page": 0, "media": 2}, results.Facets)
+		assert.EqualValues(t, 3, results.Total)
+		require.Len(t, results.Hits, 1)
+		assert.Equal(t, Hit{Type: "post", ID: 4, Title: "Go modules", Slug: "go-modules", Status: "published",
+			Snippet: "Using <mark>Go</mark> modules", Rank: 0.9, CreatedAt: created}, results.Hits[0])
+
+		require.Len(t, recorder.requests, 2)
+		var facets, hits meilisearchRequest
+		require.NoError(t, json.Unmarshal(recorder.requests[0].Body, &facets))
+		require.NoError(t, json.Unmarshal(recorder.requests[1].Body, &hits))
+		assert.Equal(t, []string{`(type != "post" OR status = "published")`}, facets.Filter, "facets ignore the type filter")
+		assert.Equal(t, []string{`(type != "post" OR status = "published")`, `type IN ["post"]`}, hits.Filter)
+		assert.Equal(t, "<mark>", hits.HighlightPreTag)
+	})
+
+	t.Run("SkipsHitsWithoutMatches", func(t *testing.T) {
+		recorder := &meilisearchRecorder{respond: func(path string, body []byte) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{"hits": []interface{}{}}
+		}}
+		indexer := newTestMeilisearch(t, recorder)
+
+		results, err := indexer.Search(context.Background(), Query{Text: "nothing", Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, results.Hits)
+		assert.Len(t, recorder.requests, 1)
+	})
+
+	t.Run("ReportsErrors", func(t *testing.T) {
+		recorder := &meilisearchRecorder{respond: func(path string, body []byte) (int, interface{}) {
+			return http.StatusUnauthorized, map[string]string{"message": "The provided API key is invalid."}
+		}}
+		indexer := newTestMeilisearch(t, recorder)
+
+		err := indexer.Index(context.Background(), Document{ID: "post-1"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "401")
+		assert.Contains(t, err.Error(), "API key is invalid")
+	})
+
+	t.Run("RequiresURL", func(t *testing.T) {
+		_, err := NewMeilisearch(MeilisearchConfig{})
+		assert.Error(t, err)
+	})
+}
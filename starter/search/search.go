@@ -0,0 +1,190 @@
+// Package search runs full-text queries across posts, pages and media with
+// the Postgres text search functions, so the GIN indexes on to_tsvector can be
+// used instead of ILIKE scans, or with an external index kept in sync through
+// the outbox
+package search
+
+import (
+	"cms-backend/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Searchable resource types
+const (
+	TypePost  = "post"
+	TypePage  = "page"
+	TypeMedia = "media"
+)
+
+// Types lists every searchable type, in the order facets are reported
+var Types = []string{TypePost, TypePage, TypeMedia}
+
+// ValidType reports whether a type can be searched
+func ValidType(name string) bool {
+	for _, known := range Types {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// languages are the text search configurations that ship with Postgres
+var languages = map[string]bool{
+	"simple": true, "arabic": true, "armenian": true, "basque": true, "catalan": true,
+	"danish": true, "dutch": true, "english": true, "finnish": true, "french": true,
+	"german": true, "greek": true, "hindi": true, "hungarian": true, "indonesian": true,
+	"irish": true, "italian": true, "lithuanian": true, "nepali": true, "norwegian": true,
+	"portuguese": true, "romanian": true, "russian": true, "serbian": true, "spanish": true,
+	"swedish": true, "tamil": true, "turkish": true, "yiddish": true,
+}
+
+// DefaultLanguage is the configuration used when a query names none. The
+// indexes are built for English; queries in another language still work but
+// scan the tables unless matching indexes are added.
+var DefaultLanguage = "english"
+
+// ValidLanguage reports whether name is a known text search configuration
+func ValidLanguage(name string) bool {
+	return languages[name]
+}
+
+// Query is a full-text search request
+type Query struct {
+	// Text uses web search syntax: quoted phrases, OR, and -word to exclude
+	Text string
+	// Types restricts the hits to some types; empty means every type
+	Types    []string
+	Language string
+	// Unpublished posts and pages only match when the caller may see them
+	UnpublishedPosts bool
+	UnpublishedPages bool
+	Limit            int
+	Offset           int
+}
+
+// Hit is one matching post, page or media item
+type Hit struct {
+	Type      string    `json:"type"`
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Results holds a page of hits, the number of hits across all pages, and the
+// number of matches of each type regardless of the type filter
+type Results struct {
+	Hits   []Hit            `json:"hits"`
+	Total  int64            `json:"total"`
+	Facets map[string]int64 `json:"facets"`
+}
+
+// headlineOptions marks matched words and keeps a couple of short fragments
+const headlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=12, MaxFragments=2, FragmentDelimiter=" ... "`
+
+// mediaDocument is the text media is searched on; migration 000023 indexes
+// the same expression
+const mediaDocument = `COALESCE(title, '') || ' ' || COALESCE(alt_text, '') || ' ' || COALESCE(caption, '') || ' ' || COALESCE(original_filename, '')`
+
+// stripTags drops HTML tags from content so snippets are plain text
+const stripTags = `regexp_replace(content, '<[^>]*>', ' ', 'g')`
+
+// matchSQL selects the matches of one type with their rank and the text to
+// build the snippet from. config is a validated regconfig literal; it is
+// written into the SQL so the planner can match the expression indexes.
+func matchSQL(resourceType, config string, unpublished bool) string {
+	switch resourceType {
+	case TypePost, TypePage:
+		table := resourceType + "s"
+		sql := fmt.Sprintf(`SELECT '%[1]s' AS type, id, title, slug, '' AS url, status, created_at, %[4]s AS body,
+			ts_rank(setweight(to_tsvector(%[3]s, title), 'A') || setweight(to_tsvector(%[3]s, content), 'B'), q.query) AS rank
+			FROM %[2]s, q
+			WHERE deleted_at IS NULL AND (to_tsvector(%[3]s, title) @@ q.query OR to_tsvector(%[3]s, content) @@ q.query)`,
+			resourceType, table, config, stripTags)
+		if !unpublished {
+			sql += fmt.Sprintf(" AND status = '%s'", models.StatusPublished)
+		}
+		return sql
+	default:
+		return fmt.Sprintf(`SELECT '%[1]s' AS type, id, COALESCE(NULLIF(title, ''), NULLIF(original_filename, ''), url) AS title, '' AS slug, url, '' AS status, created_at, %[3]s AS body,
+			ts_rank(setweight(to_tsvector(%[2]s, COALESCE(title, '')), 'A') || setweight(to_tsvector(%[2]s, %[3]s), 'B'), q.query) AS rank
+			FROM media, q
+			WHERE deleted_at IS NULL AND to_tsvector(%[2]s, %[3]s) @@ q.query`,
+			TypeMedia, config, mediaDocument)
+	}
+}
+
+// matchesCTE builds the common table expressions shared by the facet and hit
+// queries: the parsed query and the union of the matches of each type
+func matchesCTE(query Query, config string, types []string) string {
+	parts := make([]string, len(types))
+	for i, resourceType := range types {
+		unpublished := (resourceType == TypePost && query.UnpublishedPosts) || (resourceType == TypePage && query.UnpublishedPages)
+		parts[i] = matchSQL(resourceType, config, unpublished)
+	}
+	return fmt.Sprintf("WITH q AS (SELECT websearch_to_tsquery(%s, ?) AS query),\nmatches AS (\n%s\n)\n",
+		config, strings.Join(parts, "\nUNION ALL\n"))
+}
+
+// Run searches with Postgres text search. Hits are ordered by rank, then the
+// newest first.
+func Run(db *gorm.DB, query Query) (*Results, error) {
+	language := query.Language
+	if language == "" {
+		language = DefaultLanguage
+	}
+	if !ValidLanguage(language) {
+		return nil, fmt.Errorf("unknown search language %q", language)
+	}
+	config := fmt.Sprintf("'%s'::regconfig", language)
+	types := query.Types
+	if len(types) == 0 {
+		types = Types
+	}
+	for _, resourceType := range types {
+		if !ValidType(resourceType) {
+			return nil, fmt.Errorf("unknown search type %q", resourceType)
+		}
+	}
+
+	var counts []struct {
+		Type  string
+		Count int64
+	}
+	if err := db.Raw(matchesCTE(query, config, Types)+"SELECT type, count(*) AS count FROM matches GROUP BY type", query.Text).
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	results := &Results{Hits: []Hit{}, Facets: make(map[string]int64, len(Types))}
+	for _, resourceType := range Types {
+		results.Facets[resourceType] = 0
+	}
+	for _, count := range counts {
+		results.Facets[count.Type] = count.Count
+	}
+	for _, resourceType := range types {
+		results.Total += results.Facets[resourceType]
+	}
+	if results.Total == 0 || query.Offset >= int(results.Total) {
+		return results, nil
+	}
+
+	// Headlines are expensive, so they are only built for the requested page
+	sql := matchesCTE(query, config, types) + fmt.Sprintf(`SELECT type, id, title, slug, url, status, created_at, rank,
+		ts_headline(%s, body, q.query, '%s') AS snippet
+		FROM (SELECT * FROM matches ORDER BY rank DESC, created_at DESC, type, id LIMIT ? OFFSET ?) AS hits, q
+		ORDER BY rank DESC, created_at DESC, type, id`, config, headlineOptions)
+	if err := db.Raw(sql, query.Text, query.Limit, query.Offset).Scan(&results.Hits).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,114 @@
+package search
+
+import (
+	"cms-backend/utils"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidLanguage(t *testing.T) {
+	assert.True(t, ValidLanguage("english"))
+	assert.True(t, ValidLanguage("simple"))
+	assert.False(t, ValidLanguage("klingon"))
+	assert.False(t, ValidLanguage("english'; DROP TABLE posts; --"))
+}
+
+func TestMatchSQL(t *testing.T) {
+	config := "'english'::regconfig"
+	published := matchSQL(TypePost, config, false)
+	assert.Contains(t, published, "to_tsvector('english'::regconfig, title) @@ q.query", "the expression indexes can be used")
+	assert.Contains(t, published, "AND status = 'published'")
+	assert.NotContains(t, matchSQL(TypePage, config, true), "status = 'published'")
+	assert.Contains(t, matchSQL(TypeMedia, config, false), "FROM media, q")
+}
+
+func TestRun(t *testing.T) {
+	now := time.Now()
+
+	t.Run("RanksHitsWithFacets", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+
+		mock.ExpectQuery(`WITH q AS \(SELECT websearch_to_tsquery\('german'::regconfig, \$1\) AS query\),\s+matches AS \(.*FROM posts, q.*UNION ALL.*FROM pages, q.*UNION ALL.*FROM media, q.*\)\s+SELECT type, count\(\*\) AS count FROM matches GROUP BY type`).
+			WithArgs(`"go modules" -vendor`).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "count"}).AddRow("post", 3).AddRow("media", 1))
+		mock.ExpectQuery(`FROM posts, q.*\)\s+SELECT type, id, title, slug, url, status, created_at, rank,\s+ts_headline\('german'::regconfig, body, q\.query, 'StartSel=<mark>.*FROM \(SELECT \* FROM matches ORDER BY rank DESC, created_at DESC, type, id LIMIT \$2 OFFSET \$3\)`).
+			WithArgs(`"go modules" -vendor`, 2, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "id", "title", "slug", "url", "status", "created_at", "rank", "snippet"}).
+				AddRow("post", 4, "Go modules", "go-modules", "", "published", now, 0.6, "Using <mark>Go</mark> <mark>modules</mark>").
+				AddRow("post", 9, "Building", "building", "", "published", now, 0.2, "with <mark>go</mark> <mark>modules</mark>"))
+
+		results, err := Run(db, Query{Text: `"go modules" -vendor`, Types: []string{TypePost}, Language: "german", Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int64{"post": 3, "page": 0, "media": 1}, results.Facets, "facets ignore the type filter")
+		assert.EqualValues(t, 3, results.Total)
+		require.Len(t, results.Hits, 2)
+		assert.Equal(t, "Go modules", results.Hits[0].Title)
+		assert.Equal(t, "Using <mark>Go</mark> <mark>modules</mark>", results.Hits[0].Snippet)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("SkipsHitsWithoutMatches", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+
+		mock.ExpectQuery(`SELECT type, count\(\*\) AS count FROM matches GROUP BY type`).
+			WithArgs("nothing").
+			WillReturnRows(sqlmock.NewRows([]string{"type", "count"}))
+
+		results, err := Run(db, Query{Text: "nothing", Limit: 20})
+		require.NoError(t, err)
+		assert.Zero(t, results.Total)
+		assert.Empty(t, results.Hits)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("RejectsUnknownLanguage", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+
+		_, err := Run(db, Query{Text: "hello", Language: "klingon"})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSuggest(t *testing.T) {
+	t.Run("MatchesWithTypoTolerance", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT set_config\('pg_trgm.word_similarity_threshold', \$1, true\)`).
+			WithArgs("0.3").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`SELECT \* FROM \( SELECT 'post' AS type, id, title AS label, .* FROM posts WHERE deleted_at IS NULL AND \$3 <% title AND status = 'published' UNION ALL SELECT 'tag' AS type, id, name AS label, .* FROM tags WHERE \$6 <% name \) AS suggestions ORDER BY score DESC, length\(label\), type, id LIMIT \$7`).
+			WithArgs("progam", "progam%", "progam", "progam", "progam%", "progam", 5).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "id", "label", "slug", "url", "status", "score"}).
+				AddRow("tag", 3, "programming", "programming", "", "", 0.55).
+				AddRow("post", 8, "Programming in Go", "programming-in-go", "", "published", 0.5))
+		mock.ExpectCommit()
+
+		suggestions, err := Suggest(db, SuggestQuery{Text: "progam", Types: []string{TypePost, TypeTag}, Limit: 5})
+		require.NoError(t, err)
+		require.Len(t, suggestions, 2)
+		assert.Equal(t, Suggestion{Type: "tag", ID: 3, Label: "programming", Slug: "programming", Score: 0.55}, suggestions[0])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("EscapesLikePatterns", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`set_config`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(`FROM media WHERE deleted_at IS NULL AND \$3 <% original_filename`).
+			WithArgs("100%_off", `100\%\_off%`, "100%_off", 10).
+			WillReturnRows(sqlmock.NewRows([]string{"type", "id", "label"}))
+		mock.ExpectCommit()
+
+		suggestions, err := Suggest(db, SuggestQuery{Text: "100%_off", Types: []string{TypeMedia}, Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, suggestions)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,115 @@
+package search
+
+import (
+	"cms-backend/models"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// TypeTag is only offered as a suggestion; tags are not full-text searched
+const TypeTag = "tag"
+
+// SuggestTypes lists the types suggestions are drawn from
+var SuggestTypes = []string{TypePost, TypePage, TypeMedia, TypeTag}
+
+// ValidSuggestType reports whether suggestions can be drawn from a type
+func ValidSuggestType(name string) bool {
+	for _, known := range SuggestTypes {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SuggestThreshold is the lowest pg_trgm word similarity a suggestion needs.
+// It is below the extension's default of 0.6 so that a typo or two still
+// matches.
+var SuggestThreshold = 0.3
+
+// SuggestQuery is a search-as-you-type request
+type SuggestQuery struct {
+	Text string
+	// Types restricts the suggestions to some types; empty means every type
+	Types []string
+	// Unpublished posts and pages are only suggested when the caller may see them
+	UnpublishedPosts bool
+	UnpublishedPages bool
+	Limit            int
+}
+
+// Suggestion is a title, filename or tag name close to what was typed
+type Suggestion struct {
+	Type   string  `json:"type"`
+	ID     uint    `json:"id"`
+	Label  string  `json:"label"`
+	Slug   string  `json:"slug,omitempty"`
+	URL    string  `json:"url,omitempty"`
+	Status string  `json:"status,omitempty"`
+	Score  float64 `json:"score"`
+}
+
+// likeEscaper makes a string match itself literally in a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// suggestSQL selects the suggestions of one type. The <% operator uses the
+// trigram indexes from migration 000024; labels starting with the typed text
+// are ranked above other matches of the same similarity.
+func suggestSQL(resourceType string, unpublished bool) string {
+	var sql string
+	switch resourceType {
+	case TypePost, TypePage:
+		sql = fmt.Sprintf(`SELECT '%s' AS type, id, title AS label, slug, '' AS url, status,
+			word_similarity(?, title) + CASE WHEN title ILIKE ? THEN 0.5 ELSE 0 END AS score
+			FROM %ss WHERE deleted_at IS NULL AND ? <%% title`, resourceType, resourceType)
+		if !unpublished {
+			sql += fmt.Sprintf(" AND status = '%s'", models.StatusPublished)
+		}
+	case TypeMedia:
+		sql = fmt.Sprintf(`SELECT '%s' AS type, id, original_filename AS label, '' AS slug, url, '' AS status,
+			word_similarity(?, original_filename) + CASE WHEN original_filename ILIKE ? THEN 0.5 ELSE 0 END AS score
+			FROM media WHERE deleted_at IS NULL AND ? <%% original_filename`, TypeMedia)
+	default:
+		sql = fmt.Sprintf(`SELECT '%s' AS type, id, name AS label, slug, '' AS url, '' AS status,
+			word_similarity(?, name) + CASE WHEN name ILIKE ? THEN 0.5 ELSE 0 END AS score
+			FROM tags WHERE ? <%% name`, TypeTag)
+	}
+	return sql
+}
+
+// Suggest returns up to Limit titles, media filenames and tag names that are
+// similar to the typed text, best first
+func Suggest(db *gorm.DB, query SuggestQuery) ([]Suggestion, error) {
+	types := query.Types
+	if len(types) == 0 {
+		types = SuggestTypes
+	}
+	parts := make([]string, len(types))
+	var args []interface{}
+	prefix := likeEscaper.Replace(query.Text) + "%"
+	for i, resourceType := range types {
+		if !ValidSuggestType(resourceType) {
+			return nil, fmt.Errorf("unknown suggestion type %q", resourceType)
+		}
+		unpublished := (resourceType == TypePost && query.UnpublishedPosts) || (resourceType == TypePage && query.UnpublishedPages)
+		parts[i] = suggestSQL(resourceType, unpublished)
+		args = append(args, query.Text, prefix, query.Text)
+	}
+	sql := "SELECT * FROM (\n" + strings.Join(parts, "\nUNION ALL\n") + "\n) AS suggestions ORDER BY score DESC, length(label), type, id LIMIT ?"
+	args = append(args, query.Limit)
+
+	suggestions := []Suggestion{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lowering the threshold for this transaction only keeps <% indexable
+		if err := tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)", fmt.Sprint(SuggestThreshold)).Error; err != nil {
+			return err
+		}
+		return tx.Raw(sql, args...).Scan(&suggestions).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// htmlTag matches the tags stripped from content before it is indexed
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// plainText drops HTML tags and collapses whitespace
+func plainText(content string) string {
+	return strings.Join(strings.Fields(htmlTag.ReplaceAllString(content, " ")), " ")
+}
+
+func documentForPost(post *models.Post) Document {
+	return Document{
+		ID: DocumentID(TypePost, post.ID), Type: TypePost, ResourceID: post.ID,
+		Title: post.Title, Slug: post.Slug, Status: post.Status,
+		Body: plainText(post.Content), CreatedAt: post.CreatedAt.Unix(),
+	}
+}
+
+func documentForPage(page *models.Page) Document {
+	return Document{
+		ID: DocumentID(TypePage, page.ID), Type: TypePage, ResourceID: page.ID,
+		Title: page.Title, Slug: page.Slug, Status: page.Status,
+		Body: plainText(page.Content), CreatedAt: page.CreatedAt.Unix(),
+	}
+}
+
+// documentForMedia mirrors the text and title the Postgres search uses for media
+func documentForMedia(media *models.Media) Document {
+	title := media.Title
+	if title == "" {
+		title = media.OriginalFilename
+	}
+	if title == "" {
+		title = media.URL
+	}
+	return Document{
+		ID: DocumentID(TypeMedia, media.ID), Type: TypeMedia, ResourceID: media.ID,
+		Title: title, URL: media.URL,
+		Body:      strings.Join(strings.Fields(strings.Join([]string{media.Title, media.AltText, media.Caption, media.OriginalFilename}, " ")), " "),
+		CreatedAt: media.CreatedAt.Unix(),
+	}
+}
+
+// loadDocument builds the document for a resource from its current row. ok
+// is false when the row is gone or trashed.
+func loadDocument(db *gorm.DB, resourceType string, id uint) (doc Document, ok bool, err error) {
+	switch resourceType {
+	case TypePost:
+		var post models.Post
+		if err = db.Take(&post, id).Error; err == nil {
+			doc = documentForPost(&post)
+		}
+	case TypePage:
+		var page models.Page
+		if err = db.Take(&page, id).Error; err == nil {
+			doc = documentForPage(&page)
+		}
+	default:
+		var media models.Media
+		if err = db.Take(&media, id).Error; err == nil {
+			doc = documentForMedia(&media)
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return doc, false, nil
+	}
+	return doc, err == nil, err
+}
+
+// IndexHandler is the outbox handler keeping an external index in step with
+// the content. Each post, page or media event re-indexes the current row,
+// which also covers status changes, or removes the document once the row is
+// trashed or purged.
+func IndexHandler(indexer Indexer) outbox.Handler {
+	return outbox.Handler{Name: "search", Handle: func(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+		if !ValidType(event.ResourceType) {
+			return nil
+		}
+		doc, ok, err := loadDocument(tx.WithContext(ctx), event.ResourceType, event.ResourceID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return indexer.Delete(ctx, DocumentID(event.ResourceType, event.ResourceID))
+		}
+		return indexer.Index(ctx, doc)
+	}}
+}
+
+// Reindex sends every post, page and media item to the index, loading
+// batchSize rows at a time so memory use does not grow with the corpus.
+// progress, when set, is called after each batch with the number of documents
+// of that type indexed so far. Documents whose rows no longer exist are left
+// in the index.
+func Reindex(ctx context.Context, db *gorm.DB, indexer Indexer, batchSize int, progress func(resourceType string, indexed int)) (int, error) {
+	db = db.WithContext(ctx)
+	total := 0
+	reindex := func(resourceType string, dest interface{}, columns []string, documents func() []Document) error {
+		indexed := 0
+		return db.Select(columns).FindInBatches(dest, batchSize, func(tx *gorm.DB, batch int) error {
+			docs := documents()
+			if err := indexer.Index(ctx, docs...); err != nil {
+				return err
+			}
+			indexed += len(docs)
+			total += len(docs)
+			if progress != nil {
+				progress(resourceType, indexed)
+			}
+			return nil
+		}).Error
+	}
+
+	var posts []models.Post
+	if err := reindex(TypePost, &posts, []string{"id", "title", "slug", "content", "status", "created_at"}, func() []Document {
+		docs := make([]Document, len(posts))
+		for i := range posts {
+			docs[i] = documentForPost(&posts[i])
+		}
+		return docs
+	}); err != nil {
+		return total, err
+	}
+	var pages []models.Page
+	if err := reindex(TypePage, &pages, []string{"id", "title", "slug", "content", "status", "created_at"}, func() []Document {
+		docs := make([]Document, len(pages))
+		for i := range pages {
+			docs[i] = documentForPage(&pages[i])
+		}
+		return docs
+	}); err != nil {
+		return total, err
+	}
+	var media []models.Media
+	err := reindex(TypeMedia, &media, []string{"id", "url", "title", "alt_text", "caption", "original_filename", "created_at"}, func() []Document {
+		docs := make([]Document, len(media))
+		for i := range media {
+			docs[i] = documentForMedia(&media[i])
+		}
+		return docs
+	})
+	return total, err
+}
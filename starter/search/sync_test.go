@@ -0,0 +1,97 @@
+package search
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIndexer keeps the documents it is sent
+type fakeIndexer struct {
+	batches [][]Document
+	deleted []string
+}
+
+func (f *fakeIndexer) Setup(ctx context.Context) error { return nil }
+
+func (f *fakeIndexer) Index(ctx context.Context, docs ...Document) error {
+	f.batches = append(f.batches, docs)
+	return nil
+}
+
+func (f *fakeIndexer) Delete(ctx context.Context, ids ...string) error {
+	f.deleted = append(f.deleted, ids...)
+	return nil
+}
+
+func (f *fakeIndexer) Search(ctx context.Context, query Query) (*Results, error) {
+	return &Results{}, nil
+}
+
+func TestIndexHandler(t *testing.T) {
+	now := time.Now()
+	_, db, mock := utils.SetupRouterAndMockDB(t)
+	indexer := &fakeIndexer{}
+	handler := IndexHandler(indexer)
+
+	mock.ExpectQuery(`SELECT \* FROM "posts" WHERE "posts"\."id" = \$1 AND "posts"\."deleted_at" IS NULL`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "slug", "content", "status", "created_at"}).
+			AddRow(7, "Hello", "hello", "<p>Hello <b>world</b></p>", "draft", now))
+	require.NoError(t, handler.Handle(context.Background(), db, &models.OutboxEvent{ResourceType: models.RevisionResourcePost, ResourceID: 7}))
+
+	mock.ExpectQuery(`SELECT \* FROM "media" WHERE "media"\."id" = \$1 AND "media"\."deleted_at" IS NULL`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	require.NoError(t, handler.Handle(context.Background(), db, &models.OutboxEvent{ResourceType: models.AuditResourceMedia, ResourceID: 3}))
+
+	// Tags are not indexed
+	require.NoError(t, handler.Handle(context.Background(), db, &models.OutboxEvent{ResourceType: "tag", ResourceID: 1}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, indexer.batches, 1)
+	assert.Equal(t, []Document{{ID: "post-7", Type: "post", ResourceID: 7, Title: "Hello", Slug: "hello", Status: "draft",
+		Body: "Hello world", CreatedAt: now.Unix()}}, indexer.batches[0])
+	assert.Equal(t, []string{"media-3"}, indexer.deleted, "trashed or purged rows leave the index")
+}
+
+func TestReindex(t *testing.T) {
+	now := time.Now()
+	_, db, mock := utils.SetupRouterAndMockDB(t)
+	indexer := &fakeIndexer{}
+
+	columns := []string{"id", "title", "slug", "content", "status", "created_at"}
+	mock.ExpectQuery(`SELECT "id","title","slug","content","status","created_at" FROM "posts" WHERE "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "One", "one", "1", "published", now).AddRow(2, "Two", "two", "2", "draft", now))
+	mock.ExpectQuery(`FROM "posts" WHERE "posts"\."id" > \$1 AND "posts"\."deleted_at" IS NULL ORDER BY "posts"\."id" LIMIT \$2`).
+		WithArgs(2, 2).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, "Five", "five", "5", "published", now))
+	mock.ExpectQuery(`FROM "pages" WHERE "pages"\."deleted_at" IS NULL ORDER BY "pages"\."id" LIMIT \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(`SELECT "id","url","title","alt_text","caption","original_filename","created_at" FROM "media"`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "title", "original_filename", "created_at"}).
+			AddRow(9, "/uploads/cat.jpg", "", "cat.jpg", now))
+
+	var progress []int
+	total, err := Reindex(context.Background(), db, indexer, 2, func(resourceType string, indexed int) {
+		progress = append(progress, indexed)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []int{2, 3, 1}, progress)
+	require.Len(t, indexer.batches, 3)
+	assert.Len(t, indexer.batches[0], 2)
+	assert.Equal(t, "post-5", indexer.batches[1][0].ID)
+	assert.Equal(t, Document{ID: "media-9", Type: "media", ResourceID: 9, Title: "cat.jpg", URL: "/uploads/cat.jpg",
+		Body: "cat.jpg", CreatedAt: now.Unix()}, indexer.batches[2][0])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalDriver keeps objects as files under a root directory
+type LocalDriver struct {
+	root      string
+	publicURL string
+}
+
+// NewLocal creates the root directory if needed. With publicURL set (e.g. a
+// CDN or an nginx location serving root), clients are sent there instead of
+// having the API stream the file.
+func NewLocal(root, publicURL string) (*LocalDriver, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalDriver{root: root, publicURL: publicURL}, nil
+}
+
+func (d *LocalDriver) Name() string {
+	return "local"
+}
+
+// path maps a key into root, refusing anything that would escape it
+func (d *LocalDriver) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(d.root, filepath.FromSlash(clean)), nil
+}
+
+func (d *LocalDriver) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	target, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	// Write next to the target and rename, so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("short write: stored %d of %d bytes", written, size)
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (d *LocalDriver) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	target, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (d *LocalDriver) Delete(ctx context.Context, key string) error {
+	target, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (d *LocalDriver) URL(ctx context.Context, key string) (string, error) {
+	if d.publicURL == "" {
+		return "", nil
+	}
+	return joinURL(d.publicURL, key), nil
+}
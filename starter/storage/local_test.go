@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalDriver(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	driver, err := NewLocal(root, "")
+	require.NoError(t, err)
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		require.NoError(t, driver.Put(ctx, "2024/05/a.txt", strings.NewReader("hello"), 5, "text/plain"))
+		assert.FileExists(t, filepath.Join(root, "2024", "05", "a.txt"))
+
+		object, err := driver.Open(ctx, "2024/05/a.txt")
+		require.NoError(t, err)
+		data, _ := io.ReadAll(object)
+		object.Close()
+		assert.Equal(t, "hello", string(data))
+
+		url, err := driver.URL(ctx, "2024/05/a.txt")
+		require.NoError(t, err)
+		assert.Empty(t, url, "files are streamed by the API without a public URL")
+
+		require.NoError(t, driver.Delete(ctx, "2024/05/a.txt"))
+		_, err = driver.Open(ctx, "2024/05/a.txt")
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.NoError(t, driver.Delete(ctx, "2024/05/a.txt"), "deleting twice is not an error")
+	})
+
+	t.Run("ShortWrite", func(t *testing.T) {
+		err := driver.Put(ctx, "short.txt", strings.NewReader("abc"), 10, "text/plain")
+		assert.Error(t, err)
+		_, statErr := os.Stat(filepath.Join(root, "short.txt"))
+		assert.True(t, os.IsNotExist(statErr), "a failed write must not leave a file behind")
+	})
+
+	t.Run("StaysInsideRoot", func(t *testing.T) {
+		require.NoError(t, driver.Put(ctx, "../../escape.txt", strings.NewReader("x"), 1, "text/plain"))
+		assert.FileExists(t, filepath.Join(root, "escape.txt"))
+		assert.Error(t, driver.Put(ctx, "..", strings.NewReader("x"), 1, "text/plain"))
+	})
+
+	t.Run("PublicURL", func(t *testing.T) {
+		public, err := NewLocal(root, "https://cdn.example.com/media/")
+		require.NoError(t, err)
+		url, err := public.URL(ctx, "2024/05/a.png")
+		require.NoError(t, err)
+		assert.Equal(t, "https://cdn.example.com/media/2024/05/a.png", url)
+	})
+}
+
+func TestRemove(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	driver, err := NewLocal(root, "")
+	require.NoError(t, err)
+	SetDefault(driver)
+	t.Cleanup(func() { SetDefault(nil) })
+	for _, name := range []string{"a.png", "a-w150.png"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name), []byte("png"), 0o644))
+	}
+
+	Remove(ctx, "s3", "a.png")
+	assert.FileExists(t, filepath.Join(root, "a.png"), "objects of another driver are left alone")
+
+	Remove(ctx, "local", "a.png", "a-w150.png")
+	assert.NoFileExists(t, filepath.Join(root, "a.png"))
+	assert.NoFileExists(t, filepath.Join(root, "a-w150.png"))
+}
+
+func TestNewKey(t *testing.T) {
+	now := mustParseTime(t, "2024-05-17T10:00:00Z")
+	key, err := NewKey(now, "image/jpeg")
+	require.NoError(t, err)
+	assert.Regexp(t, `^2024/05/[0-9a-f]{32}\.jpg$`, key)
+
+	other, err := NewKey(now, "application/x-unknown")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+	assert.Regexp(t, `^2024/05/[0-9a-f]{32}$`, other)
+}
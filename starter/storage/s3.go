@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config describes an S3-compatible bucket (AWS S3, MinIO, R2, ...)
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	// PublicURL, when set, is a base URL the bucket is publicly readable at;
+	// otherwise clients get a presigned URL valid for PresignTTL
+	PublicURL  string
+	PresignTTL time.Duration
+	// Transport overrides the HTTP transport, mainly for tests
+	Transport http.RoundTripper
+}
+
+// S3Driver stores objects in a bucket through the S3 API
+type S3Driver struct {
+	client     *minio.Client
+	bucket     string
+	publicURL  string
+	presignTTL time.Duration
+}
+
+// NewS3 creates the client without contacting the server; a missing bucket
+// shows up on the first upload
+func NewS3(cfg S3Config) (*S3Driver, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required for the s3 storage driver")
+	}
+	if cfg.Region == "" {
+		// A fixed region skips the GetBucketLocation round trip before each request
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PresignTTL <= 0 {
+		cfg.PresignTTL = 15 * time.Minute
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:    cfg.UseSSL,
+		Region:    cfg.Region,
+		Transport: cfg.Transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	return &S3Driver{client: client, bucket: cfg.Bucket, publicURL: cfg.PublicURL, presignTTL: cfg.PresignTTL}, nil
+}
+
+func (d *S3Driver) Name() string {
+	return "s3"
+}
+
+func (d *S3Driver) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := d.client.PutObject(ctx, d.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (d *S3Driver) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	object, err := d.client.GetObject(ctx, d.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat makes the request so a missing key fails here
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (d *S3Driver) Delete(ctx context.Context, key string) error {
+	return d.client.RemoveObject(ctx, d.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (d *S3Driver) URL(ctx context.Context, key string) (string, error) {
+	if d.publicURL != "" {
+		return joinURL(d.publicURL, key), nil
+	}
+	signed, err := d.client.PresignedGetObject(ctx, d.bucket, key, d.presignTTL, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return signed.String(), nil
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal in-memory stand-in for MinIO covering the object calls
+// the driver makes. Signatures are not checked.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[name] = data
+		f.types[name] = r.Header.Get("Content-Type")
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[name]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Content-Type", f.types[name])
+		http.ServeContent(w, r, "", time.Unix(1700000000, 0), bytes.NewReader(data))
+	case http.MethodDelete:
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newFakeS3Driver(t *testing.T, publicURL string) (*S3Driver, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+	driver, err := NewS3(S3Config{
+		Endpoint:   strings.TrimPrefix(server.URL, "https://"),
+		Bucket:     "media",
+		AccessKey:  "access",
+		SecretKey:  "secret",
+		UseSSL:     true,
+		PublicURL:  publicURL,
+		PresignTTL: time.Minute,
+		Transport:  server.Client().Transport,
+	})
+	require.NoError(t, err)
+	return driver, fake
+}
+
+func TestS3Driver(t *testing.T) {
+	ctx := context.Background()
+	driver, fake := newFakeS3Driver(t, "")
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		body := append(append([]byte{}, pngHeader...), "pixels"...)
+		require.NoError(t, driver.Put(ctx, "2024/05/a.png", bytes.NewReader(body), int64(len(body)), "image/png"))
+		assert.Equal(t, body, fake.objects["media/2024/05/a.png"])
+		assert.Equal(t, "image/png", fake.types["media/2024/05/a.png"])
+
+		object, err := driver.Open(ctx, "2024/05/a.png")
+		require.NoError(t, err)
+		defer object.Close()
+		_, err = object.Seek(int64(len(pngHeader)), io.SeekStart)
+		require.NoError(t, err)
+		rest, err := io.ReadAll(object)
+		require.NoError(t, err)
+		assert.Equal(t, "pixels", string(rest))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := driver.Open(ctx, "2024/05/missing.png")
+		assert.True(t, errors.Is(err, ErrNotFound), "got %v", err)
+	})
+
+	t.Run("PresignedURL", func(t *testing.T) {
+		location, err := driver.URL(ctx, "2024/05/a.png")
+		require.NoError(t, err)
+		parsed, err := url.Parse(location)
+		require.NoError(t, err)
+		assert.Equal(t, "/media/2024/05/a.png", parsed.Path)
+		assert.NotEmpty(t, parsed.Query().Get("X-Amz-Signature"))
+		assert.Equal(t, "60", parsed.Query().Get("X-Amz-Expires"))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, driver.Delete(ctx, "2024/05/a.png"))
+		assert.NotContains(t, fake.objects, "media/2024/05/a.png")
+	})
+}
+
+func TestS3DriverPublicURL(t *testing.T) {
+	driver, _ := newFakeS3Driver(t, "https://media.example.com")
+	location, err := driver.URL(context.Background(), "2024/05/a.png")
+	require.NoError(t, err)
+	assert.Equal(t, "https://media.example.com/2024/05/a.png", location)
+}
+
+func TestNewS3RequiresBucket(t *testing.T) {
+	_, err := NewS3(S3Config{Endpoint: "localhost:9000"})
+	assert.Error(t, err)
+}
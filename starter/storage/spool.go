@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// ErrTooLarge is returned by Spool when the upload exceeds its limit
+var ErrTooLarge = errors.New("storage: upload exceeds the size limit")
+
+// sniffLen is how much of the upload is inspected to detect its type
+const sniffLen = 3072
+
+// Spooled is an upload buffered to a temporary file, with the facts about it
+// that can only be known after reading it
+type Spooled struct {
+	File        *os.File
+	Size        int64
+	Checksum    string
+	ContentType string
+}
+
+// Spool copies r to a temporary file while hashing it and sniffing its
+// content type from the leading bytes. Uploads over limit bytes fail with
+// ErrTooLarge. The caller must Close the result.
+func Spool(r io.Reader, limit int64) (*Spooled, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	file, err := os.CreateTemp("", "cms-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &Spooled{File: file, ContentType: baseType(mimetype.Detect(head).String())}
+	hasher := sha256.New()
+	body := io.MultiReader(bytes.NewReader(head), io.LimitReader(r, limit-int64(n)+1))
+	size, err := io.Copy(io.MultiWriter(file, hasher), body)
+	if err == nil && size > limit {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	spooled.Size = size
+	spooled.Checksum = hex.EncodeToString(hasher.Sum(nil))
+	return spooled, nil
+}
+
+// Rehash recomputes the checksum after the file was modified in place and
+// rewinds it
+func (s *Spooled) Rehash() error {
+	if _, err := s.File.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, s.File); err != nil {
+		return err
+	}
+	s.Checksum = hex.EncodeToString(hasher.Sum(nil))
+	_, err := s.File.Seek(0, io.SeekStart)
+	return err
+}
+
+// Close removes the temporary file
+func (s *Spooled) Close() error {
+	s.File.Close()
+	return os.Remove(s.File.Name())
+}
+
+func baseType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return contentType
+}
+
+// TypeAllowed matches a content type against patterns such as "image/png"
+// or "video/*"
+func TypeAllowed(contentType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "*" || pattern == contentType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(contentType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngHeader is enough of a PNG file for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestSpool(t *testing.T) {
+	t.Run("SniffsAndHashes", func(t *testing.T) {
+		body := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{1}, 5000)...)
+		spooled, err := Spool(bytes.NewReader(body), 1<<20)
+		require.NoError(t, err)
+		defer spooled.Close()
+
+		sum := sha256.Sum256(body)
+		assert.Equal(t, "image/png", spooled.ContentType)
+		assert.Equal(t, int64(len(body)), spooled.Size)
+		assert.Equal(t, hex.EncodeToString(sum[:]), spooled.Checksum)
+
+		stored, err := io.ReadAll(spooled.File)
+		require.NoError(t, err)
+		assert.Equal(t, body, stored)
+	})
+
+	t.Run("IgnoresClaimedType", func(t *testing.T) {
+		spooled, err := Spool(strings.NewReader("<html><script>alert(1)</script></html>"), 1<<20)
+		require.NoError(t, err)
+		defer spooled.Close()
+		assert.Equal(t, "text/html", spooled.ContentType)
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		_, err := Spool(bytes.NewReader(make([]byte, 4097)), 4096)
+		assert.ErrorIs(t, err, ErrTooLarge)
+
+		spooled, err := Spool(bytes.NewReader(make([]byte, 4096)), 4096)
+		require.NoError(t, err)
+		spooled.Close()
+	})
+
+	t.Run("Rehash", func(t *testing.T) {
+		spooled, err := Spool(strings.NewReader("data"), 1<<20)
+		require.NoError(t, err)
+		defer spooled.Close()
+		_, err = spooled.File.WriteAt([]byte("D"), 0)
+		require.NoError(t, err)
+
+		require.NoError(t, spooled.Rehash())
+		sum := sha256.Sum256([]byte("Data"))
+		assert.Equal(t, hex.EncodeToString(sum[:]), spooled.Checksum)
+		stored, err := io.ReadAll(spooled.File)
+		require.NoError(t, err)
+		assert.Equal(t, "Data", string(stored), "the file is rewound")
+	})
+
+	t.Run("CloseRemovesFile", func(t *testing.T) {
+		spooled, err := Spool(strings.NewReader("data"), 1<<20)
+		require.NoError(t, err)
+		name := spooled.File.Name()
+		require.NoError(t, spooled.Close())
+		_, err = os.Stat(name)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestTypeAllowed(t *testing.T) {
+	patterns := []string{"image/png", " video/*", "application/pdf"}
+	assert.True(t, TypeAllowed("image/png", patterns))
+	assert.True(t, TypeAllowed("video/mp4", patterns))
+	assert.False(t, TypeAllowed("image/svg+xml", patterns))
+	assert.False(t, TypeAllowed("videox/mp4", patterns))
+	assert.True(t, TypeAllowed("text/html", []string{"*"}))
+}
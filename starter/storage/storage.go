@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"cms-backend/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Open when the key does not exist
+var ErrNotFound = errors.New("storage: object not found")
+
+// Driver stores uploaded media files under opaque keys
+type Driver interface {
+	// Name identifies the driver in models.Media.StorageDriver
+	Name() string
+	// Put stores size bytes read from r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the stored object for streaming
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes the object; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns a location clients can fetch the object from directly, or ""
+	// when it has to be streamed through the API
+	URL(ctx context.Context, key string) (string, error)
+}
+
+var (
+	defaultDriver   Driver
+	defaultDriverMu sync.RWMutex
+)
+
+// SetDefault installs the driver used by the media controllers
+func SetDefault(driver Driver) {
+	defaultDriverMu.Lock()
+	defer defaultDriverMu.Unlock()
+	defaultDriver = driver
+}
+
+// Default returns the configured driver, or nil when uploads are disabled
+func Default() Driver {
+	defaultDriverMu.RLock()
+	defer defaultDriverMu.RUnlock()
+	return defaultDriver
+}
+
+// Remove deletes objects stored by the named driver, such as an uploaded
+// file and its renditions once the media row is gone. A failure only leaves an
+// orphaned object behind, so it is logged rather than returned.
+func Remove(ctx context.Context, driverName string, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	driver := Default()
+	if driver == nil || driver.Name() != driverName {
+		log.Printf("No %q storage driver to remove %s", driverName, keys[0])
+		return
+	}
+	for _, key := range keys {
+		if err := driver.Delete(ctx, key); err != nil {
+			log.Printf("Failed to remove stored file %s: %v", key, err)
+		}
+	}
+}
+
+// NewKey builds a unique key such as "2024/05/3f9a...c1.png" for a new upload
+func NewKey(now time.Time, contentType string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate storage key: %w", err)
+	}
+	return path.Join(now.UTC().Format("2006/01"), hex.EncodeToString(buf)) + extensionFor(contentType), nil
+}
+
+func extensionFor(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "text/plain":
+		return ".txt"
+	}
+	if exts, err := mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// FromEnv configures a driver from STORAGE_DRIVER ("local" or "s3") and the
+// matching STORAGE_* / S3_* variables
+func FromEnv() (Driver, error) {
+	publicURL := os.Getenv("STORAGE_PUBLIC_URL")
+	switch driver := strings.ToLower(os.Getenv("STORAGE_DRIVER")); driver {
+	case "", "local":
+		root := os.Getenv("STORAGE_LOCAL_PATH")
+		if root == "" {
+			root = "./uploads"
+		}
+		return NewLocal(root, publicURL)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:   os.Getenv("S3_ENDPOINT"),
+			Region:     os.Getenv("S3_REGION"),
+			Bucket:     os.Getenv("S3_BUCKET"),
+			AccessKey:  os.Getenv("S3_ACCESS_KEY"),
+			SecretKey:  os.Getenv("S3_SECRET_KEY"),
+			UseSSL:     os.Getenv("S3_USE_SSL") != "false",
+			PublicURL:  publicURL,
+			PresignTTL: utils.DurationFromEnv("S3_PRESIGN_TTL", 15*time.Minute),
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
+
+// joinURL appends an object key to a public base URL
+func joinURL(base, key string) string {
+	return strings.TrimRight(base, "/") + "/" + key
+}
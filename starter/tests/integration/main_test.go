@@ -3,7 +3,9 @@ package integration
 import (
 	"cms-backend/models"
 	"cms-backend/routes"
+	"cms-backend/utils"
 	"log"
+	"net/http"
 	"os"
 	"testing"
 
@@ -13,8 +15,9 @@ import (
 )
 
 var (
-	testDB *gorm.DB
-	router *gin.Engine
+	testDB    *gorm.DB
+	router    *gin.Engine
+	authToken string
 )
 
 // TODO: Import required packages for:
@@ -76,19 +79,46 @@ func setup() {
 	//   * Page
 	//   * Post
 	//   * Any join tables
-	if err := testDB.AutoMigrate(&models.Media{}, &models.Page{}, &models.Post{}); err != nil {
+	if err := testDB.AutoMigrate(&models.Media{}, &models.Page{}, &models.Post{}, &models.User{}, &models.RevokedToken{}, &models.Revision{}, &models.SlugRedirect{}, &models.Category{}, &models.Tag{}, &models.MediaRendition{}); err != nil {
 		log.Fatalf("Failed to migrate schemas: %v", err)
 	}
 	// Migrate join table for Post-Media
 	if err := testDB.SetupJoinTable(&models.Post{}, "Media", &models.PostMedia{}); err != nil {
 		log.Fatalf("Failed to setup join table: %v", err)
 	}
+	if err := testDB.SetupJoinTable(&models.Post{}, "Categories", &models.PostCategory{}); err != nil {
+		log.Fatalf("Failed to setup join table: %v", err)
+	}
+	if err := testDB.SetupJoinTable(&models.Post{}, "Tags", &models.PostTag{}); err != nil {
+		log.Fatalf("Failed to setup join table: %v", err)
+	}
 
 	// STEP 4: Router Setup
 	// - Initialize Gin router
 	// - Set up routes with test database
 	router = gin.New()
 	routes.InitializeRoutes(router, testDB)
+
+	// STEP 5: Authentication
+	// - Create a test user and issue an access token for mutating requests
+	user := models.User{Username: "integration", Email: "integration@example.com", Role: models.RoleAdmin}
+	if err := user.SetPassword("integration"); err != nil {
+		log.Fatalf("Failed to hash test password: %v", err)
+	}
+	if err := testDB.Where(models.User{Username: user.Username}).FirstOrCreate(&user).Error; err != nil {
+		log.Fatalf("Failed to create test user: %v", err)
+	}
+	tokens, err := utils.GenerateTokenPair(user.ID, user.Username, user.Role)
+	if err != nil {
+		log.Fatalf("Failed to issue test token: %v", err)
+	}
+	authToken = tokens.AccessToken
+}
+
+// authorize attaches the test user's access token to a request
+func authorize(req *http.Request) *http.Request {
+	req.Header.Set("Authorization", "Bearer "+authToken)
+	return req
 }
 
 func cleanup() {
@@ -104,11 +134,13 @@ func cleanup() {
 		return
 	}
 
-	if err := testDB.Migrator().DropTable("post_media"); err != nil {
-		log.Printf("Failed to drop post_media: %v", err)
+	for _, tbl := range []string{"post_media", "post_categories", "post_tags"} {
+		if err := testDB.Migrator().DropTable(tbl); err != nil {
+			log.Printf("Failed to drop %s: %v", tbl, err)
+		}
 	}
 
-	for _, tbl := range []string{"posts", "media", "pages"} {
+	for _, tbl := range []string{"posts", "media", "pages", "categories", "tags", "revisions", "slug_redirects", "revoked_tokens", "users"} {
 		if err := testDB.Migrator().DropTable(tbl); err != nil {
 			log.Printf("Failed to drop table %s: %v", tbl, err)
 		}
@@ -129,9 +161,16 @@ func clearTables() {
 	// - Maintain referential integrity
 	// Delete data in correct order
 	testDB.Exec("DELETE FROM post_media")
+	testDB.Exec("DELETE FROM post_categories")
+	testDB.Exec("DELETE FROM post_tags")
 	testDB.Exec("DELETE FROM posts")
+	testDB.Exec("DELETE FROM media_renditions")
 	testDB.Exec("DELETE FROM media")
 	testDB.Exec("DELETE FROM pages")
+	testDB.Exec("DELETE FROM categories")
+	testDB.Exec("DELETE FROM tags")
+	testDB.Exec("DELETE FROM revisions")
+	testDB.Exec("DELETE FROM slug_redirects")
 }
 
 /*
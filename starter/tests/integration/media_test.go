@@ -18,7 +18,7 @@ func TestMediaIntegration(t *testing.T) {
             "url": "http://example.com/test.jpg",
             "type": "image"
         }`
-		req := httptest.NewRequest("POST", "/api/v1/media", strings.NewReader(body))
+		req := authorize(httptest.NewRequest("POST", "/api/v1/media", strings.NewReader(body)))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
@@ -56,7 +56,7 @@ func TestMediaIntegration(t *testing.T) {
 
 	t.Run("Get Media By ID", func(t *testing.T) {
 		body := `{"url": "http://example.com/unique.jpg", "type": "image"}`
-		req := httptest.NewRequest("POST", "/api/v1/media", strings.NewReader(body))
+		req := authorize(httptest.NewRequest("POST", "/api/v1/media", strings.NewReader(body)))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
@@ -80,14 +80,14 @@ func TestMediaIntegration(t *testing.T) {
 
 	t.Run("Delete Media", func(t *testing.T) {
 		body := `{"url": "http://example.com/delete.jpg", "type": "image"}`
-		req := httptest.NewRequest("POST", "/api/v1/media", strings.NewReader(body))
+		req := authorize(httptest.NewRequest("POST", "/api/v1/media", strings.NewReader(body)))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		var created models.Media
 		json.Unmarshal(w.Body.Bytes(), &created)
 
-		req = httptest.NewRequest("DELETE", "/api/v1/media/"+strconv.Itoa(int(created.ID)), nil)
+		req = authorize(httptest.NewRequest("DELETE", "/api/v1/media/"+strconv.Itoa(int(created.ID)), nil))
 		w = httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		if w.Code != http.StatusOK {
@@ -21,7 +21,7 @@ func TestPostIntegration(t *testing.T) {
             "author": "Tester",
             "media_ids": [` + fmt.Sprintf("%d", mediaID) + `]
         }`
-		req := httptest.NewRequest("POST", "/api/v1/posts", strings.NewReader(body))
+		req := authorize(httptest.NewRequest("POST", "/api/v1/posts", strings.NewReader(body)))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
@@ -35,6 +35,16 @@ func TestPostIntegration(t *testing.T) {
 		if response.Title != "Test Post" || len(response.Media) == 0 {
 			t.Errorf("Expected media attached to post")
 		}
+		if response.Status != models.StatusDraft {
+			t.Errorf("Expected new post to be a draft, got %s", response.Status)
+		}
+
+		req = authorize(httptest.NewRequest("POST", fmt.Sprintf("/api/v1/posts/%d/publish", response.ID), nil))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 on publish, got %d: %s", w.Code, w.Body.String())
+		}
 	})
 
 	t.Run("Get Posts with Filter", func(t *testing.T) {
@@ -67,7 +77,7 @@ func createTestMedia(t *testing.T) uint {
         "url": "http://example.com/test.jpg",
         "type": "image"
     }`
-	req := httptest.NewRequest("POST", "/api/v1/media", strings.NewReader(body))
+	req := authorize(httptest.NewRequest("POST", "/api/v1/media", strings.NewReader(body)))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
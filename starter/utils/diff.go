@@ -0,0 +1,128 @@
+package utils
+
+import "strings"
+
+// Diff operations
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffLine is one line of a line-level diff
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// DiffLines returns the shortest line-level edit script that turns a into b
+func DiffLines(a, b string) []DiffLine {
+	return diffSlices(splitLines(a), splitLines(b))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func diffSlices(a, b []string) []DiffLine {
+	result := []DiffLine{}
+
+	// Common prefix and suffix never need the full search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, line := range a[:prefix] {
+		result = append(result, DiffLine{Op: DiffEqual, Text: line})
+	}
+	result = append(result, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		result = append(result, DiffLine{Op: DiffEqual, Text: line})
+	}
+	return result
+}
+
+// myers implements Myers' O((N+M)D) diff. After each round d it keeps the
+// furthest-reaching x for diagonals -d..d, which is all the backtrack needs.
+func myers(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var rounds [][]int
+
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+		}
+		round := make([]int, 2*d+1)
+		copy(round, v[offset-d:offset+d+1])
+		rounds = append(rounds, round)
+		if v[offset+n-m] >= n && (n-m+d)%2 == 0 && n-m >= -d && n-m <= d {
+			return backtrack(rounds, a, b)
+		}
+	}
+	return nil
+}
+
+func backtrack(rounds [][]int, a, b []string) []DiffLine {
+	x, y := len(a), len(b)
+	var reversed []DiffLine
+	for d := len(rounds) - 1; d > 0; d-- {
+		prev := rounds[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffLine{Op: DiffEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, DiffLine{Op: DiffInsert, Text: b[y-1]})
+		} else {
+			reversed = append(reversed, DiffLine{Op: DiffDelete, Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, DiffLine{Op: DiffEqual, Text: a[x-1]})
+		x--
+		y--
+	}
+
+	result := make([]DiffLine, len(reversed))
+	for i, line := range reversed {
+		result[len(reversed)-1-i] = line
+	}
+	return result
+}
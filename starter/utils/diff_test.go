@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// applyDiff rebuilds both sides of a diff so tests can check it is lossless
+func applyDiff(lines []DiffLine) (string, string) {
+	var a, b []string
+	for _, line := range lines {
+		switch line.Op {
+		case DiffEqual:
+			a = append(a, line.Text)
+			b = append(b, line.Text)
+		case DiffDelete:
+			a = append(a, line.Text)
+		case DiffInsert:
+			b = append(b, line.Text)
+		}
+	}
+	return strings.Join(a, "\n"), strings.Join(b, "\n")
+}
+
+func countEdits(lines []DiffLine) int {
+	edits := 0
+	for _, line := range lines {
+		if line.Op != DiffEqual {
+			edits++
+		}
+	}
+	return edits
+}
+
+// lcsLength is the textbook dynamic-programming LCS, used as a reference
+func lcsLength(a, b []string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				table[i][j] = table[i-1][j-1] + 1
+			} else if table[i-1][j] > table[i][j-1] {
+				table[i][j] = table[i-1][j]
+			} else {
+				table[i][j] = table[i][j-1]
+			}
+		}
+	}
+	return table[len(a)][len(b)]
+}
+
+func TestDiffLines(t *testing.T) {
+	t.Run("Identical", func(t *testing.T) {
+		diff := DiffLines("a\nb\nc", "a\nb\nc")
+		assert.Equal(t, 0, countEdits(diff))
+		assert.Len(t, diff, 3)
+	})
+
+	t.Run("BothEmpty", func(t *testing.T) {
+		assert.Empty(t, DiffLines("", ""))
+	})
+
+	t.Run("FromEmpty", func(t *testing.T) {
+		diff := DiffLines("", "a\nb")
+		assert.Equal(t, []DiffLine{{Op: DiffInsert, Text: "a"}, {Op: DiffInsert, Text: "b"}}, diff)
+	})
+
+	t.Run("ChangedLine", func(t *testing.T) {
+		diff := DiffLines("one\ntwo\nthree", "one\n2\nthree")
+		assert.Equal(t, []DiffLine{
+			{Op: DiffEqual, Text: "one"},
+			{Op: DiffDelete, Text: "two"},
+			{Op: DiffInsert, Text: "2"},
+			{Op: DiffEqual, Text: "three"},
+		}, diff)
+	})
+
+	t.Run("MinimalEditScript", func(t *testing.T) {
+		// The classic example from Myers' paper has an edit distance of 5
+		diff := DiffLines("A\nB\nC\nA\nB\nB\nA", "C\nB\nA\nB\nA\nC")
+		assert.Equal(t, 5, countEdits(diff))
+		a, b := applyDiff(diff)
+		assert.Equal(t, "A\nB\nC\nA\nB\nB\nA", a)
+		assert.Equal(t, "C\nB\nA\nB\nA\nC", b)
+	})
+
+	t.Run("IgnoresLineEndings", func(t *testing.T) {
+		diff := DiffLines("a\r\nb\r\n", "a\nb")
+		assert.Equal(t, 0, countEdits(diff))
+	})
+
+	t.Run("RandomInputsRoundTrip", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		randomText := func() string {
+			lines := make([]string, rng.Intn(30))
+			for i := range lines {
+				lines[i] = string(rune('a' + rng.Intn(4)))
+			}
+			return strings.Join(lines, "\n")
+		}
+		for i := 0; i < 200; i++ {
+			before, after := randomText(), randomText()
+			diff := DiffLines(before, after)
+			a, b := applyDiff(diff)
+			assert.Equal(t, before, a)
+			assert.Equal(t, after, b)
+
+			beforeLines, afterLines := splitLines(before), splitLines(after)
+			minimal := len(beforeLines) + len(afterLines) - 2*lcsLength(beforeLines, afterLines)
+			assert.Equal(t, minimal, countEdits(diff))
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// DurationFromEnv reads a positive duration such as "90s" or "15m" from the
+// environment; a bare integer is taken as seconds
+func DurationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationFromEnv(t *testing.T) {
+	os.Setenv("TEST_TTL", "30m")
+	defer os.Unsetenv("TEST_TTL")
+	assert.Equal(t, 30*time.Minute, DurationFromEnv("TEST_TTL", time.Minute))
+
+	os.Setenv("TEST_TTL", "90")
+	assert.Equal(t, 90*time.Second, DurationFromEnv("TEST_TTL", time.Minute))
+
+	os.Setenv("TEST_TTL", "bogus")
+	assert.Equal(t, time.Minute, DurationFromEnv("TEST_TTL", time.Minute))
+}
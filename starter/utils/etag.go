@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceETag builds the strong ETag of a versioned row, e.g. "post-12-v3"
+func ResourceETag(resource string, id uint, version int) string {
+	return fmt.Sprintf(`"%s-%d-v%d"`, resource, id, version)
+}
+
+// ETagMatches reports whether an If-Match style header matches etag using the
+// strong comparison, so weak validators never match
+func ETagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ETagMatchesWeak reports whether an If-None-Match style header matches etag
+// using the weak comparison, which ignores the W/ prefix on either side
+func ETagMatchesWeak(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
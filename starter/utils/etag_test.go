@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceETag(t *testing.T) {
+	assert.Equal(t, `"post-12-v3"`, ResourceETag("post", 12, 3))
+	assert.NotEqual(t, ResourceETag("post", 12, 3), ResourceETag("page", 12, 3))
+}
+
+func TestETagMatches(t *testing.T) {
+	etag := ResourceETag("post", 1, 2)
+
+	assert.True(t, ETagMatches(etag, etag))
+	assert.True(t, ETagMatches("*", etag))
+	assert.True(t, ETagMatches(`"post-1-v1", `+etag, etag))
+	assert.False(t, ETagMatches(`"post-1-v1"`, etag))
+	assert.False(t, ETagMatches("W/"+etag, etag))
+	assert.False(t, ETagMatches("", etag))
+}
+
+func TestETagMatchesWeak(t *testing.T) {
+	etag := `W/"abc"`
+
+	assert.True(t, ETagMatchesWeak(etag, etag))
+	assert.True(t, ETagMatchesWeak(`"abc"`, etag))
+	assert.True(t, ETagMatchesWeak(`"xyz", W/"abc"`, etag))
+	assert.True(t, ETagMatchesWeak("*", etag))
+	assert.False(t, ETagMatchesWeak(`"xyz"`, etag))
+	assert.False(t, ETagMatchesWeak("", etag))
+}
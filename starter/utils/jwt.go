@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
+// Claims is the payload carried by access and refresh tokens
+type Claims struct {
+	UserID    uint   `json:"uid"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	TokenType string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair is returned to clients on login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"900"`
+}
+
+var (
+	jwtSecret     []byte
+	jwtSecretOnce sync.Once
+)
+
+func getJWTSecret() []byte {
+	jwtSecretOnce.Do(func() {
+		if secret := os.Getenv("JWT_SECRET"); secret != "" {
+			jwtSecret = []byte(secret)
+			return
+		}
+		log.Println("JWT_SECRET is not set, generating a random secret; tokens will not survive a restart")
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			log.Fatalf("Failed to generate JWT secret: %v", err)
+		}
+		jwtSecret = buf
+	})
+	return jwtSecret
+}
+
+// AccessTokenTTL is the lifetime of access tokens (JWT_ACCESS_TTL, default 15m)
+func AccessTokenTTL() time.Duration {
+	return DurationFromEnv("JWT_ACCESS_TTL", 15*time.Minute)
+}
+
+// RefreshTokenTTL is the lifetime of refresh tokens (JWT_REFRESH_TTL, default 7 days)
+func RefreshTokenTTL() time.Duration {
+	return DurationFromEnv("JWT_REFRESH_TTL", 7*24*time.Hour)
+}
+
+func newTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func signToken(userID uint, username, role, tokenType string, ttl time.Duration) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	now := time.Now()
+	claims := Claims{
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(getJWTSecret())
+}
+
+// GenerateTokenPair issues a signed access and refresh token for the user.
+// The role in the claims is only informational: AuthRequired reloads the
+// user's role and password change time on every request.
+func GenerateTokenPair(userID uint, username, role string) (*TokenPair, error) {
+	accessTTL := AccessTokenTTL()
+	accessToken, err := signToken(userID, username, role, AccessTokenType, accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := signToken(userID, username, role, RefreshTokenType, RefreshTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTTL.Seconds()),
+	}, nil
+}
+
+// ParseToken verifies the signature and expiry of a token and checks that it
+// is of the expected type
+func ParseToken(tokenString, expectedType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return getJWTSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != expectedType {
+		return nil, errors.New("unexpected token type")
+	}
+	if claims.ID == "" {
+		return nil, errors.New("token has no id")
+	}
+	return claims, nil
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	os.Exit(m.Run())
+}
+
+func TestGenerateTokenPair(t *testing.T) {
+	t.Run("IssuesAccessAndRefreshTokens", func(t *testing.T) {
+		pair, err := GenerateTokenPair(7, "alice", "editor")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, pair.AccessToken)
+		assert.NotEmpty(t, pair.RefreshToken)
+		assert.Equal(t, "Bearer", pair.TokenType)
+		assert.Equal(t, int64(AccessTokenTTL().Seconds()), pair.ExpiresIn)
+
+		access, err := ParseToken(pair.AccessToken, AccessTokenType)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(7), access.UserID)
+		assert.Equal(t, "alice", access.Username)
+		assert.Equal(t, "editor", access.Role)
+		assert.Equal(t, "7", access.Subject)
+
+		refresh, err := ParseToken(pair.RefreshToken, RefreshTokenType)
+		assert.NoError(t, err)
+		assert.NotEqual(t, access.ID, refresh.ID)
+	})
+
+	t.Run("RejectsWrongTokenType", func(t *testing.T) {
+		pair, err := GenerateTokenPair(1, "bob", "viewer")
+		assert.NoError(t, err)
+
+		_, err = ParseToken(pair.AccessToken, RefreshTokenType)
+		assert.Error(t, err)
+		_, err = ParseToken(pair.RefreshToken, AccessTokenType)
+		assert.Error(t, err)
+	})
+}
+
+func TestParseToken(t *testing.T) {
+	t.Run("RejectsExpiredToken", func(t *testing.T) {
+		token, err := signToken(1, "bob", "viewer", AccessTokenType, -time.Minute)
+		assert.NoError(t, err)
+
+		_, err = ParseToken(token, AccessTokenType)
+		assert.Error(t, err)
+	})
+
+	t.Run("RejectsForeignSignature", func(t *testing.T) {
+		claims := Claims{UserID: 1, TokenType: AccessTokenType, RegisteredClaims: jwt.RegisteredClaims{ID: "x"}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("other-secret"))
+		assert.NoError(t, err)
+
+		_, err = ParseToken(token, AccessTokenType)
+		assert.Error(t, err)
+	})
+
+	t.Run("RejectsGarbage", func(t *testing.T) {
+		_, err := ParseToken("not-a-token", AccessTokenType)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"My First Post":          "my-first-post",
+		"  Hello,   World!  ":    "hello-world",
+		"Café's crème brûlée":    "cafes-creme-brulee",
+		"Go 1.23 -- what's new?": "go-1-23-whats-new",
+		"already-a-slug":         "already-a-slug",
+		"日本語":                    "",
+		"":                       "",
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, Slugify(input), "Slugify(%q)", input)
+	}
+
+	long := Slugify(strings.Repeat("word ", 40))
+	assert.LessOrEqual(t, len(long), MaxSlugLength)
+	assert.False(t, strings.HasSuffix(long, "-"))
+}
@@ -0,0 +1,249 @@
+// Package webhooks queues content lifecycle events for subscribed URLs and
+// delivers them as signed JSON requests
+package webhooks
+
+import (
+	"bytes"
+	"cms-backend/models"
+	"cms-backend/outbox"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Request headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// maxResponseBody is how much of a receiver's response is kept for the log
+const maxResponseBody = 2048
+
+// resourceActions lists the events a webhook can subscribe to
+var resourceActions = map[string][]string{
+	models.RevisionResourcePost: {models.EventCreated, models.EventUpdated, models.EventDeleted, models.EventRestored, models.EventPurged, models.EventPublished, models.EventUnpublished},
+	models.RevisionResourcePage: {models.EventCreated, models.EventUpdated, models.EventDeleted, models.EventRestored, models.EventPurged, models.EventPublished, models.EventUnpublished},
+	models.AuditResourceMedia:   {models.EventCreated, models.EventUpdated, models.EventDeleted, models.EventRestored, models.EventPurged},
+}
+
+// ValidFilter reports whether a subscription filter names a known event,
+// every event of a resource ("post.*"), or every event ("*")
+func ValidFilter(filter string) bool {
+	if filter == "*" {
+		return true
+	}
+	resource, action, ok := strings.Cut(filter, ".")
+	if !ok {
+		return false
+	}
+	actions, ok := resourceActions[resource]
+	if !ok {
+		return false
+	}
+	if action == "*" {
+		return true
+	}
+	for _, known := range actions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler queues deliveries of relayed outbox events for the webhooks
+// subscribed to them. Events webhooks cannot subscribe to are ignored.
+var Handler = outbox.Handler{Name: "webhooks", Handle: enqueue}
+
+// enqueue queues the event for every active webhook subscribed to it. A
+// webhook that already has a delivery for the event is skipped, so relaying
+// the same event twice does not deliver it twice.
+func enqueue(ctx context.Context, tx *gorm.DB, event *models.OutboxEvent) error {
+	resource, _, _ := strings.Cut(event.Type, ".")
+	if !ValidFilter(event.Type) {
+		return nil
+	}
+	filter := func(name string) string {
+		encoded, _ := json.Marshal([]string{name})
+		return string(encoded)
+	}
+	now := time.Now()
+	return tx.WithContext(ctx).Exec(`INSERT INTO webhook_deliveries (webhook_id, event, event_key, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		SELECT id, ?, ?, ?::jsonb, ?, 0, ?, ?, ? FROM webhooks
+		WHERE active AND (events @> ?::jsonb OR events @> ?::jsonb OR events @> ?::jsonb)
+		ON CONFLICT (webhook_id, event_key) WHERE redelivery_of IS NULL DO NOTHING`,
+		event.Type, event.IdempotencyKey, string(event.Payload), models.DeliveryPending, now, now, now,
+		filter(event.Type), filter(resource+".*"), filter("*")).Error
+}
+
+// NewSecret generates a random signing secret for a subscription
+func NewSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Sign computes the X-Webhook-Signature value for a request body. The
+// timestamp is signed along with the body ("<timestamp>.<body>") so receivers
+// can reject replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Body builds the JSON request body for a delivery. It only depends on the
+// stored delivery, so every attempt sends the same body. The idempotency key
+// is shared by a delivery and its manual redeliveries.
+func Body(delivery *models.WebhookDelivery) ([]byte, error) {
+	return json.Marshal(struct {
+		ID             uint            `json:"id"`
+		Event          string          `json:"event"`
+		IdempotencyKey string          `json:"idempotency_key,omitempty"`
+		CreatedAt      time.Time       `json:"created_at"`
+		Data           json.RawMessage `json:"data"`
+	}{delivery.ID, delivery.Event, delivery.EventKey, delivery.CreatedAt.UTC(), delivery.Payload})
+}
+
+// RetryPolicy decides when failed deliveries are retried
+type RetryPolicy struct {
+	// MaxAttempts is how many attempts are made before a delivery fails for good
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure; it doubles with each attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries for roughly a day before giving up
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 10, BaseDelay: 30 * time.Second, MaxDelay: 6 * time.Hour}
+
+// Backoff returns how long to wait after the given number of failed attempts
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Sender makes delivery attempts and records their outcome
+type Sender struct {
+	Client *http.Client
+	Policy RetryPolicy
+	Now    func() time.Time
+}
+
+// NewSender creates a sender whose requests time out after timeout
+func NewSender(timeout time.Duration, policy RetryPolicy) *Sender {
+	return &Sender{Client: &http.Client{Timeout: timeout}, Policy: policy, Now: time.Now}
+}
+
+var (
+	defaultSender   *Sender
+	defaultSenderMu sync.RWMutex
+)
+
+// SetDefault installs the sender used for manual redeliveries
+func SetDefault(sender *Sender) {
+	defaultSenderMu.Lock()
+	defer defaultSenderMu.Unlock()
+	defaultSender = sender
+}
+
+// Default returns the configured sender, or nil when deliveries are only queued
+func Default() *Sender {
+	defaultSenderMu.RLock()
+	defer defaultSenderMu.RUnlock()
+	return defaultSender
+}
+
+// Attempt POSTs the delivery to its webhook and saves the outcome: delivered
+// on a 2xx response, otherwise rescheduled with backoff until the policy's
+// attempts run out. delivery.Webhook must be loaded. The returned error is
+// only about saving the outcome; a failed request is recorded on the delivery.
+func (s *Sender) Attempt(ctx context.Context, db *gorm.DB, delivery *models.WebhookDelivery) error {
+	now := s.Now()
+	status, body, err := s.send(ctx, delivery, now)
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	delivery.ResponseBody = body
+	delivery.Error = ""
+	if err == nil && status >= 200 && status < 300 {
+		delivery.Status = models.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	} else {
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Error = fmt.Sprintf("receiver responded with %d", status)
+		}
+		if delivery.Attempts >= s.Policy.MaxAttempts {
+			delivery.Status = models.DeliveryFailed
+			delivery.NextAttemptAt = nil
+		} else {
+			delivery.Status = models.DeliveryPending
+			next := now.Add(s.Policy.Backoff(delivery.Attempts))
+			delivery.NextAttemptAt = &next
+		}
+	}
+
+	return db.WithContext(ctx).Model(delivery).Select(
+		"status", "attempts", "next_attempt_at", "last_attempt_at",
+		"response_status", "response_body", "error", "delivered_at",
+	).Updates(delivery).Error
+}
+
+func (s *Sender) send(ctx context.Context, delivery *models.WebhookDelivery, now time.Time) (int, string, error) {
+	if delivery.Webhook == nil {
+		return 0, "", fmt.Errorf("webhook %d not found", delivery.WebhookID)
+	}
+	body, err := Body(delivery)
+	if err != nil {
+		return 0, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cms-backend-webhooks")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Webhook.Secret, timestamp, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, strings.ToValidUTF8(string(response), "\uFFFD"), nil
+}
@@ -0,0 +1,144 @@
+package webhooks
+
+import (
+	"cms-backend/models"
+	"cms-backend/utils"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidFilter(t *testing.T) {
+	for filter, valid := range map[string]bool{
+		"*":                true,
+		"post.*":           true,
+		"post.published":   true,
+		"page.unpublished": true,
+		"media.deleted":    true,
+		"media.published":  false,
+		"post.exploded":    false,
+		"comment.created":  false,
+		"post":             false,
+		"":                 false,
+	} {
+		assert.Equal(t, valid, ValidFilter(filter), filter)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// printf '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686", Sign("secret", 1700000000, []byte(`{"a":1}`)))
+	assert.NotEqual(t, Sign("secret", 1700000000, []byte(`{"a":1}`)), Sign("secret", 1700000001, []byte(`{"a":1}`)), "the timestamp is signed")
+	assert.NotEqual(t, Sign("secret", 1700000000, []byte(`{"a":1}`)), Sign("other", 1700000000, []byte(`{"a":1}`)))
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 30 * time.Second, MaxDelay: 10 * time.Minute}
+	assert.Equal(t, 30*time.Second, policy.Backoff(1))
+	assert.Equal(t, time.Minute, policy.Backoff(2))
+	assert.Equal(t, 4*time.Minute, policy.Backoff(4))
+	assert.Equal(t, 8*time.Minute, policy.Backoff(5))
+	assert.Equal(t, 10*time.Minute, policy.Backoff(6), "capped at MaxDelay")
+	assert.Equal(t, 10*time.Minute, policy.Backoff(60))
+}
+
+func TestSenderAttempt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("down for maintenance"))
+	}))
+	defer server.Close()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	newDelivery := func(attempts int) *models.WebhookDelivery {
+		return &models.WebhookDelivery{
+			ID: 3, WebhookID: 1, Event: "post.published", Payload: []byte(`{"id":1}`),
+			Status: models.DeliveryPending, Attempts: attempts,
+			Webhook: &models.Webhook{ID: 1, URL: server.URL, Secret: "secret"},
+		}
+	}
+
+	t.Run("ReschedulesWithBackoff", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		sender := NewSender(time.Second, RetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: time.Hour})
+		sender.Now = func() time.Time { return now }
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "webhook_deliveries" SET "status"=\$1,"attempts"=\$2,"next_attempt_at"=\$3`).
+			WithArgs(models.DeliveryPending, 3, now.Add(4*time.Minute), now, http.StatusServiceUnavailable,
+				"down for maintenance", "receiver responded with 503", nil, sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		delivery := newDelivery(2)
+		require.NoError(t, sender.Attempt(context.Background(), db, delivery))
+		assert.Equal(t, models.DeliveryPending, delivery.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GivesUpAfterMaxAttempts", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		sender := NewSender(time.Second, RetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: time.Hour})
+		sender.Now = func() time.Time { return now }
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "webhook_deliveries"`).
+			WithArgs(models.DeliveryFailed, 5, nil, now, http.StatusServiceUnavailable,
+				sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		delivery := newDelivery(4)
+		require.NoError(t, sender.Attempt(context.Background(), db, delivery))
+		assert.Equal(t, models.DeliveryFailed, delivery.Status)
+		assert.Nil(t, delivery.NextAttemptAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("RecordsUnreachableReceiver", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+		sender := NewSender(time.Second, DefaultRetryPolicy)
+		sender.Now = func() time.Time { return now }
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "webhook_deliveries"`).
+			WithArgs(models.DeliveryPending, 1, now.Add(DefaultRetryPolicy.BaseDelay), now, 0,
+				"", sqlmock.AnyArg(), nil, sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		delivery := newDelivery(0)
+		delivery.Webhook.URL = "http://127.0.0.1:1/unreachable"
+		require.NoError(t, sender.Attempt(context.Background(), db, delivery))
+		assert.Contains(t, delivery.Error, "connection refused")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("QueuesSubscribedWebhooks", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+
+		mock.ExpectExec(`INSERT INTO webhook_deliveries \(webhook_id, event, event_key, payload, status, attempts, next_attempt_at, created_at, updated_at\)\s+SELECT id, .* FROM webhooks\s+WHERE active AND .*\s+ON CONFLICT \(webhook_id, event_key\) WHERE redelivery_of IS NULL DO NOTHING`).
+			WithArgs("post.unpublished", "a1b2c3", `{"id":1}`, models.DeliveryPending, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+				`["post.unpublished"]`, `["post.*"]`, `["*"]`).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		event := &models.OutboxEvent{ID: 4, IdempotencyKey: "a1b2c3", Type: "post.unpublished", ResourceType: "post", ResourceID: 1, Payload: []byte(`{"id":1}`)}
+		require.NoError(t, Handler.Handle(context.Background(), db, event))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("IgnoresUnsubscribableEvents", func(t *testing.T) {
+		_, db, mock := utils.SetupRouterAndMockDB(t)
+
+		event := &models.OutboxEvent{ID: 5, IdempotencyKey: "d4e5f6", Type: "tag.created", ResourceType: "tag", ResourceID: 2, Payload: []byte(`{"id":2}`)}
+		require.NoError(t, Handler.Handle(context.Background(), db, event))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}